@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/auth"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// roleChirpyRed is given to Chirpy Red members' access tokens
+const roleChirpyRed = "chirpy_red"
+
+// Principal is who a request is authenticated as
+type Principal struct {
+	UserID    uuid.UUID
+	TokenType auth.TokenType
+	// Scopes narrow what an access token may be used for. Tokens without
+	// any, which is all of them for now, aren't restricted.
+	Scopes    []string
+	Roles     []string
+	ExpiresAt time.Time
+
+	// The refresh token a refresh principal was authenticated with
+	refreshToken string
+}
+
+type authResult struct {
+	principal *Principal
+	// Why the credentials the request came with were rejected
+	err error
+}
+
+type authKey struct{}
+
+var errNoCredentials = errors.New("no credentials")
+
+// principalFrom returns who the request ctx belongs to authenticated as
+func principalFrom(ctx context.Context) (*Principal, bool) {
+	result, _ := ctx.Value(authKey{}).(authResult)
+	return result.principal, result.principal != nil
+}
+
+// requestPrincipal is the principal of a request a requireUser or
+// requireRefreshToken route let through
+func requestPrincipal(r *http.Request) *Principal {
+	principal, ok := principalFrom(r.Context())
+	if !ok {
+		panic("requestPrincipal called on an unauthenticated route")
+	}
+	return principal
+}
+
+// middlewareAuth authenticates the access token a request carries, once, for
+// the logs, the rate limiter and handlers. It rejects nothing itself; routes
+// say what they need with requireUser, optionalUser and requireRefreshToken.
+func (cfg *apiConfig) middlewareAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var result authResult
+		token, err := accessTokenFrom(r)
+		if err == nil {
+			var access auth.AccessToken
+			access, err = auth.ParseAccessToken(token, cfg.JWTSecret)
+			if err == nil {
+				result.principal = &Principal{
+					UserID:    access.UserID,
+					TokenType: auth.TokenTypeAccess,
+					Scopes:    access.Scopes,
+					Roles:     access.Roles,
+					ExpiresAt: access.ExpiresAt,
+				}
+			}
+		}
+		result.err = err
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authKey{}, result)))
+	})
+}
+
+// accessTokenFrom finds a request's bearer token. Browsers can't set headers
+// on a WebSocket handshake, so those may pass it as ?access_token= instead.
+func accessTokenFrom(r *http.Request) (string, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if errors.Is(err, auth.ErrNoAuthHeaderIncluded) && websocket.IsWebSocketUpgrade(r) {
+		token = r.URL.Query().Get("access_token")
+		if token != "" {
+			return token, nil
+		}
+	}
+	if errors.Is(err, auth.ErrNoAuthHeaderIncluded) {
+		return "", errNoCredentials
+	}
+	return token, err
+}
+
+// requireUser only lets requests with a valid access token through
+func (cfg *apiConfig) requireUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, _ := r.Context().Value(authKey{}).(authResult)
+		if result.principal == nil {
+			respondUnauthorized(w, result.err)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// optionalUser lets anonymous requests through as well, but still rejects
+// bad credentials rather than quietly treating them as anonymous
+func (cfg *apiConfig) optionalUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, _ := r.Context().Value(authKey{}).(authResult)
+		if result.principal == nil && !errors.Is(result.err, errNoCredentials) {
+			respondUnauthorized(w, result.err)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// requireRefreshToken authenticates with a refresh token, which is opaque
+// and has to be looked up, instead of an access token
+func (cfg *apiConfig) requireRefreshToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if errors.Is(err, auth.ErrNoAuthHeaderIncluded) {
+			err = errNoCredentials
+		}
+		if err != nil {
+			respondUnauthorized(w, err)
+			return
+		}
+
+		refreshToken, err := cfg.store.GetRefreshTokenByToken(r.Context(), token)
+		if err != nil {
+			respondUnauthorized(w, err)
+			return
+		}
+		if time.Now().After(refreshToken.ExpiresAt) || refreshToken.RevokedAt.Valid {
+			respondUnauthorized(w, errors.New("refresh token is expired or revoked"))
+			return
+		}
+
+		principal := &Principal{
+			UserID:       refreshToken.UserID,
+			TokenType:    auth.TokenTypeRefresh,
+			ExpiresAt:    refreshToken.ExpiresAt,
+			refreshToken: refreshToken.Token,
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), authKey{}, authResult{principal: principal})))
+	}
+}
+
+// respondUnauthorized sends a 401 with a WWW-Authenticate challenge, as
+// RFC 6750 describes. err is only logged; clients get a generic reason.
+func respondUnauthorized(w http.ResponseWriter, err error) {
+	challenge := `Bearer realm="chirpy"`
+	e := apiError{Status: http.StatusUnauthorized, Code: codeUnauthorized, Detail: "Missing bearer token"}
+	if !errors.Is(err, errNoCredentials) {
+		challenge += `, error="invalid_token"`
+		e.Code = codeInvalidToken
+		e.Detail = "Invalid or expired bearer token"
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	respondWithAPIError(w, e, err)
+}
+
+// accessTokenRoles are the roles a user's access tokens carry
+func accessTokenRoles(isChirpyRed bool) []string {
+	if isChirpyRed {
+		return []string{roleChirpyRed}
+	}
+	return nil
+}
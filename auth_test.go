@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/auth"
+)
+
+func TestAuthChallenges(t *testing.T) {
+	api := newTestAPI(t)
+	login := api.signUp("saul@goodman.com", "better-call")
+	expired, err := auth.MakeJWT(login.ID, api.cfg.JWTSecret, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const missing = `Bearer realm="chirpy"`
+	const invalid = `Bearer realm="chirpy", error="invalid_token"`
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		token         string
+		wantStatus    int
+		wantChallenge string
+	}{
+		{"Required without a token", "POST", "/api/chirps", "", http.StatusUnauthorized, missing},
+		{"Required with a bad token", "POST", "/api/chirps", "nonsense", http.StatusUnauthorized, invalid},
+		{"Required with an expired token", "POST", "/api/chirps", expired, http.StatusUnauthorized, invalid},
+		{"Required with a refresh token", "POST", "/api/chirps", login.RefreshToken, http.StatusUnauthorized, invalid},
+		{"Optional without a token", "GET", "/api/chirps", "", http.StatusOK, ""},
+		{"Optional with a token", "GET", "/api/chirps", login.Token, http.StatusOK, ""},
+		{"Optional with a bad token", "GET", "/api/chirps", "nonsense", http.StatusUnauthorized, invalid},
+		{"Refresh without a token", "POST", "/api/refresh", "", http.StatusUnauthorized, missing},
+		{"Refresh with an access token", "POST", "/api/refresh", login.Token, http.StatusUnauthorized, invalid},
+		{"Public route ignores a bad token", "GET", "/api/healthz", "nonsense", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := api.do(tt.method, tt.path, tt.token, nil)
+			expectStatus(t, rec, tt.wantStatus)
+			if got := rec.Header().Get("WWW-Authenticate"); got != tt.wantChallenge {
+				t.Errorf("WWW-Authenticate = %q, want %q", got, tt.wantChallenge)
+			}
+		})
+	}
+}
+
+func TestAccessTokenRoles(t *testing.T) {
+	api := newTestAPI(t)
+	login := api.signUp("kim@wexlermcgill.com", "sandpiper")
+	if err := api.cfg.promoteUser(t.Context(), login.ID.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token func() string
+	}{
+		{"Login", func() string {
+			rec := api.do("POST", "/api/login", "", map[string]string{"email": "kim@wexlermcgill.com", "password": "sandpiper"})
+			expectStatus(t, rec, http.StatusOK)
+			return decode[loginResponse](t, rec).Token
+		}},
+		{"Refresh", func() string {
+			rec := api.do("POST", "/api/refresh", login.RefreshToken, nil)
+			expectStatus(t, rec, http.StatusOK)
+			return decode[loginResponse](t, rec).Token
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := auth.ParseAccessToken(tt.token(), api.cfg.JWTSecret)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Contains(token.Roles, roleChirpyRed) {
+				t.Errorf("roles = %v, want %s", token.Roles, roleChirpyRed)
+			}
+		})
+	}
+}
+
+func TestRefreshRejectsSuspendedUsers(t *testing.T) {
+	api := newTestAPI(t)
+	login := api.signUp("nacho@varga.com", "upholstery")
+
+	// suspendUser revokes refresh tokens too; refresh mustn't depend on it
+	_, err := api.store.SuspendUser(t.Context(), login.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := api.do("POST", "/api/refresh", login.RefreshToken, nil)
+	expectStatus(t, rec, http.StatusForbidden)
+}
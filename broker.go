@@ -0,0 +1,72 @@
+package main
+
+import "sync"
+
+// broker fans events out to in-process subscribers. A subscriber that
+// falls too far behind is dropped rather than allowed to block publishers.
+type broker[T any] struct {
+	mu          sync.Mutex
+	subscribers map[*subscription[T]]struct{}
+}
+
+type subscription[T any] struct {
+	events chan T
+	// dropped is closed once the subscriber is removed, either by
+	// unsubscribe or because its buffer filled up.
+	dropped chan struct{}
+	match   func(T) bool
+}
+
+func newBroker[T any]() *broker[T] {
+	return &broker[T]{
+		subscribers: map[*subscription[T]]struct{}{},
+	}
+}
+
+// subscribe registers a subscriber for events that match, or all events if
+// match is nil
+func (b *broker[T]) subscribe(match func(T) bool) *subscription[T] {
+	const bufferSize = 64
+	sub := &subscription[T]{
+		events:  make(chan T, bufferSize),
+		dropped: make(chan struct{}),
+		match:   match,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[sub] = struct{}{}
+	return sub
+}
+
+func (b *broker[T]) unsubscribe(sub *subscription[T]) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.dropped)
+	}
+}
+
+// len is the number of subscribers
+func (b *broker[T]) len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+func (b *broker[T]) publish(event T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subscribers {
+		if sub.match != nil && !sub.match(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			delete(b.subscribers, sub)
+			close(sub.dropped)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/cache"
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// chirpCache reads chirps through a cache. Entries are chirps as stored,
+// before a viewer's blocks and mutes are applied, so every viewer shares
+// them. Creating or deleting a chirp invalidates the entries it's in, right
+// away on the instance that made the change and on the others when its
+// chirp event arrives. Both backends write chirp events with a trigger, so
+// changes made outside the API, such as "chirpy chirp delete", are seen
+// too: Postgres NOTIFYs every instance, and SQLite's events are picked up
+// on the next poll. The TTL bounds how stale an entry can get if an event
+// is missed.
+//
+// A nil *chirpCache reads straight from the store.
+type chirpCache struct {
+	cache   cache.Cache
+	ttl     time.Duration
+	metrics *metrics
+	// generation moves on with every invalidation, so a lookup that raced
+	// one doesn't cache what it read before it
+	generation atomic.Uint64
+}
+
+// Queries, as labelled in chirpy_cache_lookups_total
+const (
+	cacheQueryChirp        = "chirp"
+	cacheQueryChirps       = "chirps"
+	cacheQueryChirpsByUser = "chirps_by_user"
+)
+
+func newChirpCache(c cache.Cache, ttl time.Duration, m *metrics) *chirpCache {
+	return &chirpCache{
+		cache:   c,
+		ttl:     ttl,
+		metrics: m,
+	}
+}
+
+func chirpKey(id uuid.UUID) string {
+	return "chirp:" + id.String()
+}
+
+func chirpsKey(desc bool) string {
+	if desc {
+		return "chirps:desc"
+	}
+	return "chirps:asc"
+}
+
+func chirpsByUserKey(userID uuid.UUID) string {
+	return "chirps:user:" + userID.String()
+}
+
+func (c *chirpCache) chirp(ctx context.Context, store database.Store, id uuid.UUID) (database.Chirp, error) {
+	return readThrough(ctx, c, cacheQueryChirp, chirpKey(id), func() (database.Chirp, error) {
+		return store.GetChirp(ctx, id)
+	})
+}
+
+// chirps is every chirp, oldest first unless desc
+func (c *chirpCache) chirps(ctx context.Context, store database.Store, desc bool) ([]database.Chirp, error) {
+	return readThrough(ctx, c, cacheQueryChirps, chirpsKey(desc), func() ([]database.Chirp, error) {
+		if desc {
+			return store.GetChirpsDesc(ctx)
+		}
+		return store.GetChirps(ctx)
+	})
+}
+
+// chirpsByUser is a user's chirps, oldest first
+func (c *chirpCache) chirpsByUser(ctx context.Context, store database.Store, userID uuid.UUID) ([]database.Chirp, error) {
+	return readThrough(ctx, c, cacheQueryChirpsByUser, chirpsByUserKey(userID), func() ([]database.Chirp, error) {
+		return store.GetChirpsByUserID(ctx, userID)
+	})
+}
+
+// readThrough returns key's cached value, or loads and caches it. Cache
+// errors are logged and treated as misses, so the cache being down only
+// costs the database some load.
+func readThrough[T any](ctx context.Context, c *chirpCache, query, key string, load func() (T, error)) (T, error) {
+	if c == nil {
+		return load()
+	}
+
+	dat, ok, err := c.cache.Get(ctx, key)
+	if err != nil {
+		requestLogger(ctx).Warn("Couldn't read chirp cache", "key", key, "error", err)
+	}
+	if ok {
+		var v T
+		if err := json.Unmarshal(dat, &v); err == nil {
+			c.metrics.cacheLookups.WithLabelValues(query, "hit").Inc()
+			return v, nil
+		}
+	}
+	c.metrics.cacheLookups.WithLabelValues(query, "miss").Inc()
+
+	generation := c.generation.Load()
+	v, err := load()
+	if err != nil {
+		return v, err
+	}
+	if c.generation.Load() != generation {
+		return v, nil
+	}
+	dat, err = json.Marshal(v)
+	if err == nil {
+		err = c.cache.Set(ctx, key, dat, c.ttl)
+	}
+	if err != nil {
+		requestLogger(ctx).Warn("Couldn't write chirp cache", "key", key, "error", err)
+	}
+	// An invalidation while setting may have missed the new entry
+	if c.generation.Load() != generation {
+		c.delete(ctx, key)
+	}
+	return v, nil
+}
+
+// invalidate drops the entries a created or deleted chirp is in
+func (c *chirpCache) invalidate(ctx context.Context, chirpID, userID uuid.UUID) {
+	if c == nil {
+		return
+	}
+	c.generation.Add(1)
+	c.delete(ctx, chirpKey(chirpID), chirpsKey(false), chirpsKey(true), chirpsByUserKey(userID))
+}
+
+func (c *chirpCache) invalidateAll(ctx context.Context) {
+	if c == nil {
+		return
+	}
+	c.generation.Add(1)
+	if err := c.cache.Clear(ctx); err != nil {
+		requestLogger(ctx).Error("Couldn't clear chirp cache", "error", err)
+	}
+}
+
+func (c *chirpCache) delete(ctx context.Context, keys ...string) {
+	if err := c.cache.Delete(ctx, keys...); err != nil {
+		requestLogger(ctx).Error("Couldn't invalidate chirp cache", "keys", keys, "error", err)
+	}
+}
+
+// invalidateOn invalidates chirps as their events arrive, from whichever
+// instance made the change, until ctx is cancelled
+func (c *chirpCache) invalidateOn(ctx context.Context, events *chirpEventBroker) {
+	for ctx.Err() == nil {
+		sub := events.subscribe(nil)
+		c.invalidateFrom(ctx, sub)
+		events.unsubscribe(sub)
+	}
+}
+
+func (c *chirpCache) invalidateFrom(ctx context.Context, sub *subscription[database.ChirpEvent]) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sub.dropped:
+			// Events were missed, so any entry could be stale
+			c.invalidateAll(ctx)
+			return
+		case event := <-sub.events:
+			c.invalidate(ctx, event.ChirpID, event.UserID)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+)
+
+func TestChirpCache(t *testing.T) {
+	api := newTestAPI(t)
+	login := api.signUp("tuco@salamanca.com", "tight-tight")
+	chirp := api.postChirp(login.Token, "Tight, tight, tight!")
+
+	listIDs := func() int {
+		rec := api.do("GET", "/api/chirps", "", nil)
+		expectStatus(t, rec, http.StatusOK)
+		return len(decode[[]Chirp](t, rec))
+	}
+
+	t.Run("Hits after the first read", func(t *testing.T) {
+		for range 3 {
+			rec := api.do("GET", "/api/chirps/"+chirp.ID.String(), "", nil)
+			expectStatus(t, rec, http.StatusOK)
+		}
+		metrics := api.do("GET", "/metrics", "", nil).Body.String()
+		for _, line := range []string{
+			`chirpy_cache_lookups_total{query="chirp",result="hit"} 2`,
+			`chirpy_cache_lookups_total{query="chirp",result="miss"} 1`,
+		} {
+			if !strings.Contains(metrics, line+"\n") {
+				t.Errorf("metrics don't contain %q", line)
+			}
+		}
+	})
+
+	t.Run("Creating invalidates lists", func(t *testing.T) {
+		if got := listIDs(); got != 1 {
+			t.Fatalf("listed %d chirps, want 1", got)
+		}
+		api.postChirp(login.Token, "Hector's bell")
+		if got := listIDs(); got != 2 {
+			t.Errorf("listed %d chirps after creating one, want 2", got)
+		}
+	})
+
+	t.Run("Deleting invalidates the chirp", func(t *testing.T) {
+		rec := api.do("DELETE", "/api/chirps/"+chirp.ID.String(), login.Token, nil)
+		expectStatus(t, rec, http.StatusNoContent)
+		rec = api.do("GET", "/api/chirps/"+chirp.ID.String(), "", nil)
+		expectStatus(t, rec, http.StatusNotFound)
+		if got := listIDs(); got != 1 {
+			t.Errorf("listed %d chirps after deleting one, want 1", got)
+		}
+	})
+}
+
+func TestChirpCacheInvalidatesOnEvents(t *testing.T) {
+	api := newTestAPI(t)
+	login := api.signUp("lalo@salamanca.com", "chicken-man")
+	chirp := api.postChirp(login.Token, "Hola, hola")
+	key := chirpKey(chirp.ID)
+
+	events := newChirpEventBroker(nil)
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		api.cfg.chirpCache.invalidateOn(ctx, events)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	cached := func() bool {
+		_, ok, err := api.cfg.chirpCache.cache.Get(t.Context(), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+	waitUntil := func(cond func() bool) bool {
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			if cond() {
+				return true
+			}
+		}
+		return false
+	}
+
+	api.do("GET", "/api/chirps/"+chirp.ID.String(), "", nil)
+	if !cached() {
+		t.Fatal("chirp wasn't cached")
+	}
+	if !waitUntil(func() bool { return events.len() == 1 }) {
+		t.Fatal("cache never subscribed to chirp events")
+	}
+
+	// As if another instance deleted it
+	events.publish(database.ChirpEvent{Type: chirpEventDeleted, ChirpID: chirp.ID, UserID: chirp.UserID})
+	if !waitUntil(func() bool { return !cached() }) {
+		t.Error("chirp is still cached after its deleted event")
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/lib/pq"
+)
+
+const (
+	chirpEventsChannel = "chirp_events"
+	chirpEventCreated  = "chirp.created"
+	chirpEventDeleted  = "chirp.deleted"
+
+	// chirpEventRetention is how far back streams can resume from
+	chirpEventRetention = 24 * time.Hour
+)
+
+// chirpEventCursor is a position in the stream of chirp events. Events are
+// ordered by the transaction that wrote them, then by ID, since Postgres
+// doesn't commit IDs in order.
+type chirpEventCursor struct {
+	txID int64
+	id   int64
+}
+
+func chirpEventCursorAt(event database.ChirpEvent) chirpEventCursor {
+	return chirpEventCursor{txID: event.TxID, id: event.ID}
+}
+
+// before reports whether event comes after the cursor
+func (c chirpEventCursor) before(event database.ChirpEvent) bool {
+	if event.TxID != c.txID {
+		return event.TxID > c.txID
+	}
+	return event.ID > c.id
+}
+
+// chirpEventsAfter returns up to limit events after cursor, in order
+func chirpEventsAfter(ctx context.Context, store database.Store, cursor chirpEventCursor, limit int32) ([]database.ChirpEvent, error) {
+	return store.GetChirpEventsAfter(ctx, database.GetChirpEventsAfterParams{
+		TxID:  cursor.txID,
+		ID:    cursor.id,
+		Limit: limit,
+	})
+}
+
+// chirpEventBroker fans chirp events out to streaming clients. Events are
+// written to chirp_events by a trigger on the chirps table. On Postgres the
+// trigger also NOTIFYs every instance, so subscribers see writes from any
+// server; other backends are polled.
+type chirpEventBroker struct {
+	*broker[database.ChirpEvent]
+	store  database.Store
+	cursor chirpEventCursor
+}
+
+func newChirpEventBroker(store database.Store) *chirpEventBroker {
+	return &chirpEventBroker{
+		broker: newBroker[database.ChirpEvent](),
+		store:  store,
+	}
+}
+
+// start skips events that happened before the broker did
+func (b *chirpEventBroker) start(ctx context.Context) error {
+	latest, err := b.store.GetLatestChirpEvent(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	b.cursor = chirpEventCursorAt(latest)
+	return nil
+}
+
+// listen relays NOTIFYs on chirpEventsChannel until ctx is cancelled. report
+// is told whenever the listener's connection is checked.
+//
+// An event isn't readable until every transaction that started before the
+// one that wrote it has finished, which may be after its NOTIFY arrives, so
+// the table is also checked every catchUpInterval.
+func (b *chirpEventBroker) listen(ctx context.Context, dbURL string, catchUpInterval time.Duration, report func(error)) error {
+	err := b.start(ctx)
+	if err != nil {
+		return err
+	}
+
+	listener := pq.NewListener(dbURL, 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Warn("Chirp event listener", "error", err)
+		}
+		switch ev {
+		case pq.ListenerEventConnected, pq.ListenerEventReconnected:
+			report(nil)
+		case pq.ListenerEventDisconnected, pq.ListenerEventConnectionAttemptFailed:
+			report(err)
+		}
+	})
+	defer listener.Close()
+
+	err = listener.Listen(chirpEventsChannel)
+	if err != nil {
+		return err
+	}
+
+	catchUp := time.NewTicker(catchUpInterval)
+	defer catchUp.Stop()
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-listener.Notify:
+			// A nil notification means the connection was re-established and
+			// NOTIFYs may have been missed; catching up from the table
+			// handles both cases.
+			report(b.catchUp(ctx))
+		case <-catchUp.C:
+			report(b.catchUp(ctx))
+		case <-ping.C:
+			go func() {
+				report(listener.Ping())
+			}()
+		}
+	}
+}
+
+// poll publishes new events every interval until ctx is cancelled, for
+// backends without LISTEN/NOTIFY
+func (b *chirpEventBroker) poll(ctx context.Context, interval time.Duration, report func(error)) error {
+	err := b.start(ctx)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report(b.catchUp(ctx))
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (b *chirpEventBroker) catchUp(ctx context.Context) error {
+	const batchSize = 100
+	for {
+		events, err := chirpEventsAfter(ctx, b.store, b.cursor, batchSize)
+		if err != nil {
+			slog.Error("Couldn't get chirp events", "error", err)
+			return err
+		}
+		for _, event := range events {
+			b.publish(event)
+			b.cursor = chirpEventCursorAt(event)
+		}
+		if len(events) < batchSize {
+			return nil
+		}
+	}
+}
+
+// runChirpEventRetention prunes events older than chirpEventRetention every
+// interval until ctx is cancelled
+func (cfg *apiConfig) runChirpEventRetention(ctx context.Context, interval time.Duration, report func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		pruned, err := cfg.store.DeleteChirpEventsBefore(ctx, time.Now().Add(-chirpEventRetention))
+		if err != nil {
+			slog.Error("Couldn't prune chirp events", "error", err)
+		} else if pruned > 0 {
+			slog.Info("Pruned chirp events", "events", pruned)
+		}
+		report(err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+)
+
+func TestChirpEventCursor(t *testing.T) {
+	cursor := chirpEventCursor{txID: 5, id: 10}
+	tests := []struct {
+		name  string
+		event database.ChirpEvent
+		want  bool
+	}{
+		{"Later ID in the same transaction", database.ChirpEvent{TxID: 5, ID: 11}, true},
+		{"The same event", database.ChirpEvent{TxID: 5, ID: 10}, false},
+		{"Earlier ID in the same transaction", database.ChirpEvent{TxID: 5, ID: 9}, false},
+		// An ID handed out before the cursor's but committed after it
+		{"Earlier ID in a later transaction", database.ChirpEvent{TxID: 6, ID: 3}, true},
+		{"Later ID in an earlier transaction", database.ChirpEvent{TxID: 4, ID: 99}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cursor.before(tt.event); got != tt.want {
+				t.Errorf("before(%+v) = %v, want %v", tt.event, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// chirpFilter hides chirps a viewer shouldn't see in listings: chirps from
+// users on either side of a block, from muted users, or containing one of
+// the viewer's muted keywords. Streams can also narrow it to users the
+// viewer follows.
+type chirpFilter struct {
+	blocked  map[uuid.UUID]struct{}
+	muted    map[uuid.UUID]struct{}
+	keywords []string
+	// following is nil unless the filter is limited to followed users
+	following map[uuid.UUID]struct{}
+}
+
+func (cfg *apiConfig) chirpFilterForViewer(ctx context.Context, viewerID uuid.UUID) (chirpFilter, error) {
+	filter := chirpFilter{
+		blocked: map[uuid.UUID]struct{}{},
+		muted:   map[uuid.UUID]struct{}{},
+	}
+	blocked, err := cfg.store.GetBlockRelatedUserIDs(ctx, viewerID)
+	if err != nil {
+		return chirpFilter{}, err
+	}
+	for _, id := range blocked {
+		filter.blocked[id] = struct{}{}
+	}
+
+	muted, err := cfg.store.GetMutedUserIDs(ctx, viewerID)
+	if err != nil {
+		return chirpFilter{}, err
+	}
+	for _, id := range muted {
+		filter.muted[id] = struct{}{}
+	}
+
+	keywords, err := cfg.store.GetMutedKeywords(ctx, viewerID)
+	if err != nil {
+		return chirpFilter{}, err
+	}
+	for _, keyword := range keywords {
+		filter.keywords = append(filter.keywords, keyword.Keyword)
+	}
+
+	return filter, nil
+}
+
+// followedUserIDs is the set of users viewerID follows
+func (cfg *apiConfig) followedUserIDs(ctx context.Context, viewerID uuid.UUID) (map[uuid.UUID]struct{}, error) {
+	follows, err := cfg.store.GetFollowsByFollower(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	following := make(map[uuid.UUID]struct{}, len(follows))
+	for _, follow := range follows {
+		following[follow.FollowedID] = struct{}{}
+	}
+	return following, nil
+}
+
+func (f chirpFilter) allows(chirp database.Chirp) bool {
+	if f.following != nil {
+		if _, ok := f.following[chirp.UserID]; !ok {
+			return false
+		}
+	}
+	if _, ok := f.blocked[chirp.UserID]; ok {
+		return false
+	}
+	if _, ok := f.muted[chirp.UserID]; ok {
+		return false
+	}
+	if len(f.keywords) == 0 {
+		return true
+	}
+	body := strings.ToLower(chirp.Body)
+	for _, keyword := range f.keywords {
+		if containsWord(body, strings.ToLower(keyword)) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsWord reports whether keyword appears in s as whole words, so
+// "art" matches "art." but not "start" or "party". Both are expected to be
+// lower case already.
+func containsWord(s, keyword string) bool {
+	if keyword == "" {
+		return false
+	}
+	for i := 0; ; {
+		j := strings.Index(s[i:], keyword)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(keyword)
+		if wordBoundary(s[:start], keyword) && wordBoundary(keyword, s[end:]) {
+			return true
+		}
+		_, size := utf8.DecodeRuneInString(s[start:])
+		i = start + size
+	}
+}
+
+// wordBoundary reports whether before and after can be joined without
+// running one word into another
+func wordBoundary(before, after string) bool {
+	last, _ := utf8.DecodeLastRuneInString(before)
+	first, _ := utf8.DecodeRuneInString(after)
+	return !isWordRune(last) || !isWordRune(first)
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// viewerFilterTTL is how long an open stream reuses a viewer's filter.
+// Changes made through this server reload it at once; ones made through
+// another server apply within the TTL.
+const viewerFilterTTL = 10 * time.Second
+
+// filterVersions counts changes to each user's blocks, mutes and follows,
+// so open streams know when their cached filter is stale
+type filterVersions struct {
+	mu       sync.Mutex
+	versions map[uuid.UUID]uint64
+}
+
+func (v *filterVersions) bump(userIDs ...uuid.UUID) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.versions == nil {
+		v.versions = map[uuid.UUID]uint64{}
+	}
+	for _, userID := range userIDs {
+		v.versions[userID]++
+	}
+}
+
+func (v *filterVersions) get(userID uuid.UUID) uint64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.versions[userID]
+}
+
+// cachedChirpFilter is one connection's filter. Filtering every event
+// against the database would cost a round of queries per event per
+// subscriber, so the filter is kept until it's older than viewerFilterTTL
+// or the viewer's filterVersions entry changes. It isn't safe for
+// concurrent use.
+type cachedChirpFilter struct {
+	cfg    *apiConfig
+	userID uuid.UUID
+	// following also limits the filter to users the viewer follows
+	following bool
+
+	filter   chirpFilter
+	version  uint64
+	loadedAt time.Time
+}
+
+func (c *cachedChirpFilter) get(ctx context.Context) (chirpFilter, error) {
+	// Read the version first, so a change made while loading triggers
+	// another load next time
+	version := c.cfg.filterVersions.get(c.userID)
+	if !c.loadedAt.IsZero() && version == c.version && time.Since(c.loadedAt) < viewerFilterTTL {
+		return c.filter, nil
+	}
+
+	filter, err := c.cfg.chirpFilterForViewer(ctx, c.userID)
+	if err != nil {
+		return chirpFilter{}, err
+	}
+	if c.following {
+		filter.following, err = c.cfg.followedUserIDs(ctx, c.userID)
+		if err != nil {
+			return chirpFilter{}, err
+		}
+	}
+	c.filter, c.version, c.loadedAt = filter, version, time.Now()
+	return filter, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestChirpFilterAllows(t *testing.T) {
+	blocked := uuid.New()
+	muted := uuid.New()
+	author := uuid.New()
+	filter := chirpFilter{
+		blocked:  map[uuid.UUID]struct{}{blocked: {}},
+		muted:    map[uuid.UUID]struct{}{muted: {}},
+		keywords: []string{"spoiler", "blue sky", "art"},
+	}
+
+	tests := []struct {
+		name   string
+		filter chirpFilter
+		chirp  database.Chirp
+		want   bool
+	}{
+		{"Empty filter allows everything", chirpFilter{}, database.Chirp{UserID: blocked, Body: "spoiler"}, true},
+		{"Unrelated author", filter, database.Chirp{UserID: author, Body: "Hello"}, true},
+		{"Blocked author", filter, database.Chirp{UserID: blocked, Body: "Hello"}, false},
+		{"Muted author", filter, database.Chirp{UserID: muted, Body: "Hello"}, false},
+		{"Muted keyword", filter, database.Chirp{UserID: author, Body: "No spoiler here"}, false},
+		{"Keywords ignore case", filter, database.Chirp{UserID: author, Body: "SPOILER alert"}, false},
+		{"Keywords only match whole words", filter, database.Chirp{UserID: author, Body: "unspoilered"}, true},
+		{"Keyword at the start of a word", filter, database.Chirp{UserID: author, Body: "Let's party"}, true},
+		{"Keyword at the end of a word", filter, database.Chirp{UserID: author, Body: "A fresh start"}, true},
+		{"Keyword with punctuation", filter, database.Chirp{UserID: author, Body: "Modern ART!"}, false},
+		{"Keyword after a partial match", filter, database.Chirp{UserID: author, Body: "start the art"}, false},
+		{"Keyword in another script", chirpFilter{keywords: []string{"café"}}, database.Chirp{UserID: author, Body: "Le CAFÉ est fermé"}, false},
+		{"Keyword inside a word in another script", chirpFilter{keywords: []string{"caf"}}, database.Chirp{UserID: author, Body: "Le café"}, true},
+		{"Phrases match as a whole", filter, database.Chirp{UserID: author, Body: "The Blue Sky product"}, false},
+		{"Phrase words apart", filter, database.Chirp{UserID: author, Body: "blue and sky"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.allows(tt.chirp); got != tt.want {
+				t.Errorf("allows(%q) = %v, want %v", tt.chirp.Body, got, tt.want)
+			}
+		})
+	}
+}
+
+// countingStore counts filter loads
+type countingStore struct {
+	database.Store
+	loads *int
+}
+
+func (s countingStore) GetMutedKeywords(ctx context.Context, userID uuid.UUID) ([]database.MutedKeyword, error) {
+	*s.loads++
+	return s.Store.GetMutedKeywords(ctx, userID)
+}
+
+func TestCachedChirpFilter(t *testing.T) {
+	api := newTestAPI(t)
+	viewer := api.signUp("walt@grey.com", "heisenberg")
+	other := api.signUp("tuco@salamanca.com", "tight")
+	var loads int
+	api.cfg.store = countingStore{Store: api.store, loads: &loads}
+	cached := &cachedChirpFilter{cfg: api.cfg, userID: viewer.ID}
+
+	get := func(wantLoads int) chirpFilter {
+		t.Helper()
+		filter, err := cached.get(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loads != wantLoads {
+			t.Errorf("loads = %d, want %d", loads, wantLoads)
+		}
+		return filter
+	}
+
+	get(1)
+	get(1)
+
+	api.cfg.filterVersions.bump(other.ID)
+	get(1)
+
+	// Blocking reloads both sides' filters
+	expectStatus(t, api.do("POST", "/api/blocks", other.Token, map[string]uuid.UUID{"user_id": viewer.ID}), http.StatusNoContent)
+	if filter := get(2); filter.allows(database.Chirp{UserID: other.ID}) {
+		t.Error("the filter still allows a user who blocked the viewer")
+	}
+
+	cached.loadedAt = time.Now().Add(-viewerFilterTTL)
+	get(3)
+}
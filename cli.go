@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/exglegaming/Chirpy/internal/database/sqlitedb"
+	"github.com/exglegaming/Chirpy/internal/migrate"
+	"github.com/google/uuid"
+	"github.com/pressly/goose/v3"
+)
+
+const usage = `usage: chirpy <command> [arguments]
+
+Commands:
+  serve [-auto-migrate]              run the API server (the default)
+  migrate up|down|status             manage the database schema
+  user create <email> <password>     create a user
+  user list                          list users
+  user promote <user>                give a user Chirpy Red
+  user suspend <user>                block a user from logging in
+  token revoke <token>|-user <user>  revoke refresh tokens
+  chirp delete <id>                  delete any user's chirp
+  seed [-users n] [-chirps n]        fill a dev database with fake data
+  config print                       show the config with secrets redacted
+
+<user> is an email address or user ID. Flags follow the command, and every
+command accepts -config <file> and the config flags listed by
+"chirpy <command> -h".`
+
+// run dispatches to a subcommand. With no command, or only flags, it serves
+// so "chirpy" and "chirpy -auto-migrate" keep working.
+func run(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return runServe(args)
+	}
+
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "serve":
+		return runServe(args)
+	case "migrate":
+		return runMigrateCommand(args)
+	case "user":
+		return runUser(args)
+	case "token":
+		return runToken(args)
+	case "chirp":
+		return runChirp(args)
+	case "seed":
+		return runSeed(args)
+	case "config":
+		return runConfig(args)
+	case "help":
+		fmt.Println(usage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n\n%s", cmd, usage)
+	}
+}
+
+// openDatabase picks the backend from the DB_URL scheme: sqlite://<path>
+// for SQLite, anything else is handed to the Postgres driver
+func openDatabase(dbURL string) (*sql.DB, migrate.Backend, error) {
+	if path, ok := strings.CutPrefix(dbURL, "sqlite://"); ok {
+		db, err := sqlitedb.Open(path)
+		return db, migrate.SQLite, err
+	}
+	db, err := sql.Open("postgres", dbURL)
+	return db, migrate.Postgres, err
+}
+
+// parseCommand parses a command's flags, plus the shared config flags, and
+// loads the config
+func parseCommand(flags *flag.FlagSet, args []string, serving bool) (Config, error) {
+	addConfigFlags(flags)
+	flags.Parse(args)
+	return loadConfig(flags, serving)
+}
+
+// openMigrations opens the configured database along with its migrations
+func openMigrations(cfg Config) (*sql.DB, migrate.Backend, *goose.Provider, error) {
+	db, backend, err := openDatabase(cfg.DBURL)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("couldn't open database: %w", err)
+	}
+	migrations, err := migrate.New(db, backend)
+	if err != nil {
+		db.Close()
+		return nil, "", nil, fmt.Errorf("couldn't load migrations: %w", err)
+	}
+	return db, backend, migrations, nil
+}
+
+// openAdmin gives admin commands an apiConfig backed by the configured store,
+// so they go through the same code paths, and record the same events, as the
+// handlers. Events are picked up by the server's outbox worker.
+func openAdmin(ctx context.Context, cfg Config) (*apiConfig, func() error, error) {
+	db, backend, migrations, err := openMigrations(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkSchema(ctx, migrations, false); err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	api := &apiConfig{
+		platform:      cfg.Platform,
+		outbox:        newOutbox(nil),
+		notifications: newBroker[database.Notification](),
+		metrics:       newMetrics(),
+	}
+	if backend == migrate.SQLite {
+		api.store = sqlitedb.NewStore(db, nil)
+	} else {
+		api.store = database.NewSQLStore(db, nil)
+	}
+	return api, db.Close, nil
+}
+
+// findUser looks a user up by email or ID
+func findUser(ctx context.Context, store database.Store, ref string) (database.User, error) {
+	var user database.User
+	var err error
+	if id, parseErr := uuid.Parse(ref); parseErr == nil {
+		user, err = store.GetUserByID(ctx, id)
+	} else {
+		user, err = store.GetUserByEmail(ctx, ref)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, fmt.Errorf("no user %q", ref)
+	}
+	return user, err
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// runChirp implements "chirpy chirp delete", for moderation
+func runChirp(args []string) error {
+	if len(args) == 0 || args[0] != "delete" {
+		return errors.New("usage: chirpy chirp delete <id>")
+	}
+	flags := flag.NewFlagSet("chirp delete", flag.ExitOnError)
+	cfg, err := parseCommand(flags, args[1:], false)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: chirpy chirp delete <id>")
+	}
+	chirpID, err := uuid.Parse(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid chirp ID: %w", err)
+	}
+
+	ctx := context.Background()
+	api, closeDB, err := openAdmin(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	chirp, err := api.store.GetChirp(ctx, chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no chirp %s", chirpID)
+	}
+	if err != nil {
+		return err
+	}
+	return api.deleteChirp(ctx, chirp)
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+)
+
+// runConfig implements "chirpy config print"
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return errors.New("usage: chirpy config print")
+	}
+	cfg, err := parseCommand(flag.NewFlagSet("config print", flag.ExitOnError), args[1:], false)
+	if err != nil {
+		return err
+	}
+	return cfg.print(os.Stdout)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/pressly/goose/v3"
+)
+
+// runMigrateCommand implements "chirpy migrate up|down|status"
+func runMigrateCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: chirpy migrate up|down|status")
+	}
+	cfg, err := parseCommand(flag.NewFlagSet("migrate "+args[0], flag.ExitOnError), args[1:], false)
+	if err != nil {
+		return err
+	}
+	db, _, migrations, err := openMigrations(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return runMigrate(context.Background(), migrations, args[:1])
+}
+
+// runMigrate runs a migrate subcommand; checkSchema reuses it for
+// -auto-migrate
+func runMigrate(ctx context.Context, migrations *goose.Provider, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: chirpy migrate up|down|status")
+	}
+
+	switch args[0] {
+	case "up":
+		results, err := migrations.Up(ctx)
+		for _, result := range results {
+			log.Printf("Applied %s in %s", result.Source.Path, result.Duration)
+		}
+		if err != nil {
+			return err
+		}
+		if len(results) == 0 {
+			log.Print("No pending migrations")
+		}
+		return nil
+	case "down":
+		result, err := migrations.Down(ctx)
+		if err != nil {
+			return err
+		}
+		log.Printf("Rolled back %s in %s", result.Source.Path, result.Duration)
+		return nil
+	case "status":
+		statuses, err := migrations.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			applied := "Pending"
+			if status.State == goose.StateApplied {
+				applied = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%-19s  %s\n", applied, status.Source.Path)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
+
+// checkSchema refuses to serve against a database that is missing
+// migrations, applying them first if autoMigrate is set
+func checkSchema(ctx context.Context, migrations *goose.Provider, autoMigrate bool) error {
+	pending, err := migrations.HasPending(ctx)
+	if err != nil {
+		return fmt.Errorf("couldn't check database schema: %w", err)
+	}
+	if !pending {
+		return nil
+	}
+	if !autoMigrate {
+		return errors.New("database schema is behind; run \"chirpy migrate up\" or start with -auto-migrate")
+	}
+	return runMigrate(ctx, migrations, []string{"up"})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// seedPassword is the password of every seeded user, so you can log in as
+// any of them
+const seedPassword = "password"
+
+var seedWords = strings.Fields(`
+	the a my our this every some
+	chirp bird morning coffee code deploy bug test server database weekend
+	cat dog train book walk song idea meeting lunch release
+	is was feels looks seems just finally never always really
+	great broken fast slow quiet loud new old lovely strange fine
+	today tonight again already somehow honestly
+`)
+
+// runSeed implements "chirpy seed". Seeded users are named
+// seed-<n>-<random>@example.com so seeding twice doesn't collide.
+func runSeed(args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ExitOnError)
+	users := flags.Int("users", 10, "number of users to create")
+	chirps := flags.Int("chirps", 5, "number of chirps per user")
+	cfg, err := parseCommand(flags, args, false)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 0 || *users < 0 || *chirps < 0 {
+		return errors.New("usage: chirpy seed [-users n] [-chirps n]")
+	}
+	if cfg.Platform != "dev" {
+		return errors.New("seed only runs with PLATFORM=dev")
+	}
+	ctx := context.Background()
+	api, closeDB, err := openAdmin(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	batch := uuid.NewString()[:8]
+	for i := range *users {
+		email := fmt.Sprintf("seed-%d-%s@example.com", i+1, batch)
+		user, err := api.createUser(ctx, email, seedPassword)
+		if err != nil {
+			return fmt.Errorf("couldn't create %s: %w", email, err)
+		}
+		for range *chirps {
+			_, err := api.createChirp(ctx, user.ID, seedChirp(), uuid.NullUUID{})
+			if err != nil {
+				return fmt.Errorf("couldn't create chirp: %w", err)
+			}
+		}
+	}
+
+	fmt.Printf("Created %d users with %d chirps each; every password is %q\n", *users, *chirps, seedPassword)
+	return nil
+}
+
+func seedChirp() string {
+	words := make([]string, 3+rand.IntN(12))
+	for i := range words {
+		words[i] = seedWords[rand.IntN(len(seedWords))]
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/cache"
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/exglegaming/Chirpy/internal/database/sqlitedb"
+	"github.com/exglegaming/Chirpy/internal/migrate"
+	"github.com/exglegaming/Chirpy/internal/ratelimit"
+)
+
+// runServe implements "chirpy serve"
+func runServe(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	autoMigrate := flags.Bool("auto-migrate", false, "apply pending database migrations before serving")
+	cfg, err := parseCommand(flags, args, true)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(newLogger(cfg.LogLevel))
+
+	shutdownTracing, err := setupTracing(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+
+	db, backend, migrations, err := openMigrations(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	err = checkSchema(context.Background(), migrations, *autoMigrate)
+	if err != nil {
+		return err
+	}
+
+	apiCfg := apiConfig{
+		fileserverHits:      atomic.Int32{},
+		platform:            cfg.Platform,
+		JWTSecret:           cfg.JWTSecret,
+		polkaSecret:         cfg.PolkaKey,
+		polkaWebhookSecrets: cfg.PolkaWebhookSecrets,
+		polkaAllowAPIKey:    cfg.PolkaAllowAPIKey,
+		accessTokenTTL:      cfg.AccessTokenTTL,
+		refreshTokenTTL:     cfg.RefreshTokenTTL,
+		notifications:       newBroker[database.Notification](),
+		webhookClient:       newWebhookClient(cfg.Platform == "dev"),
+		wsAllowedOrigins:    cfg.WSAllowedOrigins,
+		draining:            make(chan struct{}),
+		metrics:             newMetrics(),
+	}
+
+	if backend == migrate.SQLite {
+		apiCfg.store = sqlitedb.NewStore(db, apiCfg.observeQuery)
+	} else {
+		db.SetMaxOpenConns(cfg.DBMaxOpenConns)
+		db.SetMaxIdleConns(cfg.DBMaxIdleConns)
+		db.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
+		db.SetConnMaxIdleTime(cfg.DBConnMaxIdleTime)
+
+		apiCfg.store = database.NewSQLStore(db, apiCfg.observeQuery)
+	}
+	apiCfg.chirpEvents = newChirpEventBroker(apiCfg.store)
+	apiCfg.outbox = newOutbox(apiCfg.store)
+
+	var limits ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.RateLimitStore == rateLimitStorePostgres {
+		if backend != migrate.Postgres {
+			return errors.New("the postgres rate limit store needs a Postgres database")
+		}
+		limits = ratelimit.NewPostgresStore(db, apiCfg.observeQuery)
+	}
+	apiCfg.rateLimiter, err = newRateLimiter(limits, cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.ChirpCacheSize > 0 {
+		apiCfg.chirpCache = newChirpCache(cache.NewLRU(cfg.ChirpCacheSize), cfg.ChirpCacheTTL, apiCfg.metrics)
+	}
+	apiCfg.readyChecks = []readyCheck{databaseCheck(db), migrationsCheck(migrations)}
+	apiCfg.metrics.registerBrokers(&apiCfg)
+
+	// Workers outlive the signal so they can handle what in-flight requests
+	// leave behind; they're stopped once the server has drained.
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var workers sync.WaitGroup
+	health := newWorkerHealth()
+	// startWorker runs work in the background. work must call report after
+	// each pass, at least once per interval, for readiness to pass.
+	startWorker := func(name string, interval time.Duration, work func(ctx context.Context, report func(error)) error) {
+		health.register(name, interval)
+		apiCfg.readyChecks = append(apiCfg.readyChecks, health.check(name))
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			err := work(workerCtx, func(err error) {
+				health.report(name, err)
+			})
+			if err != nil {
+				slog.Error("Background worker stopped", "worker", name, "error", err)
+			}
+			health.stop(name, err)
+		}()
+	}
+
+	startWorker("rate_limit_sweep", time.Minute, func(ctx context.Context, report func(error)) error {
+		apiCfg.rateLimiter.runSweep(ctx, time.Minute, report)
+		return nil
+	})
+	startWorker("subscription_expiry", 10*time.Minute, func(ctx context.Context, report func(error)) error {
+		apiCfg.runSubscriptionExpiry(ctx, 10*time.Minute, report)
+		return nil
+	})
+	// Webhooks are the outbox's only subscriber; see outbox for why live
+	// streams aren't
+	apiCfg.outbox.subscribe("webhooks", apiCfg.enqueueWebhooks)
+	startWorker("webhook_deliveries", 5*time.Second, func(ctx context.Context, report func(error)) error {
+		apiCfg.runWebhookDeliveries(ctx, 5*time.Second, report)
+		return nil
+	})
+	startWorker("outbox", time.Second, func(ctx context.Context, report func(error)) error {
+		apiCfg.outbox.run(ctx, time.Second, report)
+		return nil
+	})
+	startWorker("outbox_retention", time.Hour, func(ctx context.Context, report func(error)) error {
+		apiCfg.outbox.runRetention(ctx, time.Hour, report)
+		return nil
+	})
+	if backend == migrate.Postgres {
+		startWorker("chirp_events", 90*time.Second, func(ctx context.Context, report func(error)) error {
+			return apiCfg.chirpEvents.listen(ctx, cfg.DBURL, time.Second, report)
+		})
+	} else {
+		// Without LISTEN/NOTIFY, poll instead
+		startWorker("chirp_events", time.Second, func(ctx context.Context, report func(error)) error {
+			return apiCfg.chirpEvents.poll(ctx, time.Second, report)
+		})
+	}
+	startWorker("chirp_event_retention", time.Hour, func(ctx context.Context, report func(error)) error {
+		apiCfg.runChirpEventRetention(ctx, time.Hour, report)
+		return nil
+	})
+	if apiCfg.chirpCache != nil {
+		// Only waits on the broker, so it has nothing to report
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			apiCfg.chirpCache.invalidateOn(workerCtx, apiCfg.chirpEvents)
+		}()
+	}
+
+	srv := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           apiCfg.routes(cfg.FilepathRoot),
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+	// Shutdown waits for SSE streams and doesn't track WebSockets at all, so
+	// tell them to finish
+	srv.RegisterOnShutdown(func() {
+		close(apiCfg.draining)
+	})
+
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("Serving", "port", cfg.Port)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-signalCtx.Done():
+	}
+	// A second signal kills the process straight away
+	stopSignals()
+
+	slog.Info("Shutting down", "drain_timeout", cfg.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	err = srv.Shutdown(shutdownCtx)
+	if err != nil {
+		slog.Error("Couldn't drain all requests", "error", err)
+	}
+
+	stopWorkers()
+	stopped := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-shutdownCtx.Done():
+		slog.Error("Background workers didn't stop in time")
+	}
+
+	err = shutdownTracing(shutdownCtx)
+	if err != nil {
+		slog.Error("Couldn't flush traces", "error", err)
+	}
+
+	slog.Info("Shutdown complete")
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+)
+
+// runToken implements "chirpy token revoke"
+func runToken(args []string) error {
+	if len(args) == 0 || args[0] != "revoke" {
+		return errors.New("usage: chirpy token revoke <token>|-user <user>")
+	}
+
+	flags := flag.NewFlagSet("token revoke", flag.ExitOnError)
+	userRef := flags.String("user", "", "revoke every refresh token belonging to this user")
+	cfg, err := parseCommand(flags, args[1:], false)
+	if err != nil {
+		return err
+	}
+	if (*userRef == "") == (flags.NArg() == 0) || flags.NArg() > 1 {
+		return errors.New("usage: chirpy token revoke <token>|-user <user>")
+	}
+
+	ctx := context.Background()
+	api, closeDB, err := openAdmin(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	if *userRef != "" {
+		user, err := findUser(ctx, api.store, *userRef)
+		if err != nil {
+			return err
+		}
+		revoked, err := api.store.RevokeUserRefreshTokens(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Revoked %d refresh tokens\n", revoked)
+		return nil
+	}
+
+	token := flags.Arg(0)
+	_, err = api.store.GetRefreshTokenByToken(ctx, token)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("no active refresh token matches")
+	}
+	if err != nil {
+		return err
+	}
+	return api.store.UpdateRefreshToken(ctx, token)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// runUser implements "chirpy user create|list|promote|suspend"
+func runUser(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: chirpy user create|list|promote|suspend")
+	}
+
+	userCommands := map[string]struct {
+		args  int
+		usage string
+	}{
+		"create":  {2, "usage: chirpy user create <email> <password>"},
+		"list":    {0, "usage: chirpy user list"},
+		"promote": {1, "usage: chirpy user promote <user>"},
+		"suspend": {1, "usage: chirpy user suspend <user>"},
+	}
+	cmd := args[0]
+	command, ok := userCommands[cmd]
+	if !ok {
+		return fmt.Errorf("unknown user command %q", cmd)
+	}
+
+	flags := flag.NewFlagSet("user "+cmd, flag.ExitOnError)
+	cfg, err := parseCommand(flags, args[1:], false)
+	if err != nil {
+		return err
+	}
+	args = flags.Args()
+	if len(args) != command.args {
+		return errors.New(command.usage)
+	}
+
+	ctx := context.Background()
+	api, closeDB, err := openAdmin(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	switch cmd {
+	case "create":
+		user, err := api.createUser(ctx, args[0], args[1])
+		if err != nil {
+			return fmt.Errorf("couldn't create user: %w", err)
+		}
+		fmt.Println(user.ID)
+		return nil
+	case "list":
+		return listUsers(ctx, api.store)
+	case "promote":
+		return api.promoteUser(ctx, args[0])
+	default:
+		return api.suspendUser(ctx, args[0])
+	}
+}
+
+func listUsers(ctx context.Context, store database.Store) error {
+	users, err := store.ListUsers(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tEMAIL\tCREATED\tCHIRPY RED\tSUSPENDED")
+	for _, user := range users {
+		suspended := ""
+		if user.SuspendedAt.Valid {
+			suspended = user.SuspendedAt.Time.Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\n",
+			user.ID, user.Email, user.CreatedAt.Format(time.DateTime), user.IsChirpyRed, suspended)
+	}
+	return w.Flush()
+}
+
+// promoteUser grants Chirpy Red outside of Polka, e.g. for staff accounts
+func (cfg *apiConfig) promoteUser(ctx context.Context, ref string) error {
+	user, err := findUser(ctx, cfg.store, ref)
+	if err != nil {
+		return err
+	}
+	return cfg.withTx(ctx, func(q database.Store) error {
+		err := q.UpdateUserChirpyRed(ctx, database.UpdateUserChirpyRedParams{
+			ID:          user.ID,
+			IsChirpyRed: true,
+		})
+		if err != nil {
+			return err
+		}
+		return recordEvent(ctx, q, eventUserUpgraded, map[string]uuid.UUID{
+			"user_id": user.ID,
+		})
+	})
+}
+
+// suspendUser stops a user logging in and revokes their refresh tokens.
+// Access tokens they already hold stay valid until they expire.
+func (cfg *apiConfig) suspendUser(ctx context.Context, ref string) error {
+	user, err := findUser(ctx, cfg.store, ref)
+	if err != nil {
+		return err
+	}
+	return cfg.withTx(ctx, func(q database.Store) error {
+		_, err := q.SuspendUser(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		revoked, err := q.RevokeUserRefreshTokens(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Suspended %s and revoked %d refresh tokens\n", user.Email, revoked)
+		return nil
+	})
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Cache-Control for chirp reads. Both make clients revalidate every time,
+// which the validators make cheap. Anonymous responses are the same for
+// everyone, so shared caches may keep them too; authenticated ones leave
+// out chirps the viewer has blocked or muted, so only they may.
+const (
+	cacheControlAnonymous     = "public, no-cache"
+	cacheControlAuthenticated = "private, no-cache"
+)
+
+// setCacheControl sets the caching policy for a response that depends on
+// who asked for it
+func setCacheControl(w http.ResponseWriter, authenticated bool) {
+	header := w.Header()
+	header.Set("Vary", "Authorization")
+	if authenticated {
+		header.Set("Cache-Control", cacheControlAuthenticated)
+	} else {
+		header.Set("Cache-Control", cacheControlAnonymous)
+	}
+}
+
+// chirpsETag is a weak validator for a response of chirps. It hashes each
+// chirp's ID, update time and body, so it changes when any is added,
+// removed or edited, including by the viewer's filter changing.
+func chirpsETag(chirps []Chirp) string {
+	h := sha256.New()
+	for _, chirp := range chirps {
+		h.Write(chirp.ID[:])
+		binary.Write(h, binary.BigEndian, chirp.UpdatedAt.UnixNano())
+		binary.Write(h, binary.BigEndian, int64(len(chirp.Body)))
+		h.Write([]byte(chirp.Body))
+	}
+	return fmt.Sprintf(`W/"%d-%x"`, len(chirps), h.Sum(nil)[:12])
+}
+
+// chirpsLastModified is when the newest of chirps was last changed. A
+// delete doesn't move it, so If-None-Match, which takes precedence, is the
+// precondition clients should send for lists.
+func chirpsLastModified(chirps []Chirp) time.Time {
+	var lastModified time.Time
+	for _, chirp := range chirps {
+		if chirp.UpdatedAt.After(lastModified) {
+			lastModified = chirp.UpdatedAt
+		}
+	}
+	return lastModified
+}
+
+// checkNotModified sets a response's validators and evaluates the
+// request's If-None-Match, or failing that If-Modified-Since, against them
+// as RFC 9110 describes. It reports whether the client's copy is current,
+// in which case it has sent a 304 and the handler is done.
+func checkNotModified(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool {
+	header := w.Header()
+	header.Set("ETag", etag)
+	if !lastModified.IsZero() {
+		header.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if !etagMatches(ifNoneMatch, etag) {
+			return false
+		}
+	} else {
+		ifModifiedSince, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		// Last-Modified only has whole seconds
+		if err != nil || lastModified.IsZero() || lastModified.Truncate(time.Second).After(ifModifiedSince) {
+			return false
+		}
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches compares etag with each entry of an If-None-Match list,
+// weakly as GET requests should be
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
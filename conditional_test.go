@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConditionalChirpReads(t *testing.T) {
+	api := newTestAPI(t)
+	login := api.signUp("mike@ehrmantraut.com", "half-measures")
+	chirp := api.postChirp(login.Token, "No more half measures")
+
+	get := func(path, token string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		api.handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	paths := map[string]string{
+		"List":  "/api/chirps",
+		"Chirp": "/api/chirps/" + chirp.ID.String(),
+	}
+	for name, path := range paths {
+		first := get(path, "", nil)
+		expectStatus(t, first, http.StatusOK)
+		etag := first.Header().Get("ETag")
+		lastModified := first.Header().Get("Last-Modified")
+		if len(etag) < 2 || etag[:2] != "W/" || lastModified == "" {
+			t.Fatalf("%s: ETag = %q, Last-Modified = %q, want a weak ETag and a date", name, etag, lastModified)
+		}
+
+		tests := []struct {
+			name       string
+			header     http.Header
+			wantStatus int
+		}{
+			{"Unconditional", nil, http.StatusOK},
+			{"Matching ETag", http.Header{"If-None-Match": {etag}}, http.StatusNotModified},
+			{"Matching strong ETag", http.Header{"If-None-Match": {etag[2:]}}, http.StatusNotModified},
+			{"ETag in a list", http.Header{"If-None-Match": {`"stale", ` + etag}}, http.StatusNotModified},
+			{"Any ETag", http.Header{"If-None-Match": {"*"}}, http.StatusNotModified},
+			{"Stale ETag", http.Header{"If-None-Match": {`W/"stale"`}}, http.StatusOK},
+			{"Not modified since", http.Header{"If-Modified-Since": {lastModified}}, http.StatusNotModified},
+			{"Modified since", http.Header{"If-Modified-Since": {time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)}}, http.StatusOK},
+			{"ETag takes precedence", http.Header{"If-None-Match": {`W/"stale"`}, "If-Modified-Since": {lastModified}}, http.StatusOK},
+		}
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				rec := get(path, "", tt.header)
+				expectStatus(t, rec, tt.wantStatus)
+				if got := rec.Header().Get("ETag"); got != etag {
+					t.Errorf("ETag = %q, want %q", got, etag)
+				}
+				if tt.wantStatus == http.StatusNotModified && rec.Body.Len() != 0 {
+					t.Errorf("304 has a body: %q", rec.Body.String())
+				}
+			})
+		}
+	}
+
+	t.Run("Cache-Control", func(t *testing.T) {
+		if got := get("/api/chirps", "", nil).Header().Get("Cache-Control"); got != cacheControlAnonymous {
+			t.Errorf("anonymous Cache-Control = %q, want %q", got, cacheControlAnonymous)
+		}
+		if got := get("/api/chirps", login.Token, nil).Header().Get("Cache-Control"); got != cacheControlAuthenticated {
+			t.Errorf("authenticated Cache-Control = %q, want %q", got, cacheControlAuthenticated)
+		}
+	})
+
+	t.Run("New chirp changes the list ETag", func(t *testing.T) {
+		etag := get("/api/chirps", "", nil).Header().Get("ETag")
+		api.postChirp(login.Token, "Just because you shot Jesse James, don't make you Jesse James")
+		rec := get("/api/chirps", "", http.Header{"If-None-Match": {etag}})
+		expectStatus(t, rec, http.StatusOK)
+		if got := rec.Header().Get("ETag"); got == etag {
+			t.Errorf("ETag is still %q", got)
+		}
+	})
+}
@@ -0,0 +1,464 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/ratelimit"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the configuration shared by every chirpy command. It's loaded
+// from, lowest precedence first: defaults, a YAML file, environment
+// variables and command line flags.
+type Config struct {
+	Port                string        `yaml:"port"`
+	FilepathRoot        string        `yaml:"filepath_root"`
+	Platform            string        `yaml:"platform"`
+	DBURL               string        `yaml:"db_url"`
+	JWTSecret           string        `yaml:"jwt_secret"`
+	PolkaKey            string        `yaml:"polka_key"`
+	PolkaWebhookSecrets []string      `yaml:"polka_webhook_secrets"`
+	PolkaAllowAPIKey    bool          `yaml:"polka_allow_api_key"`
+	AccessTokenTTL      time.Duration `yaml:"access_token_ttl"`
+	RefreshTokenTTL     time.Duration `yaml:"refresh_token_ttl"`
+	LogLevel            slog.Level    `yaml:"log_level"`
+
+	// HTTP server timeouts; zero disables one
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"`
+
+	// Tracing. TraceExporter is "none", "stdout" or "otlp".
+	TraceExporter    string  `yaml:"trace_exporter"`
+	OTLPEndpoint     string  `yaml:"otlp_endpoint"`
+	TraceSampleRatio float64 `yaml:"trace_sample_ratio"`
+
+	// Postgres connection pool. SQLite always uses a single connection.
+	DBMaxOpenConns    int           `yaml:"db_max_open_conns"`
+	DBMaxIdleConns    int           `yaml:"db_max_idle_conns"`
+	DBConnMaxLifetime time.Duration `yaml:"db_conn_max_lifetime"`
+	DBConnMaxIdleTime time.Duration `yaml:"db_conn_max_idle_time"`
+
+	// Rate limiting. RateLimitStore is "memory" or "postgres", which shares
+	// limits between instances. RateLimits are by route group.
+	RateLimitStore string                     `yaml:"rate_limit_store"`
+	RateLimits     map[string]ratelimit.Limit `yaml:"rate_limits"`
+	// Proxies whose X-Forwarded-For header is believed, as CIDRs or
+	// addresses
+	TrustedProxies []string `yaml:"trusted_proxies"`
+
+	// Chirp cache. A size of 0 turns it off.
+	ChirpCacheSize int           `yaml:"chirp_cache_size"`
+	ChirpCacheTTL  time.Duration `yaml:"chirp_cache_ttl"`
+
+	// Origins, besides the server's own, whose pages may open WebSockets,
+	// e.g. https://chirpy.example
+	WSAllowedOrigins []string `yaml:"ws_allowed_origins"`
+}
+
+func defaultConfig() Config {
+	return Config{
+		Port:            "8080",
+		FilepathRoot:    ".",
+		AccessTokenTTL:  time.Hour,
+		RefreshTokenTTL: 60 * 24 * time.Hour,
+
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       15 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		ShutdownTimeout:   30 * time.Second,
+
+		TraceExporter:    traceExporterNone,
+		TraceSampleRatio: 1,
+
+		DBMaxOpenConns:    25,
+		DBMaxIdleConns:    10,
+		DBConnMaxLifetime: 30 * time.Minute,
+		DBConnMaxIdleTime: 5 * time.Minute,
+
+		RateLimitStore: rateLimitStoreMemory,
+		RateLimits: map[string]ratelimit.Limit{
+			rateLimitAuth:   {Requests: 10, Per: time.Minute},
+			rateLimitChirps: {Requests: 30, Per: time.Minute},
+			rateLimitWrite:  {Requests: 120, Per: time.Minute},
+			rateLimitRead:   {Requests: 600, Per: time.Minute},
+		},
+
+		ChirpCacheSize: 1000,
+		ChirpCacheTTL:  30 * time.Second,
+	}
+}
+
+// setting maps one Config field to its environment variable and flag.
+// Secrets have no flag so they stay out of shell history and ps.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	set   func(c *Config, v string) error
+}
+
+func stringValue(field func(c *Config) *string) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		*field(c) = v
+		return nil
+	}
+}
+
+func intValue(field func(c *Config) *int) func(*Config, string) error {
+	return func(c *Config, v string) (err error) {
+		*field(c), err = strconv.Atoi(v)
+		return err
+	}
+}
+
+func boolValue(field func(c *Config) *bool) func(*Config, string) error {
+	return func(c *Config, v string) (err error) {
+		*field(c), err = strconv.ParseBool(v)
+		return err
+	}
+}
+
+func floatValue(field func(c *Config) *float64) func(*Config, string) error {
+	return func(c *Config, v string) (err error) {
+		*field(c), err = strconv.ParseFloat(v, 64)
+		return err
+	}
+}
+
+func levelValue(field func(c *Config) *slog.Level) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		return field(c).UnmarshalText([]byte(v))
+	}
+}
+
+// listValue splits a comma separated list
+func listValue(field func(c *Config) *[]string) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		*field(c) = nil
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*field(c) = append(*field(c), item)
+			}
+		}
+		return nil
+	}
+}
+
+// rateLimitsValue parses a comma separated list of group=limit pairs, e.g.
+// "auth=5/1m,read=off". Groups it doesn't mention keep their limits.
+func rateLimitsValue(c *Config, v string) error {
+	limits := maps.Clone(c.RateLimits)
+	if limits == nil {
+		limits = make(map[string]ratelimit.Limit)
+	}
+	for _, pair := range strings.Split(v, ",") {
+		group, limit, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return fmt.Errorf("%q isn't <group>=<limit>", pair)
+		}
+		parsed, err := ratelimit.ParseLimit(limit)
+		if err != nil {
+			return err
+		}
+		limits[group] = parsed
+	}
+	c.RateLimits = limits
+	return nil
+}
+
+func durationValue(field func(c *Config) *time.Duration) func(*Config, string) error {
+	return func(c *Config, v string) (err error) {
+		*field(c), err = time.ParseDuration(v)
+		return err
+	}
+}
+
+var settings = []setting{
+	{"PORT", "port", "port to serve on",
+		stringValue(func(c *Config) *string { return &c.Port })},
+	{"FILEPATH_ROOT", "filepath-root", "directory served under /app/",
+		stringValue(func(c *Config) *string { return &c.FilepathRoot })},
+	{"PLATFORM", "platform", `"dev" enables /admin/reset and seeding`,
+		stringValue(func(c *Config) *string { return &c.Platform })},
+	{"DB_URL", "db-url", "database URL; sqlite://<path> selects SQLite",
+		stringValue(func(c *Config) *string { return &c.DBURL })},
+	{"JWT_SECRET", "", "",
+		stringValue(func(c *Config) *string { return &c.JWTSecret })},
+	{"POLKA_KEY", "", "",
+		stringValue(func(c *Config) *string { return &c.PolkaKey })},
+	// A comma separated list so secrets can be rotated without downtime
+	{"POLKA_WEBHOOK_SECRETS", "", "",
+		listValue(func(c *Config) *[]string { return &c.PolkaWebhookSecrets })},
+	// Keeps POLKA_KEY working alongside the secrets while Polka moves over
+	// to signing
+	{"POLKA_ALLOW_API_KEY", "", "",
+		boolValue(func(c *Config) *bool { return &c.PolkaAllowAPIKey })},
+	{"ACCESS_TOKEN_TTL", "access-token-ttl", "lifetime of access JWTs, e.g. 1h",
+		durationValue(func(c *Config) *time.Duration { return &c.AccessTokenTTL })},
+	{"REFRESH_TOKEN_TTL", "refresh-token-ttl", "lifetime of refresh tokens, e.g. 1440h",
+		durationValue(func(c *Config) *time.Duration { return &c.RefreshTokenTTL })},
+	{"LOG_LEVEL", "log-level", "debug, info, warn or error",
+		levelValue(func(c *Config) *slog.Level { return &c.LogLevel })},
+
+	{"READ_HEADER_TIMEOUT", "read-header-timeout", "time allowed to read request headers",
+		durationValue(func(c *Config) *time.Duration { return &c.ReadHeaderTimeout })},
+	{"READ_TIMEOUT", "read-timeout", "time allowed to read a whole request",
+		durationValue(func(c *Config) *time.Duration { return &c.ReadTimeout })},
+	{"WRITE_TIMEOUT", "write-timeout", "time allowed to write a response; streams set their own",
+		durationValue(func(c *Config) *time.Duration { return &c.WriteTimeout })},
+	{"IDLE_TIMEOUT", "idle-timeout", "how long idle keep-alive connections stay open",
+		durationValue(func(c *Config) *time.Duration { return &c.IdleTimeout })},
+	{"SHUTDOWN_TIMEOUT", "shutdown-timeout", "how long to drain requests and workers on SIGINT/SIGTERM",
+		durationValue(func(c *Config) *time.Duration { return &c.ShutdownTimeout })},
+
+	{"TRACE_EXPORTER", "trace-exporter", "none, stdout or otlp",
+		stringValue(func(c *Config) *string { return &c.TraceExporter })},
+	{"OTLP_ENDPOINT", "otlp-endpoint", "OTLP/HTTP collector URL (default $OTEL_EXPORTER_OTLP_ENDPOINT)",
+		stringValue(func(c *Config) *string { return &c.OTLPEndpoint })},
+	{"TRACE_SAMPLE_RATIO", "trace-sample-ratio", "fraction of new traces to sample, 0 to 1",
+		floatValue(func(c *Config) *float64 { return &c.TraceSampleRatio })},
+
+	{"DB_MAX_OPEN_CONNS", "db-max-open-conns", "maximum open Postgres connections, 0 for no limit",
+		intValue(func(c *Config) *int { return &c.DBMaxOpenConns })},
+	{"DB_MAX_IDLE_CONNS", "db-max-idle-conns", "maximum idle Postgres connections",
+		intValue(func(c *Config) *int { return &c.DBMaxIdleConns })},
+	{"DB_CONN_MAX_LIFETIME", "db-conn-max-lifetime", "how long a Postgres connection is reused, 0 for ever",
+		durationValue(func(c *Config) *time.Duration { return &c.DBConnMaxLifetime })},
+	{"DB_CONN_MAX_IDLE_TIME", "db-conn-max-idle-time", "how long a Postgres connection may sit idle, 0 for ever",
+		durationValue(func(c *Config) *time.Duration { return &c.DBConnMaxIdleTime })},
+
+	{"RATE_LIMIT_STORE", "rate-limit-store", "where rate limits are kept: memory or postgres",
+		stringValue(func(c *Config) *string { return &c.RateLimitStore })},
+	{"RATE_LIMITS", "rate-limits", "per group limits, e.g. auth=10/1m,chirps=30/1m,read=off",
+		rateLimitsValue},
+	{"TRUSTED_PROXIES", "trusted-proxies", "comma separated proxy CIDRs whose X-Forwarded-For is believed",
+		listValue(func(c *Config) *[]string { return &c.TrustedProxies })},
+
+	{"CHIRP_CACHE_SIZE", "chirp-cache-size", "chirp query results kept in memory, 0 to turn the cache off",
+		intValue(func(c *Config) *int { return &c.ChirpCacheSize })},
+	{"CHIRP_CACHE_TTL", "chirp-cache-ttl", "longest a cached chirp query result is used for",
+		durationValue(func(c *Config) *time.Duration { return &c.ChirpCacheTTL })},
+
+	{"WS_ALLOWED_ORIGINS", "ws-allowed-origins", "comma separated origins besides the server's own that may open WebSockets",
+		listValue(func(c *Config) *[]string { return &c.WSAllowedOrigins })},
+}
+
+// addConfigFlags registers the config flags every command accepts
+func addConfigFlags(flags *flag.FlagSet) {
+	flags.String("config", "", "YAML config file (default $CHIRPY_CONFIG)")
+	for _, s := range settings {
+		if s.flag != "" {
+			flags.String(s.flag, "", s.usage+" (env "+s.env+")")
+		}
+	}
+}
+
+// loadConfig loads the config for a command whose flags, including those
+// from addConfigFlags, have been parsed. The server needs secrets that
+// admin commands don't, so serving adds those checks. Every problem is
+// reported at once.
+func loadConfig(flags *flag.FlagSet, serving bool) (Config, error) {
+	godotenv.Load()
+
+	cfg := defaultConfig()
+	var problems []error
+
+	path := os.Getenv("CHIRPY_CONFIG")
+	if f := flags.Lookup("config"); f != nil && f.Value.String() != "" {
+		path = f.Value.String()
+	}
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	for _, s := range settings {
+		if v := os.Getenv(s.env); v != "" {
+			if err := s.set(&cfg, v); err != nil {
+				problems = append(problems, fmt.Errorf("%s: %w", s.env, err))
+			}
+		}
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag != f.Name {
+				continue
+			}
+			if err := s.set(&cfg, f.Value.String()); err != nil {
+				problems = append(problems, fmt.Errorf("-%s: %w", s.flag, err))
+			}
+		}
+	})
+
+	problems = append(problems, cfg.validate(serving)...)
+	if len(problems) > 0 {
+		return Config{}, fmt.Errorf("invalid config:\n%w", errors.Join(problems...))
+	}
+	return cfg, nil
+}
+
+func (c *Config) readFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	err = decoder.Decode(c)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+func (c Config) validate(serving bool) []error {
+	var problems []error
+
+	if c.DBURL == "" {
+		problems = append(problems, errors.New("DB_URL must be set"))
+	}
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		problems = append(problems, fmt.Errorf("port %q is not a valid port number", c.Port))
+	}
+	if c.AccessTokenTTL <= 0 {
+		problems = append(problems, errors.New("access token TTL must be positive"))
+	}
+	if c.RefreshTokenTTL <= 0 {
+		problems = append(problems, errors.New("refresh token TTL must be positive"))
+	}
+	if c.ReadHeaderTimeout < 0 || c.ReadTimeout < 0 || c.WriteTimeout < 0 || c.IdleTimeout < 0 {
+		problems = append(problems, errors.New("server timeouts can't be negative"))
+	}
+	if c.ShutdownTimeout <= 0 {
+		problems = append(problems, errors.New("shutdown timeout must be positive"))
+	}
+	switch c.TraceExporter {
+	case traceExporterNone, traceExporterStdout, traceExporterOTLP:
+	default:
+		problems = append(problems, fmt.Errorf("trace exporter %q isn't one of none, stdout or otlp", c.TraceExporter))
+	}
+	if c.TraceSampleRatio < 0 || c.TraceSampleRatio > 1 {
+		problems = append(problems, errors.New("trace sample ratio must be between 0 and 1"))
+	}
+	if c.DBMaxOpenConns < 0 || c.DBMaxIdleConns < 0 || c.DBConnMaxLifetime < 0 || c.DBConnMaxIdleTime < 0 {
+		problems = append(problems, errors.New("database pool settings can't be negative"))
+	}
+	switch c.RateLimitStore {
+	case rateLimitStoreMemory, rateLimitStorePostgres:
+	default:
+		problems = append(problems, fmt.Errorf("rate limit store %q isn't one of memory or postgres", c.RateLimitStore))
+	}
+	for group := range c.RateLimits {
+		if !slices.Contains(rateLimitGroups, group) {
+			problems = append(problems, fmt.Errorf("unknown rate limit group %q; groups are %s", group, strings.Join(rateLimitGroups, ", ")))
+		}
+	}
+	if _, err := parseTrustedProxies(c.TrustedProxies); err != nil {
+		problems = append(problems, err)
+	}
+	if c.ChirpCacheSize < 0 {
+		problems = append(problems, errors.New("chirp cache size can't be negative"))
+	}
+	if c.ChirpCacheSize > 0 && c.ChirpCacheTTL <= 0 {
+		problems = append(problems, errors.New("chirp cache TTL must be positive"))
+	}
+	for _, origin := range c.WSAllowedOrigins {
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" {
+			problems = append(problems, fmt.Errorf("WebSocket origin %q isn't <scheme>://<host>[:port]", origin))
+		}
+	}
+
+	if !serving {
+		return problems
+	}
+	if c.Platform == "" {
+		problems = append(problems, errors.New("PLATFORM must be set"))
+	}
+	if c.JWTSecret == "" {
+		problems = append(problems, errors.New("JWT_SECRET must be set"))
+	}
+	if c.PolkaKey == "" && len(c.PolkaWebhookSecrets) == 0 {
+		problems = append(problems, errors.New("POLKA_KEY or POLKA_WEBHOOK_SECRETS must be set"))
+	}
+	// Once webhooks are signed, unsigned ones would let anyone holding the
+	// old key bypass signing, so keeping it needs opting in
+	if c.PolkaKey != "" && len(c.PolkaWebhookSecrets) > 0 && !c.PolkaAllowAPIKey {
+		problems = append(problems, errors.New("POLKA_KEY is ignored once POLKA_WEBHOOK_SECRETS is set; unset it or set POLKA_ALLOW_API_KEY"))
+	}
+	if c.PolkaAllowAPIKey && c.PolkaKey == "" {
+		problems = append(problems, errors.New("POLKA_ALLOW_API_KEY needs POLKA_KEY"))
+	}
+	return problems
+}
+
+// print writes the config as YAML with secrets redacted, so the output can
+// be used as a starting config file
+func (c Config) print(w io.Writer) error {
+	c.DBURL = redactDBURL(c.DBURL)
+	c.JWTSecret = redact(c.JWTSecret)
+	c.PolkaKey = redact(c.PolkaKey)
+	secrets := make([]string, len(c.PolkaWebhookSecrets))
+	for i, secret := range c.PolkaWebhookSecrets {
+		secrets[i] = redact(secret)
+	}
+	c.PolkaWebhookSecrets = secrets
+
+	encoder := yaml.NewEncoder(w)
+	defer encoder.Close()
+	return encoder.Encode(c)
+}
+
+// dsnPasswordPattern matches the password fields of a key/value DSN such as
+// "host=db user=chirpy password='s3 cret'"
+var dsnPasswordPattern = regexp.MustCompile(`(?i)\b(password|sslpassword)\s*=\s*('(?:[^'\\]|\\.)*'|\S+)`)
+
+// redactDBURL hides the passwords in a database URL or key/value DSN, which
+// can be in the userinfo or in password and sslpassword parameters
+func redactDBURL(dbURL string) string {
+	if !strings.Contains(dbURL, "://") {
+		return dsnPasswordPattern.ReplaceAllString(dbURL, "${1}=xxxxx")
+	}
+	u, err := url.Parse(dbURL)
+	if err != nil {
+		// Can't tell where a password might be
+		return redact(dbURL)
+	}
+	query := u.Query()
+	for _, key := range []string{"password", "sslpassword"} {
+		if query.Has(key) {
+			query.Set(key, "xxxxx")
+		}
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = query.Encode()
+	}
+	return u.Redacted()
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "xxxxx"
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// maxRequestBodyBytes caps every request body. Routes that decode JSON
+// set their own, lower, limit with decodeOptions.
+const maxRequestBodyBytes = 1 << 20
+
+// Body limits for JSON routes. They're generous for what each route takes,
+// allowing for escaped characters.
+const (
+	// Credentials, IDs, chirps and keywords
+	smallBodyBytes = 4 << 10
+	// Direct messages
+	messageBodyBytes = 16 << 10
+	// Routes that don't set a limit
+	defaultBodyBytes = 64 << 10
+)
+
+type decodeOptions struct {
+	// MaxBytes defaults to defaultBodyBytes
+	MaxBytes int64
+	// DisallowUnknownFields rejects fields the target doesn't have, for
+	// routes where a misspelt field would otherwise be quietly ignored
+	DisallowUnknownFields bool
+}
+
+var errTrailingData = errors.New("request body has data after the JSON value")
+
+// contentTypeError is a request body that isn't declared as JSON
+type contentTypeError struct {
+	contentType string
+}
+
+func (e *contentTypeError) Error() string {
+	return fmt.Sprintf("content type %q isn't JSON", e.contentType)
+}
+
+type unknownFieldError struct {
+	field string
+}
+
+func (e *unknownFieldError) Error() string {
+	return fmt.Sprintf("unknown field %q", e.field)
+}
+
+// jsonSyntaxError is a json.SyntaxError positioned by line and column,
+// which people can find in their request more easily than an offset
+type jsonSyntaxError struct {
+	line, column int
+	err          *json.SyntaxError
+}
+
+func (e *jsonSyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %v", e.line, e.column, e.err)
+}
+
+func (e *jsonSyntaxError) Unwrap() error {
+	return e.err
+}
+
+// decodeJSON decodes r's body, which must be declared as JSON and hold a
+// single JSON value, into v. Its errors are for respondWithDecodeError.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any, opts decodeOptions) error {
+	contentType := r.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+		return &contentTypeError{contentType: contentType}
+	}
+
+	maxBytes := opts.MaxBytes
+	if maxBytes == 0 {
+		maxBytes = defaultBodyBytes
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	if opts.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	err = decoder.Decode(v)
+	if err != nil {
+		return locateDecodeError(body, err)
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return errTrailingData
+	}
+	return nil
+}
+
+// locateDecodeError adds what a person needs to find the problem in body
+// to err
+func locateDecodeError(body []byte, err error) error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		// Offset is just past the byte that was wrong
+		before := body[:min(max(int(syntaxErr.Offset)-1, 0), len(body))]
+		return &jsonSyntaxError{
+			line:   bytes.Count(before, []byte("\n")) + 1,
+			column: len(before) - bytes.LastIndexByte(before, '\n'),
+			err:    syntaxErr,
+		}
+	}
+	// encoding/json has no type for these
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		if field, err := strconv.Unquote(field); err == nil {
+			return &unknownFieldError{field: field}
+		}
+	}
+	return err
+}
+
+// respondWithDecodeError explains why decodeJSON couldn't decode a request
+func respondWithDecodeError(w http.ResponseWriter, err error) {
+	e := decodeError(err)
+	if e.Status == http.StatusUnsupportedMediaType {
+		w.Header().Set("Accept", "application/json")
+	}
+	respondWithAPIError(w, e, err)
+}
+
+func decodeError(err error) apiError {
+	var contentTypeErr *contentTypeError
+	var maxBytesErr *http.MaxBytesError
+	var syntaxErr *jsonSyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var unknownErr *unknownFieldError
+	switch {
+	case errors.As(err, &contentTypeErr):
+		return apiError{
+			Status: http.StatusUnsupportedMediaType,
+			Code:   codeUnsupportedMedia,
+			Detail: "Request body must be application/json",
+		}
+	case errors.As(err, &maxBytesErr):
+		return apiError{
+			Status: http.StatusRequestEntityTooLarge,
+			Code:   codeBodyTooLarge,
+			Detail: fmt.Sprintf("Request body must be at most %d bytes", maxBytesErr.Limit),
+		}
+	case errors.Is(err, io.EOF):
+		return apiError{
+			Status: http.StatusBadRequest,
+			Code:   codeInvalidJSON,
+			Detail: "Request body is empty",
+		}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return apiError{
+			Status: http.StatusBadRequest,
+			Code:   codeInvalidJSON,
+			Detail: "Request body ends in the middle of its JSON value",
+		}
+	case errors.As(err, &syntaxErr):
+		return apiError{
+			Status: http.StatusBadRequest,
+			Code:   codeInvalidJSON,
+			Detail: fmt.Sprintf("Request body isn't valid JSON at line %d, column %d", syntaxErr.line, syntaxErr.column),
+		}
+	case errors.Is(err, errTrailingData):
+		return apiError{
+			Status: http.StatusBadRequest,
+			Code:   codeInvalidJSON,
+			Detail: "Request body must hold a single JSON value",
+		}
+	case errors.As(err, &unknownErr):
+		return apiError{
+			Status: http.StatusBadRequest,
+			Code:   codeValidationFailed,
+			Detail: fmt.Sprintf("%s isn't a known field", unknownErr.field),
+			Fields: []fieldError{{Field: unknownErr.field, Code: fieldUnknown, Message: "isn't a known field"}},
+		}
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		message := "must be " + jsonTypeName(typeErr.Type)
+		return apiError{
+			Status: http.StatusBadRequest,
+			Code:   codeValidationFailed,
+			Detail: typeErr.Field + " " + message,
+			Fields: []fieldError{{Field: typeErr.Field, Code: fieldInvalidType, Message: message}},
+		}
+	case errors.As(err, &typeErr):
+		return apiError{
+			Status: http.StatusBadRequest,
+			Code:   codeInvalidJSON,
+			Detail: "Request body must be " + jsonTypeName(typeErr.Type),
+		}
+	}
+	return apiError{
+		Status: http.StatusBadRequest,
+		Code:   codeInvalidRequest,
+		Detail: "Couldn't decode request body",
+	}
+}
+
+// jsonTypeName describes the JSON a Go type decodes from
+func jsonTypeName(t reflect.Type) string {
+	if reflect.PointerTo(t).Implements(reflect.TypeFor[encoding.TextUnmarshaler]()) {
+		return "a string"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	}
+	return "a " + t.String()
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	type target struct {
+		Email string `json:"email"`
+		Data  struct {
+			UserID uuid.UUID `json:"user_id"`
+			Count  int       `json:"count"`
+		} `json:"data"`
+	}
+	strict := decodeOptions{DisallowUnknownFields: true}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		opts        decodeOptions
+		wantStatus  int
+		wantCode    string
+		wantDetail  string
+		wantFields  []string
+	}{
+		{"Valid", "application/json", `{"email": "a@b.com"}`, strict, 0, "", "", nil},
+		{"Charset parameter", "application/json; charset=utf-8", `{}`, decodeOptions{}, 0, "", "", nil},
+		{"JSON suffix", "application/merge-patch+json", `{}`, decodeOptions{}, 0, "", "", nil},
+		{"Trailing whitespace", "application/json", "{}\n\n", decodeOptions{}, 0, "", "", nil},
+		{"No content type", "", `{}`, decodeOptions{}, http.StatusUnsupportedMediaType, codeUnsupportedMedia, "", nil},
+		{"Form", "application/x-www-form-urlencoded", `email=a@b.com`, decodeOptions{}, http.StatusUnsupportedMediaType, codeUnsupportedMedia, "", nil},
+		{"Too large", "application/json", `{"email": "` + strings.Repeat("a", 64) + `"}`, decodeOptions{MaxBytes: 32}, http.StatusRequestEntityTooLarge, codeBodyTooLarge, "Request body must be at most 32 bytes", nil},
+		{"Syntax error", "application/json", "{\n  \"email\": \"a@b.com\",\n  oops\n}", decodeOptions{}, http.StatusBadRequest, codeInvalidJSON, "Request body isn't valid JSON at line 3, column 3", nil},
+		{"Truncated", "application/json", `{"email": "a@b.com"`, decodeOptions{}, http.StatusBadRequest, codeInvalidJSON, "", nil},
+		{"Trailing data", "application/json", `{} {}`, decodeOptions{}, http.StatusBadRequest, codeInvalidJSON, "Request body must hold a single JSON value", nil},
+		{"Unknown field ignored", "application/json", `{"emial": "a@b.com"}`, decodeOptions{}, 0, "", "", nil},
+		{"Unknown field rejected", "application/json", `{"emial": "a@b.com"}`, strict, http.StatusBadRequest, codeValidationFailed, "", []string{"emial"}},
+		{"Nested wrong type", "application/json", `{"data": {"count": "3"}}`, decodeOptions{}, http.StatusBadRequest, codeValidationFailed, "data.count must be an integer", []string{"data.count"}},
+		{"Text value wrong type", "application/json", `{"data": {"user_id": 3}}`, decodeOptions{}, http.StatusBadRequest, codeValidationFailed, "data.user_id must be a string", []string{"data.user_id"}},
+		{"Not an object", "application/json", `[]`, decodeOptions{}, http.StatusBadRequest, codeInvalidJSON, "Request body must be an object", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			var v target
+			err := decodeJSON(httptest.NewRecorder(), req, &v, tt.opts)
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Fatalf("decodeJSON() error = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("decodeJSON() succeeded, want an error")
+			}
+
+			got := decodeError(err)
+			if got.Status != tt.wantStatus || got.Code != tt.wantCode {
+				t.Errorf("error = %d %s, want %d %s", got.Status, got.Code, tt.wantStatus, tt.wantCode)
+			}
+			if tt.wantDetail != "" && got.Detail != tt.wantDetail {
+				t.Errorf("detail = %q, want %q", got.Detail, tt.wantDetail)
+			}
+			var fields []string
+			for _, field := range got.Fields {
+				fields = append(fields, field.Field)
+			}
+			if !slices.Equal(fields, tt.wantFields) {
+				t.Errorf("fields = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestUnsupportedMediaTypeAdvertisesJSON(t *testing.T) {
+	api := newTestAPI(t)
+
+	req := httptest.NewRequest("POST", "/api/login", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "text/plain")
+	rec := httptest.NewRecorder()
+	api.handler.ServeHTTP(rec, req)
+
+	expectStatus(t, rec, http.StatusUnsupportedMediaType)
+	if got := rec.Header().Get("Accept"); got != "application/json" {
+		t.Errorf("Accept = %q, want application/json", got)
+	}
+}
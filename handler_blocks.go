@@ -0,0 +1,103 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type Block struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (cfg *apiConfig) handlerBlocksCreate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		UserID uuid.UUID `json:"user_id"`
+	}
+
+	userID := requestPrincipal(r).UserID
+
+	params := parameters{}
+	err := decodeJSON(w, r, &params, decodeOptions{MaxBytes: smallBodyBytes})
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	if params.UserID == userID {
+		respondWithError(w, http.StatusBadRequest, "You can't block yourself", nil)
+		return
+	}
+
+	_, err = cfg.store.GetUserByID(r.Context(), params.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+
+	err = cfg.store.CreateBlock(r.Context(), database.CreateBlockParams{
+		BlockerID: userID,
+		BlockedID: params.UserID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't block user", err)
+		return
+	}
+	cfg.filterVersions.bump(userID, params.UserID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerBlocksList(w http.ResponseWriter, r *http.Request) {
+	userID := requestPrincipal(r).UserID
+
+	blocks, err := cfg.store.GetBlocksByBlocker(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get blocks", err)
+		return
+	}
+
+	blockList := []Block{}
+	for _, block := range blocks {
+		blockList = append(blockList, Block{
+			UserID:    block.BlockedID,
+			CreatedAt: block.CreatedAt,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, blockList)
+}
+
+func (cfg *apiConfig) handlerBlocksDelete(w http.ResponseWriter, r *http.Request) {
+	blockedID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	userID := requestPrincipal(r).UserID
+
+	deleted, err := cfg.store.DeleteBlock(r.Context(), database.DeleteBlockParams{
+		BlockerID: userID,
+		BlockedID: blockedID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unblock user", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "User is not blocked", nil)
+		return
+	}
+	cfg.filterVersions.bump(userID, blockedID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
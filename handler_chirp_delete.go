@@ -4,7 +4,6 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"github.com/exglegaming/Chirpy/internal/auth"
 	"github.com/exglegaming/Chirpy/internal/database"
 	"github.com/google/uuid"
 	"net/http"
@@ -22,22 +21,16 @@ func (cfg *apiConfig) handlerChirpsDelete(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
-		return
-	}
+	userID := requestPrincipal(r).UserID
 
-	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+	// First check if chirp exists
+	chirp, err := cfg.store.GetChirp(r.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp", err)
 		return
 	}
-
-	// First check if chirp exists
-	chirp, err := cfg.db.GetChirp(context.Background(), chirpID)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "Couldn't find chirp", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp", err)
 		return
 	}
 
@@ -48,23 +41,38 @@ func (cfg *apiConfig) handlerChirpsDelete(w http.ResponseWriter, r *http.Request
 	}
 
 	// Now perform the actual deletion
-	_, err = cfg.db.DeleteChirp(context.Background(), database.DeleteChirpParams{
-		ID:     chirp.ID,
-		UserID: userID,
-	})
+	err = cfg.deleteChirp(r.Context(), chirp)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Deleted since we looked it up
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
 	if err != nil {
-		// Try to determine what kind of error it is
-		if strings.Contains(err.Error(), "not found") || errors.Is(err, sql.ErrNoRows) {
-			respondWithError(w, http.StatusNotFound, "Chirp not found", err)
-			return
-		}
-		if strings.Contains(err.Error(), "not authorized") || strings.Contains(err.Error(), "permission") {
-			respondWithError(w, http.StatusForbidden, "You can only delete your own chirps", err)
-			return
-		}
-		// Generic error
 		respondWithError(w, http.StatusInternalServerError, "Couldn't delete chirp", err)
 		return
 	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// deleteChirp is shared by the handler and "chirpy chirp delete", which
+// skips the ownership check
+func (cfg *apiConfig) deleteChirp(ctx context.Context, chirp database.Chirp) error {
+	err := cfg.withTx(ctx, func(q database.Store) error {
+		_, err := q.DeleteChirp(ctx, database.DeleteChirpParams{
+			ID:     chirp.ID,
+			UserID: chirp.UserID,
+		})
+		if err != nil {
+			return err
+		}
+		return recordEvent(ctx, q, eventChirpDeleted, map[string]uuid.UUID{
+			"id":      chirp.ID,
+			"user_id": chirp.UserID,
+		})
+	})
+	if err == nil {
+		cfg.chirpCache.invalidate(ctx, chirp.ID, chirp.UserID)
+	}
+	return err
+}
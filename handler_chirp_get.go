@@ -1,9 +1,12 @@
 package main
 
 import (
-	"context"
-	"github.com/google/uuid"
+	"database/sql"
+	"errors"
 	"net/http"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
 )
 
 func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
@@ -15,31 +18,38 @@ func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	chirps, err := cfg.db.GetChirps(context.Background())
+	viewer, authenticated := principalFrom(r.Context())
+
+	chirp, err := cfg.chirpCache.chirp(r.Context(), cfg.store, chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp", nil)
+		return
+	}
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirps", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp", err)
 		return
 	}
+	foundChirp := chirpFromDB(chirp)
 
-	var foundChirp Chirp
-	found := false
-	for _, chirp := range chirps {
-		if chirp.ID == chirpID {
-			foundChirp = Chirp{
-				ID:        chirp.ID,
-				CreatedAt: chirp.CreatedAt,
-				UpdatedAt: chirp.UpdatedAt,
-				Body:      chirp.Body,
-				UserID:    chirp.UserID,
-			}
-			found = true
-			break
+	// Blocked chirps look the same as missing ones to the viewer
+	if authenticated {
+		blocked, err := cfg.store.IsBlockedBetween(r.Context(), database.IsBlockedBetweenParams{
+			BlockerID: foundChirp.UserID,
+			BlockedID: viewer.UserID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't check blocks", err)
+			return
+		}
+		if blocked {
+			respondWithError(w, http.StatusNotFound, "Couldn't find chirp", nil)
+			return
 		}
 	}
-	if !found {
-		w.WriteHeader(http.StatusNotFound)
+
+	setCacheControl(w, authenticated)
+	if checkNotModified(w, r, chirpsETag([]Chirp{foundChirp}), foundChirp.UpdatedAt) {
 		return
 	}
-
 	respondWithJSON(w, http.StatusOK, foundChirp)
 }
@@ -1,87 +1,214 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"database/sql"
 	"errors"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
-	"github.com/exglegaming/Chirpy/internal/auth"
 	"github.com/exglegaming/Chirpy/internal/database"
 	"github.com/google/uuid"
 )
 
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	UserID    uuid.UUID `json:"user_id"`
-	Body      string    `json:"body"`
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	UserID    uuid.UUID  `json:"user_id"`
+	Body      string     `json:"body"`
+	ReplyToID *uuid.UUID `json:"reply_to_id,omitempty"`
 }
 
-func (cfg *apiConfig) handlerChirpsCreate(w http.ResponseWriter, r *http.Request) {
-	type parameters struct {
-		Body string `json:"body"`
+func chirpFromDB(c database.Chirp) Chirp {
+	chirp := Chirp{
+		ID:        c.ID,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+		Body:      c.Body,
+		UserID:    c.UserID,
 	}
-
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
-		return
+	if c.ReplyToID.Valid {
+		replyToID := c.ReplyToID.UUID
+		chirp.ReplyToID = &replyToID
 	}
+	return chirp
+}
 
-	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
-		return
+// errReplyToNotFound means the chirp being replied to doesn't exist, or its
+// author and the replier have blocked each other
+var errReplyToNotFound = errors.New("chirp to reply to not found")
+
+func (cfg *apiConfig) handlerChirpsCreate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body      string        `json:"body"`
+		ReplyToID uuid.NullUUID `json:"reply_to_id"`
 	}
 
-	decoder := json.NewDecoder(r.Body)
+	userID := requestPrincipal(r).UserID
+
 	params := parameters{}
-	err = decoder.Decode(&params)
+	err := decodeJSON(w, r, &params, decodeOptions{MaxBytes: smallBodyBytes})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithDecodeError(w, err)
 		return
 	}
 
 	cleaned, err := validateChirp(params.Body)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		respondWithValidationError(w, err)
 		return
 	}
 
-	chirp, err := cfg.db.CreatChirp(r.Context(), database.CreatChirpParams{
-		Body:   cleaned,
-		UserID: userID,
-	})
+	response, err := cfg.createChirp(r.Context(), userID, cleaned, params.ReplyToID)
+	if errors.Is(err, errReplyToNotFound) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp to reply to", nil)
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't create chirp", err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusCreated, Chirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
+	respondWithJSON(w, http.StatusCreated, response)
+}
+
+// createChirp stores an already validated chirp and notifies the users it
+// replies to or mentions. It's shared by the handler and "chirpy seed".
+func (cfg *apiConfig) createChirp(ctx context.Context, userID uuid.UUID, body string, replyToID uuid.NullUUID) (Chirp, error) {
+	var created Chirp
+	var notifications []database.Notification
+	err := cfg.withTx(ctx, func(q database.Store) error {
+		var replyToAuthorID uuid.UUID
+		if replyToID.Valid {
+			replyTo, err := q.GetChirp(ctx, replyToID.UUID)
+			if errors.Is(err, sql.ErrNoRows) {
+				return errReplyToNotFound
+			}
+			if err != nil {
+				return err
+			}
+			blocked, err := q.IsBlockedBetween(ctx, database.IsBlockedBetweenParams{
+				BlockerID: replyTo.UserID,
+				BlockedID: userID,
+			})
+			if err != nil {
+				return err
+			}
+			if blocked {
+				return errReplyToNotFound
+			}
+			replyToAuthorID = replyTo.UserID
+		}
+
+		chirp, err := q.CreatChirp(ctx, database.CreatChirpParams{
+			Body:      body,
+			UserID:    userID,
+			ReplyToID: replyToID,
+		})
+		if err != nil {
+			return err
+		}
+		created = chirpFromDB(chirp)
+		err = recordEvent(ctx, q, eventChirpCreated, created)
+		if err != nil {
+			return err
+		}
+
+		notifications, err = chirpNotifications(ctx, q, chirp, replyToAuthorID)
+		return err
 	})
+	if err == nil {
+		cfg.metrics.chirpsCreated.Inc()
+		cfg.chirpCache.invalidate(ctx, created.ID, created.UserID)
+		cfg.publishNotifications(notifications)
+	}
+	return created, err
+}
+
+// chirpNotifications notifies the author of the chirp being replied to and
+// everyone mentioned, once each
+func chirpNotifications(ctx context.Context, q database.Store, chirp database.Chirp, replyToAuthorID uuid.UUID) ([]database.Notification, error) {
+	var notifications []database.Notification
+	notified := map[uuid.UUID]struct{}{}
+	add := func(recipientID uuid.UUID, notificationType string, chirpID uuid.UUID) error {
+		if _, ok := notified[recipientID]; ok {
+			return nil
+		}
+		notified[recipientID] = struct{}{}
+
+		notification, err := notify(ctx, q, recipientID, chirp.UserID, notificationType, uuid.NullUUID{UUID: chirpID, Valid: true})
+		if err != nil {
+			return err
+		}
+		if notification != nil {
+			notifications = append(notifications, *notification)
+		}
+		return nil
+	}
+
+	// Replies are grouped under the chirp they reply to
+	if chirp.ReplyToID.Valid {
+		err := add(replyToAuthorID, notificationTypeReply, chirp.ReplyToID.UUID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, email := range mentionedEmails(chirp.Body) {
+		user, err := q.GetUserByEmail(ctx, email)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = add(user.ID, notificationTypeMention, chirp.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return notifications, nil
+}
+
+// mentionedEmails returns the emails mentioned as "@email" in body, in order
+// and without duplicates
+func mentionedEmails(body string) []string {
+	var emails []string
+	for _, word := range strings.Fields(body) {
+		email, ok := strings.CutPrefix(word, "@")
+		if !ok {
+			continue
+		}
+		email = strings.TrimRight(email, ".,;:!?)")
+		if !strings.Contains(email, "@") || slices.Contains(emails, email) {
+			continue
+		}
+		emails = append(emails, email)
+	}
+	return emails
 }
 
 func validateChirp(body string) (string, error) {
 	const maxChirpLength = 140
 	if len(body) > maxChirpLength {
-		return "", errors.New("Chirp is too long")
+		return "", &fieldError{Field: "body", Code: fieldTooLong, Message: "Chirp is too long"}
 	}
 
+	cleaned := filterBadWords(body)
+	return cleaned, nil
+}
+
+// filterBadWords is the content filter shared by chirps and direct messages
+func filterBadWords(body string) string {
 	badWords := map[string]struct{}{
 		"kerfuffle": {},
 		"sharbert":  {},
 		"fornax":    {},
 	}
-	cleaned := getCleanedBody(body, badWords)
-	return cleaned, nil
+	return getCleanedBody(body, badWords)
 }
 
 func getCleanedBody(body string, badWords map[string]struct{}) string {
@@ -1,92 +1,63 @@
 package main
 
 import (
-	"context"
-	"github.com/google/uuid"
 	"net/http"
+	"slices"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
 )
 
 func (cfg *apiConfig) handlerChirpsList(w http.ResponseWriter, r *http.Request) {
 	author := r.URL.Query().Get("author_id")
 	sort := r.URL.Query().Get("sort")
+
+	viewer, authenticated := principalFrom(r.Context())
+	var err error
+
+	var chirps []database.Chirp
 	if author == "" {
-		if sort == "desc" {
-			chirps, err := cfg.db.GetChirpsDesc(context.Background())
-			if err != nil {
-				respondWithError(w, http.StatusInternalServerError, "Couldn't get chirps", err)
-			}
-			var chirpList []Chirp
-			for _, chirp := range chirps {
-				chirpList = append(chirpList, Chirp{
-					ID:        chirp.ID,
-					CreatedAt: chirp.CreatedAt,
-					UpdatedAt: chirp.UpdatedAt,
-					Body:      chirp.Body,
-					UserID:    chirp.UserID,
-				})
-			}
-			respondWithJSON(w, http.StatusOK, chirpList)
-			return
-		}
-		chirps, err := cfg.db.GetChirps(context.Background())
+		chirps, err = cfg.chirpCache.chirps(r.Context(), cfg.store, sort == "desc")
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Couldn't get chirps", err)
 			return
 		}
-		var chirpList []Chirp
-		for _, chirp := range chirps {
-			chirpList = append(chirpList, Chirp{
-				ID:        chirp.ID,
-				CreatedAt: chirp.CreatedAt,
-				UpdatedAt: chirp.UpdatedAt,
-				Body:      chirp.Body,
-				UserID:    chirp.UserID,
-			})
-		}
-		respondWithJSON(w, http.StatusOK, chirpList)
-		return
 	} else {
 		user, err := uuid.Parse(author)
 		if err != nil {
 			respondWithError(w, http.StatusBadRequest, "Couldn't parse author_id", err)
 			return
 		}
-		if sort == "desc" {
-			chirps, err := cfg.db.GetChirpsByUserID(context.Background(), user)
-			if err != nil {
-				respondWithError(w, http.StatusInternalServerError, "Couldn't find chirps by user", err)
-				return
-			}
-			var chirpList []Chirp
-			for _, chirp := range chirps {
-				chirpList = append(chirpList, Chirp{
-					ID:        chirp.ID,
-					CreatedAt: chirp.CreatedAt,
-					UpdatedAt: chirp.UpdatedAt,
-					Body:      chirp.Body,
-					UserID:    chirp.UserID,
-				})
-			}
-			respondWithJSON(w, http.StatusOK, chirpList)
+		chirps, err = cfg.chirpCache.chirpsByUser(r.Context(), cfg.store, user)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't find chirps by user", err)
 			return
-		} else {
-			chirps, err := cfg.db.GetChirpsByUserID(context.Background(), user)
-			if err != nil {
-				respondWithError(w, http.StatusInternalServerError, "Couldn't find chirps by user", err)
-				return
-			}
-			var chirpList []Chirp
-			for _, chirp := range chirps {
-				chirpList = append(chirpList, Chirp{
-					ID:        chirp.ID,
-					CreatedAt: chirp.CreatedAt,
-					UpdatedAt: chirp.UpdatedAt,
-					Body:      chirp.Body,
-					UserID:    chirp.UserID,
-				})
-			}
-			respondWithJSON(w, http.StatusOK, chirpList)
+		}
+		if sort == "desc" {
+			slices.Reverse(chirps)
+		}
+	}
+
+	filter := chirpFilter{}
+	if authenticated {
+		filter, err = cfg.chirpFilterForViewer(r.Context(), viewer.UserID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get blocks and mutes", err)
 			return
 		}
 	}
+
+	var chirpList []Chirp
+	for _, chirp := range chirps {
+		if !filter.allows(chirp) {
+			continue
+		}
+		chirpList = append(chirpList, chirpFromDB(chirp))
+	}
+
+	setCacheControl(w, authenticated)
+	if checkNotModified(w, r, chirpsETag(chirpList), chirpsLastModified(chirpList)) {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, chirpList)
 }
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type Conversation struct {
+	ID          uuid.UUID            `json:"id"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+	Members     []ConversationMember `json:"members"`
+	UnreadCount int64                `json:"unread_count"`
+}
+
+type ConversationMember struct {
+	UserID     uuid.UUID  `json:"user_id"`
+	JoinedAt   time.Time  `json:"joined_at"`
+	LastReadAt *time.Time `json:"last_read_at"`
+}
+
+func (cfg *apiConfig) handlerConversationsCreate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		UserIDs []uuid.UUID `json:"user_ids"`
+	}
+
+	userID := requestPrincipal(r).UserID
+
+	params := parameters{}
+	err := decodeJSON(w, r, &params, decodeOptions{})
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	const maxConversationMembers = 50
+	memberIDs := []uuid.UUID{userID}
+	seen := map[uuid.UUID]struct{}{userID: {}}
+	for _, id := range params.UserIDs {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		memberIDs = append(memberIDs, id)
+	}
+	if len(memberIDs) < 2 {
+		respondWithError(w, http.StatusBadRequest, "A conversation needs at least one other user", nil)
+		return
+	}
+	if len(memberIDs) > maxConversationMembers {
+		respondWithError(w, http.StatusBadRequest, "Conversation has too many members", nil)
+		return
+	}
+
+	for _, id := range memberIDs[1:] {
+		_, err := cfg.store.GetUserByID(r.Context(), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Couldn't find user", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+			return
+		}
+
+		blocked, err := cfg.store.IsBlockedBetween(r.Context(), database.IsBlockedBetweenParams{
+			BlockerID: id,
+			BlockedID: userID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't check blocks", err)
+			return
+		}
+		if blocked {
+			respondWithError(w, http.StatusForbidden, "You can't message this user", nil)
+			return
+		}
+	}
+
+	var conversation database.Conversation
+	err = cfg.withTx(r.Context(), func(q database.Store) error {
+		conversation, err = q.CreateConversation(r.Context())
+		if err != nil {
+			return err
+		}
+		for _, id := range memberIDs {
+			err = q.AddConversationMember(r.Context(), database.AddConversationMemberParams{
+				ConversationID: conversation.ID,
+				UserID:         id,
+			})
+			if err != nil {
+				return fmt.Errorf("couldn't add member %s: %w", id, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create conversation", err)
+		return
+	}
+
+	members, err := cfg.conversationMembers(r.Context(), conversation.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get conversation members", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, Conversation{
+		ID:        conversation.ID,
+		CreatedAt: conversation.CreatedAt,
+		UpdatedAt: conversation.UpdatedAt,
+		Members:   members,
+	})
+}
+
+func (cfg *apiConfig) handlerConversationsList(w http.ResponseWriter, r *http.Request) {
+	userID := requestPrincipal(r).UserID
+
+	conversations, err := cfg.store.GetConversationsForUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get conversations", err)
+		return
+	}
+
+	conversationList := []Conversation{}
+	for _, conversation := range conversations {
+		members, err := cfg.conversationMembers(r.Context(), conversation.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get conversation members", err)
+			return
+		}
+		conversationList = append(conversationList, Conversation{
+			ID:          conversation.ID,
+			CreatedAt:   conversation.CreatedAt,
+			UpdatedAt:   conversation.UpdatedAt,
+			Members:     members,
+			UnreadCount: conversation.UnreadCount,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, conversationList)
+}
+
+func (cfg *apiConfig) handlerConversationGet(w http.ResponseWriter, r *http.Request) {
+	conversationID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid conversation ID", err)
+		return
+	}
+
+	userID := requestPrincipal(r).UserID
+
+	conversation, err := cfg.store.GetConversationForUser(r.Context(), database.GetConversationForUserParams{
+		UserID: userID,
+		ID:     conversationID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find conversation", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get conversation", err)
+		return
+	}
+
+	members, err := cfg.conversationMembers(r.Context(), conversation.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get conversation members", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, Conversation{
+		ID:          conversation.ID,
+		CreatedAt:   conversation.CreatedAt,
+		UpdatedAt:   conversation.UpdatedAt,
+		Members:     members,
+		UnreadCount: conversation.UnreadCount,
+	})
+}
+
+// handlerConversationRead records a read receipt for the caller, clearing
+// the conversation's unread count.
+func (cfg *apiConfig) handlerConversationRead(w http.ResponseWriter, r *http.Request) {
+	conversationID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid conversation ID", err)
+		return
+	}
+
+	userID := requestPrincipal(r).UserID
+
+	_, err = cfg.store.GetConversationForUser(r.Context(), database.GetConversationForUserParams{
+		UserID: userID,
+		ID:     conversationID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find conversation", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get conversation", err)
+		return
+	}
+
+	err = cfg.store.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't mark conversation as read", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) conversationMembers(ctx context.Context, conversationID uuid.UUID) ([]ConversationMember, error) {
+	members, err := cfg.store.GetConversationMembers(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	memberList := []ConversationMember{}
+	for _, member := range members {
+		m := ConversationMember{
+			UserID:   member.UserID,
+			JoinedAt: member.JoinedAt,
+		}
+		if member.LastReadAt.Valid {
+			m.LastReadAt = &member.LastReadAt.Time
+		}
+		memberList = append(memberList, m)
+	}
+	return memberList, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type Follow struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (cfg *apiConfig) handlerFollowsCreate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		UserID uuid.UUID `json:"user_id"`
+	}
+
+	userID := requestPrincipal(r).UserID
+
+	params := parameters{}
+	err := decodeJSON(w, r, &params, decodeOptions{MaxBytes: smallBodyBytes})
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	if params.UserID == userID {
+		respondWithError(w, http.StatusBadRequest, "You can't follow yourself", nil)
+		return
+	}
+
+	_, err = cfg.store.GetUserByID(r.Context(), params.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+
+	blocked, err := cfg.store.IsBlockedBetween(r.Context(), database.IsBlockedBetweenParams{
+		BlockerID: params.UserID,
+		BlockedID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check blocks", err)
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "You can't follow this user", nil)
+		return
+	}
+
+	var notification *database.Notification
+	err = cfg.withTx(r.Context(), func(q database.Store) error {
+		created, err := q.CreateFollow(r.Context(), database.CreateFollowParams{
+			FollowerID: userID,
+			FollowedID: params.UserID,
+		})
+		if err != nil || created == 0 {
+			return err
+		}
+		notification, err = notify(r.Context(), q, params.UserID, userID, notificationTypeFollow, uuid.NullUUID{})
+		return err
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't follow user", err)
+		return
+	}
+	cfg.filterVersions.bump(userID)
+	if notification != nil {
+		cfg.publishNotifications([]database.Notification{*notification})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerFollowsList(w http.ResponseWriter, r *http.Request) {
+	userID := requestPrincipal(r).UserID
+
+	follows, err := cfg.store.GetFollowsByFollower(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get follows", err)
+		return
+	}
+
+	followList := []Follow{}
+	for _, follow := range follows {
+		followList = append(followList, Follow{
+			UserID:    follow.FollowedID,
+			CreatedAt: follow.CreatedAt,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, followList)
+}
+
+func (cfg *apiConfig) handlerFollowsDelete(w http.ResponseWriter, r *http.Request) {
+	followedID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	userID := requestPrincipal(r).UserID
+
+	deleted, err := cfg.store.DeleteFollow(r.Context(), database.DeleteFollowParams{
+		FollowerID: userID,
+		FollowedID: followedID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unfollow user", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "User is not followed", nil)
+		return
+	}
+	cfg.filterVersions.bump(userID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handlerChirpLikesCreate(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	userID := requestPrincipal(r).UserID
+
+	chirp, err := cfg.store.GetChirp(r.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp", err)
+		return
+	}
+
+	// Blocked chirps look the same as missing ones
+	blocked, err := cfg.store.IsBlockedBetween(r.Context(), database.IsBlockedBetweenParams{
+		BlockerID: chirp.UserID,
+		BlockedID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check blocks", err)
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp", nil)
+		return
+	}
+
+	var notification *database.Notification
+	err = cfg.withTx(r.Context(), func(q database.Store) error {
+		created, err := q.CreateChirpLike(r.Context(), database.CreateChirpLikeParams{
+			ChirpID: chirp.ID,
+			UserID:  userID,
+		})
+		if err != nil || created == 0 {
+			return err
+		}
+		notification, err = notify(r.Context(), q, chirp.UserID, userID, notificationTypeLike, uuid.NullUUID{UUID: chirp.ID, Valid: true})
+		return err
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't like chirp", err)
+		return
+	}
+	if notification != nil {
+		cfg.publishNotifications([]database.Notification{*notification})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerChirpLikesDelete(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	userID := requestPrincipal(r).UserID
+
+	deleted, err := cfg.store.DeleteChirpLike(r.Context(), database.DeleteChirpLikeParams{
+		ChirpID: chirpID,
+		UserID:  userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unlike chirp", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Chirp is not liked", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
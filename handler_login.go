@@ -2,12 +2,12 @@ package main
 
 import (
 	"database/sql"
-	"encoding/json"
 	"github.com/exglegaming/Chirpy/internal/database"
 	"net/http"
 	"time"
 
 	"github.com/exglegaming/Chirpy/internal/auth"
+	"github.com/google/uuid"
 )
 
 func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
@@ -23,31 +23,37 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		RefreshToken string `json:"refresh_token"`
 	}
 
-	decoder := json.NewDecoder(r.Body)
 	req := loginRequest{}
-	err := decoder.Decode(&req)
+	err := decodeJSON(w, r, &req, decodeOptions{MaxBytes: smallBodyBytes})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithDecodeError(w, err)
 		return
 	}
 
-	user, err := cfg.db.GetUserByEmail(r.Context(), req.Email)
+	user, err := cfg.store.GetUserByEmail(r.Context(), req.Email)
 	if err != nil {
+		cfg.metrics.logins.WithLabelValues("failure").Inc()
 		respondWithError(w, http.StatusUnauthorized, "Invalid email or password", err)
 		return
 	}
 
 	err = auth.CheckPasswordHash(req.Password, user.HashedPassword)
 	if err != nil {
+		cfg.metrics.logins.WithLabelValues("failure").Inc()
 		respondWithError(w, http.StatusUnauthorized, "Passwords do not match", err)
 		return
 	}
 
-	accessToken, err := auth.MakeJWT(
-		user.ID,
-		cfg.JWTSecret,
-		time.Hour,
-	)
+	if user.SuspendedAt.Valid {
+		cfg.metrics.logins.WithLabelValues("failure").Inc()
+		respondWithError(w, http.StatusForbidden, "Account suspended", nil)
+		return
+	}
+
+	accessToken, err := auth.MakeAccessToken(auth.AccessToken{
+		UserID: user.ID,
+		Roles:  accessTokenRoles(user.IsChirpyRed),
+	}, cfg.JWTSecret, cfg.accessTokenTTL)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't create access JWT", err)
 		return
@@ -58,18 +64,32 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't create refresh token", err)
 	}
 
-	refreshExpiry := time.Now().Add(60 * 24 * time.Hour)
+	refreshExpiry := time.Now().Add(cfg.refreshTokenTTL)
 
-	refresh, err := cfg.db.CreateRefreshTokens(r.Context(), database.CreateRefreshTokensParams{
-		Token:     refreshToken,
-		UserID:    user.ID,
-		ExpiresAt: refreshExpiry,
-		RevokedAt: sql.NullTime{
-			Time:  time.Time{},
-			Valid: false,
-		},
+	var refresh database.RefreshToken
+	err = cfg.withTx(r.Context(), func(q database.Store) error {
+		refresh, err = q.CreateRefreshTokens(r.Context(), database.CreateRefreshTokensParams{
+			Token:     refreshToken,
+			UserID:    user.ID,
+			ExpiresAt: refreshExpiry,
+			RevokedAt: sql.NullTime{
+				Time:  time.Time{},
+				Valid: false,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		return recordEvent(r.Context(), q, eventUserLoggedIn, map[string]uuid.UUID{
+			"user_id": user.ID,
+		})
 	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save refresh token", err)
+		return
+	}
 
+	cfg.metrics.logins.WithLabelValues("success").Inc()
 	respondWithJSON(w, http.StatusOK, response{
 		User: User{
 			ID:          user.ID,
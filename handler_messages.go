@@ -0,0 +1,214 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type Message struct {
+	ID             uuid.UUID `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	Body           string    `json:"body"`
+}
+
+func (cfg *apiConfig) handlerMessagesCreate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	conversationID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid conversation ID", err)
+		return
+	}
+
+	userID := requestPrincipal(r).UserID
+
+	params := parameters{}
+	err = decodeJSON(w, r, &params, decodeOptions{MaxBytes: messageBodyBytes})
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	cleaned, err := validateMessage(params.Body)
+	if err != nil {
+		respondWithValidationError(w, err)
+		return
+	}
+
+	_, err = cfg.store.GetConversationForUser(r.Context(), database.GetConversationForUserParams{
+		UserID: userID,
+		ID:     conversationID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find conversation", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get conversation", err)
+		return
+	}
+
+	blocked, err := cfg.store.HasBlockWithConversationMembers(r.Context(), database.HasBlockWithConversationMembersParams{
+		UserID:         userID,
+		ConversationID: conversationID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check blocks", err)
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "You can't message this conversation", nil)
+		return
+	}
+
+	var message database.Message
+	err = cfg.withTx(r.Context(), func(q database.Store) error {
+		message, err = q.CreateMessage(r.Context(), database.CreateMessageParams{
+			ConversationID: conversationID,
+			SenderID:       userID,
+			Body:           cleaned,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = q.TouchConversation(r.Context(), conversationID)
+		if err != nil {
+			return fmt.Errorf("couldn't update conversation: %w", err)
+		}
+
+		// Sending a message implies the sender has read everything before it
+		err = q.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+			ConversationID: conversationID,
+			UserID:         userID,
+		})
+		if err != nil {
+			return fmt.Errorf("couldn't mark conversation read: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create message", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, Message{
+		ID:             message.ID,
+		CreatedAt:      message.CreatedAt,
+		UpdatedAt:      message.UpdatedAt,
+		ConversationID: message.ConversationID,
+		SenderID:       message.SenderID,
+		Body:           message.Body,
+	})
+}
+
+// handlerMessagesList returns messages newest first. Pass the ID of the
+// oldest message received as "before" to fetch the next page.
+func (cfg *apiConfig) handlerMessagesList(w http.ResponseWriter, r *http.Request) {
+	const defaultLimit = 50
+	const maxLimit = 100
+
+	conversationID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid conversation ID", err)
+		return
+	}
+
+	userID := requestPrincipal(r).UserID
+
+	limit := defaultLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxLimit {
+			respondWithError(w, http.StatusBadRequest, "limit must be between 1 and 100", err)
+			return
+		}
+	}
+
+	_, err = cfg.store.GetConversationForUser(r.Context(), database.GetConversationForUserParams{
+		UserID: userID,
+		ID:     conversationID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find conversation", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get conversation", err)
+		return
+	}
+
+	var messages []database.Message
+	if beforeStr := r.URL.Query().Get("before"); beforeStr != "" {
+		beforeID, err := uuid.Parse(beforeStr)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Couldn't parse before", err)
+			return
+		}
+		before, err := cfg.store.GetMessage(r.Context(), database.GetMessageParams{
+			ID:             beforeID,
+			ConversationID: conversationID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Couldn't find before message", err)
+			return
+		}
+		messages, err = cfg.store.GetMessagesBefore(r.Context(), database.GetMessagesBeforeParams{
+			ConversationID:  conversationID,
+			BeforeCreatedAt: before.CreatedAt,
+			BeforeID:        before.ID,
+			MaxResults:      int32(limit),
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get messages", err)
+			return
+		}
+	} else {
+		messages, err = cfg.store.GetMessages(r.Context(), database.GetMessagesParams{
+			ConversationID: conversationID,
+			Limit:          int32(limit),
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get messages", err)
+			return
+		}
+	}
+
+	messageList := []Message{}
+	for _, message := range messages {
+		messageList = append(messageList, Message{
+			ID:             message.ID,
+			CreatedAt:      message.CreatedAt,
+			UpdatedAt:      message.UpdatedAt,
+			ConversationID: message.ConversationID,
+			SenderID:       message.SenderID,
+			Body:           message.Body,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, messageList)
+}
+
+func validateMessage(body string) (string, error) {
+	const maxMessageLength = 1000
+	if body == "" {
+		return "", &fieldError{Field: "body", Code: fieldRequired, Message: "Message can't be empty"}
+	}
+	if len(body) > maxMessageLength {
+		return "", &fieldError{Field: "body", Code: fieldTooLong, Message: "Message is too long"}
+	}
+
+	cleaned := filterBadWords(body)
+	return cleaned, nil
+}
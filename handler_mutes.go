@@ -0,0 +1,202 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type Mute struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type MutedKeyword struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Keyword   string    `json:"keyword"`
+}
+
+func (cfg *apiConfig) handlerMutesCreate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		UserID uuid.UUID `json:"user_id"`
+	}
+
+	userID := requestPrincipal(r).UserID
+
+	params := parameters{}
+	err := decodeJSON(w, r, &params, decodeOptions{MaxBytes: smallBodyBytes})
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	if params.UserID == userID {
+		respondWithError(w, http.StatusBadRequest, "You can't mute yourself", nil)
+		return
+	}
+
+	_, err = cfg.store.GetUserByID(r.Context(), params.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+
+	err = cfg.store.CreateMute(r.Context(), database.CreateMuteParams{
+		MuterID: userID,
+		MutedID: params.UserID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't mute user", err)
+		return
+	}
+	cfg.filterVersions.bump(userID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerMutesList(w http.ResponseWriter, r *http.Request) {
+	userID := requestPrincipal(r).UserID
+
+	mutes, err := cfg.store.GetMutesByMuter(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get mutes", err)
+		return
+	}
+
+	muteList := []Mute{}
+	for _, mute := range mutes {
+		muteList = append(muteList, Mute{
+			UserID:    mute.MutedID,
+			CreatedAt: mute.CreatedAt,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, muteList)
+}
+
+func (cfg *apiConfig) handlerMutesDelete(w http.ResponseWriter, r *http.Request) {
+	mutedID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	userID := requestPrincipal(r).UserID
+
+	deleted, err := cfg.store.DeleteMute(r.Context(), database.DeleteMuteParams{
+		MuterID: userID,
+		MutedID: mutedID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unmute user", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "User is not muted", nil)
+		return
+	}
+	cfg.filterVersions.bump(userID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerMutedKeywordsCreate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Keyword string `json:"keyword"`
+	}
+
+	userID := requestPrincipal(r).UserID
+
+	params := parameters{}
+	err := decodeJSON(w, r, &params, decodeOptions{MaxBytes: smallBodyBytes})
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	const maxKeywordLength = 100
+	keyword := strings.ToLower(strings.TrimSpace(params.Keyword))
+	if keyword == "" {
+		respondWithValidationError(w, &fieldError{Field: "keyword", Code: fieldRequired, Message: "Keyword can't be empty"})
+		return
+	}
+	if len(keyword) > maxKeywordLength {
+		respondWithValidationError(w, &fieldError{Field: "keyword", Code: fieldTooLong, Message: "Keyword is too long"})
+		return
+	}
+
+	mutedKeyword, err := cfg.store.CreateMutedKeyword(r.Context(), database.CreateMutedKeywordParams{
+		UserID:  userID,
+		Keyword: keyword,
+	})
+	if database.IsUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "Keyword is already muted", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't mute keyword", err)
+		return
+	}
+	cfg.filterVersions.bump(userID)
+
+	respondWithJSON(w, http.StatusCreated, MutedKeyword{
+		ID:        mutedKeyword.ID,
+		CreatedAt: mutedKeyword.CreatedAt,
+		Keyword:   mutedKeyword.Keyword,
+	})
+}
+
+func (cfg *apiConfig) handlerMutedKeywordsList(w http.ResponseWriter, r *http.Request) {
+	userID := requestPrincipal(r).UserID
+
+	keywords, err := cfg.store.GetMutedKeywords(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get muted keywords", err)
+		return
+	}
+
+	keywordList := []MutedKeyword{}
+	for _, keyword := range keywords {
+		keywordList = append(keywordList, MutedKeyword{
+			ID:        keyword.ID,
+			CreatedAt: keyword.CreatedAt,
+			Keyword:   keyword.Keyword,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, keywordList)
+}
+
+func (cfg *apiConfig) handlerMutedKeywordsDelete(w http.ResponseWriter, r *http.Request) {
+	keywordID, err := uuid.Parse(r.PathValue("keywordID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid keyword ID", err)
+		return
+	}
+
+	userID := requestPrincipal(r).UserID
+
+	deleted, err := cfg.store.DeleteMutedKeyword(r.Context(), database.DeleteMutedKeywordParams{
+		ID:     keywordID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete muted keyword", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Couldn't find muted keyword", nil)
+		return
+	}
+	cfg.filterVersions.bump(userID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handlerNotificationsList(w http.ResponseWriter, r *http.Request) {
+	const defaultLimit = 100
+	const maxLimit = 500
+
+	type response struct {
+		UnreadCount   int64               `json:"unread_count"`
+		Notifications []NotificationGroup `json:"notifications"`
+	}
+
+	userID := requestPrincipal(r).UserID
+
+	var err error
+	limit := defaultLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxLimit {
+			respondWithError(w, http.StatusBadRequest, "limit must be between 1 and 500", err)
+			return
+		}
+	}
+
+	var notifications []database.Notification
+	if r.URL.Query().Get("unread") == "true" {
+		notifications, err = cfg.store.GetUnreadNotifications(r.Context(), database.GetUnreadNotificationsParams{
+			UserID: userID,
+			Limit:  int32(limit),
+		})
+	} else {
+		notifications, err = cfg.store.GetNotifications(r.Context(), database.GetNotificationsParams{
+			UserID: userID,
+			Limit:  int32(limit),
+		})
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get notifications", err)
+		return
+	}
+
+	unread, err := cfg.store.CountUnreadNotifications(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't count unread notifications", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{
+		UnreadCount:   unread,
+		Notifications: groupNotifications(notifications),
+	})
+}
+
+func (cfg *apiConfig) handlerNotificationRead(w http.ResponseWriter, r *http.Request) {
+	notificationID, err := uuid.Parse(r.PathValue("notificationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid notification ID", err)
+		return
+	}
+
+	userID := requestPrincipal(r).UserID
+
+	updated, err := cfg.store.MarkNotificationRead(r.Context(), database.MarkNotificationReadParams{
+		ID:     notificationID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't mark notification as read", err)
+		return
+	}
+	if updated == 0 {
+		respondWithError(w, http.StatusNotFound, "Couldn't find notification", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerNotificationsReadAll(w http.ResponseWriter, r *http.Request) {
+	userID := requestPrincipal(r).UserID
+
+	err := cfg.store.MarkAllNotificationsRead(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't mark notifications as read", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerNotificationPreferencesGet(w http.ResponseWriter, r *http.Request) {
+	userID := requestPrincipal(r).UserID
+
+	prefs, err := cfg.notificationPreferences(r, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get notification preferences", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, prefs)
+}
+
+// handlerNotificationPreferencesUpdate takes a partial map of notification
+// type to enabled, e.g. {"like": false}, and returns the full set.
+func (cfg *apiConfig) handlerNotificationPreferencesUpdate(w http.ResponseWriter, r *http.Request) {
+	userID := requestPrincipal(r).UserID
+
+	params := map[string]bool{}
+	err := decodeJSON(w, r, &params, decodeOptions{})
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	for notificationType := range params {
+		if !slices.Contains(notificationTypes, notificationType) {
+			respondWithValidationError(w, &fieldError{Field: notificationType, Code: fieldUnknown, Message: "Unknown notification type"})
+			return
+		}
+	}
+
+	for notificationType, enabled := range params {
+		err = cfg.store.UpsertNotificationPreference(r.Context(), database.UpsertNotificationPreferenceParams{
+			UserID:  userID,
+			Type:    notificationType,
+			Enabled: enabled,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't update notification preferences", err)
+			return
+		}
+	}
+
+	prefs, err := cfg.notificationPreferences(r, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get notification preferences", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, prefs)
+}
+
+// notificationPreferences fills in the default (enabled) for any type the
+// user hasn't set explicitly.
+func (cfg *apiConfig) notificationPreferences(r *http.Request, userID uuid.UUID) (map[string]bool, error) {
+	stored, err := cfg.store.GetNotificationPreferences(r.Context(), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	prefs := map[string]bool{}
+	for _, notificationType := range notificationTypes {
+		prefs[notificationType] = true
+	}
+	for _, pref := range stored {
+		prefs[pref.Type] = pref.Enabled
+	}
+	return prefs, nil
+}
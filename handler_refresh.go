@@ -1,11 +1,8 @@
 package main
 
 import (
-	"context"
 	"github.com/exglegaming/Chirpy/internal/auth"
 	"net/http"
-	"strings"
-	"time"
 )
 
 func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
@@ -13,29 +10,21 @@ func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 		Token string `json:"token"`
 	}
 
-	// Getting token if it exists in the header
-	authHeader := r.Header.Get("Authorization")
-	splitAuth := strings.Split(authHeader, " ")
-	if len(splitAuth) < 2 || splitAuth[0] != "Bearer" {
-		http.Error(w, "malformed authorization header", http.StatusUnauthorized)
-		return
-	}
-
-	// Getting the refresh token
-	refreshToken, err := cfg.db.GetRefreshTokenByToken(context.Background(), splitAuth[1])
+	user, err := cfg.store.GetUserByID(r.Context(), requestPrincipal(r).UserID)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "refresh token is not valid", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
 		return
 	}
-
-	// Check if token is expired or revoked
-	if time.Now().After(refreshToken.ExpiresAt) || refreshToken.RevokedAt.Valid {
-		respondWithError(w, http.StatusUnauthorized, "refresh token is expired or revoked", nil)
+	if user.SuspendedAt.Valid {
+		respondWithError(w, http.StatusForbidden, "Account suspended", nil)
 		return
 	}
-	
-	// Make JWT for user
-	token, err := auth.MakeJWT(refreshToken.UserID, cfg.JWTSecret, time.Hour)
+
+	// Make JWT for user, with their current roles
+	token, err := auth.MakeAccessToken(auth.AccessToken{
+		UserID: user.ID,
+		Roles:  accessTokenRoles(user.IsChirpyRed),
+	}, cfg.JWTSecret, cfg.accessTokenTTL)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't create access JWT", err)
 		return
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// handlerStream pushes chirp.created and chirp.deleted events as
+// Server-Sent Events. Clients resume after a disconnect by sending the last
+// id they saw in Last-Event-ID (or ?last_event_id=). Events are kept for
+// chirpEventRetention; a stream can't resume from one that's been pruned,
+// so it starts from now instead. Authenticated clients can pass
+// ?following=true to only get chirps from users they follow.
+func (cfg *apiConfig) handlerStream(w http.ResponseWriter, r *http.Request) {
+	const replayBatchSize = 100
+	const heartbeatInterval = 15 * time.Second
+	const writeWait = 10 * time.Second
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondWithError(w, http.StatusInternalServerError, "Streaming is not supported", nil)
+		return
+	}
+
+	// The stream outlives the server's WriteTimeout, so give each write its
+	// own deadline instead
+	rc := http.NewResponseController(w)
+	extendWriteDeadline := func() error {
+		return rc.SetWriteDeadline(time.Now().Add(writeWait))
+	}
+	if err := extendWriteDeadline(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Streaming is not supported", err)
+		return
+	}
+
+	viewer, authenticated := principalFrom(r.Context())
+	var err error
+
+	authors := map[uuid.UUID]struct{}{}
+	for _, author := range r.URL.Query()["author_id"] {
+		authorID, err := uuid.Parse(author)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Couldn't parse author_id", err)
+			return
+		}
+		authors[authorID] = struct{}{}
+	}
+
+	following := r.URL.Query().Get("following") == "true"
+	if following && !authenticated {
+		respondUnauthorized(w, errNoCredentials)
+		return
+	}
+
+	lastEventIDStr := r.Header.Get("Last-Event-ID")
+	if lastEventIDStr == "" {
+		lastEventIDStr = r.URL.Query().Get("last_event_id")
+	}
+	var lastEventID int64
+	if lastEventIDStr != "" {
+		lastEventID, err = strconv.ParseInt(lastEventIDStr, 10, 64)
+		if err != nil || lastEventID < 0 {
+			respondWithError(w, http.StatusBadRequest, "Couldn't parse Last-Event-ID", err)
+			return
+		}
+	}
+
+	wants := func(event database.ChirpEvent) bool {
+		if len(authors) == 0 {
+			return true
+		}
+		_, ok := authors[event.UserID]
+		return ok
+	}
+	// Blocks, mutes and follows are checked as events are sent rather than
+	// once at connect, so changes apply to streams that are already open
+	var cached *cachedChirpFilter
+	if authenticated {
+		cached = &cachedChirpFilter{cfg: cfg, userID: viewer.UserID, following: following}
+	}
+	viewerFilter := func() (chirpFilter, error) {
+		if cached == nil {
+			return chirpFilter{}, nil
+		}
+		return cached.get(r.Context())
+	}
+	allows := func(filter chirpFilter, event database.ChirpEvent) bool {
+		return wants(event) && filter.allows(database.Chirp{
+			ID:     event.ChirpID,
+			UserID: event.UserID,
+			Body:   event.Body.String,
+		})
+	}
+	if _, err := viewerFilter(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get blocks, mutes and follows", err)
+		return
+	}
+
+	// Subscribe before replaying so nothing published in between is lost;
+	// anything seen twice is skipped by ID.
+	sub := cfg.chirpEvents.subscribe(wants)
+	defer cfg.chirpEvents.unsubscribe(sub)
+	defer cfg.metrics.trackStream("sse")()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	flusher.Flush()
+
+	// Events aren't delivered in ID order, so the stream remembers its
+	// position by the event it last saw. 0 replays every event still kept.
+	var cursor chirpEventCursor
+	resume := lastEventIDStr != ""
+	if resume && lastEventID > 0 {
+		last, err := cfg.store.GetChirpEvent(r.Context(), lastEventID)
+		if errors.Is(err, sql.ErrNoRows) {
+			resume = false
+		} else if err != nil {
+			return
+		}
+		cursor = chirpEventCursorAt(last)
+	}
+	for resume {
+		events, err := chirpEventsAfter(r.Context(), cfg.store, cursor, replayBatchSize)
+		if err != nil {
+			return
+		}
+		filter, err := viewerFilter()
+		if err != nil {
+			return
+		}
+		if err := extendWriteDeadline(); err != nil {
+			return
+		}
+		for _, event := range events {
+			cursor = chirpEventCursorAt(event)
+			if !allows(filter, event) {
+				continue
+			}
+			if err := writeChirpEvent(w, event); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+		resume = len(events) == replayBatchSize
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-cfg.draining:
+			// Clients reconnect with Last-Event-ID and pick up where they
+			// left off
+			return
+		case <-sub.dropped:
+			return
+		case event := <-sub.events:
+			if !cursor.before(event) {
+				continue
+			}
+			cursor = chirpEventCursorAt(event)
+			filter, err := viewerFilter()
+			if err != nil {
+				return
+			}
+			if !allows(filter, event) {
+				continue
+			}
+			if err := extendWriteDeadline(); err != nil {
+				return
+			}
+			if err := writeChirpEvent(w, event); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if err := extendWriteDeadline(); err != nil {
+				return
+			}
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeChirpEvent(w io.Writer, event database.ChirpEvent) error {
+	dat, err := json.Marshal(chirpEventPayload(event))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, dat)
+	return err
+}
+
+// chirpEventPayload is the chirp for created events, and just enough to
+// identify it for deleted ones
+func chirpEventPayload(event database.ChirpEvent) interface{} {
+	if event.Type == chirpEventCreated {
+		return Chirp{
+			ID:        event.ChirpID,
+			CreatedAt: event.CreatedAt,
+			UpdatedAt: event.CreatedAt,
+			Body:      event.Body.String,
+			UserID:    event.UserID,
+		}
+	}
+	return struct {
+		ID     uuid.UUID `json:"id"`
+		UserID uuid.UUID `json:"user_id"`
+	}{
+		ID:     event.ChirpID,
+		UserID: event.UserID,
+	}
+}
@@ -0,0 +1,1425 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/auth"
+	"github.com/exglegaming/Chirpy/internal/cache"
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/exglegaming/Chirpy/internal/database/memstore"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+type testAPI struct {
+	t       *testing.T
+	cfg     *apiConfig
+	store   *memstore.Store
+	handler http.Handler
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	store := memstore.New()
+	cfg := &apiConfig{
+		store:           store,
+		platform:        "dev",
+		JWTSecret:       "test-secret",
+		outbox:          newOutbox(store),
+		notifications:   newBroker[database.Notification](),
+		chirpEvents:     newChirpEventBroker(store),
+		metrics:         newMetrics(),
+		accessTokenTTL:  time.Hour,
+		refreshTokenTTL: 24 * time.Hour,
+	}
+	cfg.chirpCache = newChirpCache(cache.NewLRU(100), time.Minute, cfg.metrics)
+	return &testAPI{
+		t:       t,
+		cfg:     cfg,
+		store:   store,
+		handler: cfg.routes("."),
+	}
+}
+
+// do sends a request through the router. body is marshalled to JSON unless
+// it is nil.
+func (api *testAPI) do(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	api.t.Helper()
+
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			api.t.Fatalf("Couldn't encode request body: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &reqBody)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rec := httptest.NewRecorder()
+	api.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatalf("Couldn't decode response %q: %v", rec.Body.String(), err)
+	}
+	return v
+}
+
+func expectStatus(t *testing.T, rec *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if rec.Code != want {
+		t.Fatalf("status = %d, want %d (body %q)", rec.Code, want, rec.Body.String())
+	}
+}
+
+type loginResponse struct {
+	User
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// signUp creates a user and logs them in
+func (api *testAPI) signUp(email, password string) loginResponse {
+	api.t.Helper()
+
+	rec := api.do("POST", "/api/users", "", map[string]string{"email": email, "password": password})
+	expectStatus(api.t, rec, http.StatusCreated)
+
+	rec = api.do("POST", "/api/login", "", map[string]string{"email": email, "password": password})
+	expectStatus(api.t, rec, http.StatusOK)
+	return decode[loginResponse](api.t, rec)
+}
+
+func (api *testAPI) postChirp(token, body string) Chirp {
+	api.t.Helper()
+
+	rec := api.do("POST", "/api/chirps", token, map[string]string{"body": body})
+	expectStatus(api.t, rec, http.StatusCreated)
+	return decode[Chirp](api.t, rec)
+}
+
+func (api *testAPI) outboxEventTypes() []string {
+	var types []string
+	for _, event := range api.store.OutboxEvents() {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestUsersCreate(t *testing.T) {
+	api := newTestAPI(t)
+
+	rec := api.do("POST", "/api/users", "", map[string]string{
+		"email":    "walt@breakingbad.com",
+		"password": "123456",
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	if strings.Contains(rec.Body.String(), "123456") || strings.Contains(rec.Body.String(), "password") {
+		t.Errorf("response leaks the password: %s", rec.Body.String())
+	}
+	user := decode[User](t, rec)
+	if user.Email != "walt@breakingbad.com" || user.ID == uuid.Nil || user.IsChirpyRed {
+		t.Errorf("unexpected user %+v", user)
+	}
+
+	rec = api.do("POST", "/api/users", "", map[string]string{
+		"email":    "walt@breakingbad.com",
+		"password": "654321",
+	})
+	expectStatus(t, rec, http.StatusConflict)
+
+	if got := api.outboxEventTypes(); !slices.Equal(got, []string{eventUserCreated}) {
+		t.Errorf("outbox events = %v, want one %s", got, eventUserCreated)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	api := newTestAPI(t)
+	api.signUp("saul@bettercall.com", "correct-horse")
+
+	tests := []struct {
+		name       string
+		email      string
+		password   string
+		wantStatus int
+	}{
+		{
+			name:       "Correct password",
+			email:      "saul@bettercall.com",
+			password:   "correct-horse",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Wrong password",
+			email:      "saul@bettercall.com",
+			password:   "battery-staple",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Unknown email",
+			email:      "kim@bettercall.com",
+			password:   "correct-horse",
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := api.do("POST", "/api/login", "", map[string]string{
+				"email":    tt.email,
+				"password": tt.password,
+			})
+			expectStatus(t, rec, tt.wantStatus)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			resp := decode[loginResponse](t, rec)
+			if resp.Token == "" || resp.RefreshToken == "" {
+				t.Errorf("missing tokens in %+v", resp)
+			}
+		})
+	}
+}
+
+func TestUserUpdate(t *testing.T) {
+	api := newTestAPI(t)
+	login := api.signUp("jesse@breakingbad.com", "yo")
+
+	rec := api.do("PUT", "/api/users", "", map[string]string{"email": "pinkman@breakingbad.com", "password": "science"})
+	expectStatus(t, rec, http.StatusUnauthorized)
+
+	rec = api.do("PUT", "/api/users", login.Token, map[string]string{"email": "pinkman@breakingbad.com", "password": "science"})
+	expectStatus(t, rec, http.StatusOK)
+	if user := decode[User](t, rec); user.Email != "pinkman@breakingbad.com" || user.ID != login.ID {
+		t.Errorf("unexpected user %+v", user)
+	}
+
+	rec = api.do("POST", "/api/login", "", map[string]string{"email": "jesse@breakingbad.com", "password": "yo"})
+	expectStatus(t, rec, http.StatusUnauthorized)
+	rec = api.do("POST", "/api/login", "", map[string]string{"email": "pinkman@breakingbad.com", "password": "science"})
+	expectStatus(t, rec, http.StatusOK)
+}
+
+func TestRefreshAndRevoke(t *testing.T) {
+	api := newTestAPI(t)
+	login := api.signUp("mike@breakingbad.com", "half-measures")
+
+	rec := api.do("POST", "/api/refresh", login.RefreshToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if resp := decode[struct {
+		Token string `json:"token"`
+	}](t, rec); resp.Token == "" {
+		t.Error("refresh returned an empty token")
+	}
+
+	// Access tokens aren't refresh tokens
+	rec = api.do("POST", "/api/refresh", login.Token, nil)
+	expectStatus(t, rec, http.StatusUnauthorized)
+
+	rec = api.do("POST", "/api/revoke", login.RefreshToken, nil)
+	expectStatus(t, rec, http.StatusNoContent)
+
+	rec = api.do("POST", "/api/refresh", login.RefreshToken, nil)
+	expectStatus(t, rec, http.StatusUnauthorized)
+	rec = api.do("POST", "/api/revoke", login.RefreshToken, nil)
+	expectStatus(t, rec, http.StatusUnauthorized)
+}
+
+func TestSuspendUser(t *testing.T) {
+	api := newTestAPI(t)
+	login := api.signUp("gus@lospolloshermanos.com", "chicken")
+
+	err := api.cfg.suspendUser(t.Context(), "gus@lospolloshermanos.com")
+	if err != nil {
+		t.Fatalf("suspendUser: %v", err)
+	}
+
+	rec := api.do("POST", "/api/login", "", map[string]string{"email": "gus@lospolloshermanos.com", "password": "chicken"})
+	expectStatus(t, rec, http.StatusForbidden)
+	rec = api.do("POST", "/api/refresh", login.RefreshToken, nil)
+	expectStatus(t, rec, http.StatusUnauthorized)
+
+	if err := api.cfg.suspendUser(t.Context(), uuid.NewString()); err == nil {
+		t.Error("suspending an unknown user succeeded")
+	}
+}
+
+func TestPromoteUser(t *testing.T) {
+	api := newTestAPI(t)
+	login := api.signUp("lydia@madrigal.com", "stevia")
+
+	err := api.cfg.promoteUser(t.Context(), login.ID.String())
+	if err != nil {
+		t.Fatalf("promoteUser: %v", err)
+	}
+
+	user, err := api.store.GetUserByID(t.Context(), login.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.IsChirpyRed {
+		t.Error("user wasn't promoted to Chirpy Red")
+	}
+	if got := api.outboxEventTypes(); !slices.Contains(got, eventUserUpgraded) {
+		t.Errorf("outbox events = %v, want a %s", got, eventUserUpgraded)
+	}
+}
+
+func TestChirpsCreate(t *testing.T) {
+	api := newTestAPI(t)
+	login := api.signUp("gus@pollos.com", "chicken")
+
+	tests := []struct {
+		name       string
+		token      string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Valid chirp",
+			token:      login.Token,
+			body:       "Los Pollos Hermanos",
+			wantStatus: http.StatusCreated,
+			wantBody:   "Los Pollos Hermanos",
+		},
+		{
+			name:       "Profanity is cleaned",
+			token:      login.Token,
+			body:       "What a Kerfuffle this is",
+			wantStatus: http.StatusCreated,
+			wantBody:   "What a **** this is",
+		},
+		{
+			name:       "Too long",
+			token:      login.Token,
+			body:       strings.Repeat("a", 141),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Missing token",
+			body:       "Hello",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Invalid token",
+			token:      "not-a-jwt",
+			body:       "Hello",
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := api.do("POST", "/api/chirps", tt.token, map[string]string{"body": tt.body})
+			expectStatus(t, rec, tt.wantStatus)
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+			chirp := decode[Chirp](t, rec)
+			if chirp.Body != tt.wantBody || chirp.UserID != login.ID {
+				t.Errorf("unexpected chirp %+v", chirp)
+			}
+		})
+	}
+
+	var chirpEvents int
+	for _, event := range api.store.OutboxEvents() {
+		if event.Type == eventChirpCreated {
+			chirpEvents++
+		}
+	}
+	if chirpEvents != 2 {
+		t.Errorf("got %d %s events, want 2", chirpEvents, eventChirpCreated)
+	}
+}
+
+func TestChirpsList(t *testing.T) {
+	api := newTestAPI(t)
+	hank := api.signUp("hank@dea.gov", "minerals")
+	marie := api.signUp("marie@purple.com", "purple")
+
+	first := api.postChirp(hank.Token, "They're minerals")
+	second := api.postChirp(marie.Token, "Purple is a state of mind")
+	third := api.postChirp(hank.Token, "Tread lightly")
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantIDs    []uuid.UUID
+	}{
+		{
+			name:       "All ascending",
+			wantStatus: http.StatusOK,
+			wantIDs:    []uuid.UUID{first.ID, second.ID, third.ID},
+		},
+		{
+			name:       "All descending",
+			query:      "?sort=desc",
+			wantStatus: http.StatusOK,
+			wantIDs:    []uuid.UUID{third.ID, second.ID, first.ID},
+		},
+		{
+			name:       "By author",
+			query:      "?author_id=" + hank.ID.String(),
+			wantStatus: http.StatusOK,
+			wantIDs:    []uuid.UUID{first.ID, third.ID},
+		},
+		{
+			name:       "By author descending",
+			query:      "?author_id=" + hank.ID.String() + "&sort=desc",
+			wantStatus: http.StatusOK,
+			wantIDs:    []uuid.UUID{third.ID, first.ID},
+		},
+		{
+			name:       "Invalid author",
+			query:      "?author_id=hank",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := api.do("GET", "/api/chirps"+tt.query, "", nil)
+			expectStatus(t, rec, tt.wantStatus)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var ids []uuid.UUID
+			for _, chirp := range decode[[]Chirp](t, rec) {
+				ids = append(ids, chirp.ID)
+			}
+			if !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("chirp IDs = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestChirpGet(t *testing.T) {
+	api := newTestAPI(t)
+	login := api.signUp("skyler@breakingbad.com", "car-wash")
+	chirp := api.postChirp(login.Token, "A1A Car Wash")
+
+	rec := api.do("GET", "/api/chirps/"+chirp.ID.String(), "", nil)
+	expectStatus(t, rec, http.StatusOK)
+	if got := decode[Chirp](t, rec); got != chirp {
+		t.Errorf("got %+v, want %+v", got, chirp)
+	}
+
+	rec = api.do("GET", "/api/chirps/"+uuid.NewString(), "", nil)
+	expectStatus(t, rec, http.StatusNotFound)
+
+	rec = api.do("GET", "/api/chirps/not-a-uuid", "", nil)
+	expectStatus(t, rec, http.StatusBadRequest)
+}
+
+func TestChirpDelete(t *testing.T) {
+	api := newTestAPI(t)
+	owner := api.signUp("tuco@salamanca.com", "tight")
+	other := api.signUp("hector@salamanca.com", "ding")
+	chirp := api.postChirp(owner.Token, "Tight tight tight")
+	path := "/api/chirps/" + chirp.ID.String()
+
+	rec := api.do("DELETE", path, "", nil)
+	expectStatus(t, rec, http.StatusUnauthorized)
+
+	rec = api.do("DELETE", path, other.Token, nil)
+	expectStatus(t, rec, http.StatusForbidden)
+
+	rec = api.do("DELETE", path, owner.Token, nil)
+	expectStatus(t, rec, http.StatusNoContent)
+
+	rec = api.do("GET", path, "", nil)
+	expectStatus(t, rec, http.StatusNotFound)
+
+	rec = api.do("DELETE", path, owner.Token, nil)
+	expectStatus(t, rec, http.StatusNotFound)
+
+	if got := api.outboxEventTypes(); !slices.Contains(got, eventChirpDeleted) {
+		t.Errorf("outbox events = %v, want a %s", got, eventChirpDeleted)
+	}
+
+	// A database failure isn't passed off as a missing chirp
+	kept := api.postChirp(owner.Token, "Tight tight tight, yeah")
+	api.cfg.store = failingStore{Store: api.store, failGetChirp: true}
+	rec = api.do("DELETE", "/api/chirps/"+kept.ID.String(), owner.Token, nil)
+	expectStatus(t, rec, http.StatusInternalServerError)
+}
+
+func TestReset(t *testing.T) {
+	api := newTestAPI(t)
+	login := api.signUp("todd@vamonos.com", "pest")
+	api.postChirp(login.Token, "Vamonos")
+
+	api.cfg.platform = "prod"
+	rec := api.do("POST", "/admin/reset", "", nil)
+	expectStatus(t, rec, http.StatusForbidden)
+
+	api.cfg.platform = "dev"
+	rec = api.do("POST", "/admin/reset", "", nil)
+	expectStatus(t, rec, http.StatusOK)
+
+	rec = api.do("GET", "/api/chirps", "", nil)
+	expectStatus(t, rec, http.StatusOK)
+	if chirps := decode[[]Chirp](t, rec); len(chirps) != 0 {
+		t.Errorf("got %d chirps after reset, want 0", len(chirps))
+	}
+	if _, err := api.store.GetUserByEmail(t.Context(), "todd@vamonos.com"); err == nil {
+		t.Error("user survived reset")
+	}
+}
+
+func TestStreamReplay(t *testing.T) {
+	api := newTestAPI(t)
+	login := api.signUp("jesse@pinkman.com", "yo")
+	chirp := api.postChirp(login.Token, "Yeah science")
+	rec := api.do("DELETE", "/api/chirps/"+chirp.ID.String(), login.Token, nil)
+	expectStatus(t, rec, http.StatusNoContent)
+	created, err := api.store.GetChirpEvent(t.Context(), 1)
+	if err != nil || created.ChirpID != chirp.ID {
+		t.Fatalf("GetChirpEvent = %+v, %v; want the chirp's creation", created, err)
+	}
+
+	// The stream sets write deadlines, which the recorder doesn't support
+	srv := httptest.NewServer(api.handler)
+	defer srv.Close()
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		want        []string
+	}{
+		{"From the start", "0", []string{chirpEventCreated, chirpEventDeleted}},
+		{"After an event", strconv.FormatInt(created.ID, 10), []string{chirpEventDeleted}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+			defer cancel()
+			req, err := http.NewRequestWithContext(ctx, "GET", srv.URL+"/api/stream", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Last-Event-ID", tt.lastEventID)
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want 200", resp.StatusCode)
+			}
+
+			var events []string
+			scanner := bufio.NewScanner(resp.Body)
+			for len(events) < len(tt.want) && scanner.Scan() {
+				if eventType, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+					events = append(events, eventType)
+				}
+			}
+			if !slices.Equal(events, tt.want) {
+				t.Errorf("replayed events = %v, want %v", events, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamBlockAfterConnect(t *testing.T) {
+	api := newTestAPI(t)
+	viewer := api.signUp("walt@grey.com", "heisenberg")
+	blocked := api.signUp("tuco@salamanca.com", "tight")
+	friend := api.signUp("jesse@pinkman.com", "yo")
+	srv := httptest.NewServer(api.handler)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", srv.URL+"/api/stream", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+viewer.Token)
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// Blocked after the stream opened, so only a per-event check hides it
+	expectStatus(t, api.do("POST", "/api/blocks", viewer.Token, map[string]uuid.UUID{"user_id": blocked.ID}), http.StatusNoContent)
+	api.postChirp(blocked.Token, "Tight tight tight")
+	want := api.postChirp(friend.Token, "Yeah science")
+	if err := api.cfg.chirpEvents.catchUp(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var got Chirp
+		if err := json.Unmarshal([]byte(data), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != want.ID {
+			t.Errorf("streamed chirp %q, want the friend's", got.Body)
+		}
+		return
+	}
+	t.Fatalf("stream ended: %v", scanner.Err())
+}
+
+func TestStreamFollowing(t *testing.T) {
+	api := newTestAPI(t)
+	viewer := api.signUp("walt@grey.com", "heisenberg")
+	friend := api.signUp("jesse@pinkman.com", "yo")
+	stranger := api.signUp("tuco@salamanca.com", "tight")
+	expectStatus(t, api.do("POST", "/api/follows", viewer.Token, map[string]uuid.UUID{"user_id": friend.ID}), http.StatusNoContent)
+	srv := httptest.NewServer(api.handler)
+	defer srv.Close()
+
+	resp, err := srv.Client().Get(srv.URL + "/api/stream?following=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("status without a token = %d, want 401", resp.StatusCode)
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", srv.URL+"/api/stream?following=true", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+viewer.Token)
+	resp, err = srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	next := func() string {
+		t.Helper()
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			var chirp Chirp
+			if err := json.Unmarshal([]byte(data), &chirp); err != nil {
+				t.Fatal(err)
+			}
+			return chirp.Body
+		}
+		t.Fatalf("stream ended: %v", scanner.Err())
+		return ""
+	}
+
+	api.postChirp(stranger.Token, "Tight tight tight")
+	api.postChirp(friend.Token, "Yeah science")
+	if err := api.cfg.chirpEvents.catchUp(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if got := next(); got != "Yeah science" {
+		t.Errorf("streamed %q, want the friend's chirp", got)
+	}
+
+	// Followed after the stream opened, so only a per-event check shows it
+	expectStatus(t, api.do("POST", "/api/follows", viewer.Token, map[string]uuid.UUID{"user_id": stranger.ID}), http.StatusNoContent)
+	api.postChirp(stranger.Token, "Bring me the cousins")
+	if err := api.cfg.chirpEvents.catchUp(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if got := next(); got != "Bring me the cousins" {
+		t.Errorf("streamed %q, want the newly followed user's chirp", got)
+	}
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	api := newTestAPI(t)
+	api.cfg.wsAllowedOrigins = []string{"https://chirpy.example"}
+	login := api.signUp("jesse@pinkman.com", "yo")
+	srv := httptest.NewServer(api.handler)
+	defer srv.Close()
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/ws?access_token=" + login.Token
+
+	tests := []struct {
+		name   string
+		origin string
+		want   int
+	}{
+		{"No origin", "", http.StatusSwitchingProtocols},
+		{"Own origin", srv.URL, http.StatusSwitchingProtocols},
+		{"Allowed origin", "https://CHIRPY.example", http.StatusSwitchingProtocols},
+		{"Other origin", "https://evil.example", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.origin != "" {
+				header.Set("Origin", tt.origin)
+			}
+			conn, resp, err := websocket.DefaultDialer.DialContext(t.Context(), wsURL, header)
+			if conn != nil {
+				conn.Close()
+			}
+			if resp == nil {
+				t.Fatalf("Dial: %v", err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebSocketBlockAfterConnect(t *testing.T) {
+	api := newTestAPI(t)
+	viewer := api.signUp("walt@grey.com", "heisenberg")
+	blocked := api.signUp("tuco@salamanca.com", "tight")
+	friend := api.signUp("jesse@pinkman.com", "yo")
+	srv := httptest.NewServer(api.handler)
+	defer srv.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/ws?access_token=" + viewer.Token
+	conn, _, err := websocket.DefaultDialer.DialContext(t.Context(), wsURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err := conn.WriteJSON(wsClientMessage{Type: "subscribe", Channel: wsChannelChirps}); err != nil {
+		t.Fatal(err)
+	}
+	var msg wsServerMessage
+	if err := conn.ReadJSON(&msg); err != nil || msg.Type != "subscribed" {
+		t.Fatalf("got %+v, %v; want subscribed", msg, err)
+	}
+
+	// Blocked after the connection opened, so only a per-event check hides it
+	expectStatus(t, api.do("POST", "/api/blocks", viewer.Token, map[string]uuid.UUID{"user_id": blocked.ID}), http.StatusNoContent)
+	api.postChirp(blocked.Token, "Tight tight tight")
+	want := api.postChirp(friend.Token, "Yeah science")
+	if err := api.cfg.chirpEvents.catchUp(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	var event struct {
+		Type string `json:"type"`
+		Data Chirp  `json:"data"`
+	}
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != "event" || event.Data.ID != want.ID {
+		t.Errorf("got %+v, want the friend's chirp", event)
+	}
+}
+
+func TestBlocksAndMutesFilterReads(t *testing.T) {
+	api := newTestAPI(t)
+	viewer := api.signUp("saul@goodman.com", "better")
+	blocker := api.signUp("lalo@salamanca.com", "hi")
+	blocked := api.signUp("nacho@varga.com", "hands")
+	muted := api.signUp("howard@hhm.com", "bowling")
+	friend := api.signUp("kim@wexler.com", "jazz")
+
+	blockerChirp := api.postChirp(blocker.Token, "Hi Saul")
+	blockedChirp := api.postChirp(blocked.Token, "Keep your hands where I can see them")
+	api.postChirp(muted.Token, "Bowling tonight")
+	api.postChirp(friend.Token, "Cigarette on the curb")
+	friendKeyword := api.postChirp(friend.Token, "Another Spoiler for the case")
+
+	expectStatus(t, api.do("POST", "/api/blocks", blocker.Token, map[string]uuid.UUID{"user_id": viewer.ID}), http.StatusNoContent)
+	expectStatus(t, api.do("POST", "/api/blocks", viewer.Token, map[string]uuid.UUID{"user_id": blocked.ID}), http.StatusNoContent)
+	expectStatus(t, api.do("POST", "/api/mutes", viewer.Token, map[string]uuid.UUID{"user_id": muted.ID}), http.StatusNoContent)
+	expectStatus(t, api.do("POST", "/api/mutes/keywords", viewer.Token, map[string]string{"keyword": "spoiler"}), http.StatusCreated)
+
+	tests := []struct {
+		name  string
+		token string
+		want  []string
+	}{
+		{
+			name:  "Viewer sees neither side of a block, nor mutes",
+			token: viewer.Token,
+			want:  []string{"Cigarette on the curb"},
+		},
+		{
+			name:  "Anonymous readers see everything",
+			token: "",
+			want: []string{
+				"Hi Saul", "Keep your hands where I can see them", "Bowling tonight",
+				"Cigarette on the curb", "Another Spoiler for the case",
+			},
+		},
+		{
+			name:  "Mutes are the muter's own",
+			token: friend.Token,
+			want: []string{
+				"Hi Saul", "Keep your hands where I can see them", "Bowling tonight",
+				"Cigarette on the curb", "Another Spoiler for the case",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := api.do("GET", "/api/chirps", tt.token, nil)
+			expectStatus(t, rec, http.StatusOK)
+			var bodies []string
+			for _, chirp := range decode[[]Chirp](t, rec) {
+				bodies = append(bodies, chirp.Body)
+			}
+			if !slices.Equal(bodies, tt.want) {
+				t.Errorf("chirps = %q, want %q", bodies, tt.want)
+			}
+		})
+	}
+
+	// Blocks hide single chirps both ways, mutes only filter listings
+	expectStatus(t, api.do("GET", "/api/chirps/"+blockerChirp.ID.String(), viewer.Token, nil), http.StatusNotFound)
+	expectStatus(t, api.do("GET", "/api/chirps/"+blockedChirp.ID.String(), viewer.Token, nil), http.StatusNotFound)
+	expectStatus(t, api.do("GET", "/api/chirps/"+friendKeyword.ID.String(), viewer.Token, nil), http.StatusOK)
+}
+
+func TestBlocksAndMutesManage(t *testing.T) {
+	api := newTestAPI(t)
+	saul := api.signUp("saul@goodman.com", "better")
+	lalo := api.signUp("lalo@salamanca.com", "hi")
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"Blocks", "/api/blocks"},
+		{"Mutes", "/api/mutes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list := func() []uuid.UUID {
+				t.Helper()
+				rec := api.do("GET", tt.path, saul.Token, nil)
+				expectStatus(t, rec, http.StatusOK)
+				var ids []uuid.UUID
+				for _, entry := range decode[[]struct {
+					UserID uuid.UUID `json:"user_id"`
+				}](t, rec) {
+					ids = append(ids, entry.UserID)
+				}
+				return ids
+			}
+
+			expectStatus(t, api.do("POST", tt.path, saul.Token, map[string]uuid.UUID{"user_id": saul.ID}), http.StatusBadRequest)
+			expectStatus(t, api.do("POST", tt.path, saul.Token, map[string]uuid.UUID{"user_id": uuid.New()}), http.StatusNotFound)
+			expectStatus(t, api.do("POST", tt.path, saul.Token, map[string]uuid.UUID{"user_id": lalo.ID}), http.StatusNoContent)
+			// Repeating it is harmless
+			expectStatus(t, api.do("POST", tt.path, saul.Token, map[string]uuid.UUID{"user_id": lalo.ID}), http.StatusNoContent)
+			if got := list(); !slices.Equal(got, []uuid.UUID{lalo.ID}) {
+				t.Errorf("list = %v, want just lalo", got)
+			}
+
+			expectStatus(t, api.do("DELETE", tt.path+"/nope", saul.Token, nil), http.StatusBadRequest)
+			expectStatus(t, api.do("DELETE", tt.path+"/"+lalo.ID.String(), saul.Token, nil), http.StatusNoContent)
+			expectStatus(t, api.do("DELETE", tt.path+"/"+lalo.ID.String(), saul.Token, nil), http.StatusNotFound)
+			if got := list(); len(got) != 0 {
+				t.Errorf("list after deleting = %v, want none", got)
+			}
+		})
+	}
+}
+
+func TestMutedKeywordsCreate(t *testing.T) {
+	api := newTestAPI(t)
+	login := api.signUp("mike@ehrmantraut.com", "halfmeasures")
+
+	rec := api.do("POST", "/api/mutes/keywords", login.Token, map[string]string{"keyword": "  Kaylee "})
+	expectStatus(t, rec, http.StatusCreated)
+	if keyword := decode[MutedKeyword](t, rec); keyword.Keyword != "kaylee" {
+		t.Errorf("keyword = %q, want it trimmed and lowercased", keyword.Keyword)
+	}
+
+	rec = api.do("POST", "/api/mutes/keywords", login.Token, map[string]string{"keyword": "KAYLEE"})
+	expectStatus(t, rec, http.StatusConflict)
+}
+
+func TestMutedKeywordsDelete(t *testing.T) {
+	api := newTestAPI(t)
+	mike := api.signUp("mike@ehrmantraut.com", "halfmeasures")
+	gus := api.signUp("gus@pollos.com", "chicken")
+
+	rec := api.do("POST", "/api/mutes/keywords", mike.Token, map[string]string{"keyword": "kaylee"})
+	expectStatus(t, rec, http.StatusCreated)
+	path := "/api/mutes/keywords/" + decode[MutedKeyword](t, rec).ID.String()
+
+	// Only the owner can see or delete it
+	rec = api.do("GET", "/api/mutes/keywords", gus.Token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if keywords := decode[[]MutedKeyword](t, rec); len(keywords) != 0 {
+		t.Errorf("another user's keywords = %+v, want none", keywords)
+	}
+	expectStatus(t, api.do("DELETE", path, gus.Token, nil), http.StatusNotFound)
+
+	expectStatus(t, api.do("DELETE", path, mike.Token, nil), http.StatusNoContent)
+	expectStatus(t, api.do("DELETE", path, mike.Token, nil), http.StatusNotFound)
+	rec = api.do("GET", "/api/mutes/keywords", mike.Token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if keywords := decode[[]MutedKeyword](t, rec); len(keywords) != 0 {
+		t.Errorf("keywords after deleting = %+v, want none", keywords)
+	}
+}
+
+// failingStore fails chosen queries, inside transactions too
+type failingStore struct {
+	database.Store
+	failMember          uuid.UUID
+	failTouch           bool
+	failGetChirp        bool
+	failGetUser         bool
+	failGetConversation bool
+	failGetWebhook      bool
+}
+
+var errInjected = errors.New("injected failure")
+
+func (s failingStore) InTx(ctx context.Context, fn func(q database.Store) error) error {
+	return s.Store.InTx(ctx, func(q database.Store) error {
+		s.Store = q
+		return fn(s)
+	})
+}
+
+func (s failingStore) GetChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	if s.failGetChirp {
+		return database.Chirp{}, errInjected
+	}
+	return s.Store.GetChirp(ctx, id)
+}
+
+func (s failingStore) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	if s.failGetUser {
+		return database.User{}, errInjected
+	}
+	return s.Store.GetUserByID(ctx, id)
+}
+
+func (s failingStore) GetConversationForUser(ctx context.Context, arg database.GetConversationForUserParams) (database.GetConversationForUserRow, error) {
+	if s.failGetConversation {
+		return database.GetConversationForUserRow{}, errInjected
+	}
+	return s.Store.GetConversationForUser(ctx, arg)
+}
+
+func (s failingStore) GetWebhookSubscription(ctx context.Context, arg database.GetWebhookSubscriptionParams) (database.WebhookSubscription, error) {
+	if s.failGetWebhook {
+		return database.WebhookSubscription{}, errInjected
+	}
+	return s.Store.GetWebhookSubscription(ctx, arg)
+}
+
+func (s failingStore) AddConversationMember(ctx context.Context, arg database.AddConversationMemberParams) error {
+	if arg.UserID == s.failMember {
+		return errInjected
+	}
+	return s.Store.AddConversationMember(ctx, arg)
+}
+
+func (s failingStore) TouchConversation(ctx context.Context, id uuid.UUID) error {
+	if s.failTouch {
+		return errInjected
+	}
+	return s.Store.TouchConversation(ctx, id)
+}
+
+func TestConversationWritesAreAtomic(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.signUp("skyler@white.com", "carwash")
+	bob := api.signUp("marie@schrader.com", "purple")
+
+	// The creator is added first, so failing on bob leaves a half-made
+	// conversation unless it's rolled back
+	api.cfg.store = failingStore{Store: api.store, failMember: bob.ID}
+	rec := api.do("POST", "/api/conversations", alice.Token, map[string][]uuid.UUID{"user_ids": {bob.ID}})
+	expectStatus(t, rec, http.StatusInternalServerError)
+	rec = api.do("GET", "/api/conversations", alice.Token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if conversations := decode[[]Conversation](t, rec); len(conversations) != 0 {
+		t.Fatalf("conversations after a failed create = %+v, want none", conversations)
+	}
+
+	api.cfg.store = api.store
+	rec = api.do("POST", "/api/conversations", alice.Token, map[string][]uuid.UUID{"user_ids": {bob.ID}})
+	expectStatus(t, rec, http.StatusCreated)
+	conversation := decode[Conversation](t, rec)
+	messagesPath := "/api/conversations/" + conversation.ID.String() + "/messages"
+
+	api.cfg.store = failingStore{Store: api.store, failTouch: true}
+	rec = api.do("POST", messagesPath, alice.Token, map[string]string{"body": "We need to talk"})
+	expectStatus(t, rec, http.StatusInternalServerError)
+
+	api.cfg.store = api.store
+	rec = api.do("GET", messagesPath, bob.Token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if messages := decode[[]Message](t, rec); len(messages) != 0 {
+		t.Errorf("messages after a failed send = %+v, want none", messages)
+	}
+
+	rec = api.do("POST", messagesPath, alice.Token, map[string]string{"body": "We need to talk"})
+	expectStatus(t, rec, http.StatusCreated)
+	rec = api.do("GET", "/api/conversations/"+conversation.ID.String(), alice.Token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if got := decode[Conversation](t, rec); got.UnreadCount != 0 || !got.UpdatedAt.After(conversation.UpdatedAt) {
+		t.Errorf("conversation after sending = %+v; want it read by the sender and updated", got)
+	}
+}
+
+func TestMessagesPagination(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.signUp("skyler@white.com", "carwash")
+	bob := api.signUp("marie@schrader.com", "purple")
+
+	rec := api.do("POST", "/api/conversations", alice.Token, map[string][]uuid.UUID{"user_ids": {bob.ID}})
+	expectStatus(t, rec, http.StatusCreated)
+	conversation := decode[Conversation](t, rec)
+	messagesPath := "/api/conversations/" + conversation.ID.String() + "/messages"
+
+	ids := map[string]uuid.UUID{}
+	for _, body := range []string{"1", "2", "3", "4", "5"} {
+		rec := api.do("POST", messagesPath, alice.Token, map[string]string{"body": body})
+		expectStatus(t, rec, http.StatusCreated)
+		ids[body] = decode[Message](t, rec).ID
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		want       []string
+	}{
+		{"Newest first", "", http.StatusOK, []string{"5", "4", "3", "2", "1"}},
+		{"Limit", "?limit=2", http.StatusOK, []string{"5", "4"}},
+		{"Before", "?limit=2&before=" + ids["4"].String(), http.StatusOK, []string{"3", "2"}},
+		{"Last page", "?limit=2&before=" + ids["2"].String(), http.StatusOK, []string{"1"}},
+		{"Before the first", "?before=" + ids["1"].String(), http.StatusOK, []string{}},
+		{"Limit too small", "?limit=0", http.StatusBadRequest, nil},
+		{"Limit too large", "?limit=101", http.StatusBadRequest, nil},
+		{"Unparseable before", "?before=yesterday", http.StatusBadRequest, nil},
+		{"Before from elsewhere", "?before=" + uuid.NewString(), http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := api.do("GET", messagesPath+tt.query, bob.Token, nil)
+			expectStatus(t, rec, tt.wantStatus)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			got := []string{}
+			for _, message := range decode[[]Message](t, rec) {
+				got = append(got, message.Body)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("messages = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessagesUnreadCount(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.signUp("skyler@white.com", "carwash")
+	bob := api.signUp("marie@schrader.com", "purple")
+
+	rec := api.do("POST", "/api/conversations", alice.Token, map[string][]uuid.UUID{"user_ids": {bob.ID}})
+	expectStatus(t, rec, http.StatusCreated)
+	conversationPath := "/api/conversations/" + decode[Conversation](t, rec).ID.String()
+
+	send := func(token, body string) {
+		t.Helper()
+		expectStatus(t, api.do("POST", conversationPath+"/messages", token, map[string]string{"body": body}), http.StatusCreated)
+	}
+	unread := func(token string) int64 {
+		t.Helper()
+		rec := api.do("GET", conversationPath, token, nil)
+		expectStatus(t, rec, http.StatusOK)
+		return decode[Conversation](t, rec).UnreadCount
+	}
+
+	send(alice.Token, "We need to talk")
+	send(alice.Token, "It's about Hank")
+	if got := unread(bob.Token); got != 2 {
+		t.Errorf("bob's unread count = %d, want 2", got)
+	}
+	if got := unread(alice.Token); got != 0 {
+		t.Errorf("alice's unread count = %d, want 0 for her own messages", got)
+	}
+
+	expectStatus(t, api.do("POST", conversationPath+"/read", bob.Token, nil), http.StatusNoContent)
+	if got := unread(bob.Token); got != 0 {
+		t.Errorf("bob's unread count after reading = %d, want 0", got)
+	}
+
+	send(alice.Token, "Call me back")
+	send(bob.Token, "What about him?")
+	if got := unread(bob.Token); got != 0 {
+		t.Errorf("bob's unread count after replying = %d, want 0", got)
+	}
+	if got := unread(alice.Token); got != 1 {
+		t.Errorf("alice's unread count = %d, want 1", got)
+	}
+}
+
+func TestMessagesBlockedMembers(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.signUp("skyler@white.com", "carwash")
+	bob := api.signUp("marie@schrader.com", "purple")
+	carol := api.signUp("lydia@madrigal.com", "stevia")
+
+	rec := api.do("POST", "/api/conversations", alice.Token, map[string][]uuid.UUID{"user_ids": {bob.ID}})
+	expectStatus(t, rec, http.StatusCreated)
+	messagesPath := "/api/conversations/" + decode[Conversation](t, rec).ID.String() + "/messages"
+
+	// Carol blocked alice, so alice can't start a conversation with her
+	expectStatus(t, api.do("POST", "/api/blocks", carol.Token, map[string]uuid.UUID{"user_id": alice.ID}), http.StatusNoContent)
+	rec = api.do("POST", "/api/conversations", alice.Token, map[string][]uuid.UUID{"user_ids": {carol.ID}})
+	expectStatus(t, rec, http.StatusForbidden)
+
+	// A block in either direction stops both sides of an existing
+	// conversation
+	expectStatus(t, api.do("POST", "/api/blocks", bob.Token, map[string]uuid.UUID{"user_id": alice.ID}), http.StatusNoContent)
+	for _, login := range []loginResponse{alice, bob} {
+		rec := api.do("POST", messagesPath, login.Token, map[string]string{"body": "Hello?"})
+		expectStatus(t, rec, http.StatusForbidden)
+	}
+
+	expectStatus(t, api.do("DELETE", "/api/blocks/"+alice.ID.String(), bob.Token, nil), http.StatusNoContent)
+	rec = api.do("POST", messagesPath, alice.Token, map[string]string{"body": "Hello?"})
+	expectStatus(t, rec, http.StatusCreated)
+}
+
+func TestStoreErrorsAreNotMissingRows(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.signUp("skyler@white.com", "carwash")
+	bob := api.signUp("marie@schrader.com", "purple")
+
+	rec := api.do("POST", "/api/conversations", alice.Token, map[string][]uuid.UUID{"user_ids": {bob.ID}})
+	expectStatus(t, rec, http.StatusCreated)
+	conversation := decode[Conversation](t, rec)
+	messagesPath := "/api/conversations/" + conversation.ID.String() + "/messages"
+	webhookPath := "/api/webhooks/" + uuid.NewString()
+
+	tests := []struct {
+		name   string
+		store  failingStore
+		method string
+		path   string
+		body   any
+	}{
+		{"Block", failingStore{failGetUser: true}, "POST", "/api/blocks", map[string]uuid.UUID{"user_id": bob.ID}},
+		{"Mute", failingStore{failGetUser: true}, "POST", "/api/mutes", map[string]uuid.UUID{"user_id": bob.ID}},
+		{"Send message", failingStore{failGetConversation: true}, "POST", messagesPath, map[string]string{"body": "Say my name"}},
+		{"List messages", failingStore{failGetConversation: true}, "GET", messagesPath, nil},
+		{"Mark read", failingStore{failGetConversation: true}, "POST", "/api/conversations/" + conversation.ID.String() + "/read", nil},
+		{"Start conversation", failingStore{failGetUser: true}, "POST", "/api/conversations", map[string][]uuid.UUID{"user_ids": {bob.ID}}},
+		{"List deliveries", failingStore{failGetWebhook: true}, "GET", webhookPath + "/deliveries", nil},
+		{"Redeliver", failingStore{failGetWebhook: true}, "POST", webhookPath + "/deliveries/" + uuid.NewString() + "/redeliver", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.store.Store = api.store
+			api.cfg.store = tt.store
+			defer func() { api.cfg.store = api.store }()
+
+			rec := api.do(tt.method, tt.path, alice.Token, tt.body)
+			expectStatus(t, rec, http.StatusInternalServerError)
+		})
+	}
+}
+
+func (api *testAPI) notificationGroups(token string) []NotificationGroup {
+	api.t.Helper()
+
+	rec := api.do("GET", "/api/notifications", token, nil)
+	expectStatus(api.t, rec, http.StatusOK)
+	return decode[struct {
+		Notifications []NotificationGroup `json:"notifications"`
+	}](api.t, rec).Notifications
+}
+
+func TestNotify(t *testing.T) {
+	api := newTestAPI(t)
+	walt := api.signUp("walt@breakingbad.com", "heisenberg")
+	jesse := api.signUp("jesse@breakingbad.com", "yo")
+	gus := api.signUp("gus@pollos.com", "chicken")
+
+	sub := api.cfg.notifications.subscribe(nil)
+	defer api.cfg.notifications.unsubscribe(sub)
+
+	chirp := api.postChirp(walt.Token, "Say my name")
+	rec := api.do("POST", "/api/chirps", jesse.Token, map[string]interface{}{
+		"body":        "Heisenberg, cc @gus@pollos.com @walt@breakingbad.com",
+		"reply_to_id": chirp.ID,
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	reply := decode[Chirp](t, rec)
+	if reply.ReplyToID == nil || *reply.ReplyToID != chirp.ID {
+		t.Errorf("reply_to_id = %v, want %v", reply.ReplyToID, chirp.ID)
+	}
+
+	expectStatus(t, api.do("POST", "/api/chirps/"+chirp.ID.String()+"/likes", jesse.Token, nil), http.StatusNoContent)
+	// Liking again, or your own chirp, notifies nobody
+	expectStatus(t, api.do("POST", "/api/chirps/"+chirp.ID.String()+"/likes", jesse.Token, nil), http.StatusNoContent)
+	expectStatus(t, api.do("POST", "/api/chirps/"+chirp.ID.String()+"/likes", walt.Token, nil), http.StatusNoContent)
+	expectStatus(t, api.do("POST", "/api/follows", gus.Token, map[string]uuid.UUID{"user_id": walt.ID}), http.StatusNoContent)
+
+	type want struct {
+		notificationType string
+		actors           []uuid.UUID
+	}
+	summarize := func(groups []NotificationGroup) []want {
+		var got []want
+		for _, group := range groups {
+			got = append(got, want{group.Type, group.ActorIDs})
+		}
+		return got
+	}
+	equal := func(a, b want) bool {
+		return a.notificationType == b.notificationType && slices.Equal(a.actors, b.actors)
+	}
+
+	// walt was mentioned too, but the reply already told him
+	got := summarize(api.notificationGroups(walt.Token))
+	wantWalt := []want{
+		{notificationTypeFollow, []uuid.UUID{gus.ID}},
+		{notificationTypeLike, []uuid.UUID{jesse.ID}},
+		{notificationTypeReply, []uuid.UUID{jesse.ID}},
+	}
+	if !slices.EqualFunc(got, wantWalt, equal) {
+		t.Errorf("walt's notifications = %+v, want %+v", got, wantWalt)
+	}
+	got = summarize(api.notificationGroups(gus.Token))
+	wantGus := []want{{notificationTypeMention, []uuid.UUID{jesse.ID}}}
+	if !slices.EqualFunc(got, wantGus, equal) {
+		t.Errorf("gus's notifications = %+v, want %+v", got, wantGus)
+	}
+
+	// Each one is published once it's committed
+	published := 0
+	for len(sub.events) > 0 {
+		<-sub.events
+		published++
+	}
+	if published != 4 {
+		t.Errorf("published %d notifications, want 4", published)
+	}
+
+	rec = api.do("POST", "/api/chirps", jesse.Token, map[string]interface{}{"body": "Hello?", "reply_to_id": uuid.New()})
+	expectStatus(t, rec, http.StatusNotFound)
+	expectStatus(t, api.do("POST", "/api/chirps/"+uuid.NewString()+"/likes", jesse.Token, nil), http.StatusNotFound)
+	expectStatus(t, api.do("POST", "/api/follows", jesse.Token, map[string]uuid.UUID{"user_id": jesse.ID}), http.StatusBadRequest)
+}
+
+func TestNotificationPreferencesFilter(t *testing.T) {
+	api := newTestAPI(t)
+	walt := api.signUp("walt@breakingbad.com", "heisenberg")
+	jesse := api.signUp("jesse@breakingbad.com", "yo")
+	chirp := api.postChirp(walt.Token, "Say my name")
+
+	rec := api.do("PUT", "/api/notifications/preferences", walt.Token, map[string]bool{notificationTypeLike: false})
+	expectStatus(t, rec, http.StatusOK)
+
+	expectStatus(t, api.do("POST", "/api/chirps/"+chirp.ID.String()+"/likes", jesse.Token, nil), http.StatusNoContent)
+	expectStatus(t, api.do("POST", "/api/follows", jesse.Token, map[string]uuid.UUID{"user_id": walt.ID}), http.StatusNoContent)
+
+	groups := api.notificationGroups(walt.Token)
+	if len(groups) != 1 || groups[0].Type != notificationTypeFollow {
+		t.Errorf("notifications = %+v, want only the follow", groups)
+	}
+
+	// Blocked users can't notify either
+	expectStatus(t, api.do("POST", "/api/blocks", walt.Token, map[string]uuid.UUID{"user_id": jesse.ID}), http.StatusNoContent)
+	api.postChirp(jesse.Token, "@walt@breakingbad.com yo")
+	if groups := api.notificationGroups(walt.Token); len(groups) != 1 {
+		t.Errorf("notifications after a block = %+v, want only the follow", groups)
+	}
+}
+
+// polkaWebhook sends event for userID, signed with secret if set and
+// otherwise authenticated with apiKey
+func (api *testAPI) polkaWebhook(event, eventID string, userID uuid.UUID, secret, apiKey string) *httptest.ResponseRecorder {
+	api.t.Helper()
+
+	body, err := json.Marshal(map[string]interface{}{
+		"id":    eventID,
+		"event": event,
+		"data":  map[string]uuid.UUID{"user_id": userID},
+	})
+	if err != nil {
+		api.t.Fatal(err)
+	}
+	req := httptest.NewRequest("POST", "/api/polka/webhooks", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		timestamp := time.Now().Unix()
+		req.Header.Set(polkaTimestampHeader, strconv.FormatInt(timestamp, 10))
+		req.Header.Set(polkaSignatureHeader, auth.SignWebhook(secret, timestamp, body))
+	}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+apiKey)
+	}
+	rec := httptest.NewRecorder()
+	api.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestNotificationsRead(t *testing.T) {
+	api := newTestAPI(t)
+	walt := api.signUp("walt@breakingbad.com", "heisenberg")
+	jesse := api.signUp("jesse@breakingbad.com", "yo")
+	chirp := api.postChirp(walt.Token, "Say my name")
+	expectStatus(t, api.do("POST", "/api/chirps/"+chirp.ID.String()+"/likes", jesse.Token, nil), http.StatusNoContent)
+	expectStatus(t, api.do("POST", "/api/follows", jesse.Token, map[string]uuid.UUID{"user_id": walt.ID}), http.StatusNoContent)
+
+	type listResponse struct {
+		UnreadCount   int64               `json:"unread_count"`
+		Notifications []NotificationGroup `json:"notifications"`
+	}
+	list := func(query string) listResponse {
+		t.Helper()
+		rec := api.do("GET", "/api/notifications"+query, walt.Token, nil)
+		expectStatus(t, rec, http.StatusOK)
+		return decode[listResponse](t, rec)
+	}
+	types := func(groups []NotificationGroup) []string {
+		var got []string
+		for _, group := range groups {
+			got = append(got, group.Type)
+		}
+		slices.Sort(got)
+		return got
+	}
+
+	all := list("")
+	if all.UnreadCount != 2 || !slices.Equal(types(all.Notifications), []string{notificationTypeFollow, notificationTypeLike}) {
+		t.Fatalf("notifications = %+v, want an unread follow and like", all)
+	}
+	var like NotificationGroup
+	for _, group := range all.Notifications {
+		if group.Type == notificationTypeLike {
+			like = group
+		}
+	}
+	likePath := "/api/notifications/" + like.NotificationIDs[0].String() + "/read"
+
+	expectStatus(t, api.do("POST", likePath, jesse.Token, nil), http.StatusNotFound)
+	expectStatus(t, api.do("POST", "/api/notifications/"+uuid.NewString()+"/read", walt.Token, nil), http.StatusNotFound)
+	expectStatus(t, api.do("POST", "/api/notifications/nope/read", walt.Token, nil), http.StatusBadRequest)
+
+	expectStatus(t, api.do("POST", likePath, walt.Token, nil), http.StatusNoContent)
+	unread := list("?unread=true")
+	if unread.UnreadCount != 1 || !slices.Equal(types(unread.Notifications), []string{notificationTypeFollow}) {
+		t.Errorf("unread notifications = %+v, want just the follow", unread)
+	}
+
+	expectStatus(t, api.do("POST", "/api/notifications/read", walt.Token, nil), http.StatusNoContent)
+	if unread := list("?unread=true"); unread.UnreadCount != 0 || len(unread.Notifications) != 0 {
+		t.Errorf("unread notifications after reading all = %+v, want none", unread)
+	}
+	for _, group := range list("").Notifications {
+		if !group.Read {
+			t.Errorf("group %+v is still unread", group)
+		}
+	}
+}
+
+func TestPolkaWebhookAuth(t *testing.T) {
+	tests := []struct {
+		name        string
+		apiKey      string
+		secrets     []string
+		allowAPIKey bool
+		event       string
+		eventID     string
+		signWith    string
+		sendAPIKey  string
+		want        int
+	}{
+		{"Signed", "", []string{"whsec"}, false, polkaEventUpgraded, "evt_1", "whsec", "", http.StatusNoContent},
+		{"Bad signature", "", []string{"whsec"}, false, polkaEventUpgraded, "evt_1", "other", "", http.StatusUnauthorized},
+		{"Signed without an event id", "", []string{"whsec"}, false, polkaEventUpgraded, "", "whsec", "", http.StatusBadRequest},
+		{"API key before signing", "key", nil, false, polkaEventUpgraded, "evt_1", "", "key", http.StatusNoContent},
+		{"API key without an event id", "key", nil, false, polkaEventUpgraded, "", "", "key", http.StatusNoContent},
+		{"API key once signing", "key", []string{"whsec"}, false, polkaEventUpgraded, "evt_1", "", "key", http.StatusUnauthorized},
+		{"API key kept explicitly", "key", []string{"whsec"}, true, polkaEventUpgraded, "evt_1", "", "key", http.StatusNoContent},
+		{"Ignored event", "", []string{"whsec"}, false, "user.payment_method_updated", "", "whsec", "", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI(t)
+			api.cfg.polkaSecret = tt.apiKey
+			api.cfg.polkaWebhookSecrets = tt.secrets
+			api.cfg.polkaAllowAPIKey = tt.allowAPIKey
+			login := api.signUp("walt@breakingbad.com", "heisenberg")
+
+			rec := api.polkaWebhook(tt.event, tt.eventID, login.ID, tt.signWith, tt.sendAPIKey)
+			expectStatus(t, rec, tt.want)
+		})
+	}
+}
+
+func TestPolkaWebhookReplay(t *testing.T) {
+	tests := []struct {
+		name    string
+		eventID string
+	}{
+		{"By event id", "evt_1"},
+		// Legacy requests have no ID, so the body is the key
+		{"Without an event id", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI(t)
+			api.cfg.polkaSecret = "key"
+			login := api.signUp("walt@breakingbad.com", "heisenberg")
+
+			for range 2 {
+				expectStatus(t, api.polkaWebhook(polkaEventUpgraded, tt.eventID, login.ID, "", "key"), http.StatusNoContent)
+			}
+			upgrades := 0
+			for _, eventType := range api.outboxEventTypes() {
+				if eventType == eventUserUpgraded {
+					upgrades++
+				}
+			}
+			if upgrades != 1 {
+				t.Errorf("%d upgrades recorded, want the replay ignored", upgrades)
+			}
+		})
+	}
+}
@@ -1,13 +1,15 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/exglegaming/Chirpy/internal/auth"
 	"github.com/exglegaming/Chirpy/internal/database"
 	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type User struct {
@@ -28,37 +30,71 @@ func (cfg *apiConfig) handlerUsersCreate(w http.ResponseWriter, r *http.Request)
 		User
 	}
 
-	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err := decoder.Decode(&params)
+	err := decodeJSON(w, r, &params, decodeOptions{MaxBytes: smallBodyBytes})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithDecodeError(w, err)
 		return
 	}
 
-	// Hash the password
-	hashedPassword, err := auth.HashPassword(params.Password)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't hash password", err)
+	created, err := cfg.createUser(r.Context(), params.Email, params.Password)
+	if database.IsUniqueViolation(err) {
+		respondWithAPIError(w, emailTaken(), err)
+		return
+	}
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		respondWithValidationError(w, errPasswordTooLong)
 		return
 	}
-
-	user, err := cfg.db.CreateUser(r.Context(), database.CreateUserParams{
-		Email:          params.Email,
-		HashedPassword: hashedPassword,
-	})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't create user", err)
 		return
 	}
 
 	respondWithJSON(w, http.StatusCreated, response{
-		User: User{
+		User: created,
+	})
+}
+
+// bcrypt only looks at the first 72 bytes of a password, so longer ones
+// are refused rather than quietly truncated
+var errPasswordTooLong = &fieldError{Field: "password", Code: fieldTooLong, Message: "Password must be at most 72 bytes"}
+
+// emailTaken is the error for signing up with, or changing to, an email
+// another user has
+func emailTaken() apiError {
+	return apiError{
+		Status: http.StatusConflict,
+		Code:   codeEmailTaken,
+		Detail: "Email is already in use",
+		Fields: []fieldError{{Field: "email", Code: codeEmailTaken, Message: "is already in use"}},
+	}
+}
+
+// createUser is shared by the signup handler and "chirpy user create"
+func (cfg *apiConfig) createUser(ctx context.Context, email, password string) (User, error) {
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return User{}, err
+	}
+
+	var created User
+	err = cfg.withTx(ctx, func(q database.Store) error {
+		user, err := q.CreateUser(ctx, database.CreateUserParams{
+			Email:          email,
+			HashedPassword: hashedPassword,
+		})
+		if err != nil {
+			return err
+		}
+		created = User{
 			ID:          user.ID,
 			CreatedAt:   user.CreatedAt,
 			UpdatedAt:   user.UpdatedAt,
 			Email:       user.Email,
 			IsChirpyRed: user.IsChirpyRed,
-		},
+		}
+		return recordEvent(ctx, q, eventUserCreated, created)
 	})
+	return created, err
 }
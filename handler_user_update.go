@@ -1,11 +1,12 @@
 package main
 
 import (
-	"context"
-	"encoding/json"
+	"errors"
+	"net/http"
+
 	"github.com/exglegaming/Chirpy/internal/auth"
 	"github.com/exglegaming/Chirpy/internal/database"
-	"net/http"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func (cfg *apiConfig) handlerUserUpdate(w http.ResponseWriter, r *http.Request) {
@@ -18,47 +19,53 @@ func (cfg *apiConfig) handlerUserUpdate(w http.ResponseWriter, r *http.Request)
 		User
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
-		return
-	}
-
-	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
-		return
-	}
+	userID := requestPrincipal(r).UserID
 
-	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err = decoder.Decode(&params)
+	err := decodeJSON(w, r, &params, decodeOptions{MaxBytes: smallBodyBytes, DisallowUnknownFields: true})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithDecodeError(w, err)
 		return
 	}
 
 	hashedPass, err := auth.HashPassword(params.Password)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		respondWithValidationError(w, errPasswordTooLong)
+		return
+	}
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't hash password", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't hash password", err)
 		return
 	}
 
-	user, err := cfg.db.UpdateUser(context.Background(), database.UpdateUserParams{
-		ID:             userID,
-		Email:          params.Email,
-		HashedPassword: hashedPass,
+	var updated User
+	err = cfg.withTx(r.Context(), func(q database.Store) error {
+		user, err := q.UpdateUser(r.Context(), database.UpdateUserParams{
+			ID:             userID,
+			Email:          params.Email,
+			HashedPassword: hashedPass,
+		})
+		if err != nil {
+			return err
+		}
+		updated = User{
+			ID:        userID,
+			CreatedAt: user.CreatedAt,
+			UpdatedAt: user.UpdatedAt,
+			Email:     user.Email,
+		}
+		return recordEvent(r.Context(), q, eventUserUpdated, updated)
 	})
+	if database.IsUniqueViolation(err) {
+		respondWithAPIError(w, emailTaken(), err)
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't update user", err)
+		return
 	}
 
 	respondWithJSON(w, http.StatusOK, response{
-		User: User{
-			ID:        userID,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
-			Email:     user.Email,
-		},
+		User: updated,
 	})
 }
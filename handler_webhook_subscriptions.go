@@ -0,0 +1,282 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/auth"
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type WebhookSubscription struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	Active     bool      `json:"active"`
+	// Secret is only returned when the subscription is created
+	Secret string `json:"secret,omitempty"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	CreatedAt      time.Time       `json:"created_at"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty"`
+	LastStatusCode *int32          `json:"last_status_code,omitempty"`
+	LastError      *string         `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+func (cfg *apiConfig) handlerWebhookSubscriptionsCreate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		URL        string   `json:"url"`
+		EventTypes []string `json:"event_types"`
+		Secret     string   `json:"secret"`
+	}
+
+	userID := requestPrincipal(r).UserID
+
+	params := parameters{}
+	err := decodeJSON(w, r, &params, decodeOptions{DisallowUnknownFields: true})
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	target, err := validateWebhookSubscription(params.URL, params.EventTypes, cfg.platform == "dev")
+	if err != nil {
+		respondWithValidationError(w, err)
+		return
+	}
+
+	secret := params.Secret
+	if secret == "" {
+		secret, err = auth.MakeWebhookSecret()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't create webhook secret", err)
+			return
+		}
+	}
+
+	subscription, err := cfg.store.CreateWebhookSubscription(r.Context(), database.CreateWebhookSubscriptionParams{
+		UserID:     userID,
+		Url:        target.String(),
+		EventTypes: params.EventTypes,
+		Secret:     secret,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create webhook subscription", err)
+		return
+	}
+
+	response := webhookSubscriptionFromDB(subscription)
+	response.Secret = subscription.Secret
+	respondWithJSON(w, http.StatusCreated, response)
+}
+
+func (cfg *apiConfig) handlerWebhookSubscriptionsList(w http.ResponseWriter, r *http.Request) {
+	userID := requestPrincipal(r).UserID
+
+	subscriptions, err := cfg.store.GetWebhookSubscriptionsByUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get webhook subscriptions", err)
+		return
+	}
+
+	subscriptionList := []WebhookSubscription{}
+	for _, subscription := range subscriptions {
+		subscriptionList = append(subscriptionList, webhookSubscriptionFromDB(subscription))
+	}
+	respondWithJSON(w, http.StatusOK, subscriptionList)
+}
+
+func (cfg *apiConfig) handlerWebhookSubscriptionsDelete(w http.ResponseWriter, r *http.Request) {
+	subscriptionID, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid webhook ID", err)
+		return
+	}
+
+	userID := requestPrincipal(r).UserID
+
+	deleted, err := cfg.store.DeleteWebhookSubscription(r.Context(), database.DeleteWebhookSubscriptionParams{
+		ID:     subscriptionID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete webhook subscription", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Couldn't find webhook subscription", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerWebhookDeliveriesList(w http.ResponseWriter, r *http.Request) {
+	const defaultLimit = 50
+	const maxLimit = 200
+
+	subscriptionID, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid webhook ID", err)
+		return
+	}
+
+	userID := requestPrincipal(r).UserID
+
+	limit := defaultLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxLimit {
+			respondWithError(w, http.StatusBadRequest, "limit must be between 1 and 200", err)
+			return
+		}
+	}
+
+	_, err = cfg.store.GetWebhookSubscription(r.Context(), database.GetWebhookSubscriptionParams{
+		ID:     subscriptionID,
+		UserID: userID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find webhook subscription", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get webhook subscription", err)
+		return
+	}
+
+	deliveries, err := cfg.store.GetWebhookDeliveries(r.Context(), database.GetWebhookDeliveriesParams{
+		SubscriptionID: subscriptionID,
+		Limit:          int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get webhook deliveries", err)
+		return
+	}
+
+	deliveryList := []WebhookDelivery{}
+	for _, delivery := range deliveries {
+		deliveryList = append(deliveryList, webhookDeliveryFromDB(delivery))
+	}
+	respondWithJSON(w, http.StatusOK, deliveryList)
+}
+
+func (cfg *apiConfig) handlerWebhookRedeliver(w http.ResponseWriter, r *http.Request) {
+	subscriptionID, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid webhook ID", err)
+		return
+	}
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid delivery ID", err)
+		return
+	}
+
+	userID := requestPrincipal(r).UserID
+
+	_, err = cfg.store.GetWebhookSubscription(r.Context(), database.GetWebhookSubscriptionParams{
+		ID:     subscriptionID,
+		UserID: userID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find webhook subscription", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get webhook subscription", err)
+		return
+	}
+
+	updated, err := cfg.store.RedeliverWebhook(r.Context(), database.RedeliverWebhookParams{
+		ID:             deliveryID,
+		SubscriptionID: subscriptionID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't redeliver webhook", err)
+		return
+	}
+	if updated == 0 {
+		respondWithError(w, http.StatusNotFound, "Couldn't find webhook delivery", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func webhookSubscriptionFromDB(s database.WebhookSubscription) WebhookSubscription {
+	return WebhookSubscription{
+		ID:         s.ID,
+		CreatedAt:  s.CreatedAt,
+		UpdatedAt:  s.UpdatedAt,
+		URL:        s.Url,
+		EventTypes: s.EventTypes,
+		Active:     s.Active,
+	}
+}
+
+func webhookDeliveryFromDB(d database.WebhookDelivery) WebhookDelivery {
+	delivery := WebhookDelivery{
+		ID:        d.ID,
+		CreatedAt: d.CreatedAt,
+		EventType: d.EventType,
+		Payload:   d.Payload,
+		Status:    d.Status,
+		Attempts:  d.Attempts,
+	}
+	if d.Status == webhookStatusPending {
+		delivery.NextAttemptAt = &d.NextAttemptAt
+	}
+	if d.LastAttemptAt.Valid {
+		delivery.LastAttemptAt = &d.LastAttemptAt.Time
+	}
+	if d.LastStatusCode.Valid {
+		delivery.LastStatusCode = &d.LastStatusCode.Int32
+	}
+	if d.LastError.Valid {
+		delivery.LastError = &d.LastError.String
+	}
+	if d.DeliveredAt.Valid {
+		delivery.DeliveredAt = &d.DeliveredAt.Time
+	}
+	return delivery
+}
+
+// validateWebhookSubscription checks the target URL and event types of a
+// new subscription. Plain http is only allowed in dev.
+func validateWebhookSubscription(rawURL string, eventTypes []string, allowHTTP bool) (*url.URL, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil || target.Host == "" || (target.Scheme != "https" && target.Scheme != "http") {
+		return nil, &fieldError{Field: "url", Code: fieldInvalid, Message: "URL must be an absolute http(s) URL"}
+	}
+	if target.Scheme != "https" && !allowHTTP {
+		return nil, &fieldError{Field: "url", Code: fieldInvalid, Message: "URL must use https"}
+	}
+
+	if len(eventTypes) == 0 {
+		return nil, &fieldError{Field: "event_types", Code: fieldRequired, Message: "Event types can't be empty"}
+	}
+	for _, eventType := range eventTypes {
+		if !slices.Contains(webhookEventTypes, eventType) {
+			return nil, &fieldError{Field: "event_types", Code: fieldInvalid, Message: "Unknown event type: " + eventType}
+		}
+	}
+	return target, nil
+}
@@ -1,52 +1,158 @@
 package main
 
 import (
-	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
 	"github.com/exglegaming/Chirpy/internal/auth"
 	"github.com/exglegaming/Chirpy/internal/database"
 	"github.com/google/uuid"
-	"net/http"
+)
+
+// errPolkaEventReplayed rolls back a webhook whose event ID was already
+// applied
+var errPolkaEventReplayed = errors.New("polka event already applied")
+
+const (
+	polkaTimestampHeader    = "X-Polka-Timestamp"
+	polkaSignatureHeader    = "X-Polka-Signature"
+	polkaSignatureTolerance = 5 * time.Minute
 )
 
 func (cfg *apiConfig) handlerUpdateUserChirpyRed(w http.ResponseWriter, r *http.Request) {
+	const maxBodySize = 1 << 20
+
 	type parameters struct {
+		ID    string `json:"id"`
 		Event string `json:"event"`
 		Data  struct {
-			UserID uuid.UUID `json:"user_id"`
+			UserID    uuid.UUID  `json:"user_id"`
+			PeriodEnd *time.Time `json:"period_end"`
 		} `json:"data"`
 	}
 
-	key, err := auth.GetAPIKey(r.Header)
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find api key", err)
+		respondWithError(w, http.StatusBadRequest, "Couldn't read body", err)
 		return
 	}
-	if key != cfg.polkaSecret {
-		respondWithError(w, http.StatusUnauthorized, "API key is invalid", err)
-		return
+
+	// Once secrets are configured every request must be signed, unless the
+	// static ApiKey is explicitly kept while Polka is being migrated over
+	signed := r.Header.Get(polkaSignatureHeader) != ""
+	if signed || !cfg.acceptsPolkaAPIKey() {
+		err = auth.VerifyWebhookSignature(
+			cfg.polkaWebhookSecrets,
+			r.Header.Get(polkaTimestampHeader),
+			r.Header.Get(polkaSignatureHeader),
+			body,
+			time.Now(),
+			polkaSignatureTolerance,
+		)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Webhook signature is invalid", err)
+			return
+		}
+	} else {
+		key, err := auth.GetAPIKey(r.Header)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Couldn't find api key", err)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(key), []byte(cfg.polkaSecret)) != 1 {
+			respondWithError(w, http.StatusUnauthorized, "API key is invalid", nil)
+			return
+		}
 	}
 
-	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err = decoder.Decode(&params)
+	err = json.Unmarshal(body, &params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithDecodeError(w, locateDecodeError(body, err))
 		return
 	}
 
-	if params.Event != "user.upgraded" {
+	if !isPolkaSubscriptionEvent(params.Event) {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
-	err = cfg.db.UpdateUserChirpyRed(context.Background(), database.UpdateUserChirpyRedParams{
-		ID:          params.Data.UserID,
-		IsChirpyRed: true,
+	// Replays are recognised by event ID. Signed webhooks must have one;
+	// legacy ApiKey requests predate IDs, so an identical body counts as
+	// the same event.
+	eventID := params.ID
+	if eventID == "" {
+		if signed {
+			respondWithError(w, http.StatusBadRequest, "Signed webhooks must include an event id", nil)
+			return
+		}
+		sum := sha256.Sum256(body)
+		eventID = "body:" + hex.EncodeToString(sum[:])
+	}
+
+	err = cfg.withTx(r.Context(), func(q database.Store) error {
+		// Replays of an event we've already applied are acknowledged
+		// without doing anything, so Polka stops retrying
+		recorded, err := q.RecordPolkaEvent(r.Context(), database.RecordPolkaEventParams{
+			ID:    eventID,
+			Event: params.Event,
+		})
+		if err != nil {
+			return fmt.Errorf("couldn't record webhook event: %w", err)
+		}
+		if recorded == 0 {
+			return errPolkaEventReplayed
+		}
+
+		_, err = q.GetUserByID(r.Context(), params.Data.UserID)
+		if err != nil {
+			return err
+		}
+
+		err = applyPolkaEvent(r.Context(), q, params.Event, params.Data.UserID, params.Data.PeriodEnd)
+		if err != nil {
+			return fmt.Errorf("couldn't update subscription: %w", err)
+		}
+
+		if params.Event == polkaEventUpgraded {
+			return recordEvent(r.Context(), q, eventUserUpgraded, map[string]uuid.UUID{
+				"user_id": params.Data.UserID,
+			})
+		}
+		return nil
 	})
-	if err != nil {
+	if errors.Is(err, errPolkaEventReplayed) {
+		requestLogger(r.Context()).Info("Ignoring replayed Polka event", "event_id", eventID)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
 		respondWithError(w, http.StatusNotFound, "User could not be found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't apply webhook event", err)
+		return
 	}
 
+	requestLogger(r.Context()).Info("Applied Polka event",
+		"event", params.Event, "event_id", eventID, "polka_user_id", params.Data.UserID)
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// acceptsPolkaAPIKey reports whether unsigned webhooks authenticated with the
+// static ApiKey are still allowed
+func (cfg *apiConfig) acceptsPolkaAPIKey() bool {
+	if cfg.polkaSecret == "" {
+		return false
+	}
+	return len(cfg.polkaWebhookSecrets) == 0 || cfg.polkaAllowAPIKey
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/auth"
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsChannelChirps           = "chirps"
+	wsChannelUserChirpsPrefix = "chirps:"
+	wsChannelNotifications    = "notifications"
+
+	wsWriteWait      = 10 * time.Second
+	wsPongWait       = 60 * time.Second
+	wsPingPeriod     = (wsPongWait * 9) / 10
+	wsMaxMessageSize = 4096
+	wsSendBufferSize = 16
+)
+
+// checkWebSocketOrigin lets pages from the server's own origin and
+// wsAllowedOrigins connect. Tokens can ride along in ?access_token=, so
+// otherwise any page holding a leaked one could connect as its user.
+// Non-browser clients send no Origin and are let through.
+func (cfg *apiConfig) checkWebSocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return slices.ContainsFunc(cfg.wsAllowedOrigins, func(allowed string) bool {
+		return strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin)
+	})
+}
+
+type wsClientMessage struct {
+	Type    string `json:"type"`
+	Channel string `json:"channel,omitempty"`
+	Token   string `json:"token,omitempty"`
+}
+
+type wsServerMessage struct {
+	Type      string      `json:"type"`
+	Channel   string      `json:"channel,omitempty"`
+	Event     string      `json:"event,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
+	ExpiresAt *time.Time  `json:"expires_at,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+type wsConn struct {
+	cfg    *apiConfig
+	conn   *websocket.Conn
+	userID uuid.UUID
+	send   chan wsServerMessage
+	expiry chan time.Time
+	// filter is only used by writePump
+	filter *cachedChirpFilter
+
+	mu       sync.Mutex
+	channels map[string]struct{}
+}
+
+// handlerWebSocket upgrades to a WebSocket authenticated with the same access
+// token as the REST API. Browsers can't set headers on the handshake, so the
+// token may also be passed as ?access_token=; see accessTokenFrom.
+//
+// Clients send {"type": "subscribe"|"unsubscribe", "channel": ...} for the
+// channels "chirps", "chirps:<user_id>" and "notifications", {"type": "ping"}
+// as an application-level heartbeat, and {"type": "auth", "token": ...} to
+// extend the connection with a fresh token before the current one expires.
+func (cfg *apiConfig) handlerWebSocket(w http.ResponseWriter, r *http.Request) {
+	principal := requestPrincipal(r)
+	userID, expiresAt := principal.UserID, principal.ExpiresAt
+
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     cfg.checkWebSocketOrigin,
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already written an error response
+		return
+	}
+
+	c := &wsConn{
+		cfg:      cfg,
+		conn:     conn,
+		userID:   userID,
+		send:     make(chan wsServerMessage, wsSendBufferSize),
+		expiry:   make(chan time.Time, 1),
+		filter:   &cachedChirpFilter{cfg: cfg, userID: userID},
+		channels: map[string]struct{}{},
+	}
+
+	chirps := cfg.chirpEvents.subscribe(c.wantsChirpEvent)
+	defer cfg.chirpEvents.unsubscribe(chirps)
+	notifications := cfg.notifications.subscribe(c.wantsNotification)
+	defer cfg.notifications.unsubscribe(notifications)
+	defer cfg.metrics.trackStream("websocket")()
+
+	done := make(chan struct{})
+	go c.readPump(done)
+	c.writePump(r.Context(), done, chirps, notifications, expiresAt)
+}
+
+func (c *wsConn) readPump(done chan<- struct{}) {
+	defer close(done)
+
+	c.conn.SetReadLimit(wsMaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		msg := wsClientMessage{}
+		reply := wsServerMessage{}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			reply = wsServerMessage{Type: "error", Error: "Couldn't decode message"}
+		} else {
+			reply = c.handleMessage(msg)
+		}
+
+		select {
+		case c.send <- reply:
+		default:
+			// The client isn't reading its replies; give up on it
+			c.conn.Close()
+			return
+		}
+	}
+}
+
+func (c *wsConn) handleMessage(msg wsClientMessage) wsServerMessage {
+	switch msg.Type {
+	case "subscribe":
+		if !c.validChannel(msg.Channel) {
+			return wsServerMessage{Type: "error", Channel: msg.Channel, Error: "Unknown channel"}
+		}
+		c.mu.Lock()
+		c.channels[msg.Channel] = struct{}{}
+		c.mu.Unlock()
+		return wsServerMessage{Type: "subscribed", Channel: msg.Channel}
+	case "unsubscribe":
+		c.mu.Lock()
+		delete(c.channels, msg.Channel)
+		c.mu.Unlock()
+		return wsServerMessage{Type: "unsubscribed", Channel: msg.Channel}
+	case "auth":
+		userID, expiresAt, err := auth.ValidateJWTWithExpiry(msg.Token, c.cfg.JWTSecret)
+		if err != nil {
+			return wsServerMessage{Type: "error", Error: "Couldn't validate JWT"}
+		}
+		if userID != c.userID {
+			return wsServerMessage{Type: "error", Error: "Token belongs to a different user"}
+		}
+		// Only the latest expiry matters, so replace any pending one
+		select {
+		case <-c.expiry:
+		default:
+		}
+		c.expiry <- expiresAt
+		return wsServerMessage{Type: "authenticated", ExpiresAt: &expiresAt}
+	case "ping":
+		return wsServerMessage{Type: "pong"}
+	default:
+		return wsServerMessage{Type: "error", Error: "Unknown message type"}
+	}
+}
+
+func (c *wsConn) validChannel(channel string) bool {
+	if channel == wsChannelChirps || channel == wsChannelNotifications {
+		return true
+	}
+	if userID, ok := strings.CutPrefix(channel, wsChannelUserChirpsPrefix); ok {
+		_, err := uuid.Parse(userID)
+		return err == nil
+	}
+	return false
+}
+
+func (c *wsConn) subscribed(channel string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.channels[channel]
+	return ok
+}
+
+// chirpEventChannel returns the channel an event is delivered on, or "" if
+// the connection isn't subscribed to it
+func (c *wsConn) chirpEventChannel(event database.ChirpEvent) string {
+	if c.subscribed(wsChannelChirps) {
+		return wsChannelChirps
+	}
+	if channel := wsChannelUserChirpsPrefix + event.UserID.String(); c.subscribed(channel) {
+		return channel
+	}
+	return ""
+}
+
+// wantsChirpEvent only checks subscriptions; blocks and mutes are checked
+// as the event is sent, since the broker calls this while publishing
+func (c *wsConn) wantsChirpEvent(event database.ChirpEvent) bool {
+	return c.chirpEventChannel(event) != ""
+}
+
+// allowsChirpEvent checks the user's blocks and mutes for each event, so
+// changes made since the connection opened apply within viewerFilterTTL on
+// any server, and at once on this one
+func (c *wsConn) allowsChirpEvent(ctx context.Context, event database.ChirpEvent) (bool, error) {
+	filter, err := c.filter.get(ctx)
+	if err != nil {
+		return false, err
+	}
+	return filter.allows(database.Chirp{
+		ID:     event.ChirpID,
+		UserID: event.UserID,
+		Body:   event.Body.String,
+	}), nil
+}
+
+func (c *wsConn) wantsNotification(notification database.Notification) bool {
+	return notification.UserID == c.userID && c.subscribed(wsChannelNotifications)
+}
+
+func (c *wsConn) writePump(
+	ctx context.Context,
+	done <-chan struct{},
+	chirps *subscription[database.ChirpEvent],
+	notifications *subscription[database.Notification],
+	expiresAt time.Time,
+) {
+	ping := time.NewTicker(wsPingPeriod)
+	expiry := time.NewTimer(time.Until(expiresAt))
+	defer func() {
+		ping.Stop()
+		expiry.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		var msg wsServerMessage
+		select {
+		case <-done:
+			return
+		case <-c.cfg.draining:
+			c.closeWith(websocket.CloseGoingAway, "server shutting down")
+			return
+		case msg = <-c.send:
+		case event := <-chirps.events:
+			channel := c.chirpEventChannel(event)
+			if channel == "" {
+				// Unsubscribed since the event was queued
+				continue
+			}
+			allowed, err := c.allowsChirpEvent(ctx, event)
+			if err != nil {
+				slog.Error("Couldn't get blocks and mutes", "error", err)
+				c.closeWith(websocket.CloseInternalServerErr, "couldn't get blocks and mutes")
+				return
+			}
+			if !allowed {
+				continue
+			}
+			msg = wsServerMessage{
+				Type:    "event",
+				Channel: channel,
+				Event:   event.Type,
+				Data:    chirpEventPayload(event),
+			}
+		case notification := <-notifications.events:
+			msg = wsServerMessage{
+				Type:    "event",
+				Channel: wsChannelNotifications,
+				Event:   "notification.created",
+				Data:    notificationFromDB(notification),
+			}
+		case <-chirps.dropped:
+			c.closeWith(websocket.CloseTryAgainLater, "client too slow")
+			return
+		case <-notifications.dropped:
+			c.closeWith(websocket.CloseTryAgainLater, "client too slow")
+			return
+		case expiresAt := <-c.expiry:
+			expiry.Reset(time.Until(expiresAt))
+			continue
+		case <-expiry.C:
+			c.closeWith(websocket.ClosePolicyViolation, "token expired")
+			return
+		case <-ping.C:
+			err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+			if err != nil {
+				return
+			}
+			continue
+		}
+
+		c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		if err := c.conn.WriteJSON(msg); err != nil {
+			return
+		}
+	}
+}
+
+func (c *wsConn) closeWith(code int, reason string) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsWriteWait))
+}
@@ -17,6 +17,8 @@ type TokenType string
 const (
 	// TokenTypeAccess -
 	TokenTypeAccess TokenType = "chirpy-access"
+	// TokenTypeRefresh is the opaque token MakeRefreshToken makes
+	TokenTypeRefresh TokenType = "chirpy-refresh"
 )
 
 // ErrNoAuthHeaderIncluded -
@@ -38,47 +40,100 @@ func CheckPasswordHash(password, hash string) error {
 
 // MakeJWT -
 func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+	return MakeAccessToken(AccessToken{UserID: userID}, tokenSecret, expiresIn)
+}
+
+// AccessToken is what an access JWT says about its holder
+type AccessToken struct {
+	UserID uuid.UUID
+	// Scopes narrow what the token may be used for. Tokens without any
+	// aren't restricted.
+	Scopes    []string
+	Roles     []string
+	ExpiresAt time.Time
+}
+
+type accessClaims struct {
+	jwt.RegisteredClaims
+	// Space separated, as in OAuth
+	Scope string   `json:"scope,omitempty"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+// MakeAccessToken signs an access JWT for token's user, scopes and roles.
+// token.ExpiresAt is ignored in favour of expiresIn.
+func MakeAccessToken(token AccessToken, tokenSecret string, expiresIn time.Duration) (string, error) {
 	signingKey := []byte(tokenSecret)
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Issuer:    string(TokenTypeAccess),
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
-		Subject:   userID.String(),
+	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    string(TokenTypeAccess),
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+			Subject:   token.UserID.String(),
+		},
+		Scope: strings.Join(token.Scopes, " "),
+		Roles: token.Roles,
 	})
-	return token.SignedString(signingKey)
+	return jwtToken.SignedString(signingKey)
 }
 
 // ValidateJWT -
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	claimsStruct := jwt.RegisteredClaims{}
+	token, err := ParseAccessToken(tokenString, tokenSecret)
+	return token.UserID, err
+}
+
+// ValidateJWTWithExpiry validates like ValidateJWT and also returns when the
+// token expires, for long-lived connections that must end with it
+func ValidateJWTWithExpiry(tokenString, tokenSecret string) (uuid.UUID, time.Time, error) {
+	token, err := ParseAccessToken(tokenString, tokenSecret)
+	return token.UserID, token.ExpiresAt, err
+}
+
+// ParseAccessToken validates an access JWT and returns its claims
+func ParseAccessToken(tokenString, tokenSecret string) (AccessToken, error) {
+	claimsStruct := accessClaims{}
 	token, err := jwt.ParseWithClaims(
 		tokenString,
 		&claimsStruct,
 		func(token *jwt.Token) (interface{}, error) { return []byte(tokenSecret), nil },
 	)
 	if err != nil {
-		return uuid.Nil, err
+		return AccessToken{}, err
 	}
 
 	userIDString, err := token.Claims.GetSubject()
 	if err != nil {
-		return uuid.Nil, err
+		return AccessToken{}, err
 	}
 
 	issuer, err := token.Claims.GetIssuer()
 	if err != nil {
-		return uuid.Nil, err
+		return AccessToken{}, err
 	}
 
 	if issuer != string(TokenTypeAccess) {
-		return uuid.Nil, errors.New("invalid issuer")
+		return AccessToken{}, errors.New("invalid issuer")
+	}
+
+	expiresAt, err := token.Claims.GetExpirationTime()
+	if err != nil {
+		return AccessToken{}, err
+	}
+	if expiresAt == nil {
+		return AccessToken{}, errors.New("token has no expiry")
 	}
 
 	id, err := uuid.Parse(userIDString)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("invalid user ID: %w", err)
+		return AccessToken{}, fmt.Errorf("invalid user ID: %w", err)
 	}
-	return id, nil
+	return AccessToken{
+		UserID:    id,
+		Scopes:    strings.Fields(claimsStruct.Scope),
+		Roles:     claimsStruct.Roles,
+		ExpiresAt: expiresAt.Time,
+	}, nil
 }
 
 // GetBearerToken -
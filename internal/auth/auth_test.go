@@ -2,6 +2,7 @@ package auth
 
 import (
 	"net/http"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -169,6 +170,35 @@ func TestGetBearerToken(t *testing.T) {
 	}
 }
 
+func TestParseAccessToken(t *testing.T) {
+	want := AccessToken{
+		UserID: uuid.New(),
+		Scopes: []string{"chirps:read", "chirps:write"},
+		Roles:  []string{"chirpy_red"},
+	}
+	token, err := MakeAccessToken(want, "secret", time.Hour)
+	if err != nil {
+		t.Fatalf("Error creating JWT: %v", err)
+	}
+
+	got, err := ParseAccessToken(token, "secret")
+	if err != nil {
+		t.Fatalf("Error parsing JWT: %v", err)
+	}
+	if got.UserID != want.UserID {
+		t.Errorf("UserID = %v, want %v", got.UserID, want.UserID)
+	}
+	if !slices.Equal(got.Scopes, want.Scopes) {
+		t.Errorf("Scopes = %v, want %v", got.Scopes, want.Scopes)
+	}
+	if !slices.Equal(got.Roles, want.Roles) {
+		t.Errorf("Roles = %v, want %v", got.Roles, want.Roles)
+	}
+	if time.Until(got.ExpiresAt) <= 0 || time.Until(got.ExpiresAt) > time.Hour {
+		t.Errorf("ExpiresAt = %v, want within the hour", got.ExpiresAt)
+	}
+}
+
 func TestValidateJWT(t *testing.T) {
 	userID := uuid.New()
 	validToken, _ := MakeJWT(userID, "secret", time.Hour)
@@ -216,3 +246,23 @@ func TestValidateJWT(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateJWTWithExpiry(t *testing.T) {
+	userID := uuid.New()
+	before := time.Now().Add(time.Hour).Truncate(time.Second)
+	token, err := MakeJWT(userID, "secret", time.Hour)
+	if err != nil {
+		t.Fatalf("Error creating JWT: %v", err)
+	}
+
+	gotUserID, expiresAt, err := ValidateJWTWithExpiry(token, "secret")
+	if err != nil {
+		t.Fatalf("Error validating JWT: %v", err)
+	}
+	if gotUserID != userID {
+		t.Errorf("Expected user ID %v, got %v", userID, gotUserID)
+	}
+	if expiresAt.Before(before) || expiresAt.After(before.Add(2*time.Second)) {
+		t.Errorf("Expected expiry around %v, got %v", before, expiresAt)
+	}
+}
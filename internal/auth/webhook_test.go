@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"event":"user.upgraded"}`)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := SignWebhook("current-secret", now.Unix(), body)
+
+	tests := []struct {
+		name      string
+		secrets   []string
+		timestamp string
+		signature string
+		body      []byte
+		wantErr   error
+	}{
+		{
+			name:      "Valid signature",
+			secrets:   []string{"current-secret"},
+			timestamp: timestamp,
+			signature: signature,
+			body:      body,
+		},
+		{
+			name:      "Matches rotated secret",
+			secrets:   []string{"new-secret", "current-secret"},
+			timestamp: timestamp,
+			signature: signature,
+			body:      body,
+		},
+		{
+			name:      "One of several signatures matches",
+			secrets:   []string{"current-secret"},
+			timestamp: timestamp,
+			signature: SignWebhook("old-secret", now.Unix(), body) + "," + signature,
+			body:      body,
+		},
+		{
+			name:      "Wrong secret",
+			secrets:   []string{"other-secret"},
+			timestamp: timestamp,
+			signature: signature,
+			body:      body,
+			wantErr:   ErrInvalidWebhookSignature,
+		},
+		{
+			name:      "Tampered body",
+			secrets:   []string{"current-secret"},
+			timestamp: timestamp,
+			signature: signature,
+			body:      []byte(`{"event":"user.downgraded"}`),
+			wantErr:   ErrInvalidWebhookSignature,
+		},
+		{
+			name:      "Stale timestamp",
+			secrets:   []string{"current-secret"},
+			timestamp: strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10),
+			signature: SignWebhook("current-secret", now.Add(-10*time.Minute).Unix(), body),
+			body:      body,
+			wantErr:   ErrWebhookTimestampOutOfRange,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyWebhookSignature(tt.secrets, tt.timestamp, tt.signature, tt.body, now, 5*time.Minute)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyWebhookSignature() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("Malformed signature", func(t *testing.T) {
+		err := VerifyWebhookSignature([]string{"current-secret"}, timestamp, "sha256=nothex", body, now, 5*time.Minute)
+		if err == nil {
+			t.Error("Expected error for malformed signature, got nil")
+		}
+	})
+}
@@ -0,0 +1,20 @@
+// Package cache has the caches read-through lookups keep query results in
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Cache keeps values by key for up to a TTL. LRU keeps them in process. A
+// shared implementation, on Redis or memcached say, would let every
+// instance see the same entries, which is why values are bytes and every
+// method takes a context and can fail. Callers treat errors as misses.
+type Cache interface {
+	// Get reports whether key was found, and its value if it was
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+	// Clear deletes every entry
+	Clear(ctx context.Context) error
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewLRU(2)
+	c.now = func() time.Time { return now }
+
+	get := func(key string) string {
+		t.Helper()
+		value, ok, err := c.Get(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			return ""
+		}
+		return string(value)
+	}
+
+	c.Set(ctx, "a", []byte("1"), time.Minute)
+	c.Set(ctx, "b", []byte("2"), time.Minute)
+	if got := get("a"); got != "1" {
+		t.Errorf("a = %q, want 1", got)
+	}
+
+	// a was used more recently, so b is evicted
+	c.Set(ctx, "c", []byte("3"), time.Minute)
+	if got := get("b"); got != "" {
+		t.Errorf("b = %q, want it evicted", got)
+	}
+	if got := get("a"); got != "1" {
+		t.Errorf("a = %q, want 1", got)
+	}
+
+	c.Set(ctx, "a", []byte("4"), time.Minute)
+	if got := get("a"); got != "4" {
+		t.Errorf("a = %q after overwriting, want 4", got)
+	}
+
+	c.Delete(ctx, "a", "missing")
+	if got := get("a"); got != "" {
+		t.Errorf("a = %q after Delete, want it gone", got)
+	}
+
+	now = now.Add(time.Minute)
+	if got := get("c"); got != "" {
+		t.Errorf("c = %q after its TTL, want it expired", got)
+	}
+	if c.Len() != 0 {
+		t.Errorf("Len() = %d, want expired entries removed on Get", c.Len())
+	}
+
+	c.Set(ctx, "d", []byte("5"), time.Minute)
+	c.Clear(ctx)
+	if got := get("d"); got != "" || c.Len() != 0 {
+		t.Errorf("d = %q after Clear, want an empty cache", got)
+	}
+}
@@ -12,24 +12,26 @@ import (
 )
 
 const creatChirp = `-- name: CreatChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, reply_to_id)
 VALUES (
         gen_random_uuid(),
         NOW(),
         NOW(),
         $1,
-        $2
+        $2,
+        $3
        )
-    RETURNING id, created_at, updated_at, body, user_id
+    RETURNING id, created_at, updated_at, body, user_id, reply_to_id
 `
 
 type CreatChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body      string
+	UserID    uuid.UUID
+	ReplyToID uuid.NullUUID
 }
 
 func (q *Queries) CreatChirp(ctx context.Context, arg CreatChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, creatChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, creatChirp, arg.Body, arg.UserID, arg.ReplyToID)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,6 +39,7 @@ func (q *Queries) CreatChirp(ctx context.Context, arg CreatChirpParams) (Chirp,
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.ReplyToID,
 	)
 	return i, err
 }
@@ -44,7 +47,7 @@ func (q *Queries) CreatChirp(ctx context.Context, arg CreatChirpParams) (Chirp,
 const deleteChirp = `-- name: DeleteChirp :one
 DELETE FROM chirps
 WHERE id = $1 AND user_id = $2
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, reply_to_id
 `
 
 type DeleteChirpParams struct {
@@ -61,12 +64,13 @@ func (q *Queries) DeleteChirp(ctx context.Context, arg DeleteChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.ReplyToID,
 	)
 	return i, err
 }
 
 const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, reply_to_id FROM chirps
 WHERE id = $1
 `
 
@@ -79,12 +83,13 @@ func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.ReplyToID,
 	)
 	return i, err
 }
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, reply_to_id FROM chirps
 ORDER BY created_at ASC
 `
 
@@ -103,6 +108,7 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.ReplyToID,
 		); err != nil {
 			return nil, err
 		}
@@ -118,7 +124,7 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpsByUserID = `-- name: GetChirpsByUserID :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, reply_to_id FROM chirps
 WHERE user_id = $1
 ORDER BY created_at ASC
 `
@@ -138,6 +144,7 @@ func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Ch
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.ReplyToID,
 		); err != nil {
 			return nil, err
 		}
@@ -153,7 +160,7 @@ func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Ch
 }
 
 const getChirpsDesc = `-- name: GetChirpsDesc :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, reply_to_id FROM chirps
 ORDER BY created_at DESC
 `
 
@@ -172,6 +179,7 @@ func (q *Queries) GetChirpsDesc(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.ReplyToID,
 		); err != nil {
 			return nil, err
 		}
@@ -0,0 +1,237 @@
+package memstore
+
+import (
+	"context"
+	"slices"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// usersExist reports whether every id is a user, as a foreign key would
+func (s *Store) usersExist(ids ...uuid.UUID) bool {
+	for _, id := range ids {
+		if _, ok := s.data.users[id]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// CreateFollow does nothing if the follow already exists
+func (s *Store) CreateFollow(ctx context.Context, arg database.CreateFollowParams) (int64, error) {
+	defer s.lock()()
+
+	if !s.usersExist(arg.FollowerID, arg.FollowedID) {
+		return 0, ErrForeignKey
+	}
+	if slices.ContainsFunc(s.data.follows, func(f database.Follow) bool {
+		return f.FollowerID == arg.FollowerID && f.FollowedID == arg.FollowedID
+	}) {
+		return 0, nil
+	}
+	s.data.follows = append(s.data.follows, database.Follow{
+		FollowerID: arg.FollowerID,
+		FollowedID: arg.FollowedID,
+		CreatedAt:  now(),
+	})
+	return 1, nil
+}
+
+func (s *Store) DeleteFollow(ctx context.Context, arg database.DeleteFollowParams) (int64, error) {
+	defer s.lock()()
+
+	n := len(s.data.follows)
+	s.data.follows = slices.DeleteFunc(s.data.follows, func(f database.Follow) bool {
+		return f.FollowerID == arg.FollowerID && f.FollowedID == arg.FollowedID
+	})
+	return int64(n - len(s.data.follows)), nil
+}
+
+// GetFollowsByFollower returns follows newest first
+func (s *Store) GetFollowsByFollower(ctx context.Context, followerID uuid.UUID) ([]database.Follow, error) {
+	defer s.lock()()
+
+	var follows []database.Follow
+	for _, follow := range slices.Backward(s.data.follows) {
+		if follow.FollowerID == followerID {
+			follows = append(follows, follow)
+		}
+	}
+	return follows, nil
+}
+
+// CreateBlock does nothing if the block already exists
+func (s *Store) CreateBlock(ctx context.Context, arg database.CreateBlockParams) error {
+	defer s.lock()()
+
+	if !s.usersExist(arg.BlockerID, arg.BlockedID) {
+		return ErrForeignKey
+	}
+	if slices.ContainsFunc(s.data.blocks, func(b database.Block) bool {
+		return b.BlockerID == arg.BlockerID && b.BlockedID == arg.BlockedID
+	}) {
+		return nil
+	}
+	s.data.blocks = append(s.data.blocks, database.Block{
+		BlockerID: arg.BlockerID,
+		BlockedID: arg.BlockedID,
+		CreatedAt: now(),
+	})
+	return nil
+}
+
+func (s *Store) DeleteBlock(ctx context.Context, arg database.DeleteBlockParams) (int64, error) {
+	defer s.lock()()
+
+	n := len(s.data.blocks)
+	s.data.blocks = slices.DeleteFunc(s.data.blocks, func(b database.Block) bool {
+		return b.BlockerID == arg.BlockerID && b.BlockedID == arg.BlockedID
+	})
+	return int64(n - len(s.data.blocks)), nil
+}
+
+// GetBlocksByBlocker returns blocks newest first
+func (s *Store) GetBlocksByBlocker(ctx context.Context, blockerID uuid.UUID) ([]database.Block, error) {
+	defer s.lock()()
+
+	var blocks []database.Block
+	for _, block := range slices.Backward(s.data.blocks) {
+		if block.BlockerID == blockerID {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks, nil
+}
+
+// GetBlockRelatedUserIDs returns the users blockerID blocked or was blocked
+// by
+func (s *Store) GetBlockRelatedUserIDs(ctx context.Context, blockerID uuid.UUID) ([]uuid.UUID, error) {
+	defer s.lock()()
+
+	var ids []uuid.UUID
+	for _, block := range s.data.blocks {
+		var id uuid.UUID
+		switch blockerID {
+		case block.BlockerID:
+			id = block.BlockedID
+		case block.BlockedID:
+			id = block.BlockerID
+		default:
+			continue
+		}
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// IsBlockedBetween reports whether either user blocked the other
+func (s *Store) IsBlockedBetween(ctx context.Context, arg database.IsBlockedBetweenParams) (bool, error) {
+	defer s.lock()()
+
+	return slices.ContainsFunc(s.data.blocks, func(b database.Block) bool {
+		return (b.BlockerID == arg.BlockerID && b.BlockedID == arg.BlockedID) ||
+			(b.BlockerID == arg.BlockedID && b.BlockedID == arg.BlockerID)
+	}), nil
+}
+
+// CreateMute does nothing if the mute already exists
+func (s *Store) CreateMute(ctx context.Context, arg database.CreateMuteParams) error {
+	defer s.lock()()
+
+	if !s.usersExist(arg.MuterID, arg.MutedID) {
+		return ErrForeignKey
+	}
+	if slices.ContainsFunc(s.data.mutes, func(m database.Mute) bool {
+		return m.MuterID == arg.MuterID && m.MutedID == arg.MutedID
+	}) {
+		return nil
+	}
+	s.data.mutes = append(s.data.mutes, database.Mute{
+		MuterID:   arg.MuterID,
+		MutedID:   arg.MutedID,
+		CreatedAt: now(),
+	})
+	return nil
+}
+
+func (s *Store) DeleteMute(ctx context.Context, arg database.DeleteMuteParams) (int64, error) {
+	defer s.lock()()
+
+	n := len(s.data.mutes)
+	s.data.mutes = slices.DeleteFunc(s.data.mutes, func(m database.Mute) bool {
+		return m.MuterID == arg.MuterID && m.MutedID == arg.MutedID
+	})
+	return int64(n - len(s.data.mutes)), nil
+}
+
+// GetMutesByMuter returns mutes newest first
+func (s *Store) GetMutesByMuter(ctx context.Context, muterID uuid.UUID) ([]database.Mute, error) {
+	defer s.lock()()
+
+	var mutes []database.Mute
+	for _, mute := range slices.Backward(s.data.mutes) {
+		if mute.MuterID == muterID {
+			mutes = append(mutes, mute)
+		}
+	}
+	return mutes, nil
+}
+
+func (s *Store) GetMutedUserIDs(ctx context.Context, muterID uuid.UUID) ([]uuid.UUID, error) {
+	defer s.lock()()
+
+	var ids []uuid.UUID
+	for _, mute := range s.data.mutes {
+		if mute.MuterID == muterID {
+			ids = append(ids, mute.MutedID)
+		}
+	}
+	return ids, nil
+}
+
+func (s *Store) CreateMutedKeyword(ctx context.Context, arg database.CreateMutedKeywordParams) (database.MutedKeyword, error) {
+	defer s.lock()()
+
+	if !s.usersExist(arg.UserID) {
+		return database.MutedKeyword{}, ErrForeignKey
+	}
+	if slices.ContainsFunc(s.data.mutedKeywords, func(k database.MutedKeyword) bool {
+		return k.UserID == arg.UserID && k.Keyword == arg.Keyword
+	}) {
+		return database.MutedKeyword{}, ErrDuplicateKey
+	}
+	keyword := database.MutedKeyword{
+		ID:        uuid.New(),
+		CreatedAt: now(),
+		UserID:    arg.UserID,
+		Keyword:   arg.Keyword,
+	}
+	s.data.mutedKeywords = append(s.data.mutedKeywords, keyword)
+	return keyword, nil
+}
+
+func (s *Store) DeleteMutedKeyword(ctx context.Context, arg database.DeleteMutedKeywordParams) (int64, error) {
+	defer s.lock()()
+
+	n := len(s.data.mutedKeywords)
+	s.data.mutedKeywords = slices.DeleteFunc(s.data.mutedKeywords, func(k database.MutedKeyword) bool {
+		return k.ID == arg.ID && k.UserID == arg.UserID
+	})
+	return int64(n - len(s.data.mutedKeywords)), nil
+}
+
+// GetMutedKeywords returns a user's keywords oldest first
+func (s *Store) GetMutedKeywords(ctx context.Context, userID uuid.UUID) ([]database.MutedKeyword, error) {
+	defer s.lock()()
+
+	var keywords []database.MutedKeyword
+	for _, keyword := range s.data.mutedKeywords {
+		if keyword.UserID == userID {
+			keywords = append(keywords, keyword)
+		}
+	}
+	return keywords, nil
+}
@@ -0,0 +1,535 @@
+// Package memstore is an in-memory database.Store for tests. It enforces the
+// same constraints as the Postgres schema (unique emails, foreign keys with
+// ON DELETE CASCADE) so handlers see the same errors they would in production.
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrDuplicateKey = fmt.Errorf("memstore: %w", database.ErrUniqueViolation)
+	ErrForeignKey   = errors.New("memstore: foreign key violation")
+)
+
+type Store struct {
+	mu   *sync.Mutex
+	data *data
+	// inTx is set on the Store passed to InTx callbacks, which already hold mu
+	inTx bool
+}
+
+type data struct {
+	users         map[uuid.UUID]database.User
+	chirps        []database.Chirp // in insertion, and so created_at, order
+	chirpEvents   []database.ChirpEvent
+	lastEventID   int64 // chirp event IDs aren't reused once pruned
+	chirpLikes    []database.ChirpLike
+	refreshTokens map[string]database.RefreshToken
+	follows       []database.Follow // in insertion order, as are the rest
+	blocks        []database.Block
+	mutes         []database.Mute
+	mutedKeywords []database.MutedKeyword
+
+	conversations       map[uuid.UUID]database.Conversation
+	conversationMembers []database.ConversationMember
+	messages            []database.Message
+
+	notifications           []database.Notification
+	notificationPreferences []database.NotificationPreference
+
+	polkaEvents   map[string]database.PolkaEvent
+	subscriptions map[uuid.UUID]database.Subscription
+
+	outboxEvents           []database.OutboxEvent
+	outboxEventSubscribers []database.OutboxEventSubscriber // who has handled each event
+
+	webhookSubscriptions []database.WebhookSubscription
+	webhookDeliveries    []database.WebhookDelivery
+}
+
+var _ database.Store = (*Store)(nil)
+
+func New() *Store {
+	return &Store{
+		mu: &sync.Mutex{},
+		data: &data{
+			users:         map[uuid.UUID]database.User{},
+			refreshTokens: map[string]database.RefreshToken{},
+			conversations: map[uuid.UUID]database.Conversation{},
+			polkaEvents:   map[string]database.PolkaEvent{},
+			subscriptions: map[uuid.UUID]database.Subscription{},
+		},
+	}
+}
+
+func (d *data) clone() *data {
+	return &data{
+		users:         maps.Clone(d.users),
+		chirps:        slices.Clone(d.chirps),
+		chirpEvents:   slices.Clone(d.chirpEvents),
+		lastEventID:   d.lastEventID,
+		chirpLikes:    slices.Clone(d.chirpLikes),
+		refreshTokens: maps.Clone(d.refreshTokens),
+		follows:       slices.Clone(d.follows),
+		blocks:        slices.Clone(d.blocks),
+		mutes:         slices.Clone(d.mutes),
+		mutedKeywords: slices.Clone(d.mutedKeywords),
+
+		conversations:       maps.Clone(d.conversations),
+		conversationMembers: slices.Clone(d.conversationMembers),
+		messages:            slices.Clone(d.messages),
+
+		notifications:           slices.Clone(d.notifications),
+		notificationPreferences: slices.Clone(d.notificationPreferences),
+
+		polkaEvents:   maps.Clone(d.polkaEvents),
+		subscriptions: maps.Clone(d.subscriptions),
+
+		outboxEvents:           slices.Clone(d.outboxEvents),
+		outboxEventSubscribers: slices.Clone(d.outboxEventSubscribers),
+
+		webhookSubscriptions: slices.Clone(d.webhookSubscriptions),
+		webhookDeliveries:    slices.Clone(d.webhookDeliveries),
+	}
+}
+
+func (s *Store) lock() func() {
+	if s.inTx {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+// InTx runs fn against a copy of the data and keeps the copy only if fn
+// succeeds. Other callers wait until the transaction finishes.
+func (s *Store) InTx(ctx context.Context, fn func(s database.Store) error) error {
+	if s.inTx {
+		return fn(s)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &Store{mu: s.mu, data: s.data.clone(), inTx: true}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	s.data = tx.data
+	return nil
+}
+
+// OutboxEvents returns every event recorded so far, oldest first
+func (s *Store) OutboxEvents() []database.OutboxEvent {
+	defer s.lock()()
+	return slices.Clone(s.data.outboxEvents)
+}
+
+// Subscription returns userID's subscription, if they have one
+func (s *Store) Subscription(userID uuid.UUID) (database.Subscription, bool) {
+	defer s.lock()()
+	subscription, ok := s.data.subscriptions[userID]
+	return subscription, ok
+}
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	defer s.lock()()
+
+	if s.emailTaken(arg.Email, uuid.Nil) {
+		return database.User{}, ErrDuplicateKey
+	}
+	t := now()
+	user := database.User{
+		ID:             uuid.New(),
+		CreatedAt:      t,
+		UpdatedAt:      t,
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+		IsChirpyRed:    arg.IsChirpyRed,
+	}
+	s.data.users[user.ID] = user
+	return user, nil
+}
+
+func (s *Store) emailTaken(email string, except uuid.UUID) bool {
+	for _, user := range s.data.users {
+		if user.Email == email && user.ID != except {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+	defer s.lock()()
+
+	for _, user := range s.data.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+func (s *Store) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	defer s.lock()()
+
+	user, ok := s.data.users[id]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (s *Store) UpdateUser(ctx context.Context, arg database.UpdateUserParams) (database.User, error) {
+	defer s.lock()()
+
+	user, ok := s.data.users[arg.ID]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	if s.emailTaken(arg.Email, arg.ID) {
+		return database.User{}, ErrDuplicateKey
+	}
+	user.Email = arg.Email
+	user.HashedPassword = arg.HashedPassword
+	user.UpdatedAt = now()
+	s.data.users[user.ID] = user
+	return user, nil
+}
+
+func (s *Store) UpdateUserChirpyRed(ctx context.Context, arg database.UpdateUserChirpyRedParams) error {
+	defer s.lock()()
+
+	user, ok := s.data.users[arg.ID]
+	if !ok {
+		return nil
+	}
+	user.IsChirpyRed = arg.IsChirpyRed
+	s.data.users[user.ID] = user
+	return nil
+}
+
+func (s *Store) SuspendUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+	defer s.lock()()
+
+	user, ok := s.data.users[id]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	t := now()
+	user.SuspendedAt = sql.NullTime{Time: t, Valid: true}
+	user.UpdatedAt = t
+	s.data.users[user.ID] = user
+	return user, nil
+}
+
+// ListUsers returns users oldest first
+func (s *Store) ListUsers(ctx context.Context) ([]database.User, error) {
+	defer s.lock()()
+
+	var users []database.User
+	for _, user := range s.data.users {
+		users = append(users, user)
+	}
+	slices.SortFunc(users, func(a, b database.User) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return users, nil
+}
+
+// Reset deletes every user, cascading to everything that references them
+func (s *Store) Reset(ctx context.Context) error {
+	defer s.lock()()
+
+	clear(s.data.users)
+	for _, chirp := range s.data.chirps {
+		s.recordChirpEvent("chirp.deleted", chirp)
+	}
+	s.data.chirps = nil
+	clear(s.data.refreshTokens)
+	s.data.chirpLikes = nil
+	s.data.follows = nil
+	s.data.blocks = nil
+	s.data.mutes = nil
+	s.data.mutedKeywords = nil
+	// Conversations don't reference users, so they outlive their members
+	s.data.conversationMembers = nil
+	s.data.messages = nil
+	s.data.notifications = nil
+	s.data.notificationPreferences = nil
+	clear(s.data.subscriptions)
+	s.data.webhookSubscriptions = nil
+	s.data.webhookDeliveries = nil
+	return nil
+}
+
+func (s *Store) CreatChirp(ctx context.Context, arg database.CreatChirpParams) (database.Chirp, error) {
+	defer s.lock()()
+
+	if _, ok := s.data.users[arg.UserID]; !ok {
+		return database.Chirp{}, ErrForeignKey
+	}
+	if arg.ReplyToID.Valid && !s.chirpExists(arg.ReplyToID.UUID) {
+		return database.Chirp{}, ErrForeignKey
+	}
+	t := now()
+	chirp := database.Chirp{
+		ID:        uuid.New(),
+		CreatedAt: t,
+		UpdatedAt: t,
+		Body:      arg.Body,
+		UserID:    arg.UserID,
+		ReplyToID: arg.ReplyToID,
+	}
+	s.data.chirps = append(s.data.chirps, chirp)
+	s.recordChirpEvent("chirp.created", chirp)
+	return chirp, nil
+}
+
+func (s *Store) GetChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	defer s.lock()()
+
+	i := slices.IndexFunc(s.data.chirps, func(c database.Chirp) bool { return c.ID == id })
+	if i < 0 {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return s.data.chirps[i], nil
+}
+
+func (s *Store) GetChirps(ctx context.Context) ([]database.Chirp, error) {
+	defer s.lock()()
+	return slices.Clone(s.data.chirps), nil
+}
+
+func (s *Store) GetChirpsDesc(ctx context.Context) ([]database.Chirp, error) {
+	defer s.lock()()
+
+	chirps := slices.Clone(s.data.chirps)
+	slices.Reverse(chirps)
+	return chirps, nil
+}
+
+func (s *Store) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	defer s.lock()()
+
+	var chirps []database.Chirp
+	for _, chirp := range s.data.chirps {
+		if chirp.UserID == userID {
+			chirps = append(chirps, chirp)
+		}
+	}
+	return chirps, nil
+}
+
+func (s *Store) DeleteChirp(ctx context.Context, arg database.DeleteChirpParams) (database.Chirp, error) {
+	defer s.lock()()
+
+	i := slices.IndexFunc(s.data.chirps, func(c database.Chirp) bool {
+		return c.ID == arg.ID && c.UserID == arg.UserID
+	})
+	if i < 0 {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	chirp := s.data.chirps[i]
+	s.data.chirps = slices.Delete(s.data.chirps, i, i+1)
+	s.recordChirpEvent("chirp.deleted", chirp)
+	s.data.notifications = slices.DeleteFunc(s.data.notifications, func(n database.Notification) bool {
+		return n.ChirpID.Valid && n.ChirpID.UUID == chirp.ID
+	})
+	s.data.chirpLikes = slices.DeleteFunc(s.data.chirpLikes, func(l database.ChirpLike) bool {
+		return l.ChirpID == chirp.ID
+	})
+	for i, reply := range s.data.chirps {
+		if reply.ReplyToID.Valid && reply.ReplyToID.UUID == chirp.ID {
+			s.data.chirps[i].ReplyToID = uuid.NullUUID{}
+		}
+	}
+	return chirp, nil
+}
+
+func (s *Store) chirpExists(id uuid.UUID) bool {
+	return slices.ContainsFunc(s.data.chirps, func(c database.Chirp) bool { return c.ID == id })
+}
+
+// CreateChirpLike does nothing if the user already liked the chirp
+func (s *Store) CreateChirpLike(ctx context.Context, arg database.CreateChirpLikeParams) (int64, error) {
+	defer s.lock()()
+
+	if !s.chirpExists(arg.ChirpID) || !s.usersExist(arg.UserID) {
+		return 0, ErrForeignKey
+	}
+	if slices.ContainsFunc(s.data.chirpLikes, func(l database.ChirpLike) bool {
+		return l.ChirpID == arg.ChirpID && l.UserID == arg.UserID
+	}) {
+		return 0, nil
+	}
+	s.data.chirpLikes = append(s.data.chirpLikes, database.ChirpLike{
+		ChirpID:   arg.ChirpID,
+		UserID:    arg.UserID,
+		CreatedAt: now(),
+	})
+	return 1, nil
+}
+
+func (s *Store) DeleteChirpLike(ctx context.Context, arg database.DeleteChirpLikeParams) (int64, error) {
+	defer s.lock()()
+
+	n := len(s.data.chirpLikes)
+	s.data.chirpLikes = slices.DeleteFunc(s.data.chirpLikes, func(l database.ChirpLike) bool {
+		return l.ChirpID == arg.ChirpID && l.UserID == arg.UserID
+	})
+	return int64(n - len(s.data.chirpLikes)), nil
+}
+
+// recordChirpEvent does what the chirps trigger does on Postgres. Writers
+// hold the lock until they commit, so events commit in ID order.
+func (s *Store) recordChirpEvent(eventType string, chirp database.Chirp) {
+	s.data.lastEventID++
+	event := database.ChirpEvent{
+		ID:        s.data.lastEventID,
+		CreatedAt: now(),
+		Type:      eventType,
+		ChirpID:   chirp.ID,
+		UserID:    chirp.UserID,
+	}
+	if eventType == "chirp.created" {
+		event.Body = sql.NullString{String: chirp.Body, Valid: true}
+	}
+	s.data.chirpEvents = append(s.data.chirpEvents, event)
+}
+
+func (s *Store) GetLatestChirpEvent(ctx context.Context) (database.ChirpEvent, error) {
+	defer s.lock()()
+
+	if len(s.data.chirpEvents) == 0 {
+		return database.ChirpEvent{}, sql.ErrNoRows
+	}
+	return s.data.chirpEvents[len(s.data.chirpEvents)-1], nil
+}
+
+func (s *Store) GetChirpEvent(ctx context.Context, id int64) (database.ChirpEvent, error) {
+	defer s.lock()()
+
+	i := slices.IndexFunc(s.data.chirpEvents, func(e database.ChirpEvent) bool { return e.ID == id })
+	if i < 0 {
+		return database.ChirpEvent{}, sql.ErrNoRows
+	}
+	return s.data.chirpEvents[i], nil
+}
+
+func (s *Store) GetChirpEventsAfter(ctx context.Context, arg database.GetChirpEventsAfterParams) ([]database.ChirpEvent, error) {
+	defer s.lock()()
+
+	i, _ := slices.BinarySearchFunc(s.data.chirpEvents, arg.ID+1, func(e database.ChirpEvent, id int64) int {
+		return cmp.Compare(e.ID, id)
+	})
+	after := s.data.chirpEvents[i:]
+	return slices.Clone(after[:min(int(arg.Limit), len(after))]), nil
+}
+
+func (s *Store) DeleteChirpEventsBefore(ctx context.Context, createdAt time.Time) (int64, error) {
+	defer s.lock()()
+
+	n := len(s.data.chirpEvents)
+	s.data.chirpEvents = slices.DeleteFunc(s.data.chirpEvents, func(e database.ChirpEvent) bool {
+		return e.CreatedAt.Before(createdAt)
+	})
+	return int64(n - len(s.data.chirpEvents)), nil
+}
+
+func (s *Store) CreateRefreshTokens(ctx context.Context, arg database.CreateRefreshTokensParams) (database.RefreshToken, error) {
+	defer s.lock()()
+
+	if _, ok := s.data.users[arg.UserID]; !ok {
+		return database.RefreshToken{}, ErrForeignKey
+	}
+	if _, ok := s.data.refreshTokens[arg.Token]; ok {
+		return database.RefreshToken{}, ErrDuplicateKey
+	}
+	t := now()
+	token := database.RefreshToken{
+		Token:     arg.Token,
+		CreatedAt: t,
+		UpdatedAt: t,
+		UserID:    arg.UserID,
+		ExpiresAt: arg.ExpiresAt,
+		RevokedAt: arg.RevokedAt,
+	}
+	s.data.refreshTokens[token.Token] = token
+	return token, nil
+}
+
+// GetRefreshTokenByToken only returns tokens that are neither revoked nor
+// expired
+func (s *Store) GetRefreshTokenByToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	defer s.lock()()
+
+	refreshToken, ok := s.data.refreshTokens[token]
+	if !ok || refreshToken.RevokedAt.Valid || !refreshToken.ExpiresAt.After(now()) {
+		return database.RefreshToken{}, sql.ErrNoRows
+	}
+	return refreshToken, nil
+}
+
+func (s *Store) UpdateRefreshToken(ctx context.Context, token string) error {
+	defer s.lock()()
+
+	refreshToken, ok := s.data.refreshTokens[token]
+	if !ok {
+		return nil
+	}
+	t := now()
+	refreshToken.RevokedAt = sql.NullTime{Time: t, Valid: true}
+	refreshToken.UpdatedAt = t
+	s.data.refreshTokens[token] = refreshToken
+	return nil
+}
+
+func (s *Store) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	defer s.lock()()
+
+	var revoked int64
+	t := now()
+	for token, refreshToken := range s.data.refreshTokens {
+		if refreshToken.UserID != userID || refreshToken.RevokedAt.Valid {
+			continue
+		}
+		refreshToken.RevokedAt = sql.NullTime{Time: t, Valid: true}
+		refreshToken.UpdatedAt = t
+		s.data.refreshTokens[token] = refreshToken
+		revoked++
+	}
+	return revoked, nil
+}
+
+func (s *Store) CreateOutboxEvent(ctx context.Context, arg database.CreateOutboxEventParams) (database.OutboxEvent, error) {
+	defer s.lock()()
+
+	t := now()
+	event := database.OutboxEvent{
+		ID:            uuid.New(),
+		CreatedAt:     t,
+		Type:          arg.Type,
+		Payload:       arg.Payload,
+		NextAttemptAt: t,
+	}
+	s.data.outboxEvents = append(s.data.outboxEvents, event)
+	return event, nil
+}
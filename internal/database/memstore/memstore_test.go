@@ -0,0 +1,14 @@
+package memstore
+
+import (
+	"testing"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/exglegaming/Chirpy/internal/database/storetest"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) database.Store {
+		return New()
+	})
+}
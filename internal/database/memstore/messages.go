@@ -0,0 +1,236 @@
+package memstore
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func (s *Store) CreateConversation(ctx context.Context) (database.Conversation, error) {
+	defer s.lock()()
+
+	t := now()
+	conversation := database.Conversation{
+		ID:        uuid.New(),
+		CreatedAt: t,
+		UpdatedAt: t,
+	}
+	s.data.conversations[conversation.ID] = conversation
+	return conversation, nil
+}
+
+func (s *Store) AddConversationMember(ctx context.Context, arg database.AddConversationMemberParams) error {
+	defer s.lock()()
+
+	if _, ok := s.data.conversations[arg.ConversationID]; !ok || !s.usersExist(arg.UserID) {
+		return ErrForeignKey
+	}
+	if s.member(arg.ConversationID, arg.UserID) >= 0 {
+		return ErrDuplicateKey
+	}
+	s.data.conversationMembers = append(s.data.conversationMembers, database.ConversationMember{
+		ConversationID: arg.ConversationID,
+		UserID:         arg.UserID,
+		JoinedAt:       now(),
+	})
+	return nil
+}
+
+// member returns the index of userID's membership of conversationID, or -1
+func (s *Store) member(conversationID, userID uuid.UUID) int {
+	return slices.IndexFunc(s.data.conversationMembers, func(m database.ConversationMember) bool {
+		return m.ConversationID == conversationID && m.UserID == userID
+	})
+}
+
+// GetConversationMembers returns members in the order they joined
+func (s *Store) GetConversationMembers(ctx context.Context, conversationID uuid.UUID) ([]database.ConversationMember, error) {
+	defer s.lock()()
+
+	var members []database.ConversationMember
+	for _, member := range s.data.conversationMembers {
+		if member.ConversationID == conversationID {
+			members = append(members, member)
+		}
+	}
+	slices.SortStableFunc(members, func(a, b database.ConversationMember) int {
+		if c := a.JoinedAt.Compare(b.JoinedAt); c != 0 {
+			return c
+		}
+		return bytes.Compare(a.UserID[:], b.UserID[:])
+	})
+	return members, nil
+}
+
+// unreadCount is how many messages member hasn't read, not counting their
+// own
+func (s *Store) unreadCount(member database.ConversationMember) int64 {
+	var unread int64
+	for _, message := range s.data.messages {
+		if message.ConversationID != member.ConversationID || message.SenderID == member.UserID {
+			continue
+		}
+		if !member.LastReadAt.Valid || message.CreatedAt.After(member.LastReadAt.Time) {
+			unread++
+		}
+	}
+	return unread
+}
+
+// GetConversationsForUser returns userID's conversations, most recently
+// active first
+func (s *Store) GetConversationsForUser(ctx context.Context, userID uuid.UUID) ([]database.GetConversationsForUserRow, error) {
+	defer s.lock()()
+
+	var rows []database.GetConversationsForUserRow
+	for _, member := range s.data.conversationMembers {
+		if member.UserID != userID {
+			continue
+		}
+		conversation := s.data.conversations[member.ConversationID]
+		rows = append(rows, database.GetConversationsForUserRow{
+			ID:          conversation.ID,
+			CreatedAt:   conversation.CreatedAt,
+			UpdatedAt:   conversation.UpdatedAt,
+			UnreadCount: s.unreadCount(member),
+		})
+	}
+	slices.SortStableFunc(rows, func(a, b database.GetConversationsForUserRow) int {
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+	return rows, nil
+}
+
+// GetConversationForUser only finds conversations UserID is a member of
+func (s *Store) GetConversationForUser(ctx context.Context, arg database.GetConversationForUserParams) (database.GetConversationForUserRow, error) {
+	defer s.lock()()
+
+	i := s.member(arg.ID, arg.UserID)
+	if i < 0 {
+		return database.GetConversationForUserRow{}, sql.ErrNoRows
+	}
+	conversation := s.data.conversations[arg.ID]
+	return database.GetConversationForUserRow{
+		ID:          conversation.ID,
+		CreatedAt:   conversation.CreatedAt,
+		UpdatedAt:   conversation.UpdatedAt,
+		UnreadCount: s.unreadCount(s.data.conversationMembers[i]),
+	}, nil
+}
+
+func (s *Store) TouchConversation(ctx context.Context, id uuid.UUID) error {
+	defer s.lock()()
+
+	conversation, ok := s.data.conversations[id]
+	if !ok {
+		return nil
+	}
+	conversation.UpdatedAt = now()
+	s.data.conversations[id] = conversation
+	return nil
+}
+
+func (s *Store) MarkConversationRead(ctx context.Context, arg database.MarkConversationReadParams) error {
+	defer s.lock()()
+
+	if i := s.member(arg.ConversationID, arg.UserID); i >= 0 {
+		s.data.conversationMembers[i].LastReadAt = sql.NullTime{Time: now(), Valid: true}
+	}
+	return nil
+}
+
+// HasBlockWithConversationMembers reports whether UserID and any other
+// member blocked one another
+func (s *Store) HasBlockWithConversationMembers(ctx context.Context, arg database.HasBlockWithConversationMembersParams) (bool, error) {
+	defer s.lock()()
+
+	for _, member := range s.data.conversationMembers {
+		if member.ConversationID != arg.ConversationID || member.UserID == arg.UserID {
+			continue
+		}
+		if slices.ContainsFunc(s.data.blocks, func(b database.Block) bool {
+			return (b.BlockerID == member.UserID && b.BlockedID == arg.UserID) ||
+				(b.BlockerID == arg.UserID && b.BlockedID == member.UserID)
+		}) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Store) CreateMessage(ctx context.Context, arg database.CreateMessageParams) (database.Message, error) {
+	defer s.lock()()
+
+	if _, ok := s.data.conversations[arg.ConversationID]; !ok || !s.usersExist(arg.SenderID) {
+		return database.Message{}, ErrForeignKey
+	}
+	t := now()
+	message := database.Message{
+		ID:             uuid.New(),
+		CreatedAt:      t,
+		UpdatedAt:      t,
+		ConversationID: arg.ConversationID,
+		SenderID:       arg.SenderID,
+		Body:           arg.Body,
+	}
+	s.data.messages = append(s.data.messages, message)
+	return message, nil
+}
+
+func (s *Store) GetMessage(ctx context.Context, arg database.GetMessageParams) (database.Message, error) {
+	defer s.lock()()
+
+	i := slices.IndexFunc(s.data.messages, func(m database.Message) bool {
+		return m.ID == arg.ID && m.ConversationID == arg.ConversationID
+	})
+	if i < 0 {
+		return database.Message{}, sql.ErrNoRows
+	}
+	return s.data.messages[i], nil
+}
+
+// GetMessages returns a conversation's latest messages, newest first
+func (s *Store) GetMessages(ctx context.Context, arg database.GetMessagesParams) ([]database.Message, error) {
+	defer s.lock()()
+
+	return s.latestMessages(arg.ConversationID, int(arg.Limit), func(database.Message) bool {
+		return true
+	}), nil
+}
+
+// GetMessagesBefore returns the messages that come after the cursor in
+// GetMessages' order
+func (s *Store) GetMessagesBefore(ctx context.Context, arg database.GetMessagesBeforeParams) ([]database.Message, error) {
+	defer s.lock()()
+
+	cursor := database.Message{CreatedAt: arg.BeforeCreatedAt, ID: arg.BeforeID}
+	return s.latestMessages(arg.ConversationID, int(arg.MaxResults), func(m database.Message) bool {
+		return compareMessages(m, cursor) < 0
+	}), nil
+}
+
+func (s *Store) latestMessages(conversationID uuid.UUID, limit int, keep func(database.Message) bool) []database.Message {
+	var messages []database.Message
+	for _, message := range s.data.messages {
+		if message.ConversationID == conversationID && keep(message) {
+			messages = append(messages, message)
+		}
+	}
+	slices.SortFunc(messages, func(a, b database.Message) int {
+		return compareMessages(b, a)
+	})
+	return messages[:min(limit, len(messages))]
+}
+
+// compareMessages orders messages by (created_at, id), as the keyset
+// pagination queries do
+func compareMessages(a, b database.Message) int {
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	return bytes.Compare(a.ID[:], b.ID[:])
+}
@@ -0,0 +1,144 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func (s *Store) CreateNotification(ctx context.Context, arg database.CreateNotificationParams) (database.Notification, error) {
+	defer s.lock()()
+
+	if !s.usersExist(arg.UserID, arg.ActorID) {
+		return database.Notification{}, ErrForeignKey
+	}
+	if arg.ChirpID.Valid && !slices.ContainsFunc(s.data.chirps, func(c database.Chirp) bool { return c.ID == arg.ChirpID.UUID }) {
+		return database.Notification{}, ErrForeignKey
+	}
+	notification := database.Notification{
+		ID:        uuid.New(),
+		CreatedAt: now(),
+		UserID:    arg.UserID,
+		ActorID:   arg.ActorID,
+		Type:      arg.Type,
+		ChirpID:   arg.ChirpID,
+	}
+	s.data.notifications = append(s.data.notifications, notification)
+	return notification, nil
+}
+
+// GetNotifications returns a user's latest notifications, newest first
+func (s *Store) GetNotifications(ctx context.Context, arg database.GetNotificationsParams) ([]database.Notification, error) {
+	defer s.lock()()
+
+	return s.latestNotifications(arg.UserID, int(arg.Limit), false), nil
+}
+
+func (s *Store) GetUnreadNotifications(ctx context.Context, arg database.GetUnreadNotificationsParams) ([]database.Notification, error) {
+	defer s.lock()()
+
+	return s.latestNotifications(arg.UserID, int(arg.Limit), true), nil
+}
+
+func (s *Store) latestNotifications(userID uuid.UUID, limit int, unread bool) []database.Notification {
+	var notifications []database.Notification
+	for _, notification := range slices.Backward(s.data.notifications) {
+		if len(notifications) == limit {
+			break
+		}
+		if notification.UserID == userID && !(unread && notification.ReadAt.Valid) {
+			notifications = append(notifications, notification)
+		}
+	}
+	return notifications
+}
+
+func (s *Store) CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int64, error) {
+	defer s.lock()()
+
+	var unread int64
+	for _, notification := range s.data.notifications {
+		if notification.UserID == userID && !notification.ReadAt.Valid {
+			unread++
+		}
+	}
+	return unread, nil
+}
+
+// MarkNotificationRead counts the notification even if it was already read
+func (s *Store) MarkNotificationRead(ctx context.Context, arg database.MarkNotificationReadParams) (int64, error) {
+	defer s.lock()()
+
+	i := slices.IndexFunc(s.data.notifications, func(n database.Notification) bool {
+		return n.ID == arg.ID && n.UserID == arg.UserID
+	})
+	if i < 0 {
+		return 0, nil
+	}
+	if !s.data.notifications[i].ReadAt.Valid {
+		s.data.notifications[i].ReadAt = sql.NullTime{Time: now(), Valid: true}
+	}
+	return 1, nil
+}
+
+func (s *Store) MarkAllNotificationsRead(ctx context.Context, userID uuid.UUID) error {
+	defer s.lock()()
+
+	t := now()
+	for i, notification := range s.data.notifications {
+		if notification.UserID == userID && !notification.ReadAt.Valid {
+			s.data.notifications[i].ReadAt = sql.NullTime{Time: t, Valid: true}
+		}
+	}
+	return nil
+}
+
+// GetNotificationPreferences returns a user's preferences ordered by type
+func (s *Store) GetNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]database.NotificationPreference, error) {
+	defer s.lock()()
+
+	var preferences []database.NotificationPreference
+	for _, preference := range s.data.notificationPreferences {
+		if preference.UserID == userID {
+			preferences = append(preferences, preference)
+		}
+	}
+	slices.SortFunc(preferences, func(a, b database.NotificationPreference) int {
+		return strings.Compare(a.Type, b.Type)
+	})
+	return preferences, nil
+}
+
+func (s *Store) UpsertNotificationPreference(ctx context.Context, arg database.UpsertNotificationPreferenceParams) error {
+	defer s.lock()()
+
+	if !s.usersExist(arg.UserID) {
+		return ErrForeignKey
+	}
+	if i := s.notificationPreference(arg.UserID, arg.Type); i >= 0 {
+		s.data.notificationPreferences[i].Enabled = arg.Enabled
+		return nil
+	}
+	s.data.notificationPreferences = append(s.data.notificationPreferences, database.NotificationPreference(arg))
+	return nil
+}
+
+// IsNotificationEnabled defaults to enabled for types without a preference
+func (s *Store) IsNotificationEnabled(ctx context.Context, arg database.IsNotificationEnabledParams) (bool, error) {
+	defer s.lock()()
+
+	if i := s.notificationPreference(arg.UserID, arg.Type); i >= 0 {
+		return s.data.notificationPreferences[i].Enabled, nil
+	}
+	return true, nil
+}
+
+func (s *Store) notificationPreference(userID uuid.UUID, notificationType string) int {
+	return slices.IndexFunc(s.data.notificationPreferences, func(p database.NotificationPreference) bool {
+		return p.UserID == userID && p.Type == notificationType
+	})
+}
@@ -0,0 +1,101 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func (s *Store) RecordPolkaEvent(ctx context.Context, arg database.RecordPolkaEventParams) (int64, error) {
+	defer s.lock()()
+
+	if _, ok := s.data.polkaEvents[arg.ID]; ok {
+		return 0, nil
+	}
+	s.data.polkaEvents[arg.ID] = database.PolkaEvent{
+		ID:         arg.ID,
+		Event:      arg.Event,
+		ReceivedAt: now(),
+	}
+	return 1, nil
+}
+
+func (s *Store) UpsertSubscription(ctx context.Context, arg database.UpsertSubscriptionParams) (database.Subscription, error) {
+	defer s.lock()()
+
+	if !s.usersExist(arg.UserID) {
+		return database.Subscription{}, ErrForeignKey
+	}
+	t := now()
+	subscription, ok := s.data.subscriptions[arg.UserID]
+	if !ok {
+		subscription = database.Subscription{UserID: arg.UserID, CreatedAt: t}
+	}
+	subscription.UpdatedAt = t
+	subscription.Status = arg.Status
+	subscription.CurrentPeriodEnd = arg.CurrentPeriodEnd
+	subscription.GracePeriodEnd = arg.GracePeriodEnd
+	s.data.subscriptions[arg.UserID] = subscription
+	return subscription, nil
+}
+
+// MarkSubscriptionPastDue leaves expired subscriptions alone
+func (s *Store) MarkSubscriptionPastDue(ctx context.Context, arg database.MarkSubscriptionPastDueParams) (int64, error) {
+	defer s.lock()()
+
+	subscription, ok := s.data.subscriptions[arg.UserID]
+	if !ok || subscription.Status == "expired" {
+		return 0, nil
+	}
+	subscription.Status = "past_due"
+	subscription.GracePeriodEnd = arg.GracePeriodEnd
+	subscription.UpdatedAt = now()
+	s.data.subscriptions[arg.UserID] = subscription
+	return 1, nil
+}
+
+// CancelSubscription leaves expired subscriptions alone
+func (s *Store) CancelSubscription(ctx context.Context, userID uuid.UUID) (int64, error) {
+	defer s.lock()()
+
+	subscription, ok := s.data.subscriptions[userID]
+	if !ok || subscription.Status == "expired" {
+		return 0, nil
+	}
+	subscription.Status = "cancelled"
+	subscription.GracePeriodEnd = sql.NullTime{}
+	subscription.UpdatedAt = now()
+	s.data.subscriptions[userID] = subscription
+	return 1, nil
+}
+
+// ExpireLapsedSubscriptions expires subscriptions past their period, or
+// their grace period if a payment failed, and returns their users
+func (s *Store) ExpireLapsedSubscriptions(ctx context.Context) ([]uuid.UUID, error) {
+	defer s.lock()()
+
+	t := now()
+	var expired []uuid.UUID
+	for userID, subscription := range s.data.subscriptions {
+		end := subscription.CurrentPeriodEnd
+		switch subscription.Status {
+		case "active", "cancelled":
+		case "past_due":
+			if subscription.GracePeriodEnd.Valid {
+				end = subscription.GracePeriodEnd.Time
+			}
+		default:
+			continue
+		}
+		if !end.Before(t) {
+			continue
+		}
+		subscription.Status = "expired"
+		subscription.UpdatedAt = t
+		s.data.subscriptions[userID] = subscription
+		expired = append(expired, userID)
+	}
+	return expired, nil
+}
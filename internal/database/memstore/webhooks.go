@@ -0,0 +1,329 @@
+package memstore
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// leaseDuration is how long a claimed outbox event or webhook delivery is
+// hidden from other claims, matching the Postgres queries
+const leaseDuration = time.Minute
+
+// ClaimOutboxEvents leases the oldest due events
+func (s *Store) ClaimOutboxEvents(ctx context.Context, limit int32) ([]database.OutboxEvent, error) {
+	defer s.lock()()
+
+	t := now()
+	var claimed []database.OutboxEvent
+	for i, event := range s.data.outboxEvents {
+		if len(claimed) == int(limit) {
+			break
+		}
+		if event.DispatchedAt.Valid || event.DeadAt.Valid || event.NextAttemptAt.After(t) {
+			continue
+		}
+		event.NextAttemptAt = t.Add(leaseDuration)
+		s.data.outboxEvents[i] = event
+		claimed = append(claimed, event)
+	}
+	return claimed, nil
+}
+
+func (s *Store) MarkOutboxEventDispatched(ctx context.Context, id uuid.UUID) error {
+	defer s.lock()()
+
+	if i := s.outboxEvent(id); i >= 0 {
+		event := &s.data.outboxEvents[i]
+		event.DispatchedAt = sql.NullTime{Time: now(), Valid: true}
+		event.Attempts++
+		event.LastError = sql.NullString{}
+	}
+	return nil
+}
+
+func (s *Store) MarkOutboxEventFailed(ctx context.Context, arg database.MarkOutboxEventFailedParams) error {
+	defer s.lock()()
+
+	if i := s.outboxEvent(arg.ID); i >= 0 {
+		event := &s.data.outboxEvents[i]
+		event.Attempts++
+		event.LastError = arg.LastError
+		event.NextAttemptAt = arg.NextAttemptAt
+	}
+	return nil
+}
+
+func (s *Store) MarkOutboxEventDead(ctx context.Context, arg database.MarkOutboxEventDeadParams) error {
+	defer s.lock()()
+
+	if i := s.outboxEvent(arg.ID); i >= 0 {
+		event := &s.data.outboxEvents[i]
+		event.Attempts++
+		event.LastError = arg.LastError
+		event.DeadAt = sql.NullTime{Time: now(), Valid: true}
+	}
+	return nil
+}
+
+func (s *Store) GetOutboxEventSubscribers(ctx context.Context, eventID uuid.UUID) ([]string, error) {
+	defer s.lock()()
+
+	var subscribers []string
+	for _, handled := range s.data.outboxEventSubscribers {
+		if handled.EventID == eventID {
+			subscribers = append(subscribers, handled.Subscriber)
+		}
+	}
+	return subscribers, nil
+}
+
+func (s *Store) CreateOutboxEventSubscriber(ctx context.Context, arg database.CreateOutboxEventSubscriberParams) error {
+	defer s.lock()()
+
+	if s.outboxEvent(arg.EventID) < 0 {
+		return ErrForeignKey
+	}
+	if slices.ContainsFunc(s.data.outboxEventSubscribers, func(handled database.OutboxEventSubscriber) bool {
+		return handled.EventID == arg.EventID && handled.Subscriber == arg.Subscriber
+	}) {
+		return nil
+	}
+	s.data.outboxEventSubscribers = append(s.data.outboxEventSubscribers, database.OutboxEventSubscriber{
+		EventID:    arg.EventID,
+		Subscriber: arg.Subscriber,
+		HandledAt:  now(),
+	})
+	return nil
+}
+
+// DeleteOutboxEventsBefore deletes finished events created before createdAt,
+// along with the record of who handled them
+func (s *Store) DeleteOutboxEventsBefore(ctx context.Context, createdAt time.Time) (int64, error) {
+	defer s.lock()()
+
+	deleted := map[uuid.UUID]bool{}
+	s.data.outboxEvents = slices.DeleteFunc(s.data.outboxEvents, func(event database.OutboxEvent) bool {
+		finished := event.DispatchedAt.Valid || event.DeadAt.Valid
+		if finished && event.CreatedAt.Before(createdAt) {
+			deleted[event.ID] = true
+		}
+		return deleted[event.ID]
+	})
+	s.data.outboxEventSubscribers = slices.DeleteFunc(s.data.outboxEventSubscribers, func(handled database.OutboxEventSubscriber) bool {
+		return deleted[handled.EventID]
+	})
+	return int64(len(deleted)), nil
+}
+
+func (s *Store) outboxEvent(id uuid.UUID) int {
+	return slices.IndexFunc(s.data.outboxEvents, func(e database.OutboxEvent) bool {
+		return e.ID == id
+	})
+}
+
+func (s *Store) CreateWebhookSubscription(ctx context.Context, arg database.CreateWebhookSubscriptionParams) (database.WebhookSubscription, error) {
+	defer s.lock()()
+
+	if !s.usersExist(arg.UserID) {
+		return database.WebhookSubscription{}, ErrForeignKey
+	}
+	t := now()
+	subscription := database.WebhookSubscription{
+		ID:         uuid.New(),
+		CreatedAt:  t,
+		UpdatedAt:  t,
+		UserID:     arg.UserID,
+		Url:        arg.Url,
+		EventTypes: slices.Clone(arg.EventTypes),
+		Secret:     arg.Secret,
+		Active:     true,
+	}
+	s.data.webhookSubscriptions = append(s.data.webhookSubscriptions, subscription)
+	return subscription, nil
+}
+
+func (s *Store) GetWebhookSubscription(ctx context.Context, arg database.GetWebhookSubscriptionParams) (database.WebhookSubscription, error) {
+	defer s.lock()()
+
+	for _, subscription := range s.data.webhookSubscriptions {
+		if subscription.ID == arg.ID && subscription.UserID == arg.UserID {
+			return subscription, nil
+		}
+	}
+	return database.WebhookSubscription{}, sql.ErrNoRows
+}
+
+// GetWebhookSubscriptionsByUser returns subscriptions oldest first
+func (s *Store) GetWebhookSubscriptionsByUser(ctx context.Context, userID uuid.UUID) ([]database.WebhookSubscription, error) {
+	defer s.lock()()
+
+	var subscriptions []database.WebhookSubscription
+	for _, subscription := range s.data.webhookSubscriptions {
+		if subscription.UserID == userID {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	return subscriptions, nil
+}
+
+// DeleteWebhookSubscription cascades to the subscription's deliveries
+func (s *Store) DeleteWebhookSubscription(ctx context.Context, arg database.DeleteWebhookSubscriptionParams) (int64, error) {
+	defer s.lock()()
+
+	before := len(s.data.webhookSubscriptions)
+	s.data.webhookSubscriptions = slices.DeleteFunc(s.data.webhookSubscriptions, func(sub database.WebhookSubscription) bool {
+		return sub.ID == arg.ID && sub.UserID == arg.UserID
+	})
+	if len(s.data.webhookSubscriptions) == before {
+		return 0, nil
+	}
+	s.data.webhookDeliveries = slices.DeleteFunc(s.data.webhookDeliveries, func(d database.WebhookDelivery) bool {
+		return d.SubscriptionID == arg.ID
+	})
+	return 1, nil
+}
+
+// EnqueueWebhookDeliveries queues a delivery for every active subscription
+// the user has to the event's type
+func (s *Store) EnqueueWebhookDeliveries(ctx context.Context, arg database.EnqueueWebhookDeliveriesParams) (int64, error) {
+	defer s.lock()()
+
+	t := now()
+	var enqueued int64
+	for _, subscription := range s.data.webhookSubscriptions {
+		if !subscription.Active || subscription.UserID != arg.UserID || !slices.Contains(subscription.EventTypes, arg.EventType) {
+			continue
+		}
+		s.data.webhookDeliveries = append(s.data.webhookDeliveries, database.WebhookDelivery{
+			ID:             uuid.New(),
+			CreatedAt:      t,
+			UpdatedAt:      t,
+			SubscriptionID: subscription.ID,
+			EventType:      arg.EventType,
+			Payload:        arg.Payload,
+			Status:         "pending",
+			NextAttemptAt:  t,
+		})
+		enqueued++
+	}
+	return enqueued, nil
+}
+
+// ClaimWebhookDeliveries leases the pending deliveries that have been due
+// longest
+func (s *Store) ClaimWebhookDeliveries(ctx context.Context, limit int32) ([]database.ClaimWebhookDeliveriesRow, error) {
+	defer s.lock()()
+
+	t := now()
+	var due []int
+	for i, delivery := range s.data.webhookDeliveries {
+		if delivery.Status == "pending" && !delivery.NextAttemptAt.After(t) {
+			due = append(due, i)
+		}
+	}
+	slices.SortStableFunc(due, func(a, b int) int {
+		return s.data.webhookDeliveries[a].NextAttemptAt.Compare(s.data.webhookDeliveries[b].NextAttemptAt)
+	})
+
+	var claimed []database.ClaimWebhookDeliveriesRow
+	for _, i := range due[:min(int(limit), len(due))] {
+		delivery := &s.data.webhookDeliveries[i]
+		delivery.NextAttemptAt = t.Add(leaseDuration)
+		delivery.UpdatedAt = t
+
+		j := slices.IndexFunc(s.data.webhookSubscriptions, func(sub database.WebhookSubscription) bool {
+			return sub.ID == delivery.SubscriptionID
+		})
+		claimed = append(claimed, database.ClaimWebhookDeliveriesRow{
+			ID:        delivery.ID,
+			EventType: delivery.EventType,
+			Payload:   delivery.Payload,
+			Attempts:  delivery.Attempts,
+			Url:       s.data.webhookSubscriptions[j].Url,
+			Secret:    s.data.webhookSubscriptions[j].Secret,
+		})
+	}
+	return claimed, nil
+}
+
+func (s *Store) MarkWebhookDelivered(ctx context.Context, arg database.MarkWebhookDeliveredParams) error {
+	defer s.lock()()
+
+	if i := s.webhookDelivery(arg.ID); i >= 0 {
+		t := now()
+		delivery := &s.data.webhookDeliveries[i]
+		delivery.Status = "delivered"
+		delivery.Attempts++
+		delivery.LastAttemptAt = sql.NullTime{Time: t, Valid: true}
+		delivery.LastStatusCode = arg.LastStatusCode
+		delivery.LastError = sql.NullString{}
+		delivery.DeliveredAt = sql.NullTime{Time: t, Valid: true}
+		delivery.UpdatedAt = t
+	}
+	return nil
+}
+
+func (s *Store) MarkWebhookDeliveryFailed(ctx context.Context, arg database.MarkWebhookDeliveryFailedParams) error {
+	defer s.lock()()
+
+	if i := s.webhookDelivery(arg.ID); i >= 0 {
+		t := now()
+		delivery := &s.data.webhookDeliveries[i]
+		delivery.Status = arg.Status
+		delivery.Attempts++
+		delivery.LastAttemptAt = sql.NullTime{Time: t, Valid: true}
+		delivery.LastStatusCode = arg.LastStatusCode
+		delivery.LastError = arg.LastError
+		delivery.NextAttemptAt = arg.NextAttemptAt
+		delivery.UpdatedAt = t
+	}
+	return nil
+}
+
+// GetWebhookDeliveries returns a subscription's latest deliveries, newest
+// first
+func (s *Store) GetWebhookDeliveries(ctx context.Context, arg database.GetWebhookDeliveriesParams) ([]database.WebhookDelivery, error) {
+	defer s.lock()()
+
+	var deliveries []database.WebhookDelivery
+	for _, delivery := range s.data.webhookDeliveries {
+		if delivery.SubscriptionID == arg.SubscriptionID {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	slices.SortStableFunc(deliveries, func(a, b database.WebhookDelivery) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return bytes.Compare(b.ID[:], a.ID[:])
+	})
+	return deliveries[:min(int(arg.Limit), len(deliveries))], nil
+}
+
+func (s *Store) RedeliverWebhook(ctx context.Context, arg database.RedeliverWebhookParams) (int64, error) {
+	defer s.lock()()
+
+	i := s.webhookDelivery(arg.ID)
+	if i < 0 || s.data.webhookDeliveries[i].SubscriptionID != arg.SubscriptionID {
+		return 0, nil
+	}
+	t := now()
+	delivery := &s.data.webhookDeliveries[i]
+	delivery.Status = "pending"
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = t
+	delivery.UpdatedAt = t
+	return 1, nil
+}
+
+func (s *Store) webhookDelivery(id uuid.UUID) int {
+	return slices.IndexFunc(s.data.webhookDeliveries, func(d database.WebhookDelivery) bool {
+		return d.ID == id
+	})
+}
@@ -6,17 +6,129 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type Block struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
 type Chirp struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	Body      string
 	UserID    uuid.UUID
+	ReplyToID uuid.NullUUID
+}
+
+type ChirpEvent struct {
+	ID        int64
+	CreatedAt time.Time
+	Type      string
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	Body      sql.NullString
+	TxID      int64
+}
+
+type ChirpLike struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type Conversation struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type ConversationMember struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	JoinedAt       time.Time
+	LastReadAt     sql.NullTime
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FollowedID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type Message struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	Body           string
+}
+
+type Mute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type MutedKeyword struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Keyword   string
+}
+
+type Notification struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	ActorID   uuid.UUID
+	Type      string
+	ChirpID   uuid.NullUUID
+	ReadAt    sql.NullTime
+}
+
+type NotificationPreference struct {
+	UserID  uuid.UUID
+	Type    string
+	Enabled bool
+}
+
+type OutboxEvent struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	Type          string
+	Payload       json.RawMessage
+	Attempts      int32
+	NextAttemptAt time.Time
+	LastError     sql.NullString
+	DispatchedAt  sql.NullTime
+	DeadAt        sql.NullTime
+}
+
+type OutboxEventSubscriber struct {
+	EventID    uuid.UUID
+	Subscriber string
+	HandledAt  time.Time
+}
+
+type PolkaEvent struct {
+	ID         string
+	Event      string
+	ReceivedAt time.Time
+}
+
+type RateLimitBucket struct {
+	Key       string
+	Tokens    float64
+	UpdatedAt time.Time
+	FullAt    time.Time
 }
 
 type RefreshToken struct {
@@ -28,6 +140,15 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime
 }
 
+type Subscription struct {
+	UserID           uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Status           string
+	CurrentPeriodEnd time.Time
+	GracePeriodEnd   sql.NullTime
+}
+
 type User struct {
 	ID             uuid.UUID
 	CreatedAt      time.Time
@@ -35,4 +156,32 @@ type User struct {
 	Email          string
 	HashedPassword string
 	IsChirpyRed    bool
+	SuspendedAt    sql.NullTime
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	SubscriptionID uuid.UUID
+	EventType      string
+	Payload        json.RawMessage
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastAttemptAt  sql.NullTime
+	LastStatusCode sql.NullInt32
+	LastError      sql.NullString
+	DeliveredAt    sql.NullTime
+}
+
+type WebhookSubscription struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Url        string
+	EventTypes []string
+	Secret     string
+	Active     bool
 }
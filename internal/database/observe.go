@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// QueryObserver is called as each query starts, with the sqlc query name,
+// e.g. "GetUserByEmail". The func it returns is called with the query's
+// error once it has finished.
+type QueryObserver func(ctx context.Context, name string) (done func(err error))
+
+type observedDB struct {
+	DBTX
+	observe QueryObserver
+}
+
+// Observe wraps db so observe sees every query run through it. A nil
+// observe returns db unchanged.
+func Observe(db DBTX, observe QueryObserver) DBTX {
+	if observe == nil {
+		return db
+	}
+	return &observedDB{DBTX: db, observe: observe}
+}
+
+func (o *observedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	done := o.observe(ctx, QueryName(query))
+	result, err := o.DBTX.ExecContext(ctx, query, args...)
+	done(err)
+	return result, err
+}
+
+func (o *observedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	done := o.observe(ctx, QueryName(query))
+	rows, err := o.DBTX.QueryContext(ctx, query, args...)
+	done(err)
+	return rows, err
+}
+
+func (o *observedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	done := o.observe(ctx, QueryName(query))
+	row := o.DBTX.QueryRowContext(ctx, query, args...)
+	done(row.Err())
+	return row
+}
+
+// QueryName extracts the name from the "-- name: <name> :<kind>" comment
+// sqlc starts every query with
+func QueryName(query string) string {
+	rest, ok := strings.CutPrefix(query, "-- name: ")
+	if !ok {
+		return "unknown"
+	}
+	name, _, _ := strings.Cut(rest, " ")
+	return name
+}
@@ -0,0 +1,19 @@
+package database
+
+import "testing"
+
+func TestQueryName(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{createUser, "CreateUser"},
+		{getChirpsByUserID, "GetChirpsByUserID"},
+		{"SELECT 1", "unknown"},
+	}
+	for _, tt := range tests {
+		if got := QueryName(tt.query); got != tt.want {
+			t.Errorf("QueryName(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
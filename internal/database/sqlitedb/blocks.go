@@ -0,0 +1,91 @@
+package sqlitedb
+
+import (
+	"context"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func (s *Store) CreateFollow(ctx context.Context, arg database.CreateFollowParams) (int64, error) {
+	return s.q.CreateFollow(ctx, CreateFollowParams{
+		FollowerID: arg.FollowerID,
+		FollowedID: arg.FollowedID,
+		CreatedAt:  now(),
+	})
+}
+
+func (s *Store) DeleteFollow(ctx context.Context, arg database.DeleteFollowParams) (int64, error) {
+	return s.q.DeleteFollow(ctx, DeleteFollowParams(arg))
+}
+
+func (s *Store) GetFollowsByFollower(ctx context.Context, followerID uuid.UUID) ([]database.Follow, error) {
+	rows, err := s.q.GetFollowsByFollower(ctx, followerID)
+	return convertRows(rows, err, func(f Follow) database.Follow { return database.Follow(f) })
+}
+
+func (s *Store) CreateBlock(ctx context.Context, arg database.CreateBlockParams) error {
+	return s.q.CreateBlock(ctx, CreateBlockParams{
+		BlockerID: arg.BlockerID,
+		BlockedID: arg.BlockedID,
+		CreatedAt: now(),
+	})
+}
+
+func (s *Store) DeleteBlock(ctx context.Context, arg database.DeleteBlockParams) (int64, error) {
+	return s.q.DeleteBlock(ctx, DeleteBlockParams(arg))
+}
+
+func (s *Store) GetBlocksByBlocker(ctx context.Context, blockerID uuid.UUID) ([]database.Block, error) {
+	rows, err := s.q.GetBlocksByBlocker(ctx, blockerID)
+	return convertRows(rows, err, func(b Block) database.Block { return database.Block(b) })
+}
+
+func (s *Store) GetBlockRelatedUserIDs(ctx context.Context, blockerID uuid.UUID) ([]uuid.UUID, error) {
+	return s.q.GetBlockRelatedUserIDs(ctx, blockerID)
+}
+
+func (s *Store) IsBlockedBetween(ctx context.Context, arg database.IsBlockedBetweenParams) (bool, error) {
+	blocked, err := s.q.IsBlockedBetween(ctx, IsBlockedBetweenParams(arg))
+	return blocked != 0, err
+}
+
+func (s *Store) CreateMute(ctx context.Context, arg database.CreateMuteParams) error {
+	return s.q.CreateMute(ctx, CreateMuteParams{
+		MuterID:   arg.MuterID,
+		MutedID:   arg.MutedID,
+		CreatedAt: now(),
+	})
+}
+
+func (s *Store) DeleteMute(ctx context.Context, arg database.DeleteMuteParams) (int64, error) {
+	return s.q.DeleteMute(ctx, DeleteMuteParams(arg))
+}
+
+func (s *Store) GetMutesByMuter(ctx context.Context, muterID uuid.UUID) ([]database.Mute, error) {
+	rows, err := s.q.GetMutesByMuter(ctx, muterID)
+	return convertRows(rows, err, func(m Mute) database.Mute { return database.Mute(m) })
+}
+
+func (s *Store) GetMutedUserIDs(ctx context.Context, muterID uuid.UUID) ([]uuid.UUID, error) {
+	return s.q.GetMutedUserIDs(ctx, muterID)
+}
+
+func (s *Store) CreateMutedKeyword(ctx context.Context, arg database.CreateMutedKeywordParams) (database.MutedKeyword, error) {
+	keyword, err := s.q.CreateMutedKeyword(ctx, CreateMutedKeywordParams{
+		ID:        uuid.New(),
+		CreatedAt: now(),
+		UserID:    arg.UserID,
+		Keyword:   arg.Keyword,
+	})
+	return database.MutedKeyword(keyword), uniqueViolation(err)
+}
+
+func (s *Store) DeleteMutedKeyword(ctx context.Context, arg database.DeleteMutedKeywordParams) (int64, error) {
+	return s.q.DeleteMutedKeyword(ctx, DeleteMutedKeywordParams(arg))
+}
+
+func (s *Store) GetMutedKeywords(ctx context.Context, userID uuid.UUID) ([]database.MutedKeyword, error) {
+	rows, err := s.q.GetMutedKeywords(ctx, userID)
+	return convertRows(rows, err, func(k MutedKeyword) database.MutedKeyword { return database.MutedKeyword(k) })
+}
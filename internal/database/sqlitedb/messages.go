@@ -0,0 +1,103 @@
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func (s *Store) CreateConversation(ctx context.Context) (database.Conversation, error) {
+	t := now()
+	conversation, err := s.q.CreateConversation(ctx, CreateConversationParams{
+		ID:        uuid.New(),
+		CreatedAt: t,
+		UpdatedAt: t,
+	})
+	return database.Conversation(conversation), err
+}
+
+func (s *Store) AddConversationMember(ctx context.Context, arg database.AddConversationMemberParams) error {
+	return uniqueViolation(s.q.AddConversationMember(ctx, AddConversationMemberParams{
+		ConversationID: arg.ConversationID,
+		UserID:         arg.UserID,
+		JoinedAt:       now(),
+	}))
+}
+
+func (s *Store) GetConversationMembers(ctx context.Context, conversationID uuid.UUID) ([]database.ConversationMember, error) {
+	rows, err := s.q.GetConversationMembers(ctx, conversationID)
+	return convertRows(rows, err, func(m ConversationMember) database.ConversationMember {
+		return database.ConversationMember(m)
+	})
+}
+
+func (s *Store) GetConversationsForUser(ctx context.Context, userID uuid.UUID) ([]database.GetConversationsForUserRow, error) {
+	rows, err := s.q.GetConversationsForUser(ctx, userID)
+	return convertRows(rows, err, func(c GetConversationsForUserRow) database.GetConversationsForUserRow {
+		return database.GetConversationsForUserRow(c)
+	})
+}
+
+func (s *Store) GetConversationForUser(ctx context.Context, arg database.GetConversationForUserParams) (database.GetConversationForUserRow, error) {
+	conversation, err := s.q.GetConversationForUser(ctx, GetConversationForUserParams(arg))
+	return database.GetConversationForUserRow(conversation), err
+}
+
+func (s *Store) TouchConversation(ctx context.Context, id uuid.UUID) error {
+	return s.q.TouchConversation(ctx, TouchConversationParams{
+		UpdatedAt: now(),
+		ID:        id,
+	})
+}
+
+func (s *Store) MarkConversationRead(ctx context.Context, arg database.MarkConversationReadParams) error {
+	return s.q.MarkConversationRead(ctx, MarkConversationReadParams{
+		LastReadAt:     sql.NullTime{Time: now(), Valid: true},
+		ConversationID: arg.ConversationID,
+		UserID:         arg.UserID,
+	})
+}
+
+func (s *Store) HasBlockWithConversationMembers(ctx context.Context, arg database.HasBlockWithConversationMembersParams) (bool, error) {
+	blocked, err := s.q.HasBlockWithConversationMembers(ctx, HasBlockWithConversationMembersParams(arg))
+	return blocked != 0, err
+}
+
+func (s *Store) CreateMessage(ctx context.Context, arg database.CreateMessageParams) (database.Message, error) {
+	t := now()
+	message, err := s.q.CreateMessage(ctx, CreateMessageParams{
+		ID:             uuid.New(),
+		CreatedAt:      t,
+		UpdatedAt:      t,
+		ConversationID: arg.ConversationID,
+		SenderID:       arg.SenderID,
+		Body:           arg.Body,
+	})
+	return database.Message(message), err
+}
+
+func (s *Store) GetMessage(ctx context.Context, arg database.GetMessageParams) (database.Message, error) {
+	message, err := s.q.GetMessage(ctx, GetMessageParams(arg))
+	return database.Message(message), err
+}
+
+func (s *Store) GetMessages(ctx context.Context, arg database.GetMessagesParams) ([]database.Message, error) {
+	rows, err := s.q.GetMessages(ctx, GetMessagesParams{
+		ConversationID: arg.ConversationID,
+		Limit:          int64(arg.Limit),
+	})
+	return convertRows(rows, err, func(m Message) database.Message { return database.Message(m) })
+}
+
+func (s *Store) GetMessagesBefore(ctx context.Context, arg database.GetMessagesBeforeParams) ([]database.Message, error) {
+	rows, err := s.q.GetMessagesBefore(ctx, GetMessagesBeforeParams{
+		ConversationID: arg.ConversationID,
+		// Timestamps compare as text, so the cursor needs the stored format
+		BeforeCreatedAt: arg.BeforeCreatedAt.UTC(),
+		BeforeID:        arg.BeforeID,
+		MaxResults:      int64(arg.MaxResults),
+	})
+	return convertRows(rows, err, func(m Message) database.Message { return database.Message(m) })
+}
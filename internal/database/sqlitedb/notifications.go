@@ -0,0 +1,71 @@
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func (s *Store) CreateNotification(ctx context.Context, arg database.CreateNotificationParams) (database.Notification, error) {
+	notification, err := s.q.CreateNotification(ctx, CreateNotificationParams{
+		ID:        uuid.New(),
+		CreatedAt: now(),
+		UserID:    arg.UserID,
+		ActorID:   arg.ActorID,
+		Type:      arg.Type,
+		ChirpID:   arg.ChirpID,
+	})
+	return database.Notification(notification), err
+}
+
+func (s *Store) GetNotifications(ctx context.Context, arg database.GetNotificationsParams) ([]database.Notification, error) {
+	rows, err := s.q.GetNotifications(ctx, GetNotificationsParams{
+		UserID: arg.UserID,
+		Limit:  int64(arg.Limit),
+	})
+	return convertRows(rows, err, func(n Notification) database.Notification { return database.Notification(n) })
+}
+
+func (s *Store) GetUnreadNotifications(ctx context.Context, arg database.GetUnreadNotificationsParams) ([]database.Notification, error) {
+	rows, err := s.q.GetUnreadNotifications(ctx, GetUnreadNotificationsParams{
+		UserID: arg.UserID,
+		Limit:  int64(arg.Limit),
+	})
+	return convertRows(rows, err, func(n Notification) database.Notification { return database.Notification(n) })
+}
+
+func (s *Store) CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return s.q.CountUnreadNotifications(ctx, userID)
+}
+
+func (s *Store) MarkNotificationRead(ctx context.Context, arg database.MarkNotificationReadParams) (int64, error) {
+	return s.q.MarkNotificationRead(ctx, MarkNotificationReadParams{
+		ReadAt: sql.NullTime{Time: now(), Valid: true},
+		ID:     arg.ID,
+		UserID: arg.UserID,
+	})
+}
+
+func (s *Store) MarkAllNotificationsRead(ctx context.Context, userID uuid.UUID) error {
+	return s.q.MarkAllNotificationsRead(ctx, MarkAllNotificationsReadParams{
+		ReadAt: sql.NullTime{Time: now(), Valid: true},
+		UserID: userID,
+	})
+}
+
+func (s *Store) GetNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]database.NotificationPreference, error) {
+	rows, err := s.q.GetNotificationPreferences(ctx, userID)
+	return convertRows(rows, err, func(p NotificationPreference) database.NotificationPreference {
+		return database.NotificationPreference(p)
+	})
+}
+
+func (s *Store) UpsertNotificationPreference(ctx context.Context, arg database.UpsertNotificationPreferenceParams) error {
+	return s.q.UpsertNotificationPreference(ctx, UpsertNotificationPreferenceParams(arg))
+}
+
+func (s *Store) IsNotificationEnabled(ctx context.Context, arg database.IsNotificationEnabledParams) (bool, error) {
+	return s.q.IsNotificationEnabled(ctx, IsNotificationEnabledParams(arg))
+}
@@ -0,0 +1,313 @@
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Store is the database.Store backed by SQLite. SQLite can't generate IDs or
+// timestamps the way the Postgres queries do, so Store fills them in before
+// calling the generated queries.
+type Store struct {
+	q       *Queries
+	db      *sql.DB
+	observe database.QueryObserver
+}
+
+var _ database.Store = (*Store)(nil)
+
+// Open opens the SQLite database at path with foreign keys enforced
+func Open(path string) (*sql.DB, error) {
+	dsn := "file:" + path +
+		"?_pragma=foreign_keys(1)" +
+		"&_pragma=busy_timeout(5000)" +
+		"&_pragma=journal_mode(WAL)" +
+		"&_time_format=sqlite"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, so serialise access here rather than
+	// surfacing SQLITE_BUSY to handlers
+	db.SetMaxOpenConns(1)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// NewStore returns a Store on db. observe, if not nil, sees every query
+// including those run in transactions.
+func NewStore(db *sql.DB, observe database.QueryObserver) *Store {
+	return &Store{
+		q:       New(database.Observe(db, observe)),
+		db:      db,
+		observe: observe,
+	}
+}
+
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+// uniqueViolation marks SQLite's unique and primary key constraint errors
+// so database.IsUniqueViolation recognises them
+func uniqueViolation(err error) error {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && (sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE ||
+		sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY) {
+		return fmt.Errorf("%w: %w", database.ErrUniqueViolation, err)
+	}
+	return err
+}
+
+// convertRows converts the rows a generated query returned to database's
+// types, passing its error through
+func convertRows[T, R any](rows []T, err error, convert func(T) R) ([]R, error) {
+	if err != nil {
+		return nil, err
+	}
+	var items []R
+	for _, row := range rows {
+		items = append(items, convert(row))
+	}
+	return items, nil
+}
+
+func (s *Store) InTx(ctx context.Context, fn func(s database.Store) error) error {
+	if s.db == nil {
+		// Already inside a transaction
+		return fn(s)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(&Store{q: New(database.Observe(tx, s.observe))})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	t := now()
+	user, err := s.q.CreateUser(ctx, CreateUserParams{
+		ID:             uuid.New(),
+		CreatedAt:      t,
+		UpdatedAt:      t,
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+		IsChirpyRed:    arg.IsChirpyRed,
+	})
+	return database.User(user), uniqueViolation(err)
+}
+
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+	user, err := s.q.GetUserByEmail(ctx, email)
+	return database.User(user), err
+}
+
+func (s *Store) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	user, err := s.q.GetUserByID(ctx, id)
+	return database.User(user), err
+}
+
+func (s *Store) UpdateUser(ctx context.Context, arg database.UpdateUserParams) (database.User, error) {
+	user, err := s.q.UpdateUser(ctx, UpdateUserParams{
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+		UpdatedAt:      now(),
+		ID:             arg.ID,
+	})
+	return database.User(user), uniqueViolation(err)
+}
+
+func (s *Store) UpdateUserChirpyRed(ctx context.Context, arg database.UpdateUserChirpyRedParams) error {
+	return s.q.UpdateUserChirpyRed(ctx, UpdateUserChirpyRedParams{
+		IsChirpyRed: arg.IsChirpyRed,
+		ID:          arg.ID,
+	})
+}
+
+func (s *Store) SuspendUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+	t := now()
+	user, err := s.q.SuspendUser(ctx, SuspendUserParams{
+		SuspendedAt: sql.NullTime{Time: t, Valid: true},
+		UpdatedAt:   t,
+		ID:          id,
+	})
+	return database.User(user), err
+}
+
+func (s *Store) ListUsers(ctx context.Context) ([]database.User, error) {
+	rows, err := s.q.ListUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var users []database.User
+	for _, row := range rows {
+		users = append(users, database.User(row))
+	}
+	return users, nil
+}
+
+func (s *Store) Reset(ctx context.Context) error {
+	return s.q.Reset(ctx)
+}
+
+func (s *Store) CreatChirp(ctx context.Context, arg database.CreatChirpParams) (database.Chirp, error) {
+	t := now()
+	chirp, err := s.q.CreatChirp(ctx, CreatChirpParams{
+		ID:        uuid.New(),
+		CreatedAt: t,
+		UpdatedAt: t,
+		Body:      arg.Body,
+		UserID:    arg.UserID,
+		ReplyToID: arg.ReplyToID,
+	})
+	return database.Chirp(chirp), err
+}
+
+func (s *Store) GetChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	chirp, err := s.q.GetChirp(ctx, id)
+	return database.Chirp(chirp), err
+}
+
+func (s *Store) GetChirps(ctx context.Context) ([]database.Chirp, error) {
+	return chirps(s.q.GetChirps(ctx))
+}
+
+func (s *Store) GetChirpsDesc(ctx context.Context) ([]database.Chirp, error) {
+	return chirps(s.q.GetChirpsDesc(ctx))
+}
+
+func (s *Store) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	return chirps(s.q.GetChirpsByUserID(ctx, userID))
+}
+
+func chirps(rows []Chirp, err error) ([]database.Chirp, error) {
+	if err != nil {
+		return nil, err
+	}
+	var items []database.Chirp
+	for _, row := range rows {
+		items = append(items, database.Chirp(row))
+	}
+	return items, nil
+}
+
+func (s *Store) DeleteChirp(ctx context.Context, arg database.DeleteChirpParams) (database.Chirp, error) {
+	chirp, err := s.q.DeleteChirp(ctx, DeleteChirpParams(arg))
+	return database.Chirp(chirp), err
+}
+
+func (s *Store) CreateChirpLike(ctx context.Context, arg database.CreateChirpLikeParams) (int64, error) {
+	return s.q.CreateChirpLike(ctx, CreateChirpLikeParams{
+		ChirpID:   arg.ChirpID,
+		UserID:    arg.UserID,
+		CreatedAt: now(),
+	})
+}
+
+func (s *Store) DeleteChirpLike(ctx context.Context, arg database.DeleteChirpLikeParams) (int64, error) {
+	return s.q.DeleteChirpLike(ctx, DeleteChirpLikeParams(arg))
+}
+
+func (s *Store) GetLatestChirpEvent(ctx context.Context) (database.ChirpEvent, error) {
+	event, err := s.q.GetLatestChirpEvent(ctx)
+	return chirpEvent(event), err
+}
+
+func (s *Store) GetChirpEvent(ctx context.Context, id int64) (database.ChirpEvent, error) {
+	event, err := s.q.GetChirpEvent(ctx, id)
+	return chirpEvent(event), err
+}
+
+// GetChirpEventsAfter ignores arg.TxID, which is always 0 here: SQLite has
+// a single writer, so events commit in ID order
+func (s *Store) GetChirpEventsAfter(ctx context.Context, arg database.GetChirpEventsAfterParams) ([]database.ChirpEvent, error) {
+	rows, err := s.q.GetChirpEventsAfter(ctx, GetChirpEventsAfterParams{
+		ID:    arg.ID,
+		Limit: int64(arg.Limit),
+	})
+	return convertRows(rows, err, chirpEvent)
+}
+
+func (s *Store) DeleteChirpEventsBefore(ctx context.Context, createdAt time.Time) (int64, error) {
+	return s.q.DeleteChirpEventsBefore(ctx, createdAt.UTC())
+}
+
+func chirpEvent(e ChirpEvent) database.ChirpEvent {
+	return database.ChirpEvent{
+		ID:        e.ID,
+		CreatedAt: e.CreatedAt,
+		Type:      e.Type,
+		ChirpID:   e.ChirpID,
+		UserID:    e.UserID,
+		Body:      e.Body,
+	}
+}
+
+func (s *Store) CreateRefreshTokens(ctx context.Context, arg database.CreateRefreshTokensParams) (database.RefreshToken, error) {
+	t := now()
+	token, err := s.q.CreateRefreshTokens(ctx, CreateRefreshTokensParams{
+		Token:     arg.Token,
+		CreatedAt: t,
+		UpdatedAt: t,
+		UserID:    arg.UserID,
+		ExpiresAt: arg.ExpiresAt.UTC(),
+		RevokedAt: arg.RevokedAt,
+	})
+	return database.RefreshToken(token), err
+}
+
+func (s *Store) GetRefreshTokenByToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	refreshToken, err := s.q.GetRefreshTokenByToken(ctx, GetRefreshTokenByTokenParams{
+		Token: token,
+		Now:   now(),
+	})
+	return database.RefreshToken(refreshToken), err
+}
+
+func (s *Store) UpdateRefreshToken(ctx context.Context, token string) error {
+	t := now()
+	return s.q.UpdateRefreshToken(ctx, UpdateRefreshTokenParams{
+		RevokedAt: sql.NullTime{Time: t, Valid: true},
+		UpdatedAt: t,
+		Token:     token,
+	})
+}
+
+func (s *Store) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	t := now()
+	return s.q.RevokeUserRefreshTokens(ctx, RevokeUserRefreshTokensParams{
+		RevokedAt: sql.NullTime{Time: t, Valid: true},
+		UpdatedAt: t,
+		UserID:    userID,
+	})
+}
+
+func (s *Store) CreateOutboxEvent(ctx context.Context, arg database.CreateOutboxEventParams) (database.OutboxEvent, error) {
+	t := now()
+	event, err := s.q.CreateOutboxEvent(ctx, CreateOutboxEventParams{
+		ID:            uuid.New(),
+		CreatedAt:     t,
+		Type:          arg.Type,
+		Payload:       arg.Payload,
+		NextAttemptAt: t,
+	})
+	return outboxEvent(event), err
+}
@@ -0,0 +1,30 @@
+package sqlitedb
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/exglegaming/Chirpy/internal/database/storetest"
+	"github.com/exglegaming/Chirpy/internal/migrate"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) database.Store {
+		db, err := Open(filepath.Join(t.TempDir(), "chirpy.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		provider, err := migrate.New(db, migrate.SQLite)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := provider.Up(context.Background()); err != nil {
+			t.Fatalf("Couldn't migrate: %v", err)
+		}
+		return NewStore(db, nil)
+	})
+}
@@ -0,0 +1,55 @@
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func (s *Store) RecordPolkaEvent(ctx context.Context, arg database.RecordPolkaEventParams) (int64, error) {
+	return s.q.RecordPolkaEvent(ctx, RecordPolkaEventParams{
+		ID:         arg.ID,
+		Event:      arg.Event,
+		ReceivedAt: now(),
+	})
+}
+
+func (s *Store) UpsertSubscription(ctx context.Context, arg database.UpsertSubscriptionParams) (database.Subscription, error) {
+	t := now()
+	subscription, err := s.q.UpsertSubscription(ctx, UpsertSubscriptionParams{
+		UserID:           arg.UserID,
+		CreatedAt:        t,
+		UpdatedAt:        t,
+		Status:           arg.Status,
+		CurrentPeriodEnd: arg.CurrentPeriodEnd.UTC(),
+		GracePeriodEnd:   utcNullTime(arg.GracePeriodEnd),
+	})
+	return database.Subscription(subscription), err
+}
+
+func (s *Store) MarkSubscriptionPastDue(ctx context.Context, arg database.MarkSubscriptionPastDueParams) (int64, error) {
+	return s.q.MarkSubscriptionPastDue(ctx, MarkSubscriptionPastDueParams{
+		GracePeriodEnd: utcNullTime(arg.GracePeriodEnd),
+		UpdatedAt:      now(),
+		UserID:         arg.UserID,
+	})
+}
+
+func (s *Store) CancelSubscription(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return s.q.CancelSubscription(ctx, CancelSubscriptionParams{
+		UpdatedAt: now(),
+		UserID:    userID,
+	})
+}
+
+func (s *Store) ExpireLapsedSubscriptions(ctx context.Context) ([]uuid.UUID, error) {
+	return s.q.ExpireLapsedSubscriptions(ctx, now())
+}
+
+// utcNullTime converts t to UTC, since timestamps compare as text
+func utcNullTime(t sql.NullTime) sql.NullTime {
+	t.Time = t.Time.UTC()
+	return t
+}
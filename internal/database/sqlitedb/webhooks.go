@@ -0,0 +1,252 @@
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// leaseDuration is how long a claimed outbox event or webhook delivery is
+// hidden from other claims, matching the Postgres queries
+const leaseDuration = time.Minute
+
+func (s *Store) ClaimOutboxEvents(ctx context.Context, limit int32) ([]database.OutboxEvent, error) {
+	t := now()
+	rows, err := s.q.ClaimOutboxEvents(ctx, ClaimOutboxEventsParams{
+		LeaseUntil: t.Add(leaseDuration),
+		Now:        t,
+		MaxResults: int64(limit),
+	})
+	return convertRows(rows, err, outboxEvent)
+}
+
+func (s *Store) MarkOutboxEventDispatched(ctx context.Context, id uuid.UUID) error {
+	return s.q.MarkOutboxEventDispatched(ctx, MarkOutboxEventDispatchedParams{
+		DispatchedAt: sql.NullTime{Time: now(), Valid: true},
+		ID:           id,
+	})
+}
+
+func (s *Store) MarkOutboxEventFailed(ctx context.Context, arg database.MarkOutboxEventFailedParams) error {
+	return s.q.MarkOutboxEventFailed(ctx, MarkOutboxEventFailedParams{
+		LastError:     arg.LastError,
+		NextAttemptAt: arg.NextAttemptAt.UTC(),
+		ID:            arg.ID,
+	})
+}
+
+func (s *Store) MarkOutboxEventDead(ctx context.Context, arg database.MarkOutboxEventDeadParams) error {
+	return s.q.MarkOutboxEventDead(ctx, MarkOutboxEventDeadParams{
+		LastError: arg.LastError,
+		DeadAt:    sql.NullTime{Time: now(), Valid: true},
+		ID:        arg.ID,
+	})
+}
+
+func (s *Store) GetOutboxEventSubscribers(ctx context.Context, eventID uuid.UUID) ([]string, error) {
+	return s.q.GetOutboxEventSubscribers(ctx, eventID)
+}
+
+func (s *Store) CreateOutboxEventSubscriber(ctx context.Context, arg database.CreateOutboxEventSubscriberParams) error {
+	return s.q.CreateOutboxEventSubscriber(ctx, CreateOutboxEventSubscriberParams{
+		EventID:    arg.EventID,
+		Subscriber: arg.Subscriber,
+		HandledAt:  now(),
+	})
+}
+
+func (s *Store) DeleteOutboxEventsBefore(ctx context.Context, createdAt time.Time) (int64, error) {
+	return s.q.DeleteOutboxEventsBefore(ctx, createdAt.UTC())
+}
+
+func outboxEvent(event OutboxEvent) database.OutboxEvent {
+	return database.OutboxEvent{
+		ID:            event.ID,
+		CreatedAt:     event.CreatedAt,
+		Type:          event.Type,
+		Payload:       event.Payload,
+		Attempts:      int32(event.Attempts),
+		NextAttemptAt: event.NextAttemptAt,
+		LastError:     event.LastError,
+		DispatchedAt:  event.DispatchedAt,
+		DeadAt:        event.DeadAt,
+	}
+}
+
+func (s *Store) CreateWebhookSubscription(ctx context.Context, arg database.CreateWebhookSubscriptionParams) (database.WebhookSubscription, error) {
+	eventTypes, err := json.Marshal(arg.EventTypes)
+	if err != nil {
+		return database.WebhookSubscription{}, err
+	}
+	t := now()
+	subscription, err := s.q.CreateWebhookSubscription(ctx, CreateWebhookSubscriptionParams{
+		ID:         uuid.New(),
+		CreatedAt:  t,
+		UpdatedAt:  t,
+		UserID:     arg.UserID,
+		Url:        arg.Url,
+		EventTypes: string(eventTypes),
+		Secret:     arg.Secret,
+	})
+	if err != nil {
+		return database.WebhookSubscription{}, err
+	}
+	return webhookSubscription(subscription)
+}
+
+func (s *Store) GetWebhookSubscription(ctx context.Context, arg database.GetWebhookSubscriptionParams) (database.WebhookSubscription, error) {
+	subscription, err := s.q.GetWebhookSubscription(ctx, GetWebhookSubscriptionParams(arg))
+	if err != nil {
+		return database.WebhookSubscription{}, err
+	}
+	return webhookSubscription(subscription)
+}
+
+func (s *Store) GetWebhookSubscriptionsByUser(ctx context.Context, userID uuid.UUID) ([]database.WebhookSubscription, error) {
+	rows, err := s.q.GetWebhookSubscriptionsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var subscriptions []database.WebhookSubscription
+	for _, row := range rows {
+		subscription, err := webhookSubscription(row)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, nil
+}
+
+func (s *Store) DeleteWebhookSubscription(ctx context.Context, arg database.DeleteWebhookSubscriptionParams) (int64, error) {
+	return s.q.DeleteWebhookSubscription(ctx, DeleteWebhookSubscriptionParams(arg))
+}
+
+// webhookSubscription decodes the JSON array event_types is stored as
+func webhookSubscription(s WebhookSubscription) (database.WebhookSubscription, error) {
+	var eventTypes []string
+	if err := json.Unmarshal([]byte(s.EventTypes), &eventTypes); err != nil {
+		return database.WebhookSubscription{}, err
+	}
+	return database.WebhookSubscription{
+		ID:         s.ID,
+		CreatedAt:  s.CreatedAt,
+		UpdatedAt:  s.UpdatedAt,
+		UserID:     s.UserID,
+		Url:        s.Url,
+		EventTypes: eventTypes,
+		Secret:     s.Secret,
+		Active:     s.Active,
+	}, nil
+}
+
+// EnqueueWebhookDeliveries inserts a delivery per matching subscription,
+// since SQLite can't generate their IDs in the INSERT ... SELECT Postgres
+// uses
+func (s *Store) EnqueueWebhookDeliveries(ctx context.Context, arg database.EnqueueWebhookDeliveriesParams) (int64, error) {
+	var enqueued int64
+	err := s.InTx(ctx, func(tx database.Store) error {
+		q := tx.(*Store).q
+		subscriptionIDs, err := q.GetWebhookSubscriptionIDsForEvent(ctx, GetWebhookSubscriptionIDsForEventParams{
+			UserID:    arg.UserID,
+			EventType: arg.EventType,
+		})
+		if err != nil {
+			return err
+		}
+		t := now()
+		for _, subscriptionID := range subscriptionIDs {
+			err := q.CreateWebhookDelivery(ctx, CreateWebhookDeliveryParams{
+				ID:             uuid.New(),
+				CreatedAt:      t,
+				SubscriptionID: subscriptionID,
+				EventType:      arg.EventType,
+				Payload:        arg.Payload,
+			})
+			if err != nil {
+				return err
+			}
+			enqueued++
+		}
+		return nil
+	})
+	return enqueued, err
+}
+
+func (s *Store) ClaimWebhookDeliveries(ctx context.Context, limit int32) ([]database.ClaimWebhookDeliveriesRow, error) {
+	t := now()
+	rows, err := s.q.ClaimWebhookDeliveries(ctx, ClaimWebhookDeliveriesParams{
+		LeaseUntil: t.Add(leaseDuration),
+		Now:        t,
+		MaxResults: int64(limit),
+	})
+	return convertRows(rows, err, func(d ClaimWebhookDeliveriesRow) database.ClaimWebhookDeliveriesRow {
+		return database.ClaimWebhookDeliveriesRow{
+			ID:        d.ID,
+			EventType: d.EventType,
+			Payload:   d.Payload,
+			Attempts:  int32(d.Attempts),
+			Url:       d.Url,
+			Secret:    d.Secret,
+		}
+	})
+}
+
+func (s *Store) MarkWebhookDelivered(ctx context.Context, arg database.MarkWebhookDeliveredParams) error {
+	return s.q.MarkWebhookDelivered(ctx, MarkWebhookDeliveredParams{
+		Now:            now(),
+		LastStatusCode: nullInt64(arg.LastStatusCode),
+		ID:             arg.ID,
+	})
+}
+
+func (s *Store) MarkWebhookDeliveryFailed(ctx context.Context, arg database.MarkWebhookDeliveryFailedParams) error {
+	return s.q.MarkWebhookDeliveryFailed(ctx, MarkWebhookDeliveryFailedParams{
+		Status:         arg.Status,
+		Now:            now(),
+		LastStatusCode: nullInt64(arg.LastStatusCode),
+		LastError:      arg.LastError,
+		NextAttemptAt:  arg.NextAttemptAt.UTC(),
+		ID:             arg.ID,
+	})
+}
+
+func (s *Store) GetWebhookDeliveries(ctx context.Context, arg database.GetWebhookDeliveriesParams) ([]database.WebhookDelivery, error) {
+	rows, err := s.q.GetWebhookDeliveries(ctx, GetWebhookDeliveriesParams{
+		SubscriptionID: arg.SubscriptionID,
+		Limit:          int64(arg.Limit),
+	})
+	return convertRows(rows, err, func(d WebhookDelivery) database.WebhookDelivery {
+		return database.WebhookDelivery{
+			ID:             d.ID,
+			CreatedAt:      d.CreatedAt,
+			UpdatedAt:      d.UpdatedAt,
+			SubscriptionID: d.SubscriptionID,
+			EventType:      d.EventType,
+			Payload:        d.Payload,
+			Status:         d.Status,
+			Attempts:       int32(d.Attempts),
+			NextAttemptAt:  d.NextAttemptAt,
+			LastAttemptAt:  d.LastAttemptAt,
+			LastStatusCode: sql.NullInt32{Int32: int32(d.LastStatusCode.Int64), Valid: d.LastStatusCode.Valid},
+			LastError:      d.LastError,
+			DeliveredAt:    d.DeliveredAt,
+		}
+	})
+}
+
+func (s *Store) RedeliverWebhook(ctx context.Context, arg database.RedeliverWebhookParams) (int64, error) {
+	return s.q.RedeliverWebhook(ctx, RedeliverWebhookParams{
+		Now:            now(),
+		ID:             arg.ID,
+		SubscriptionID: arg.SubscriptionID,
+	})
+}
+
+func nullInt64(n sql.NullInt32) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(n.Int32), Valid: n.Valid}
+}
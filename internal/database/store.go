@@ -0,0 +1,186 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Store is the storage the server needs, whichever backend is configured.
+// SQLStore implements it on Postgres, sqlitedb on SQLite and memstore in
+// memory for tests.
+//
+// Lookups that find nothing return sql.ErrNoRows whatever the backend, and
+// writes that would break a unique constraint return an error
+// IsUniqueViolation reports.
+type Store interface {
+	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	GetUserByEmail(ctx context.Context, email string) (User, error)
+	GetUserByID(ctx context.Context, id uuid.UUID) (User, error)
+	UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error)
+	UpdateUserChirpyRed(ctx context.Context, arg UpdateUserChirpyRedParams) error
+	SuspendUser(ctx context.Context, id uuid.UUID) (User, error)
+	ListUsers(ctx context.Context) ([]User, error)
+	Reset(ctx context.Context) error
+
+	CreatChirp(ctx context.Context, arg CreatChirpParams) (Chirp, error)
+	GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error)
+	GetChirps(ctx context.Context) ([]Chirp, error)
+	GetChirpsDesc(ctx context.Context) ([]Chirp, error)
+	GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Chirp, error)
+	DeleteChirp(ctx context.Context, arg DeleteChirpParams) (Chirp, error)
+	// CreateChirpLike returns 0 if the user already liked the chirp
+	CreateChirpLike(ctx context.Context, arg CreateChirpLikeParams) (int64, error)
+	DeleteChirpLike(ctx context.Context, arg DeleteChirpLikeParams) (int64, error)
+
+	// Chirp events are recorded whenever a chirp is created or deleted,
+	// including by cascade. They're read in order of TxID then ID, which is
+	// the order they become visible in; only Postgres sets TxID.
+	GetLatestChirpEvent(ctx context.Context) (ChirpEvent, error)
+	GetChirpEvent(ctx context.Context, id int64) (ChirpEvent, error)
+	GetChirpEventsAfter(ctx context.Context, arg GetChirpEventsAfterParams) ([]ChirpEvent, error)
+	DeleteChirpEventsBefore(ctx context.Context, createdAt time.Time) (int64, error)
+
+	CreateRefreshTokens(ctx context.Context, arg CreateRefreshTokensParams) (RefreshToken, error)
+	GetRefreshTokenByToken(ctx context.Context, token string) (RefreshToken, error)
+	UpdateRefreshToken(ctx context.Context, token string) error
+	RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) (int64, error)
+
+	CreateOutboxEvent(ctx context.Context, arg CreateOutboxEventParams) (OutboxEvent, error)
+	// ClaimOutboxEvents leases up to limit due events for a minute, so no
+	// other dispatcher picks them up meanwhile
+	ClaimOutboxEvents(ctx context.Context, limit int32) ([]OutboxEvent, error)
+	MarkOutboxEventDispatched(ctx context.Context, id uuid.UUID) error
+	MarkOutboxEventFailed(ctx context.Context, arg MarkOutboxEventFailedParams) error
+	// MarkOutboxEventDead stops an event being claimed again
+	MarkOutboxEventDead(ctx context.Context, arg MarkOutboxEventDeadParams) error
+	// GetOutboxEventSubscribers returns the subscribers that have handled
+	// an event, so retries skip them
+	GetOutboxEventSubscribers(ctx context.Context, eventID uuid.UUID) ([]string, error)
+	CreateOutboxEventSubscriber(ctx context.Context, arg CreateOutboxEventSubscriberParams) error
+	// DeleteOutboxEventsBefore deletes dispatched and dead events created
+	// before createdAt; pending ones are kept however old they are
+	DeleteOutboxEventsBefore(ctx context.Context, createdAt time.Time) (int64, error)
+
+	CreateWebhookSubscription(ctx context.Context, arg CreateWebhookSubscriptionParams) (WebhookSubscription, error)
+	GetWebhookSubscription(ctx context.Context, arg GetWebhookSubscriptionParams) (WebhookSubscription, error)
+	GetWebhookSubscriptionsByUser(ctx context.Context, userID uuid.UUID) ([]WebhookSubscription, error)
+	DeleteWebhookSubscription(ctx context.Context, arg DeleteWebhookSubscriptionParams) (int64, error)
+	EnqueueWebhookDeliveries(ctx context.Context, arg EnqueueWebhookDeliveriesParams) (int64, error)
+	// ClaimWebhookDeliveries leases up to limit due deliveries for a minute,
+	// as ClaimOutboxEvents does
+	ClaimWebhookDeliveries(ctx context.Context, limit int32) ([]ClaimWebhookDeliveriesRow, error)
+	MarkWebhookDelivered(ctx context.Context, arg MarkWebhookDeliveredParams) error
+	MarkWebhookDeliveryFailed(ctx context.Context, arg MarkWebhookDeliveryFailedParams) error
+	GetWebhookDeliveries(ctx context.Context, arg GetWebhookDeliveriesParams) ([]WebhookDelivery, error)
+	RedeliverWebhook(ctx context.Context, arg RedeliverWebhookParams) (int64, error)
+
+	// CreateFollow returns 0 if the follow already exists
+	CreateFollow(ctx context.Context, arg CreateFollowParams) (int64, error)
+	DeleteFollow(ctx context.Context, arg DeleteFollowParams) (int64, error)
+	GetFollowsByFollower(ctx context.Context, followerID uuid.UUID) ([]Follow, error)
+
+	CreateBlock(ctx context.Context, arg CreateBlockParams) error
+	DeleteBlock(ctx context.Context, arg DeleteBlockParams) (int64, error)
+	GetBlocksByBlocker(ctx context.Context, blockerID uuid.UUID) ([]Block, error)
+	GetBlockRelatedUserIDs(ctx context.Context, blockerID uuid.UUID) ([]uuid.UUID, error)
+	IsBlockedBetween(ctx context.Context, arg IsBlockedBetweenParams) (bool, error)
+
+	CreateMute(ctx context.Context, arg CreateMuteParams) error
+	DeleteMute(ctx context.Context, arg DeleteMuteParams) (int64, error)
+	GetMutesByMuter(ctx context.Context, muterID uuid.UUID) ([]Mute, error)
+	GetMutedUserIDs(ctx context.Context, muterID uuid.UUID) ([]uuid.UUID, error)
+	CreateMutedKeyword(ctx context.Context, arg CreateMutedKeywordParams) (MutedKeyword, error)
+	DeleteMutedKeyword(ctx context.Context, arg DeleteMutedKeywordParams) (int64, error)
+	GetMutedKeywords(ctx context.Context, userID uuid.UUID) ([]MutedKeyword, error)
+
+	CreateConversation(ctx context.Context) (Conversation, error)
+	AddConversationMember(ctx context.Context, arg AddConversationMemberParams) error
+	GetConversationMembers(ctx context.Context, conversationID uuid.UUID) ([]ConversationMember, error)
+	GetConversationsForUser(ctx context.Context, userID uuid.UUID) ([]GetConversationsForUserRow, error)
+	GetConversationForUser(ctx context.Context, arg GetConversationForUserParams) (GetConversationForUserRow, error)
+	TouchConversation(ctx context.Context, id uuid.UUID) error
+	MarkConversationRead(ctx context.Context, arg MarkConversationReadParams) error
+	HasBlockWithConversationMembers(ctx context.Context, arg HasBlockWithConversationMembersParams) (bool, error)
+	CreateMessage(ctx context.Context, arg CreateMessageParams) (Message, error)
+	GetMessage(ctx context.Context, arg GetMessageParams) (Message, error)
+	GetMessages(ctx context.Context, arg GetMessagesParams) ([]Message, error)
+	GetMessagesBefore(ctx context.Context, arg GetMessagesBeforeParams) ([]Message, error)
+
+	CreateNotification(ctx context.Context, arg CreateNotificationParams) (Notification, error)
+	GetNotifications(ctx context.Context, arg GetNotificationsParams) ([]Notification, error)
+	GetUnreadNotifications(ctx context.Context, arg GetUnreadNotificationsParams) ([]Notification, error)
+	CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int64, error)
+	MarkNotificationRead(ctx context.Context, arg MarkNotificationReadParams) (int64, error)
+	MarkAllNotificationsRead(ctx context.Context, userID uuid.UUID) error
+	GetNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]NotificationPreference, error)
+	UpsertNotificationPreference(ctx context.Context, arg UpsertNotificationPreferenceParams) error
+	IsNotificationEnabled(ctx context.Context, arg IsNotificationEnabledParams) (bool, error)
+
+	// RecordPolkaEvent returns 0 if the event was already recorded
+	RecordPolkaEvent(ctx context.Context, arg RecordPolkaEventParams) (int64, error)
+	UpsertSubscription(ctx context.Context, arg UpsertSubscriptionParams) (Subscription, error)
+	MarkSubscriptionPastDue(ctx context.Context, arg MarkSubscriptionPastDueParams) (int64, error)
+	CancelSubscription(ctx context.Context, userID uuid.UUID) (int64, error)
+	ExpireLapsedSubscriptions(ctx context.Context) ([]uuid.UUID, error)
+
+	// InTx runs fn in a transaction, committing if it returns nil. Calling
+	// InTx on the Store passed to fn runs in the same transaction.
+	InTx(ctx context.Context, fn func(s Store) error) error
+}
+
+// ErrUniqueViolation is wrapped by unique constraint errors from backends
+// that don't have a typed error of their own for them
+var ErrUniqueViolation = errors.New("unique constraint violation")
+
+// IsUniqueViolation reports whether err is from a write that would have
+// broken a unique constraint
+func IsUniqueViolation(err error) bool {
+	if errors.Is(err, ErrUniqueViolation) {
+		return true
+	}
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation"
+}
+
+// SQLStore is the Store backed by the sqlc queries
+type SQLStore struct {
+	*Queries
+	db      *sql.DB
+	observe QueryObserver
+}
+
+var _ Store = (*SQLStore)(nil)
+
+// NewSQLStore returns a Store on db. observe, if not nil, sees every query
+// including those run in transactions.
+func NewSQLStore(db *sql.DB, observe QueryObserver) *SQLStore {
+	return &SQLStore{
+		Queries: New(Observe(db, observe)),
+		db:      db,
+		observe: observe,
+	}
+}
+
+func (s *SQLStore) InTx(ctx context.Context, fn func(s Store) error) error {
+	if s.db == nil {
+		// Already inside a transaction
+		return fn(s)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(&SQLStore{Queries: New(Observe(tx, s.observe))})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
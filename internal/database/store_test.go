@@ -0,0 +1,44 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/exglegaming/Chirpy/internal/database/storetest"
+	"github.com/exglegaming/Chirpy/internal/migrate"
+	_ "github.com/lib/pq"
+)
+
+// TestSQLStore runs the conformance suite against Postgres, migrating it
+// first. It deletes every user, so point it at a scratch database.
+func TestSQLStore(t *testing.T) {
+	dbURL := os.Getenv("CHIRPY_TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("CHIRPY_TEST_DB_URL not set")
+	}
+
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	provider, err := migrate.New(db, migrate.Postgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Up(context.Background()); err != nil {
+		t.Fatalf("Couldn't migrate: %v", err)
+	}
+
+	storetest.Run(t, func(t *testing.T) database.Store {
+		store := database.NewSQLStore(db, nil)
+		if err := store.Reset(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
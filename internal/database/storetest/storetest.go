@@ -0,0 +1,1474 @@
+// Package storetest is a conformance suite for database.Store
+// implementations. Every backend runs the same tests so handlers can rely on
+// identical behaviour whichever one is configured.
+package storetest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+var errRollback = errors.New("rollback")
+
+// Run runs the suite. newStore must return an empty store for each test.
+func Run(t *testing.T, newStore func(t *testing.T) database.Store) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s database.Store)
+	}{
+		{"Users", testUsers},
+		{"UniqueEmail", testUniqueEmail},
+		{"UserAdmin", testUserAdmin},
+		{"Chirps", testChirps},
+		{"ChirpRequiresUser", testChirpRequiresUser},
+		{"DeleteChirp", testDeleteChirp},
+		{"ChirpEvents", testChirpEvents},
+		{"ChirpEventRetention", testChirpEventRetention},
+		{"ChirpReplies", testChirpReplies},
+		{"ChirpLikes", testChirpLikes},
+		{"RefreshTokens", testRefreshTokens},
+		{"RevokeUserRefreshTokens", testRevokeUserRefreshTokens},
+		{"Reset", testReset},
+		{"OutboxEvent", testOutboxEvent},
+		{"OutboxClaim", testOutboxClaim},
+		{"OutboxDeadLetter", testOutboxDeadLetter},
+		{"OutboxSubscribers", testOutboxSubscribers},
+		{"OutboxRetention", testOutboxRetention},
+		{"Follows", testFollows},
+		{"Blocks", testBlocks},
+		{"Mutes", testMutes},
+		{"MutedKeywords", testMutedKeywords},
+		{"Conversations", testConversations},
+		{"MessagePages", testMessagePages},
+		{"Notifications", testNotifications},
+		{"NotificationPreferences", testNotificationPreferences},
+		{"PolkaEvents", testPolkaEvents},
+		{"Subscriptions", testSubscriptions},
+		{"WebhookSubscriptions", testWebhookSubscriptions},
+		{"WebhookDeliveries", testWebhookDeliveries},
+		{"InTxCommit", testInTxCommit},
+		{"InTxRollback", testInTxRollback},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStore(t))
+		})
+	}
+}
+
+func createUser(t *testing.T, s database.Store, email string) database.User {
+	t.Helper()
+	user, err := s.CreateUser(context.Background(), database.CreateUserParams{
+		Email:          email,
+		HashedPassword: "hash-" + email,
+	})
+	if err != nil {
+		t.Fatalf("CreateUser(%q): %v", email, err)
+	}
+	return user
+}
+
+func createChirp(t *testing.T, s database.Store, userID uuid.UUID, body string) database.Chirp {
+	t.Helper()
+	chirp, err := s.CreatChirp(context.Background(), database.CreatChirpParams{
+		Body:   body,
+		UserID: userID,
+	})
+	if err != nil {
+		t.Fatalf("CreatChirp(%q): %v", body, err)
+	}
+	return chirp
+}
+
+func chirpIDs(chirps []database.Chirp) []uuid.UUID {
+	var ids []uuid.UUID
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.ID)
+	}
+	return ids
+}
+
+func expectNoRows(t *testing.T, what string, err error) {
+	t.Helper()
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("%s: got error %v, want sql.ErrNoRows", what, err)
+	}
+}
+
+func testUsers(t *testing.T, s database.Store) {
+	ctx := context.Background()
+
+	user := createUser(t, s, "walt@breakingbad.com")
+	if user.ID == uuid.Nil || user.CreatedAt.IsZero() || user.IsChirpyRed {
+		t.Errorf("unexpected user %+v", user)
+	}
+
+	byEmail, err := s.GetUserByEmail(ctx, "walt@breakingbad.com")
+	if err != nil || byEmail.ID != user.ID || byEmail.HashedPassword != user.HashedPassword {
+		t.Errorf("GetUserByEmail = %+v, %v; want %+v", byEmail, err, user)
+	}
+	byID, err := s.GetUserByID(ctx, user.ID)
+	if err != nil || byID.Email != user.Email {
+		t.Errorf("GetUserByID = %+v, %v; want %+v", byID, err, user)
+	}
+
+	updated, err := s.UpdateUser(ctx, database.UpdateUserParams{
+		ID:             user.ID,
+		Email:          "heisenberg@breakingbad.com",
+		HashedPassword: "new-hash",
+	})
+	if err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if updated.ID != user.ID || updated.Email != "heisenberg@breakingbad.com" || updated.HashedPassword != "new-hash" {
+		t.Errorf("unexpected updated user %+v", updated)
+	}
+	if updated.UpdatedAt.Before(user.UpdatedAt) {
+		t.Errorf("UpdatedAt went backwards: %v -> %v", user.UpdatedAt, updated.UpdatedAt)
+	}
+
+	_, err = s.GetUserByEmail(ctx, "walt@breakingbad.com")
+	expectNoRows(t, "GetUserByEmail(old email)", err)
+	_, err = s.GetUserByID(ctx, uuid.New())
+	expectNoRows(t, "GetUserByID(unknown)", err)
+	_, err = s.UpdateUser(ctx, database.UpdateUserParams{ID: uuid.New(), Email: "nobody@example.com"})
+	expectNoRows(t, "UpdateUser(unknown)", err)
+}
+
+func testUniqueEmail(t *testing.T, s database.Store) {
+	ctx := context.Background()
+
+	createUser(t, s, "saul@bettercall.com")
+	kim := createUser(t, s, "kim@bettercall.com")
+
+	_, err := s.CreateUser(ctx, database.CreateUserParams{Email: "saul@bettercall.com", HashedPassword: "x"})
+	if !database.IsUniqueViolation(err) {
+		t.Errorf("CreateUser with a duplicate email: got %v, want a unique violation", err)
+	}
+	_, err = s.UpdateUser(ctx, database.UpdateUserParams{ID: kim.ID, Email: "saul@bettercall.com", HashedPassword: "x"})
+	if !database.IsUniqueViolation(err) {
+		t.Errorf("UpdateUser to a taken email: got %v, want a unique violation", err)
+	}
+}
+
+func testUserAdmin(t *testing.T, s database.Store) {
+	ctx := context.Background()
+
+	users, err := s.ListUsers(ctx)
+	if err != nil || len(users) != 0 {
+		t.Fatalf("ListUsers on an empty store = %v, %v", users, err)
+	}
+
+	walt := createUser(t, s, "walt@breakingbad.com")
+	jesse := createUser(t, s, "jesse@breakingbad.com")
+
+	err = s.UpdateUserChirpyRed(ctx, database.UpdateUserChirpyRedParams{ID: jesse.ID, IsChirpyRed: true})
+	if err != nil {
+		t.Fatalf("UpdateUserChirpyRed: %v", err)
+	}
+	suspended, err := s.SuspendUser(ctx, walt.ID)
+	if err != nil || !suspended.SuspendedAt.Valid {
+		t.Errorf("SuspendUser = %+v, %v", suspended, err)
+	}
+	_, err = s.SuspendUser(ctx, uuid.New())
+	expectNoRows(t, "SuspendUser(unknown)", err)
+
+	users, err = s.ListUsers(ctx)
+	if err != nil || len(users) != 2 {
+		t.Fatalf("ListUsers = %v, %v", users, err)
+	}
+	if users[0].ID != walt.ID || users[1].ID != jesse.ID {
+		t.Errorf("ListUsers isn't oldest first: %v", users)
+	}
+	if !users[0].SuspendedAt.Valid || users[0].IsChirpyRed {
+		t.Errorf("unexpected suspended user %+v", users[0])
+	}
+	if users[1].SuspendedAt.Valid || !users[1].IsChirpyRed {
+		t.Errorf("unexpected promoted user %+v", users[1])
+	}
+}
+
+func testChirps(t *testing.T, s database.Store) {
+	ctx := context.Background()
+
+	hank := createUser(t, s, "hank@dea.gov")
+	marie := createUser(t, s, "marie@purple.com")
+
+	chirps, err := s.GetChirps(ctx)
+	if err != nil || len(chirps) != 0 {
+		t.Fatalf("GetChirps on an empty store = %v, %v", chirps, err)
+	}
+
+	first := createChirp(t, s, hank.ID, "They're minerals")
+	second := createChirp(t, s, marie.ID, "Purple")
+	third := createChirp(t, s, hank.ID, "Tread lightly")
+	if first.Body != "They're minerals" || first.UserID != hank.ID || first.ID == uuid.Nil {
+		t.Errorf("unexpected chirp %+v", first)
+	}
+
+	tests := []struct {
+		name string
+		get  func() ([]database.Chirp, error)
+		want []uuid.UUID
+	}{
+		{
+			name: "GetChirps",
+			get:  func() ([]database.Chirp, error) { return s.GetChirps(ctx) },
+			want: []uuid.UUID{first.ID, second.ID, third.ID},
+		},
+		{
+			name: "GetChirpsDesc",
+			get:  func() ([]database.Chirp, error) { return s.GetChirpsDesc(ctx) },
+			want: []uuid.UUID{third.ID, second.ID, first.ID},
+		},
+		{
+			name: "GetChirpsByUserID",
+			get:  func() ([]database.Chirp, error) { return s.GetChirpsByUserID(ctx, hank.ID) },
+			want: []uuid.UUID{first.ID, third.ID},
+		},
+		{
+			name: "GetChirpsByUserID unknown",
+			get:  func() ([]database.Chirp, error) { return s.GetChirpsByUserID(ctx, uuid.New()) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chirps, err := tt.get()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := chirpIDs(chirps); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	got, err := s.GetChirp(ctx, second.ID)
+	if err != nil || got.ID != second.ID || got.Body != second.Body || got.UserID != marie.ID {
+		t.Errorf("GetChirp = %+v, %v; want %+v", got, err, second)
+	}
+	_, err = s.GetChirp(ctx, uuid.New())
+	expectNoRows(t, "GetChirp(unknown)", err)
+}
+
+func testChirpRequiresUser(t *testing.T, s database.Store) {
+	_, err := s.CreatChirp(context.Background(), database.CreatChirpParams{
+		Body:   "Nobody wrote this",
+		UserID: uuid.New(),
+	})
+	if err == nil {
+		t.Error("CreatChirp for an unknown user succeeded")
+	}
+}
+
+func testDeleteChirp(t *testing.T, s database.Store) {
+	ctx := context.Background()
+
+	owner := createUser(t, s, "tuco@salamanca.com")
+	other := createUser(t, s, "hector@salamanca.com")
+	chirp := createChirp(t, s, owner.ID, "Tight")
+
+	_, err := s.DeleteChirp(ctx, database.DeleteChirpParams{ID: chirp.ID, UserID: other.ID})
+	expectNoRows(t, "DeleteChirp(wrong user)", err)
+
+	deleted, err := s.DeleteChirp(ctx, database.DeleteChirpParams{ID: chirp.ID, UserID: owner.ID})
+	if err != nil || deleted.ID != chirp.ID {
+		t.Errorf("DeleteChirp = %+v, %v; want %+v", deleted, err, chirp)
+	}
+
+	_, err = s.GetChirp(ctx, chirp.ID)
+	expectNoRows(t, "GetChirp(deleted)", err)
+	_, err = s.DeleteChirp(ctx, database.DeleteChirpParams{ID: chirp.ID, UserID: owner.ID})
+	expectNoRows(t, "DeleteChirp(deleted)", err)
+}
+
+func testChirpReplies(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	walt := createUser(t, s, "walt@breakingbad.com")
+	jesse := createUser(t, s, "jesse@breakingbad.com")
+	parent := createChirp(t, s, walt.ID, "Say my name")
+
+	reply, err := s.CreatChirp(ctx, database.CreatChirpParams{
+		Body:      "Heisenberg",
+		UserID:    jesse.ID,
+		ReplyToID: uuid.NullUUID{UUID: parent.ID, Valid: true},
+	})
+	if err != nil || reply.ReplyToID.UUID != parent.ID || !reply.ReplyToID.Valid {
+		t.Fatalf("CreatChirp(reply) = %+v, %v; want a reply to %v", reply, err, parent.ID)
+	}
+	if got, err := s.GetChirp(ctx, reply.ID); err != nil || got.ReplyToID != reply.ReplyToID {
+		t.Errorf("GetChirp(reply) = %+v, %v; want reply_to_id %v", got, err, parent.ID)
+	}
+
+	_, err = s.CreatChirp(ctx, database.CreatChirpParams{
+		Body:      "Hello?",
+		UserID:    jesse.ID,
+		ReplyToID: uuid.NullUUID{UUID: uuid.New(), Valid: true},
+	})
+	if err == nil {
+		t.Error("CreatChirp replying to an unknown chirp succeeded")
+	}
+
+	// Deleting the parent keeps the reply
+	_, err = s.DeleteChirp(ctx, database.DeleteChirpParams{ID: parent.ID, UserID: walt.ID})
+	if err != nil {
+		t.Fatalf("DeleteChirp(parent): %v", err)
+	}
+	got, err := s.GetChirp(ctx, reply.ID)
+	if err != nil || got.ReplyToID.Valid {
+		t.Errorf("GetChirp(orphaned reply) = %+v, %v; want no reply_to_id", got, err)
+	}
+}
+
+func testChirpLikes(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	walt := createUser(t, s, "walt@breakingbad.com")
+	jesse := createUser(t, s, "jesse@breakingbad.com")
+	chirp := createChirp(t, s, walt.ID, "Say my name")
+
+	like := database.CreateChirpLikeParams{ChirpID: chirp.ID, UserID: jesse.ID}
+	created, err := s.CreateChirpLike(ctx, like)
+	if err != nil || created != 1 {
+		t.Errorf("CreateChirpLike = %d, %v; want 1", created, err)
+	}
+	created, err = s.CreateChirpLike(ctx, like)
+	if err != nil || created != 0 {
+		t.Errorf("CreateChirpLike again = %d, %v; want 0", created, err)
+	}
+
+	_, err = s.CreateChirpLike(ctx, database.CreateChirpLikeParams{ChirpID: uuid.New(), UserID: jesse.ID})
+	if err == nil {
+		t.Error("CreateChirpLike of an unknown chirp succeeded")
+	}
+
+	unlike := database.DeleteChirpLikeParams{ChirpID: chirp.ID, UserID: jesse.ID}
+	deleted, err := s.DeleteChirpLike(ctx, unlike)
+	if err != nil || deleted != 1 {
+		t.Errorf("DeleteChirpLike = %d, %v; want 1", deleted, err)
+	}
+	deleted, err = s.DeleteChirpLike(ctx, unlike)
+	if err != nil || deleted != 0 {
+		t.Errorf("DeleteChirpLike again = %d, %v; want 0", deleted, err)
+	}
+
+	// Likes go with their chirp
+	if _, err := s.CreateChirpLike(ctx, like); err != nil {
+		t.Fatalf("CreateChirpLike: %v", err)
+	}
+	if _, err := s.DeleteChirp(ctx, database.DeleteChirpParams{ID: chirp.ID, UserID: walt.ID}); err != nil {
+		t.Fatalf("DeleteChirp: %v", err)
+	}
+	deleted, err = s.DeleteChirpLike(ctx, unlike)
+	if err != nil || deleted != 0 {
+		t.Errorf("DeleteChirpLike of a deleted chirp = %d, %v; want 0", deleted, err)
+	}
+}
+
+// latestChirpEvent is where a reader would start from
+func latestChirpEvent(t *testing.T, s database.Store) database.ChirpEvent {
+	t.Helper()
+	latest, err := s.GetLatestChirpEvent(context.Background())
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("GetLatestChirpEvent: %v", err)
+	}
+	return latest
+}
+
+func chirpEventsAfter(ctx context.Context, s database.Store, after database.ChirpEvent, limit int32) ([]database.ChirpEvent, error) {
+	return s.GetChirpEventsAfter(ctx, database.GetChirpEventsAfterParams{
+		TxID:  after.TxID,
+		ID:    after.ID,
+		Limit: limit,
+	})
+}
+
+func testChirpEvents(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	// Other tests' events may already be there on a shared database
+	start := latestChirpEvent(t, s)
+
+	user := createUser(t, s, "user@example.com")
+	kept := createChirp(t, s, user.ID, "kept")
+	deleted := createChirp(t, s, user.ID, "deleted")
+	if _, err := s.DeleteChirp(ctx, database.DeleteChirpParams{ID: deleted.ID, UserID: user.ID}); err != nil {
+		t.Fatalf("DeleteChirp: %v", err)
+	}
+
+	events, err := chirpEventsAfter(ctx, s, start, 10)
+	if err != nil || len(events) != 3 {
+		t.Fatalf("GetChirpEventsAfter = %+v, %v; want 3 events", events, err)
+	}
+	want := []struct {
+		eventType string
+		chirpID   uuid.UUID
+		body      string
+	}{
+		{"chirp.created", kept.ID, "kept"},
+		{"chirp.created", deleted.ID, "deleted"},
+		{"chirp.deleted", deleted.ID, ""},
+	}
+	for i, event := range events {
+		if event.Type != want[i].eventType || event.ChirpID != want[i].chirpID ||
+			event.UserID != user.ID || event.Body.String != want[i].body || event.CreatedAt.IsZero() {
+			t.Errorf("event %d = %+v, want %+v", i, event, want[i])
+		}
+		if i > 0 && event.ID <= events[i-1].ID {
+			t.Errorf("event IDs aren't increasing: %d then %d", events[i-1].ID, event.ID)
+		}
+	}
+
+	latest := latestChirpEvent(t, s)
+	if latest.ID != events[2].ID {
+		t.Errorf("GetLatestChirpEvent = %+v; want %d", latest, events[2].ID)
+	}
+	if got, err := s.GetChirpEvent(ctx, events[1].ID); err != nil || got != events[1] {
+		t.Errorf("GetChirpEvent = %+v, %v; want %+v", got, err, events[1])
+	}
+	_, err = s.GetChirpEvent(ctx, latest.ID+1000)
+	expectNoRows(t, "GetChirpEvent(unknown)", err)
+	page, err := chirpEventsAfter(ctx, s, events[0], 1)
+	if err != nil || len(page) != 1 || page[0].ID != events[1].ID {
+		t.Errorf("GetChirpEventsAfter the first = %+v, %v; want the second", page, err)
+	}
+
+	// Deleting the user cascades to the chirp, which is recorded too
+	if err := s.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+	events, err = chirpEventsAfter(ctx, s, latest, 10)
+	if err != nil || len(events) != 1 || events[0].Type != "chirp.deleted" || events[0].ChirpID != kept.ID {
+		t.Errorf("events after Reset = %+v, %v; want kept's deletion", events, err)
+	}
+}
+
+func testChirpEventRetention(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	start := latestChirpEvent(t, s)
+
+	user := createUser(t, s, "user@example.com")
+	old := createChirp(t, s, user.ID, "old")
+	events, err := chirpEventsAfter(ctx, s, start, 10)
+	if err != nil || len(events) != 1 {
+		t.Fatalf("GetChirpEventsAfter = %+v, %v; want old's creation", events, err)
+	}
+	cutoff := events[0].CreatedAt.Add(time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	createChirp(t, s, user.ID, "new")
+
+	pruned, err := s.DeleteChirpEventsBefore(ctx, cutoff)
+	if err != nil || pruned < 1 {
+		t.Errorf("DeleteChirpEventsBefore = %d, %v; want old's event pruned", pruned, err)
+	}
+	_, err = s.GetChirpEvent(ctx, events[0].ID)
+	expectNoRows(t, "GetChirpEvent(pruned)", err)
+
+	events, err = chirpEventsAfter(ctx, s, start, 10)
+	if err != nil || len(events) != 1 || events[0].Body.String != "new" {
+		t.Errorf("GetChirpEventsAfter after pruning = %+v, %v; want new's creation", events, err)
+	}
+
+	// IDs aren't reused once pruned
+	latest := latestChirpEvent(t, s)
+	if _, err := s.DeleteChirp(ctx, database.DeleteChirpParams{ID: old.ID, UserID: user.ID}); err != nil {
+		t.Fatalf("DeleteChirp: %v", err)
+	}
+	if deletion := latestChirpEvent(t, s); deletion.ID <= latest.ID {
+		t.Errorf("event ID %d after pruning isn't above %d", deletion.ID, latest.ID)
+	}
+}
+
+func testRefreshTokens(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	user := createUser(t, s, "mike@breakingbad.com")
+
+	create := func(token string, expiresAt time.Time) {
+		t.Helper()
+		_, err := s.CreateRefreshTokens(ctx, database.CreateRefreshTokensParams{
+			Token:     token,
+			UserID:    user.ID,
+			ExpiresAt: expiresAt,
+		})
+		if err != nil {
+			t.Fatalf("CreateRefreshTokens(%q): %v", token, err)
+		}
+	}
+	create("valid", time.Now().Add(time.Hour))
+	create("expired", time.Now().Add(-time.Hour))
+
+	token, err := s.GetRefreshTokenByToken(ctx, "valid")
+	if err != nil || token.UserID != user.ID || token.RevokedAt.Valid {
+		t.Errorf("GetRefreshTokenByToken = %+v, %v", token, err)
+	}
+	_, err = s.GetRefreshTokenByToken(ctx, "expired")
+	expectNoRows(t, "GetRefreshTokenByToken(expired)", err)
+	_, err = s.GetRefreshTokenByToken(ctx, "unknown")
+	expectNoRows(t, "GetRefreshTokenByToken(unknown)", err)
+
+	_, err = s.CreateRefreshTokens(ctx, database.CreateRefreshTokensParams{
+		Token:     "orphan",
+		UserID:    uuid.New(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	if err == nil {
+		t.Error("CreateRefreshTokens for an unknown user succeeded")
+	}
+
+	if err := s.UpdateRefreshToken(ctx, "valid"); err != nil {
+		t.Fatalf("UpdateRefreshToken: %v", err)
+	}
+	_, err = s.GetRefreshTokenByToken(ctx, "valid")
+	expectNoRows(t, "GetRefreshTokenByToken(revoked)", err)
+}
+
+func testRevokeUserRefreshTokens(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	skyler := createUser(t, s, "skyler@breakingbad.com")
+	marie := createUser(t, s, "marie@purple.com")
+
+	for token, userID := range map[string]uuid.UUID{
+		"skyler-1": skyler.ID,
+		"skyler-2": skyler.ID,
+		"marie-1":  marie.ID,
+	} {
+		_, err := s.CreateRefreshTokens(ctx, database.CreateRefreshTokensParams{
+			Token:     token,
+			UserID:    userID,
+			ExpiresAt: time.Now().Add(time.Hour),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.UpdateRefreshToken(ctx, "skyler-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	revoked, err := s.RevokeUserRefreshTokens(ctx, skyler.ID)
+	if err != nil || revoked != 1 {
+		t.Errorf("RevokeUserRefreshTokens = %d, %v; want 1 active token", revoked, err)
+	}
+	_, err = s.GetRefreshTokenByToken(ctx, "skyler-1")
+	expectNoRows(t, "GetRefreshTokenByToken(revoked)", err)
+	if _, err := s.GetRefreshTokenByToken(ctx, "marie-1"); err != nil {
+		t.Errorf("another user's token was revoked: %v", err)
+	}
+}
+
+func testReset(t *testing.T, s database.Store) {
+	ctx := context.Background()
+
+	user := createUser(t, s, "todd@vamonos.com")
+	createChirp(t, s, user.ID, "Vamonos")
+	_, err := s.CreateRefreshTokens(ctx, database.CreateRefreshTokensParams{
+		Token:     "todd-token",
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Reset(ctx); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+
+	_, err = s.GetUserByID(ctx, user.ID)
+	expectNoRows(t, "GetUserByID after reset", err)
+	chirps, err := s.GetChirps(ctx)
+	if err != nil || len(chirps) != 0 {
+		t.Errorf("GetChirps after reset = %v, %v", chirps, err)
+	}
+	_, err = s.GetRefreshTokenByToken(ctx, "todd-token")
+	expectNoRows(t, "GetRefreshTokenByToken after reset", err)
+}
+
+func testOutboxEvent(t *testing.T, s database.Store) {
+	event, err := s.CreateOutboxEvent(context.Background(), database.CreateOutboxEventParams{
+		Type:    "chirp.created",
+		Payload: json.RawMessage(`{"id":"abc"}`),
+	})
+	if err != nil {
+		t.Fatalf("CreateOutboxEvent: %v", err)
+	}
+	if event.ID == uuid.Nil || event.Type != "chirp.created" || event.Attempts != 0 || event.DispatchedAt.Valid {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	var payload map[string]string
+	if err := json.Unmarshal(event.Payload, &payload); err != nil || payload["id"] != "abc" {
+		t.Errorf("payload = %s, %v", event.Payload, err)
+	}
+}
+
+// claimed reports whether id is among events, since on a shared database
+// other tests' events may be claimed alongside it
+func claimed(events []database.OutboxEvent, id uuid.UUID) (database.OutboxEvent, bool) {
+	i := slices.IndexFunc(events, func(e database.OutboxEvent) bool { return e.ID == id })
+	if i < 0 {
+		return database.OutboxEvent{}, false
+	}
+	return events[i], true
+}
+
+func testOutboxClaim(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	event, err := s.CreateOutboxEvent(ctx, database.CreateOutboxEventParams{
+		Type:    "chirp.created",
+		Payload: json.RawMessage(`{}`),
+	})
+	if err != nil {
+		t.Fatalf("CreateOutboxEvent: %v", err)
+	}
+
+	events, err := s.ClaimOutboxEvents(ctx, 1000)
+	if _, ok := claimed(events, event.ID); err != nil || !ok {
+		t.Fatalf("ClaimOutboxEvents = %v, %v; want the new event", events, err)
+	}
+	events, err = s.ClaimOutboxEvents(ctx, 1000)
+	if _, ok := claimed(events, event.ID); err != nil || ok {
+		t.Errorf("ClaimOutboxEvents claimed a leased event: %v, %v", events, err)
+	}
+
+	err = s.MarkOutboxEventFailed(ctx, database.MarkOutboxEventFailedParams{
+		ID:            event.ID,
+		LastError:     sql.NullString{String: "boom", Valid: true},
+		NextAttemptAt: time.Now().Add(-time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("MarkOutboxEventFailed: %v", err)
+	}
+	events, err = s.ClaimOutboxEvents(ctx, 1000)
+	retried, ok := claimed(events, event.ID)
+	if err != nil || !ok || retried.Attempts != 1 || retried.LastError.String != "boom" {
+		t.Errorf("ClaimOutboxEvents after a failure = %+v, %v; want the event with 1 attempt", retried, err)
+	}
+
+	if err := s.MarkOutboxEventDispatched(ctx, event.ID); err != nil {
+		t.Fatalf("MarkOutboxEventDispatched: %v", err)
+	}
+	err = s.MarkOutboxEventFailed(ctx, database.MarkOutboxEventFailedParams{
+		ID:            event.ID,
+		NextAttemptAt: time.Now().Add(-time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err = s.ClaimOutboxEvents(ctx, 1000)
+	if _, ok := claimed(events, event.ID); err != nil || ok {
+		t.Errorf("ClaimOutboxEvents claimed a dispatched event: %v, %v", events, err)
+	}
+}
+
+func createOutboxEvent(t *testing.T, s database.Store) database.OutboxEvent {
+	t.Helper()
+	event, err := s.CreateOutboxEvent(context.Background(), database.CreateOutboxEventParams{
+		Type:    "chirp.created",
+		Payload: json.RawMessage(`{}`),
+	})
+	if err != nil {
+		t.Fatalf("CreateOutboxEvent: %v", err)
+	}
+	return event
+}
+
+func testOutboxDeadLetter(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	event := createOutboxEvent(t, s)
+
+	err := s.MarkOutboxEventDead(ctx, database.MarkOutboxEventDeadParams{
+		ID:        event.ID,
+		LastError: sql.NullString{String: "boom", Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("MarkOutboxEventDead: %v", err)
+	}
+	events, err := s.ClaimOutboxEvents(ctx, 1000)
+	if _, ok := claimed(events, event.ID); err != nil || ok {
+		t.Errorf("ClaimOutboxEvents claimed a dead event: %v, %v", events, err)
+	}
+}
+
+func testOutboxSubscribers(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	event := createOutboxEvent(t, s)
+
+	handled, err := s.GetOutboxEventSubscribers(ctx, event.ID)
+	if err != nil || len(handled) != 0 {
+		t.Errorf("GetOutboxEventSubscribers = %v, %v; want none", handled, err)
+	}
+	for _, subscriber := range []string{"webhooks", "search", "webhooks"} {
+		err := s.CreateOutboxEventSubscriber(ctx, database.CreateOutboxEventSubscriberParams{
+			EventID:    event.ID,
+			Subscriber: subscriber,
+		})
+		if err != nil {
+			t.Fatalf("CreateOutboxEventSubscriber(%q): %v", subscriber, err)
+		}
+	}
+	handled, err = s.GetOutboxEventSubscribers(ctx, event.ID)
+	slices.Sort(handled)
+	if err != nil || !slices.Equal(handled, []string{"search", "webhooks"}) {
+		t.Errorf("GetOutboxEventSubscribers = %v, %v; want search and webhooks once each", handled, err)
+	}
+}
+
+func testOutboxRetention(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	pending := createOutboxEvent(t, s)
+	dispatched := createOutboxEvent(t, s)
+	dead := createOutboxEvent(t, s)
+	if err := s.MarkOutboxEventDispatched(ctx, dispatched.ID); err != nil {
+		t.Fatal(err)
+	}
+	err := s.CreateOutboxEventSubscriber(ctx, database.CreateOutboxEventSubscriberParams{
+		EventID:    dispatched.ID,
+		Subscriber: "webhooks",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.MarkOutboxEventDead(ctx, database.MarkOutboxEventDeadParams{ID: dead.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	pruned, err := s.DeleteOutboxEventsBefore(ctx, time.Now().Add(time.Minute))
+	if err != nil || pruned < 2 {
+		t.Fatalf("DeleteOutboxEventsBefore = %d, %v; want the dispatched and dead events", pruned, err)
+	}
+	handled, err := s.GetOutboxEventSubscribers(ctx, dispatched.ID)
+	if err != nil || len(handled) != 0 {
+		t.Errorf("GetOutboxEventSubscribers after pruning = %v, %v; want none", handled, err)
+	}
+	// Pending events are kept however old they are
+	events, err := s.ClaimOutboxEvents(ctx, 1000)
+	if _, ok := claimed(events, pending.ID); err != nil || !ok {
+		t.Errorf("ClaimOutboxEvents after pruning = %v, %v; want the pending event", events, err)
+	}
+}
+
+func testFollows(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	walt := createUser(t, s, "walt@breakingbad.com")
+	jesse := createUser(t, s, "jesse@breakingbad.com")
+
+	follow := database.CreateFollowParams{FollowerID: jesse.ID, FollowedID: walt.ID}
+	created, err := s.CreateFollow(ctx, follow)
+	if err != nil || created != 1 {
+		t.Errorf("CreateFollow = %d, %v; want 1", created, err)
+	}
+	created, err = s.CreateFollow(ctx, follow)
+	if err != nil || created != 0 {
+		t.Errorf("CreateFollow again = %d, %v; want 0", created, err)
+	}
+
+	follows, err := s.GetFollowsByFollower(ctx, jesse.ID)
+	if err != nil || len(follows) != 1 || follows[0].FollowedID != walt.ID || follows[0].CreatedAt.IsZero() {
+		t.Errorf("GetFollowsByFollower = %+v, %v; want jesse's one follow", follows, err)
+	}
+	follows, err = s.GetFollowsByFollower(ctx, walt.ID)
+	if err != nil || len(follows) != 0 {
+		t.Errorf("GetFollowsByFollower(walt) = %+v, %v; want none", follows, err)
+	}
+
+	_, err = s.CreateFollow(ctx, database.CreateFollowParams{FollowerID: jesse.ID, FollowedID: uuid.New()})
+	if err == nil {
+		t.Error("CreateFollow of an unknown user succeeded")
+	}
+
+	unfollow := database.DeleteFollowParams{FollowerID: jesse.ID, FollowedID: walt.ID}
+	deleted, err := s.DeleteFollow(ctx, unfollow)
+	if err != nil || deleted != 1 {
+		t.Errorf("DeleteFollow = %d, %v; want 1", deleted, err)
+	}
+	deleted, err = s.DeleteFollow(ctx, unfollow)
+	if err != nil || deleted != 0 {
+		t.Errorf("DeleteFollow again = %d, %v; want 0", deleted, err)
+	}
+}
+
+func testBlocks(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	walt := createUser(t, s, "walt@breakingbad.com")
+	jesse := createUser(t, s, "jesse@breakingbad.com")
+	gus := createUser(t, s, "gus@pollos.com")
+
+	block := func(blocker, blocked uuid.UUID) {
+		t.Helper()
+		err := s.CreateBlock(ctx, database.CreateBlockParams{BlockerID: blocker, BlockedID: blocked})
+		if err != nil {
+			t.Fatalf("CreateBlock: %v", err)
+		}
+	}
+	block(walt.ID, gus.ID)
+	// Blocking again is a no-op
+	block(walt.ID, gus.ID)
+	block(gus.ID, jesse.ID)
+
+	blocks, err := s.GetBlocksByBlocker(ctx, walt.ID)
+	if err != nil || len(blocks) != 1 || blocks[0].BlockedID != gus.ID || blocks[0].CreatedAt.IsZero() {
+		t.Errorf("GetBlocksByBlocker = %+v, %v; want walt's one block", blocks, err)
+	}
+
+	related, err := s.GetBlockRelatedUserIDs(ctx, gus.ID)
+	slices.SortFunc(related, func(a, b uuid.UUID) int { return slices.Compare(a[:], b[:]) })
+	want := []uuid.UUID{walt.ID, jesse.ID}
+	slices.SortFunc(want, func(a, b uuid.UUID) int { return slices.Compare(a[:], b[:]) })
+	if err != nil || !slices.Equal(related, want) {
+		t.Errorf("GetBlockRelatedUserIDs = %v, %v; want both directions %v", related, err, want)
+	}
+
+	for _, tt := range []struct {
+		a, b database.User
+		want bool
+	}{
+		{walt, gus, true},
+		{gus, walt, true},
+		{walt, jesse, false},
+	} {
+		blocked, err := s.IsBlockedBetween(ctx, database.IsBlockedBetweenParams{BlockerID: tt.a.ID, BlockedID: tt.b.ID})
+		if err != nil || blocked != tt.want {
+			t.Errorf("IsBlockedBetween(%s, %s) = %v, %v; want %v", tt.a.Email, tt.b.Email, blocked, err, tt.want)
+		}
+	}
+
+	err = s.CreateBlock(ctx, database.CreateBlockParams{BlockerID: walt.ID, BlockedID: uuid.New()})
+	if err == nil {
+		t.Error("CreateBlock of an unknown user succeeded")
+	}
+
+	deleted, err := s.DeleteBlock(ctx, database.DeleteBlockParams{BlockerID: walt.ID, BlockedID: gus.ID})
+	if err != nil || deleted != 1 {
+		t.Errorf("DeleteBlock = %d, %v; want 1", deleted, err)
+	}
+	deleted, err = s.DeleteBlock(ctx, database.DeleteBlockParams{BlockerID: walt.ID, BlockedID: gus.ID})
+	if err != nil || deleted != 0 {
+		t.Errorf("DeleteBlock again = %d, %v; want 0", deleted, err)
+	}
+}
+
+func testMutes(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	saul := createUser(t, s, "saul@bettercall.com")
+	chuck := createUser(t, s, "chuck@hhm.com")
+	howard := createUser(t, s, "howard@hhm.com")
+
+	for _, muted := range []uuid.UUID{chuck.ID, howard.ID, chuck.ID} {
+		err := s.CreateMute(ctx, database.CreateMuteParams{MuterID: saul.ID, MutedID: muted})
+		if err != nil {
+			t.Fatalf("CreateMute: %v", err)
+		}
+	}
+
+	mutes, err := s.GetMutesByMuter(ctx, saul.ID)
+	if err != nil || len(mutes) != 2 {
+		t.Fatalf("GetMutesByMuter = %+v, %v; want 2 mutes", mutes, err)
+	}
+	muted, err := s.GetMutedUserIDs(ctx, saul.ID)
+	if err != nil || len(muted) != 2 || !slices.Contains(muted, chuck.ID) || !slices.Contains(muted, howard.ID) {
+		t.Errorf("GetMutedUserIDs = %v, %v", muted, err)
+	}
+	muted, err = s.GetMutedUserIDs(ctx, chuck.ID)
+	if err != nil || len(muted) != 0 {
+		t.Errorf("GetMutedUserIDs isn't one way: %v, %v", muted, err)
+	}
+
+	deleted, err := s.DeleteMute(ctx, database.DeleteMuteParams{MuterID: saul.ID, MutedID: chuck.ID})
+	if err != nil || deleted != 1 {
+		t.Errorf("DeleteMute = %d, %v; want 1", deleted, err)
+	}
+	muted, err = s.GetMutedUserIDs(ctx, saul.ID)
+	if err != nil || !slices.Equal(muted, []uuid.UUID{howard.ID}) {
+		t.Errorf("GetMutedUserIDs after DeleteMute = %v, %v", muted, err)
+	}
+}
+
+func testMutedKeywords(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	kim := createUser(t, s, "kim@bettercall.com")
+	lalo := createUser(t, s, "lalo@salamanca.com")
+
+	create := func(userID uuid.UUID, keyword string) (database.MutedKeyword, error) {
+		return s.CreateMutedKeyword(ctx, database.CreateMutedKeywordParams{UserID: userID, Keyword: keyword})
+	}
+	first, err := create(kim.ID, "mesa verde")
+	if err != nil || first.ID == uuid.Nil || first.Keyword != "mesa verde" {
+		t.Fatalf("CreateMutedKeyword = %+v, %v", first, err)
+	}
+	if _, err := create(kim.ID, "sandpiper"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := create(kim.ID, "mesa verde"); !database.IsUniqueViolation(err) {
+		t.Errorf("CreateMutedKeyword twice: got %v, want a unique violation", err)
+	}
+	// Keywords are unique per user
+	if _, err := create(lalo.ID, "mesa verde"); err != nil {
+		t.Errorf("CreateMutedKeyword of another user's keyword: %v", err)
+	}
+
+	keywords, err := s.GetMutedKeywords(ctx, kim.ID)
+	if err != nil || len(keywords) != 2 || keywords[0].ID != first.ID || keywords[1].Keyword != "sandpiper" {
+		t.Errorf("GetMutedKeywords = %+v, %v; want kim's keywords oldest first", keywords, err)
+	}
+
+	deleted, err := s.DeleteMutedKeyword(ctx, database.DeleteMutedKeywordParams{ID: first.ID, UserID: lalo.ID})
+	if err != nil || deleted != 0 {
+		t.Errorf("DeleteMutedKeyword(wrong user) = %d, %v; want 0", deleted, err)
+	}
+	deleted, err = s.DeleteMutedKeyword(ctx, database.DeleteMutedKeywordParams{ID: first.ID, UserID: kim.ID})
+	if err != nil || deleted != 1 {
+		t.Errorf("DeleteMutedKeyword = %d, %v; want 1", deleted, err)
+	}
+}
+
+func createConversation(t *testing.T, s database.Store, members ...database.User) database.Conversation {
+	t.Helper()
+	ctx := context.Background()
+	conversation, err := s.CreateConversation(ctx)
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+	for _, member := range members {
+		err := s.AddConversationMember(ctx, database.AddConversationMemberParams{
+			ConversationID: conversation.ID,
+			UserID:         member.ID,
+		})
+		if err != nil {
+			t.Fatalf("AddConversationMember(%q): %v", member.Email, err)
+		}
+	}
+	return conversation
+}
+
+func createMessage(t *testing.T, s database.Store, conversationID uuid.UUID, sender database.User, body string) database.Message {
+	t.Helper()
+	message, err := s.CreateMessage(context.Background(), database.CreateMessageParams{
+		ConversationID: conversationID,
+		SenderID:       sender.ID,
+		Body:           body,
+	})
+	if err != nil {
+		t.Fatalf("CreateMessage(%q): %v", body, err)
+	}
+	return message
+}
+
+func testConversations(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	walt := createUser(t, s, "walt@breakingbad.com")
+	jesse := createUser(t, s, "jesse@breakingbad.com")
+	gus := createUser(t, s, "gus@pollos.com")
+
+	conversation := createConversation(t, s, walt, jesse)
+	err := s.AddConversationMember(ctx, database.AddConversationMemberParams{ConversationID: conversation.ID, UserID: walt.ID})
+	if !database.IsUniqueViolation(err) {
+		t.Errorf("AddConversationMember twice: got %v, want a unique violation", err)
+	}
+	members, err := s.GetConversationMembers(ctx, conversation.ID)
+	if err != nil || len(members) != 2 || members[0].LastReadAt.Valid {
+		t.Errorf("GetConversationMembers = %+v, %v", members, err)
+	}
+
+	message := createMessage(t, s, conversation.ID, walt, "Say my name")
+	createMessage(t, s, conversation.ID, walt, "You're goddamn right")
+	if err := s.TouchConversation(ctx, conversation.ID); err != nil {
+		t.Fatalf("TouchConversation: %v", err)
+	}
+	got, err := s.GetMessage(ctx, database.GetMessageParams{ID: message.ID, ConversationID: conversation.ID})
+	if err != nil || got.Body != "Say my name" || got.SenderID != walt.ID {
+		t.Errorf("GetMessage = %+v, %v", got, err)
+	}
+	_, err = s.GetMessage(ctx, database.GetMessageParams{ID: message.ID, ConversationID: uuid.New()})
+	expectNoRows(t, "GetMessage(other conversation)", err)
+
+	unread := func(user database.User) int64 {
+		t.Helper()
+		row, err := s.GetConversationForUser(ctx, database.GetConversationForUserParams{UserID: user.ID, ID: conversation.ID})
+		if err != nil {
+			t.Fatalf("GetConversationForUser(%q): %v", user.Email, err)
+		}
+		return row.UnreadCount
+	}
+	if got := unread(jesse); got != 2 {
+		t.Errorf("jesse has %d unread, want 2", got)
+	}
+	if got := unread(walt); got != 0 {
+		t.Errorf("walt has %d unread of his own messages, want 0", got)
+	}
+	err = s.MarkConversationRead(ctx, database.MarkConversationReadParams{ConversationID: conversation.ID, UserID: jesse.ID})
+	if err != nil {
+		t.Fatalf("MarkConversationRead: %v", err)
+	}
+	if got := unread(jesse); got != 0 {
+		t.Errorf("jesse has %d unread after reading, want 0", got)
+	}
+
+	_, err = s.GetConversationForUser(ctx, database.GetConversationForUserParams{UserID: gus.ID, ID: conversation.ID})
+	expectNoRows(t, "GetConversationForUser(not a member)", err)
+
+	other := createConversation(t, s, jesse, gus)
+	if err := s.TouchConversation(ctx, other.ID); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := s.GetConversationsForUser(ctx, jesse.ID)
+	if err != nil || len(rows) != 2 || rows[0].ID != other.ID || rows[1].ID != conversation.ID {
+		t.Errorf("GetConversationsForUser = %+v, %v; want the most recently active first", rows, err)
+	}
+
+	hasBlock := func(user database.User) bool {
+		t.Helper()
+		blocked, err := s.HasBlockWithConversationMembers(ctx, database.HasBlockWithConversationMembersParams{
+			UserID:         user.ID,
+			ConversationID: conversation.ID,
+		})
+		if err != nil {
+			t.Fatalf("HasBlockWithConversationMembers: %v", err)
+		}
+		return blocked
+	}
+	if hasBlock(jesse) {
+		t.Error("HasBlockWithConversationMembers before any block")
+	}
+	// A block with someone outside the conversation doesn't count
+	if err := s.CreateBlock(ctx, database.CreateBlockParams{BlockerID: gus.ID, BlockedID: jesse.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if hasBlock(jesse) {
+		t.Error("HasBlockWithConversationMembers counted a non-member's block")
+	}
+	if err := s.CreateBlock(ctx, database.CreateBlockParams{BlockerID: walt.ID, BlockedID: jesse.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if !hasBlock(jesse) || !hasBlock(walt) {
+		t.Error("HasBlockWithConversationMembers missed a member's block")
+	}
+}
+
+func testMessagePages(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	skyler := createUser(t, s, "skyler@breakingbad.com")
+	marie := createUser(t, s, "marie@purple.com")
+	conversation := createConversation(t, s, skyler, marie)
+	for _, body := range []string{"one", "two", "three", "four", "five"} {
+		createMessage(t, s, conversation.ID, skyler, body)
+	}
+
+	all, err := s.GetMessages(ctx, database.GetMessagesParams{ConversationID: conversation.ID, Limit: 10})
+	if err != nil || len(all) != 5 {
+		t.Fatalf("GetMessages = %v, %v; want all 5", all, err)
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i].CreatedAt.After(all[i-1].CreatedAt) {
+			t.Errorf("GetMessages isn't newest first: %v", all)
+		}
+	}
+
+	// Paging with each page's last message as the cursor reads them all,
+	// in the same order, even if some share a timestamp
+	var paged []database.Message
+	page, err := s.GetMessages(ctx, database.GetMessagesParams{ConversationID: conversation.ID, Limit: 2})
+	for ; err == nil && len(page) > 0; page, err = s.GetMessagesBefore(ctx, database.GetMessagesBeforeParams{
+		ConversationID:  conversation.ID,
+		BeforeCreatedAt: paged[len(paged)-1].CreatedAt,
+		BeforeID:        paged[len(paged)-1].ID,
+		MaxResults:      2,
+	}) {
+		paged = append(paged, page...)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want, got []uuid.UUID
+	for i := range all {
+		want = append(want, all[i].ID)
+	}
+	for i := range paged {
+		got = append(got, paged[i].ID)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("paged through %v, want %v", got, want)
+	}
+}
+
+func testNotifications(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	hank := createUser(t, s, "hank@dea.gov")
+	gomez := createUser(t, s, "gomez@dea.gov")
+	chirp := createChirp(t, s, hank.ID, "They're minerals")
+
+	notify := func(notificationType string, chirpID uuid.NullUUID) database.Notification {
+		t.Helper()
+		notification, err := s.CreateNotification(ctx, database.CreateNotificationParams{
+			UserID:  hank.ID,
+			ActorID: gomez.ID,
+			Type:    notificationType,
+			ChirpID: chirpID,
+		})
+		if err != nil {
+			t.Fatalf("CreateNotification(%q): %v", notificationType, err)
+		}
+		return notification
+	}
+	follow := notify("follow", uuid.NullUUID{})
+	like := notify("like", uuid.NullUUID{UUID: chirp.ID, Valid: true})
+	reply := notify("reply", uuid.NullUUID{UUID: chirp.ID, Valid: true})
+	if follow.ID == uuid.Nil || follow.ReadAt.Valid || like.ChirpID.UUID != chirp.ID {
+		t.Errorf("unexpected notifications %+v, %+v", follow, like)
+	}
+
+	notifications, err := s.GetNotifications(ctx, database.GetNotificationsParams{UserID: hank.ID, Limit: 2})
+	if err != nil || len(notifications) != 2 || notifications[0].ID != reply.ID || notifications[1].ID != like.ID {
+		t.Errorf("GetNotifications = %+v, %v; want the latest 2, newest first", notifications, err)
+	}
+
+	read, err := s.MarkNotificationRead(ctx, database.MarkNotificationReadParams{ID: like.ID, UserID: gomez.ID})
+	if err != nil || read != 0 {
+		t.Errorf("MarkNotificationRead(someone else's) = %d, %v; want 0", read, err)
+	}
+	read, err = s.MarkNotificationRead(ctx, database.MarkNotificationReadParams{ID: like.ID, UserID: hank.ID})
+	if err != nil || read != 1 {
+		t.Errorf("MarkNotificationRead = %d, %v; want 1", read, err)
+	}
+	unread, err := s.GetUnreadNotifications(ctx, database.GetUnreadNotificationsParams{UserID: hank.ID, Limit: 10})
+	if err != nil || len(unread) != 2 || unread[0].ID != reply.ID || unread[1].ID != follow.ID {
+		t.Errorf("GetUnreadNotifications = %+v, %v", unread, err)
+	}
+	if count, err := s.CountUnreadNotifications(ctx, hank.ID); err != nil || count != 2 {
+		t.Errorf("CountUnreadNotifications = %d, %v; want 2", count, err)
+	}
+
+	if err := s.MarkAllNotificationsRead(ctx, hank.ID); err != nil {
+		t.Fatalf("MarkAllNotificationsRead: %v", err)
+	}
+	if count, err := s.CountUnreadNotifications(ctx, hank.ID); err != nil || count != 0 {
+		t.Errorf("CountUnreadNotifications after reading all = %d, %v; want 0", count, err)
+	}
+
+	// Deleting the chirp deletes the notifications about it
+	if _, err := s.DeleteChirp(ctx, database.DeleteChirpParams{ID: chirp.ID, UserID: hank.ID}); err != nil {
+		t.Fatal(err)
+	}
+	notifications, err = s.GetNotifications(ctx, database.GetNotificationsParams{UserID: hank.ID, Limit: 10})
+	if err != nil || len(notifications) != 1 || notifications[0].ID != follow.ID {
+		t.Errorf("GetNotifications after deleting the chirp = %+v, %v; want just the follow", notifications, err)
+	}
+}
+
+func testNotificationPreferences(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	user := createUser(t, s, "huell@bettercall.com")
+
+	enabled := func(notificationType string) bool {
+		t.Helper()
+		enabled, err := s.IsNotificationEnabled(ctx, database.IsNotificationEnabledParams{UserID: user.ID, Type: notificationType})
+		if err != nil {
+			t.Fatalf("IsNotificationEnabled(%q): %v", notificationType, err)
+		}
+		return enabled
+	}
+	set := func(notificationType string, enabled bool) {
+		t.Helper()
+		err := s.UpsertNotificationPreference(ctx, database.UpsertNotificationPreferenceParams{
+			UserID:  user.ID,
+			Type:    notificationType,
+			Enabled: enabled,
+		})
+		if err != nil {
+			t.Fatalf("UpsertNotificationPreference(%q): %v", notificationType, err)
+		}
+	}
+
+	if !enabled("like") {
+		t.Error("types without a preference should be enabled")
+	}
+	set("like", false)
+	set("mention", false)
+	set("mention", true)
+	if enabled("like") || !enabled("mention") {
+		t.Errorf("like enabled = %v, mention enabled = %v; want false, true", enabled("like"), enabled("mention"))
+	}
+
+	preferences, err := s.GetNotificationPreferences(ctx, user.ID)
+	if err != nil || len(preferences) != 2 || preferences[0].Type != "like" || preferences[1].Type != "mention" {
+		t.Errorf("GetNotificationPreferences = %+v, %v; want like and mention, by type", preferences, err)
+	}
+}
+
+func testPolkaEvents(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	// Reset doesn't clear Polka events, so the ID must be new every run
+	arg := database.RecordPolkaEventParams{ID: "evt_" + uuid.NewString(), Event: "user.upgraded"}
+
+	recorded, err := s.RecordPolkaEvent(ctx, arg)
+	if err != nil || recorded != 1 {
+		t.Errorf("RecordPolkaEvent = %d, %v; want 1", recorded, err)
+	}
+	recorded, err = s.RecordPolkaEvent(ctx, arg)
+	if err != nil || recorded != 0 {
+		t.Errorf("RecordPolkaEvent replayed = %d, %v; want 0", recorded, err)
+	}
+}
+
+func testSubscriptions(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	upsert := func(user database.User, status string, periodEnd time.Time) database.Subscription {
+		t.Helper()
+		subscription, err := s.UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+			UserID:           user.ID,
+			Status:           status,
+			CurrentPeriodEnd: periodEnd,
+		})
+		if err != nil {
+			t.Fatalf("UpsertSubscription(%q): %v", user.Email, err)
+		}
+		return subscription
+	}
+
+	lapsed := createUser(t, s, "lapsed@example.com")
+	first := upsert(lapsed, "active", future)
+	again := upsert(lapsed, "active", past)
+	if again.CreatedAt.Sub(first.CreatedAt).Abs() > time.Second || again.Status != "active" {
+		t.Errorf("UpsertSubscription didn't update in place: %+v, then %+v", first, again)
+	}
+
+	current := createUser(t, s, "current@example.com")
+	upsert(current, "active", future)
+
+	graced := createUser(t, s, "graced@example.com")
+	upsert(graced, "active", past)
+	updated, err := s.MarkSubscriptionPastDue(ctx, database.MarkSubscriptionPastDueParams{
+		UserID:         graced.ID,
+		GracePeriodEnd: sql.NullTime{Time: future, Valid: true},
+	})
+	if err != nil || updated != 1 {
+		t.Errorf("MarkSubscriptionPastDue = %d, %v; want 1", updated, err)
+	}
+
+	cancelled := createUser(t, s, "cancelled@example.com")
+	upsert(cancelled, "active", past)
+	updated, err = s.CancelSubscription(ctx, cancelled.ID)
+	if err != nil || updated != 1 {
+		t.Errorf("CancelSubscription = %d, %v; want 1", updated, err)
+	}
+	// Cancelling keeps the subscription until its period ends
+	if _, err := s.CancelSubscription(ctx, current.ID); err != nil {
+		t.Fatal(err)
+	}
+	unsubscribed := createUser(t, s, "unsubscribed@example.com")
+	updated, err = s.CancelSubscription(ctx, unsubscribed.ID)
+	if err != nil || updated != 0 {
+		t.Errorf("CancelSubscription without a subscription = %d, %v; want 0", updated, err)
+	}
+
+	expired, err := s.ExpireLapsedSubscriptions(ctx)
+	slices.SortFunc(expired, func(a, b uuid.UUID) int { return slices.Compare(a[:], b[:]) })
+	want := []uuid.UUID{lapsed.ID, cancelled.ID}
+	slices.SortFunc(want, func(a, b uuid.UUID) int { return slices.Compare(a[:], b[:]) })
+	if err != nil || !slices.Equal(expired, want) {
+		t.Errorf("ExpireLapsedSubscriptions = %v, %v; want the lapsed and cancelled users %v", expired, err, want)
+	}
+
+	// Expired subscriptions stay expired
+	updated, err = s.MarkSubscriptionPastDue(ctx, database.MarkSubscriptionPastDueParams{
+		UserID:         lapsed.ID,
+		GracePeriodEnd: sql.NullTime{Time: future, Valid: true},
+	})
+	if err != nil || updated != 0 {
+		t.Errorf("MarkSubscriptionPastDue on an expired subscription = %d, %v; want 0", updated, err)
+	}
+	expired, err = s.ExpireLapsedSubscriptions(ctx)
+	if err != nil || len(expired) != 0 {
+		t.Errorf("ExpireLapsedSubscriptions again = %v, %v; want none", expired, err)
+	}
+}
+
+func createWebhookSubscription(t *testing.T, s database.Store, user database.User, eventTypes ...string) database.WebhookSubscription {
+	t.Helper()
+	subscription, err := s.CreateWebhookSubscription(context.Background(), database.CreateWebhookSubscriptionParams{
+		UserID:     user.ID,
+		Url:        "https://example.com/" + user.Email,
+		EventTypes: eventTypes,
+		Secret:     "secret-" + user.Email,
+	})
+	if err != nil {
+		t.Fatalf("CreateWebhookSubscription(%q): %v", user.Email, err)
+	}
+	return subscription
+}
+
+func testWebhookSubscriptions(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+
+	first := createWebhookSubscription(t, s, alice, "chirp.created", "user.updated")
+	if !first.Active || first.Url != "https://example.com/alice@example.com" ||
+		!slices.Equal(first.EventTypes, []string{"chirp.created", "user.updated"}) {
+		t.Errorf("unexpected subscription %+v", first)
+	}
+	second := createWebhookSubscription(t, s, alice, "chirp.deleted")
+	createWebhookSubscription(t, s, bob, "chirp.deleted")
+
+	subscriptions, err := s.GetWebhookSubscriptionsByUser(ctx, alice.ID)
+	if err != nil || len(subscriptions) != 2 || subscriptions[0].ID != first.ID || subscriptions[1].ID != second.ID {
+		t.Errorf("GetWebhookSubscriptionsByUser = %+v, %v; want alice's two, oldest first", subscriptions, err)
+	}
+
+	got, err := s.GetWebhookSubscription(ctx, database.GetWebhookSubscriptionParams{ID: first.ID, UserID: alice.ID})
+	if err != nil || got.Secret != first.Secret || !slices.Equal(got.EventTypes, first.EventTypes) {
+		t.Errorf("GetWebhookSubscription = %+v, %v", got, err)
+	}
+	_, err = s.GetWebhookSubscription(ctx, database.GetWebhookSubscriptionParams{ID: first.ID, UserID: bob.ID})
+	expectNoRows(t, "GetWebhookSubscription for another user", err)
+
+	deleted, err := s.DeleteWebhookSubscription(ctx, database.DeleteWebhookSubscriptionParams{ID: first.ID, UserID: bob.ID})
+	if err != nil || deleted != 0 {
+		t.Errorf("DeleteWebhookSubscription for another user = %d, %v; want 0", deleted, err)
+	}
+	deleted, err = s.DeleteWebhookSubscription(ctx, database.DeleteWebhookSubscriptionParams{ID: first.ID, UserID: alice.ID})
+	if err != nil || deleted != 1 {
+		t.Errorf("DeleteWebhookSubscription = %d, %v; want 1", deleted, err)
+	}
+	_, err = s.GetWebhookSubscription(ctx, database.GetWebhookSubscriptionParams{ID: first.ID, UserID: alice.ID})
+	expectNoRows(t, "GetWebhookSubscription after delete", err)
+}
+
+func testWebhookDeliveries(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	created := createWebhookSubscription(t, s, alice, "chirp.created")
+	deleted := createWebhookSubscription(t, s, bob, "chirp.created", "chirp.deleted")
+
+	// Only alice's subscription hears about alice's chirp
+	enqueued, err := s.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+		EventType: "chirp.created",
+		Payload:   json.RawMessage(`{"id":"abc"}`),
+		UserID:    alice.ID,
+	})
+	if err != nil || enqueued != 1 {
+		t.Fatalf("EnqueueWebhookDeliveries = %d, %v; want 1", enqueued, err)
+	}
+
+	claims, err := s.ClaimWebhookDeliveries(ctx, 10)
+	if err != nil || len(claims) != 1 {
+		t.Fatalf("ClaimWebhookDeliveries = %+v, %v; want 1", claims, err)
+	}
+	claim := claims[0]
+	if claim.Url != created.Url || claim.Secret != created.Secret || claim.EventType != "chirp.created" || claim.Attempts != 0 {
+		t.Errorf("unexpected claim %+v", claim)
+	}
+	var payload map[string]string
+	if err := json.Unmarshal(claim.Payload, &payload); err != nil || payload["id"] != "abc" {
+		t.Errorf("payload = %s, %v", claim.Payload, err)
+	}
+	if claims, err := s.ClaimWebhookDeliveries(ctx, 10); err != nil || len(claims) != 0 {
+		t.Errorf("ClaimWebhookDeliveries claimed a leased delivery: %+v, %v", claims, err)
+	}
+
+	err = s.MarkWebhookDeliveryFailed(ctx, database.MarkWebhookDeliveryFailedParams{
+		ID:             claim.ID,
+		Status:         "pending",
+		LastStatusCode: sql.NullInt32{Int32: 500, Valid: true},
+		LastError:      sql.NullString{String: "subscriber responded with 500", Valid: true},
+		NextAttemptAt:  time.Now().Add(-time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("MarkWebhookDeliveryFailed: %v", err)
+	}
+	claims, err = s.ClaimWebhookDeliveries(ctx, 10)
+	if err != nil || len(claims) != 1 || claims[0].Attempts != 1 {
+		t.Fatalf("ClaimWebhookDeliveries after a failure = %+v, %v; want the delivery with 1 attempt", claims, err)
+	}
+
+	err = s.MarkWebhookDelivered(ctx, database.MarkWebhookDeliveredParams{
+		ID:             claim.ID,
+		LastStatusCode: sql.NullInt32{Int32: 204, Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("MarkWebhookDelivered: %v", err)
+	}
+	deliveries, err := s.GetWebhookDeliveries(ctx, database.GetWebhookDeliveriesParams{SubscriptionID: created.ID, Limit: 10})
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("GetWebhookDeliveries = %+v, %v; want 1", deliveries, err)
+	}
+	delivery := deliveries[0]
+	if delivery.Status != "delivered" || delivery.Attempts != 2 || delivery.LastStatusCode.Int32 != 204 ||
+		delivery.LastError.Valid || !delivery.DeliveredAt.Valid {
+		t.Errorf("unexpected delivery %+v", delivery)
+	}
+	if claims, err := s.ClaimWebhookDeliveries(ctx, 10); err != nil || len(claims) != 0 {
+		t.Errorf("ClaimWebhookDeliveries claimed a delivered webhook: %+v, %v", claims, err)
+	}
+
+	redelivered, err := s.RedeliverWebhook(ctx, database.RedeliverWebhookParams{ID: claim.ID, SubscriptionID: deleted.ID})
+	if err != nil || redelivered != 0 {
+		t.Errorf("RedeliverWebhook for another subscription = %d, %v; want 0", redelivered, err)
+	}
+	redelivered, err = s.RedeliverWebhook(ctx, database.RedeliverWebhookParams{ID: claim.ID, SubscriptionID: created.ID})
+	if err != nil || redelivered != 1 {
+		t.Errorf("RedeliverWebhook = %d, %v; want 1", redelivered, err)
+	}
+	claims, err = s.ClaimWebhookDeliveries(ctx, 10)
+	if err != nil || len(claims) != 1 || claims[0].Attempts != 0 {
+		t.Errorf("ClaimWebhookDeliveries after redelivery = %+v, %v; want the delivery with 0 attempts", claims, err)
+	}
+}
+
+func testInTxCommit(t *testing.T, s database.Store) {
+	ctx := context.Background()
+
+	var user database.User
+	err := s.InTx(ctx, func(tx database.Store) error {
+		user = createUser(t, tx, "gus@pollos.com")
+		// Nested calls join the outer transaction
+		return tx.InTx(ctx, func(tx database.Store) error {
+			createChirp(t, tx, user.ID, "Los Pollos Hermanos")
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("InTx: %v", err)
+	}
+
+	if _, err := s.GetUserByID(ctx, user.ID); err != nil {
+		t.Errorf("GetUserByID after commit: %v", err)
+	}
+	chirps, err := s.GetChirpsByUserID(ctx, user.ID)
+	if err != nil || len(chirps) != 1 {
+		t.Errorf("GetChirpsByUserID after commit = %v, %v", chirps, err)
+	}
+}
+
+func testInTxRollback(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	existing := createUser(t, s, "lydia@madrigal.com")
+
+	var user database.User
+	err := s.InTx(ctx, func(tx database.Store) error {
+		user = createUser(t, tx, "jesse@breakingbad.com")
+		createChirp(t, tx, existing.ID, "Stevia")
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("InTx returned %v, want the callback's error", err)
+	}
+
+	_, err = s.GetUserByID(ctx, user.ID)
+	expectNoRows(t, "GetUserByID after rollback", err)
+	chirps, err := s.GetChirps(ctx)
+	if err != nil || len(chirps) != 0 {
+		t.Errorf("GetChirps after rollback = %v, %v", chirps, err)
+	}
+}
@@ -0,0 +1,41 @@
+// Package migrate applies the goose migrations embedded from sql/schema and
+// sql/sqlite/schema, so the binary doesn't need the .sql files on disk.
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+
+	postgresschema "github.com/exglegaming/Chirpy/sql/schema"
+	sqliteschema "github.com/exglegaming/Chirpy/sql/sqlite/schema"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+type Backend string
+
+const (
+	Postgres Backend = "postgres"
+	SQLite   Backend = "sqlite"
+)
+
+// New returns a goose provider for backend's migrations. On Postgres,
+// migrations run while holding a session-level advisory lock so instances
+// migrating at startup don't race each other. SQLite serialises writers
+// itself and has no equivalent.
+func New(db *sql.DB, backend Backend) (*goose.Provider, error) {
+	switch backend {
+	case Postgres:
+		locker, err := lock.NewPostgresSessionLocker()
+		if err != nil {
+			return nil, err
+		}
+		return goose.NewProvider(goose.DialectPostgres, db, postgresschema.FS,
+			goose.WithSessionLocker(locker),
+		)
+	case SQLite:
+		return goose.NewProvider(goose.DialectSQLite3, db, sqliteschema.FS)
+	default:
+		return nil, fmt.Errorf("unknown database backend %q", backend)
+	}
+}
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps buckets in process, so each instance limits on its own
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]memoryBucket
+	now     func() time.Time
+}
+
+type memoryBucket struct {
+	bucket
+	fullAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]memoryBucket),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	b, ok := s.buckets[key]
+	if !ok {
+		b.bucket = bucket{tokens: float64(limit.Requests), updated: now}
+	}
+	var result Result
+	b.bucket, result, b.fullAt = b.take(limit, now)
+	s.buckets[key] = b
+	return result, nil
+}
+
+func (s *MemoryStore) Sweep(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for key, b := range s.buckets {
+		if !b.fullAt.After(now) {
+			delete(s.buckets, key)
+		}
+	}
+	return nil
+}
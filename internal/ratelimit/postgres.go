@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+)
+
+// PostgresStore keeps buckets in the rate_limit_buckets table, so the limit
+// holds across every instance. Each Take locks its bucket's row, and uses
+// the database's clock so instances' clocks don't have to agree.
+type PostgresStore struct {
+	db      *sql.DB
+	observe database.QueryObserver
+}
+
+func NewPostgresStore(db *sql.DB, observe database.QueryObserver) *PostgresStore {
+	return &PostgresStore{db: db, observe: observe}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Result{}, err
+	}
+	defer tx.Rollback()
+	q := database.New(database.Observe(tx, s.observe))
+
+	row, err := q.LockRateLimitBucket(ctx, database.LockRateLimitBucketParams{
+		Key:    key,
+		Tokens: float64(limit.Requests),
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	b, result, fullAt := bucket{tokens: row.Tokens, updated: row.UpdatedAt}.take(limit, row.Now)
+	err = q.UpdateRateLimitBucket(ctx, database.UpdateRateLimitBucketParams{
+		Key:       key,
+		Tokens:    b.tokens,
+		UpdatedAt: b.updated,
+		FullAt:    fullAt,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	return result, tx.Commit()
+}
+
+func (s *PostgresStore) Sweep(ctx context.Context) error {
+	_, err := database.New(database.Observe(s.db, s.observe)).DeleteFullRateLimitBuckets(ctx)
+	return err
+}
@@ -0,0 +1,103 @@
+// Package ratelimit implements token bucket rate limiting. Buckets are kept
+// in memory, or in Postgres so every instance shares them.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Requests per Per, in bursts of up to Requests. The zero
+// Limit allows everything.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// ParseLimit parses a limit written as "<requests>/<duration>", e.g.
+// "30/1m", or "off"
+func ParseLimit(s string) (Limit, error) {
+	if s == "off" {
+		return Limit{}, nil
+	}
+	requests, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("rate limit %q isn't <requests>/<duration> or off", s)
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n < 1 {
+		return Limit{}, fmt.Errorf("rate limit %q must allow at least 1 request", s)
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("rate limit %q must have a positive duration", s)
+	}
+	return Limit{Requests: n, Per: d}, nil
+}
+
+func (l Limit) String() string {
+	if l.Requests == 0 {
+		return "off"
+	}
+	return strconv.Itoa(l.Requests) + "/" + l.Per.String()
+}
+
+func (l Limit) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func (l *Limit) UnmarshalText(text []byte) (err error) {
+	*l, err = ParseLimit(string(text))
+	return err
+}
+
+// Result is the outcome of taking a token
+type Result struct {
+	Allowed bool
+	// Tokens left in the bucket
+	Remaining int
+	// Until the bucket is full again
+	Reset time.Duration
+	// Until a request would be allowed; zero if this one was
+	RetryAfter time.Duration
+}
+
+// Store holds buckets, keyed by whatever's being limited
+type Store interface {
+	// Take takes a token from key's bucket if it has one
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+	// Sweep forgets full buckets, which are the same as no bucket
+	Sweep(ctx context.Context) error
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// take refills b for the time since it was last updated, then takes a
+// token if there's one whole token left. It returns the updated bucket and
+// when it will be full again.
+func (b bucket) take(limit Limit, now time.Time) (bucket, Result, time.Time) {
+	burst := float64(limit.Requests)
+	perToken := limit.Per / time.Duration(limit.Requests)
+
+	elapsed := max(now.Sub(b.updated), 0)
+	b.tokens = min(burst, b.tokens+float64(elapsed)/float64(perToken))
+	b.updated = now
+
+	var result Result
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - b.tokens) * float64(perToken))
+	}
+	result.Remaining = int(math.Floor(b.tokens))
+	result.Reset = time.Duration((burst - b.tokens) * float64(perToken))
+	return b, result, now.Add(result.Reset)
+}
@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Limit
+		wantErr bool
+	}{
+		{input: "30/1m", want: Limit{Requests: 30, Per: time.Minute}},
+		{input: "1/1s", want: Limit{Requests: 1, Per: time.Second}},
+		{input: "off", want: Limit{}},
+		{input: "30", wantErr: true},
+		{input: "0/1m", wantErr: true},
+		{input: "ten/1m", wantErr: true},
+		{input: "30/0s", wantErr: true},
+		{input: "30/soon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseLimit(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLimit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseLimit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreTake(t *testing.T) {
+	limit := Limit{Requests: 2, Per: 10 * time.Second}
+
+	tests := []struct {
+		name string
+		// Seconds after the first request that each request is made
+		at   []int
+		want Result
+	}{
+		{
+			name: "First request",
+			at:   []int{0},
+			want: Result{Allowed: true, Remaining: 1, Reset: 5 * time.Second},
+		},
+		{
+			name: "Burst used up",
+			at:   []int{0, 0},
+			want: Result{Allowed: true, Remaining: 0, Reset: 10 * time.Second},
+		},
+		{
+			name: "Over the limit",
+			at:   []int{0, 0, 1},
+			want: Result{Allowed: false, Remaining: 0, Reset: 9 * time.Second, RetryAfter: 4 * time.Second},
+		},
+		{
+			name: "Refilled",
+			at:   []int{0, 0, 5},
+			want: Result{Allowed: true, Remaining: 0, Reset: 10 * time.Second},
+		},
+		{
+			name: "Refill stops at the burst",
+			at:   []int{0, 0, 60},
+			want: Result{Allowed: true, Remaining: 1, Reset: 5 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			var now time.Time
+			store := NewMemoryStore()
+			store.now = func() time.Time { return now }
+
+			var got Result
+			for _, s := range tt.at {
+				now = start.Add(time.Duration(s) * time.Second)
+				var err error
+				got, err = store.Take(context.Background(), "key", limit)
+				if err != nil {
+					t.Fatalf("Take() error = %v", err)
+				}
+			}
+			if got != tt.want {
+				t.Errorf("Take() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	start := time.Now()
+	now := start
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	limit := Limit{Requests: 2, Per: 10 * time.Second}
+
+	store.Take(context.Background(), "a", limit)
+	now = start.Add(4 * time.Second)
+	store.Take(context.Background(), "b", limit)
+
+	now = start.Add(5 * time.Second)
+	store.Sweep(context.Background())
+	if _, ok := store.buckets["a"]; ok {
+		t.Error("full bucket wasn't swept")
+	}
+	if _, ok := store.buckets["b"]; !ok {
+		t.Error("bucket that isn't full was swept")
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+type loggerKey struct{}
+
+// requestLogger returns the logger for the request ctx belongs to, tagged
+// with its request ID and user, or the default logger outside a request
+func requestLogger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// middlewareLog assigns each request an ID, reusing the caller's
+// X-Request-ID if it sent a sensible one, and writes one log line per
+// request once it finishes
+func (cfg *apiConfig) middlewareLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := r.Header.Get(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+
+		logger := slog.Default().With("request_id", requestID)
+		if span := trace.SpanContextFromContext(r.Context()); span.IsValid() {
+			logger = logger.With("trace_id", span.TraceID().String())
+		}
+		if principal, ok := principalFrom(r.Context()); ok {
+			logger = logger.With("user_id", principal.UserID)
+		}
+
+		rec, w := recorderFor(w)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), loggerKey{}, logger)))
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", rec.route),
+			slog.Int("status", rec.statusCode()),
+			slog.Int("bytes", rec.bytes),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+		}
+		if rec.errMsg != "" {
+			attrs = append(attrs, slog.String("error_message", rec.errMsg))
+		}
+		if rec.err != nil {
+			attrs = append(attrs, slog.String("error", rec.err.Error()))
+		}
+		level := slog.LevelInfo
+		if rec.statusCode() >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logger.LogAttrs(r.Context(), level, "request", attrs...)
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// statusRecorder captures what happened to a request for the log, metrics
+// and trace middleware, which share one recorder. It passes through Flush
+// and Hijack, which SSE and WebSockets rely on.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+	// The ServeMux pattern that matched, set by middlewareMetrics
+	route string
+	// Set by respondWithError
+	errMsg string
+	err    error
+}
+
+// recorderFor returns the recorder an outer middleware already wrapped w
+// in, or wraps w in a new one
+func recorderFor(w http.ResponseWriter) (*statusRecorder, http.ResponseWriter) {
+	if rec := findStatusRecorder(w); rec != nil {
+		return rec, w
+	}
+	rec := &statusRecorder{ResponseWriter: w}
+	return rec, rec
+}
+
+// statusCode is the status sent, which is 200 if the handler never set one
+func (rec *statusRecorder) statusCode() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+	return rec.status
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+func (rec *statusRecorder) Flush() {
+	http.NewResponseController(rec.ResponseWriter).Flush()
+}
+
+func (rec *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(rec.ResponseWriter).Hijack()
+	if err == nil {
+		rec.status = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to
+// set write deadlines
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// findStatusRecorder finds the recorder middlewareLog wrapped w in, if any
+func findStatusRecorder(w http.ResponseWriter) *statusRecorder {
+	for {
+		if rec, ok := w.(*statusRecorder); ok {
+			return rec
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil
+		}
+		w = u.Unwrap()
+	}
+}
+
+// newLogger builds the JSON logger the server logs through
+func newLogger(level slog.Level) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// captureLogs sends the default logger to a buffer for the rest of the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+func TestRequestID(t *testing.T) {
+	api := newTestAPI(t)
+	captureLogs(t)
+
+	tests := []struct {
+		name     string
+		incoming string
+		wantSame bool
+	}{
+		{name: "Generated when missing"},
+		{name: "Propagated", incoming: "req-123", wantSame: true},
+		{name: "Replaced when invalid", incoming: "has spaces in it"},
+		{name: "Replaced when too long", incoming: strings.Repeat("x", 129)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/chirps", nil)
+			if tt.incoming != "" {
+				req.Header.Set(requestIDHeader, tt.incoming)
+			}
+			rec := httptest.NewRecorder()
+			api.handler.ServeHTTP(rec, req)
+
+			got := rec.Header().Get(requestIDHeader)
+			if got == "" {
+				t.Fatal("no request ID in the response")
+			}
+			if (got == tt.incoming) != tt.wantSame {
+				t.Errorf("request ID = %q, incoming %q", got, tt.incoming)
+			}
+		})
+	}
+}
+
+func TestRequestLog(t *testing.T) {
+	api := newTestAPI(t)
+	login := api.signUp("hank@dea.gov", "minerals")
+	logs := captureLogs(t)
+
+	req := httptest.NewRequest("POST", "/api/chirps", strings.NewReader(`{"body": "`+strings.Repeat("a", 141)+`"}`))
+	req.Header.Set("Authorization", "Bearer "+login.Token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(requestIDHeader, "req-456")
+	api.handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var line map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &line); err != nil {
+		t.Fatalf("Couldn't decode log line %q: %v", logs.String(), err)
+	}
+	want := map[string]any{
+		"msg":           "request",
+		"request_id":    "req-456",
+		"user_id":       login.ID.String(),
+		"method":        "POST",
+		"path":          "/api/chirps",
+		"status":        float64(http.StatusBadRequest),
+		"error_message": "Chirp is too long",
+	}
+	for k, v := range want {
+		if line[k] != v {
+			t.Errorf("%s = %v, want %v", k, line[k], v)
+		}
+	}
+	if _, ok := line["latency_ms"]; !ok {
+		t.Error("log line has no latency")
+	}
+}
@@ -1,91 +1,124 @@
 package main
 
 import (
-	"database/sql"
 	"log"
 	"net/http"
 	"os"
 	"sync/atomic"
+	"time"
 
 	"github.com/exglegaming/Chirpy/internal/database"
-	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
 type apiConfig struct {
-	fileserverHits atomic.Int32
-	db             *database.Queries
-	platform       string
-	JWTSecret      string
-	polkaSecret    string
+	fileserverHits      atomic.Int32
+	store               database.Store
+	platform            string
+	JWTSecret           string
+	polkaSecret         string
+	polkaWebhookSecrets []string
+	polkaAllowAPIKey    bool
+	accessTokenTTL      time.Duration
+	refreshTokenTTL     time.Duration
+	chirpEvents         *chirpEventBroker
+	notifications       *broker[database.Notification]
+	webhookClient       *http.Client
+	outbox              *outbox
+	metrics             *metrics
+	rateLimiter         *rateLimiter
+	chirpCache          *chirpCache
+	// filterVersions tells open streams when to reload a viewer's filter
+	filterVersions filterVersions
+	// wsAllowedOrigins may open WebSockets as well as the server's own
+	wsAllowedOrigins []string
+	// readyChecks are run by /api/readyz
+	readyChecks []readyCheck
+	// draining is closed when the server starts shutting down, so
+	// long-lived streams can end
+	draining chan struct{}
 }
 
 func main() {
-	const filepathRoot = "."
-	const port = "8080"
-
-	godotenv.Load()
-	dbURL := os.Getenv("DB_URL")
-	if dbURL == "" {
-		log.Fatal("DB_URL must be set")
-	}
-	platform := os.Getenv("PLATFORM")
-	if platform == "" {
-		log.Fatal("PLATFORM must be set")
-	}
-
-	dbConn, err := sql.Open("postgres", dbURL)
-	if err != nil {
-		log.Fatalf("Error opening database: %s", err)
-	}
-	dbQueries := database.New(dbConn)
-
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		log.Fatal("JWT_SECRET environment variable is not set")
-	}
-
-	polkaKey := os.Getenv("POLKA_KEY")
-	if polkaKey == "" {
-		log.Fatal("POLKA_KEY environment variable is not set")
-	}
-
-	apiCfg := apiConfig{
-		fileserverHits: atomic.Int32{},
-		db:             dbQueries,
-		platform:       platform,
-		JWTSecret:      jwtSecret,
-		polkaSecret:    polkaKey,
+	if err := run(os.Args[1:]); err != nil {
+		log.Fatal(err)
 	}
+}
 
+func (cfg *apiConfig) routes(filepathRoot string) http.Handler {
 	mux := http.NewServeMux()
-	fsHandler := apiCfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot))))
+	fsHandler := cfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot))))
 	mux.Handle("/app/", fsHandler)
 
-	mux.HandleFunc("GET /api/healthz", handlerReadiness)
-
-	mux.HandleFunc("POST /api/users", apiCfg.handlerUsersCreate)
-	mux.HandleFunc("PUT /api/users", apiCfg.handlerUserUpdate)
-
-	mux.HandleFunc("POST /api/login", apiCfg.handlerLogin)
-	mux.HandleFunc("POST /api/refresh", apiCfg.handlerRefresh)
-	mux.HandleFunc("POST /api/revoke", apiCfg.handlerRevoke)
-
-	mux.HandleFunc("POST /api/chirps", apiCfg.handlerChirpsCreate)
-	mux.HandleFunc("GET /api/chirps", apiCfg.handlerChirpsList)
-	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerGetChirps)
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerChirpsDelete)
-
-	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerUpdateUserChirpyRed)
-
-	mux.HandleFunc("POST /admin/reset", apiCfg.handlerReset)
-	mux.HandleFunc("GET /admin/metrics", apiCfg.handlerMetrics)
-
-	srv := &http.Server{
-		Addr:    ":" + port,
-		Handler: mux,
-	}
+	mux.HandleFunc("GET /api/healthz", handlerLiveness)
+	mux.HandleFunc("GET /api/readyz", cfg.handlerReadyz)
+	mux.Handle("GET /metrics", cfg.metrics.handler())
+
+	mux.HandleFunc("POST /api/users", cfg.handlerUsersCreate)
+	mux.HandleFunc("PUT /api/users", cfg.requireUser(cfg.handlerUserUpdate))
+
+	mux.HandleFunc("POST /api/login", cfg.handlerLogin)
+	mux.HandleFunc("POST /api/refresh", cfg.requireRefreshToken(cfg.handlerRefresh))
+	mux.HandleFunc("POST /api/revoke", cfg.requireRefreshToken(cfg.handlerRevoke))
+
+	mux.HandleFunc("POST /api/chirps", cfg.requireUser(cfg.handlerChirpsCreate))
+	mux.HandleFunc("GET /api/chirps", cfg.optionalUser(cfg.handlerChirpsList))
+	mux.HandleFunc("GET /api/chirps/{chirpID}", cfg.optionalUser(cfg.handlerGetChirps))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.requireUser(cfg.handlerChirpsDelete))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/likes", cfg.requireUser(cfg.handlerChirpLikesCreate))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/likes", cfg.requireUser(cfg.handlerChirpLikesDelete))
+
+	mux.HandleFunc("GET /api/stream", cfg.optionalUser(cfg.handlerStream))
+	mux.HandleFunc("GET /api/ws", cfg.requireUser(cfg.handlerWebSocket))
+
+	mux.HandleFunc("POST /api/follows", cfg.requireUser(cfg.handlerFollowsCreate))
+	mux.HandleFunc("GET /api/follows", cfg.requireUser(cfg.handlerFollowsList))
+	mux.HandleFunc("DELETE /api/follows/{userID}", cfg.requireUser(cfg.handlerFollowsDelete))
+
+	mux.HandleFunc("POST /api/blocks", cfg.requireUser(cfg.handlerBlocksCreate))
+	mux.HandleFunc("GET /api/blocks", cfg.requireUser(cfg.handlerBlocksList))
+	mux.HandleFunc("DELETE /api/blocks/{userID}", cfg.requireUser(cfg.handlerBlocksDelete))
+
+	mux.HandleFunc("POST /api/mutes", cfg.requireUser(cfg.handlerMutesCreate))
+	mux.HandleFunc("GET /api/mutes", cfg.requireUser(cfg.handlerMutesList))
+	mux.HandleFunc("DELETE /api/mutes/{userID}", cfg.requireUser(cfg.handlerMutesDelete))
+	mux.HandleFunc("POST /api/mutes/keywords", cfg.requireUser(cfg.handlerMutedKeywordsCreate))
+	mux.HandleFunc("GET /api/mutes/keywords", cfg.requireUser(cfg.handlerMutedKeywordsList))
+	mux.HandleFunc("DELETE /api/mutes/keywords/{keywordID}", cfg.requireUser(cfg.handlerMutedKeywordsDelete))
+
+	mux.HandleFunc("POST /api/conversations", cfg.requireUser(cfg.handlerConversationsCreate))
+	mux.HandleFunc("GET /api/conversations", cfg.requireUser(cfg.handlerConversationsList))
+	mux.HandleFunc("GET /api/conversations/{conversationID}", cfg.requireUser(cfg.handlerConversationGet))
+	mux.HandleFunc("POST /api/conversations/{conversationID}/read", cfg.requireUser(cfg.handlerConversationRead))
+	mux.HandleFunc("POST /api/conversations/{conversationID}/messages", cfg.requireUser(cfg.handlerMessagesCreate))
+	mux.HandleFunc("GET /api/conversations/{conversationID}/messages", cfg.requireUser(cfg.handlerMessagesList))
+
+	mux.HandleFunc("GET /api/notifications", cfg.requireUser(cfg.handlerNotificationsList))
+	mux.HandleFunc("POST /api/notifications/read", cfg.requireUser(cfg.handlerNotificationsReadAll))
+	mux.HandleFunc("POST /api/notifications/{notificationID}/read", cfg.requireUser(cfg.handlerNotificationRead))
+	mux.HandleFunc("GET /api/notifications/preferences", cfg.requireUser(cfg.handlerNotificationPreferencesGet))
+	mux.HandleFunc("PUT /api/notifications/preferences", cfg.requireUser(cfg.handlerNotificationPreferencesUpdate))
+
+	mux.HandleFunc("POST /api/polka/webhooks", cfg.handlerUpdateUserChirpyRed)
+
+	mux.HandleFunc("POST /api/webhooks", cfg.requireUser(cfg.handlerWebhookSubscriptionsCreate))
+	mux.HandleFunc("GET /api/webhooks", cfg.requireUser(cfg.handlerWebhookSubscriptionsList))
+	mux.HandleFunc("DELETE /api/webhooks/{webhookID}", cfg.requireUser(cfg.handlerWebhookSubscriptionsDelete))
+	mux.HandleFunc("GET /api/webhooks/{webhookID}/deliveries", cfg.requireUser(cfg.handlerWebhookDeliveriesList))
+	mux.HandleFunc("POST /api/webhooks/{webhookID}/deliveries/{deliveryID}/redeliver", cfg.requireUser(cfg.handlerWebhookRedeliver))
+
+	mux.HandleFunc("POST /admin/reset", cfg.handlerReset)
+	mux.HandleFunc("GET /admin/metrics", cfg.handlerMetrics)
+
+	return cfg.middleware(mux)
+}
 
-	log.Printf("Serving on port: %s\n", port)
-	log.Fatal(srv.ListenAndServe())
+// middleware wraps the mux in what every request goes through. Tracing
+// comes first so request logs can carry the trace ID, then authentication
+// so logs and rate limits know the user. Rate limiting is last so rejected
+// requests are still logged and counted. Request bodies are capped at
+// maxRequestBodyBytes.
+func (cfg *apiConfig) middleware(mux *http.ServeMux) http.Handler {
+	handler := cfg.middlewareTrace(cfg.middlewareAuth(cfg.middlewareLog(cfg.middlewareMetrics(cfg.middlewareRateLimit(mux)))))
+	return http.MaxBytesHandler(handler, maxRequestBodyBytes)
 }
@@ -3,8 +3,145 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// metrics are exported in Prometheus format at /metrics. Each apiConfig has
+// its own registry so tests don't share counters.
+type metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	queryDuration   *prometheus.HistogramVec
+	streams         *prometheus.GaugeVec
+	chirpsCreated   prometheus.Counter
+	logins          *prometheus.CounterVec
+	rateLimited     *prometheus.CounterVec
+	cacheLookups    *prometheus.CounterVec
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_http_requests_total",
+			Help: "HTTP requests by route pattern, method and status class.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_http_request_duration_seconds",
+			Help:    "HTTP request latency by route pattern and method. Excludes WebSockets.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_db_query_duration_seconds",
+			Help:    "Database query latency by sqlc query name.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"query"}),
+		streams: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "chirpy_active_streams",
+			Help: "Open streaming connections by type (sse or websocket).",
+		}, []string{"type"}),
+		chirpsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chirpy_chirps_created_total",
+			Help: "Chirps created.",
+		}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_logins_total",
+			Help: "Login attempts by result (success or failure).",
+		}, []string{"result"}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_rate_limited_total",
+			Help: "Requests rejected with 429 by rate limit group.",
+		}, []string{"group"}),
+		cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_cache_lookups_total",
+			Help: "Chirp cache lookups by query and result (hit or miss).",
+		}, []string{"query", "result"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.queryDuration,
+		m.streams,
+		m.chirpsCreated,
+		m.logins,
+		m.rateLimited,
+		m.cacheLookups,
+	)
+	return m
+}
+
+// registerBrokers reports how many subscribers each event broker has
+func (m *metrics) registerBrokers(cfg *apiConfig) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "chirpy_broker_subscribers",
+		Help:        "In-process event subscribers by broker.",
+		ConstLabels: prometheus.Labels{"broker": "notifications"},
+	}, func() float64 {
+		return float64(cfg.notifications.len())
+	}))
+	if cfg.chirpEvents != nil {
+		m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "chirpy_broker_subscribers",
+			Help:        "In-process event subscribers by broker.",
+			ConstLabels: prometheus.Labels{"broker": "chirp_events"},
+		}, func() float64 {
+			return float64(cfg.chirpEvents.len())
+		}))
+	}
+}
+
+func (m *metrics) observeQuery(name string, d time.Duration) {
+	m.queryDuration.WithLabelValues(name).Observe(d.Seconds())
+}
+
+// trackStream counts an open SSE or WebSocket connection until the
+// returned func is called
+func (m *metrics) trackStream(kind string) func() {
+	gauge := m.streams.WithLabelValues(kind)
+	gauge.Inc()
+	return gauge.Dec
+}
+
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// middlewareMetrics records every request under the ServeMux pattern that
+// matched it, so path parameters don't blow up the label count. It must
+// wrap the mux directly.
+func (cfg *apiConfig) middlewareMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec, w := recorderFor(w)
+
+		next.ServeHTTP(w, r)
+
+		// The mux sets Pattern on the request it was given. Outer
+		// middleware only see copies, so pass it on through the recorder.
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		rec.route = route
+		status := rec.statusCode()
+		cfg.metrics.requests.WithLabelValues(route, r.Method, strconv.Itoa(status/100)+"xx").Inc()
+		// A hijacked connection's lifetime isn't request latency
+		if status != http.StatusSwitchingProtocols {
+			cfg.metrics.requestDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+		}
+	})
+}
+
 func (cfg *apiConfig) handlerMetrics(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("Content-Type", "text/html")
 	w.WriteHeader(http.StatusOK)
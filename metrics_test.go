@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	api := newTestAPI(t)
+	login := api.signUp("skyler@a1a.com", "car-wash")
+	api.do("POST", "/api/login", "", map[string]string{"email": "skyler@a1a.com", "password": "wrong"})
+	api.postChirp(login.Token, "Business is booming")
+	api.do("GET", "/api/nowhere", "", nil)
+
+	rec := api.do("GET", "/metrics", "", nil)
+	expectStatus(t, rec, http.StatusOK)
+	body := rec.Body.String()
+
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"Successful login", `chirpy_logins_total{result="success"} 1`},
+		{"Failed login", `chirpy_logins_total{result="failure"} 1`},
+		{"Chirps created", `chirpy_chirps_created_total 1`},
+		{"Requests by route", `chirpy_http_requests_total{method="POST",route="POST /api/chirps",status="2xx"} 1`},
+		{"Failed requests", `chirpy_http_requests_total{method="POST",route="POST /api/login",status="4xx"} 1`},
+		{"Unmatched routes", `chirpy_http_requests_total{method="GET",route="unmatched",status="4xx"} 1`},
+		{"Latency", `chirpy_http_request_duration_seconds_count{method="POST",route="POST /api/users"} 1`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(body, tt.line+"\n") {
+				t.Errorf("metrics don't contain %q", tt.line)
+			}
+		})
+	}
+}
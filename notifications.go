@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	notificationTypeReply   = "reply"
+	notificationTypeLike    = "like"
+	notificationTypeMention = "mention"
+	notificationTypeFollow  = "follow"
+)
+
+var notificationTypes = []string{
+	notificationTypeReply,
+	notificationTypeLike,
+	notificationTypeMention,
+	notificationTypeFollow,
+}
+
+type Notification struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Type      string     `json:"type"`
+	ActorID   uuid.UUID  `json:"actor_id"`
+	ChirpID   *uuid.UUID `json:"chirp_id,omitempty"`
+	Read      bool       `json:"read"`
+}
+
+// NotificationGroup collapses repeated events of the same type on the same
+// chirp into a single entry, e.g. "5 people liked your chirp".
+type NotificationGroup struct {
+	Type            string      `json:"type"`
+	ChirpID         *uuid.UUID  `json:"chirp_id,omitempty"`
+	Summary         string      `json:"summary"`
+	ActorIDs        []uuid.UUID `json:"actor_ids"`
+	NotificationIDs []uuid.UUID `json:"notification_ids"`
+	Count           int         `json:"count"`
+	Read            bool        `json:"read"`
+	LatestAt        time.Time   `json:"latest_at"`
+}
+
+// notify records a notification for recipientID in the same transaction as
+// the event behind it, unless it's self-inflicted, the two users have
+// blocked each other, or the recipient has turned the notification type
+// off. It returns nil if nothing was recorded; publish what it returns once
+// the transaction commits.
+func notify(ctx context.Context, q database.Store, recipientID, actorID uuid.UUID, notificationType string, chirpID uuid.NullUUID) (*database.Notification, error) {
+	if recipientID == actorID {
+		return nil, nil
+	}
+
+	blocked, err := q.IsBlockedBetween(ctx, database.IsBlockedBetweenParams{
+		BlockerID: recipientID,
+		BlockedID: actorID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, nil
+	}
+
+	enabled, err := q.IsNotificationEnabled(ctx, database.IsNotificationEnabledParams{
+		UserID: recipientID,
+		Type:   notificationType,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, nil
+	}
+
+	notification, err := q.CreateNotification(ctx, database.CreateNotificationParams{
+		UserID:  recipientID,
+		ActorID: actorID,
+		Type:    notificationType,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
+
+// publishNotifications tells clients connected to this instance about
+// committed notifications. It's best effort: if the process dies first, the
+// notification is still stored and clients see it when they next list
+// them.
+func (cfg *apiConfig) publishNotifications(notifications []database.Notification) {
+	for _, notification := range notifications {
+		cfg.notifications.publish(notification)
+	}
+}
+
+func notificationFromDB(n database.Notification) Notification {
+	notification := Notification{
+		ID:        n.ID,
+		CreatedAt: n.CreatedAt,
+		Type:      n.Type,
+		ActorID:   n.ActorID,
+		Read:      n.ReadAt.Valid,
+	}
+	if n.ChirpID.Valid {
+		chirpID := n.ChirpID.UUID
+		notification.ChirpID = &chirpID
+	}
+	return notification
+}
+
+// groupNotifications expects notifications newest first and keeps groups in
+// order of their most recent event.
+func groupNotifications(notifications []database.Notification) []NotificationGroup {
+	type groupKey struct {
+		notificationType string
+		chirpID          uuid.NullUUID
+	}
+
+	groups := []NotificationGroup{}
+	index := map[groupKey]int{}
+	actorsSeen := map[groupKey]map[uuid.UUID]struct{}{}
+	for _, n := range notifications {
+		key := groupKey{notificationType: n.Type, chirpID: n.ChirpID}
+		i, ok := index[key]
+		if !ok {
+			group := NotificationGroup{
+				Type:     n.Type,
+				Read:     true,
+				LatestAt: n.CreatedAt,
+			}
+			if n.ChirpID.Valid {
+				chirpID := n.ChirpID.UUID
+				group.ChirpID = &chirpID
+			}
+			groups = append(groups, group)
+			i = len(groups) - 1
+			index[key] = i
+			actorsSeen[key] = map[uuid.UUID]struct{}{}
+		}
+
+		group := &groups[i]
+		group.NotificationIDs = append(group.NotificationIDs, n.ID)
+		group.Count++
+		if !n.ReadAt.Valid {
+			group.Read = false
+		}
+		if _, ok := actorsSeen[key][n.ActorID]; !ok {
+			actorsSeen[key][n.ActorID] = struct{}{}
+			group.ActorIDs = append(group.ActorIDs, n.ActorID)
+		}
+	}
+
+	for i := range groups {
+		groups[i].Summary = notificationSummary(groups[i].Type, len(groups[i].ActorIDs))
+	}
+	return groups
+}
+
+func notificationSummary(notificationType string, actors int) string {
+	who := "1 person"
+	if actors != 1 {
+		who = fmt.Sprintf("%d people", actors)
+	}
+
+	switch notificationType {
+	case notificationTypeReply:
+		return who + " replied to your chirp"
+	case notificationTypeLike:
+		return who + " liked your chirp"
+	case notificationTypeMention:
+		return who + " mentioned you"
+	case notificationTypeFollow:
+		return who + " followed you"
+	default:
+		return who + " interacted with you"
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestGroupNotifications(t *testing.T) {
+	walt, jesse, gus := uuid.New(), uuid.New(), uuid.New()
+	chirp := uuid.NullUUID{UUID: uuid.New(), Valid: true}
+	otherChirp := uuid.NullUUID{UUID: uuid.New(), Valid: true}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// n builds the i-th newest notification
+	n := func(i int, notificationType string, actorID uuid.UUID, chirpID uuid.NullUUID, read bool) database.Notification {
+		notification := database.Notification{
+			ID:        uuid.New(),
+			CreatedAt: start.Add(-time.Duration(i) * time.Minute),
+			Type:      notificationType,
+			ActorID:   actorID,
+			ChirpID:   chirpID,
+		}
+		if read {
+			notification.ReadAt.Valid = true
+		}
+		return notification
+	}
+
+	type group struct {
+		notificationType string
+		chirpID          uuid.NullUUID
+		summary          string
+		actors           []uuid.UUID
+		count            int
+		read             bool
+	}
+	tests := []struct {
+		name          string
+		notifications []database.Notification
+		want          []group
+	}{
+		{
+			name: "None",
+			want: nil,
+		},
+		{
+			name: "Likes on one chirp collapse, counting each actor once",
+			notifications: []database.Notification{
+				n(0, notificationTypeLike, walt, chirp, false),
+				n(1, notificationTypeLike, jesse, chirp, true),
+				n(2, notificationTypeLike, walt, chirp, true),
+			},
+			want: []group{
+				{notificationTypeLike, chirp, "2 people liked your chirp", []uuid.UUID{walt, jesse}, 3, false},
+			},
+		},
+		{
+			name: "Different chirps and types stay apart, newest group first",
+			notifications: []database.Notification{
+				n(0, notificationTypeReply, gus, chirp, true),
+				n(1, notificationTypeLike, walt, otherChirp, true),
+				n(2, notificationTypeLike, jesse, chirp, true),
+				n(3, notificationTypeReply, walt, chirp, true),
+			},
+			want: []group{
+				{notificationTypeReply, chirp, "2 people replied to your chirp", []uuid.UUID{gus, walt}, 2, true},
+				{notificationTypeLike, otherChirp, "1 person liked your chirp", []uuid.UUID{walt}, 1, true},
+				{notificationTypeLike, chirp, "1 person liked your chirp", []uuid.UUID{jesse}, 1, true},
+			},
+		},
+		{
+			name: "Follows have no chirp",
+			notifications: []database.Notification{
+				n(0, notificationTypeFollow, walt, uuid.NullUUID{}, false),
+				n(1, notificationTypeFollow, jesse, uuid.NullUUID{}, false),
+				n(2, notificationTypeMention, gus, chirp, false),
+			},
+			want: []group{
+				{notificationTypeFollow, uuid.NullUUID{}, "2 people followed you", []uuid.UUID{walt, jesse}, 2, false},
+				{notificationTypeMention, chirp, "1 person mentioned you", []uuid.UUID{gus}, 1, false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups := groupNotifications(tt.notifications)
+			if len(groups) != len(tt.want) {
+				t.Fatalf("got %d groups, want %d: %+v", len(groups), len(tt.want), groups)
+			}
+			for i, want := range tt.want {
+				got := groups[i]
+				gotChirpID := uuid.NullUUID{}
+				if got.ChirpID != nil {
+					gotChirpID = uuid.NullUUID{UUID: *got.ChirpID, Valid: true}
+				}
+				if got.Type != want.notificationType || gotChirpID != want.chirpID || got.Summary != want.summary ||
+					!slices.Equal(got.ActorIDs, want.actors) || got.Count != want.count || got.Read != want.read {
+					t.Errorf("group %d = %+v, want %+v", i, got, want)
+				}
+				if len(got.NotificationIDs) != want.count {
+					t.Errorf("group %d has %d notification IDs, want %d", i, len(got.NotificationIDs), want.count)
+				}
+			}
+			if len(groups) > 0 && !groups[0].LatestAt.Equal(tt.notifications[0].CreatedAt) {
+				t.Errorf("LatestAt = %v, want the newest notification's %v", groups[0].LatestAt, tt.notifications[0].CreatedAt)
+			}
+		})
+	}
+}
+
+func TestMentionedEmails(t *testing.T) {
+	tests := []struct {
+		body string
+		want []string
+	}{
+		{"No mentions here", nil},
+		{"Hi @walt@breakingbad.com!", []string{"walt@breakingbad.com"}},
+		{"@jesse@breakingbad.com, @walt@breakingbad.com and @jesse@breakingbad.com", []string{"jesse@breakingbad.com", "walt@breakingbad.com"}},
+		{"Not an @email or an email@address.com", nil},
+	}
+	for _, tt := range tests {
+		if got := mentionedEmails(tt.body); !slices.Equal(got, tt.want) {
+			t.Errorf("mentionedEmails(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+)
+
+const (
+	eventChirpCreated = "chirp.created"
+	eventChirpDeleted = "chirp.deleted"
+	eventUserCreated  = "user.created"
+	eventUserUpdated  = "user.updated"
+	eventUserUpgraded = "user.upgraded"
+	eventUserLoggedIn = "user.logged_in"
+
+	outboxBatchSize = 50
+	// outboxMaxAttempts is how many times an event is dispatched before it's
+	// dead-lettered and left for someone to look at
+	outboxMaxAttempts = 10
+	outboxBaseBackoff = 30 * time.Second
+	outboxMaxBackoff  = time.Hour
+	// outboxRetention is how long dispatched and dead events are kept
+	outboxRetention = 7 * 24 * time.Hour
+)
+
+// outboxSubscriber handles a dispatched event. Each subscriber is retried on
+// its own, but delivery is still at-least-once: a subscriber that succeeds
+// just before the dispatcher dies sees the event again. Subscribers must
+// tolerate seeing the same event (by ID) more than once.
+type outboxSubscriber struct {
+	name   string
+	handle func(ctx context.Context, event database.OutboxEvent) error
+}
+
+// outbox dispatches domain events recorded by recordEvent to in-process
+// subscribers. Because the event is written in the same transaction as the
+// change it describes, an event exists if and only if the change committed.
+//
+// Each event is claimed by one instance, so the outbox is for side effects
+// that should happen once, such as queueing webhook deliveries. Pushing to
+// connected clients has to happen on every instance, so it doesn't go
+// through here: chirp events have their own table, read by every instance
+// (see chirpEventBroker), and notifications are published by the instance
+// that created them.
+type outbox struct {
+	store       database.Store
+	subscribers []outboxSubscriber
+	wake        chan struct{}
+}
+
+func newOutbox(store database.Store) *outbox {
+	return &outbox{
+		store: store,
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// subscribe registers a subscriber. It must be called before run.
+func (o *outbox) subscribe(name string, handle func(ctx context.Context, event database.OutboxEvent) error) {
+	o.subscribers = append(o.subscribers, outboxSubscriber{name: name, handle: handle})
+}
+
+// notify asks the dispatcher to check for new events now instead of at its
+// next tick
+func (o *outbox) notify() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run dispatches pending events until ctx is cancelled. Events are claimed
+// with SKIP LOCKED, so every instance can run a dispatcher.
+func (o *outbox) run(ctx context.Context, interval time.Duration, report func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			dispatched, err := o.dispatch(ctx)
+			if err != nil {
+				slog.Error("Couldn't dispatch outbox events", "error", err)
+			}
+			report(err)
+			if err != nil || dispatched < outboxBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-o.wake:
+		}
+	}
+}
+
+func (o *outbox) dispatch(ctx context.Context) (int, error) {
+	events, err := o.store.ClaimOutboxEvents(ctx, outboxBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, event := range events {
+		err := o.deliver(ctx, event)
+		if err == nil {
+			err = o.store.MarkOutboxEventDispatched(ctx, event.ID)
+			if err != nil {
+				slog.Error("Couldn't mark outbox event dispatched", "event_id", event.ID, "error", err)
+			}
+			continue
+		}
+
+		slog.Warn("Couldn't dispatch outbox event", "event_id", event.ID, "event_type", event.Type, "error", err)
+		lastError := sql.NullString{String: err.Error(), Valid: true}
+		if event.Attempts+1 >= outboxMaxAttempts {
+			slog.Error("Dead-lettering outbox event", "event_id", event.ID, "event_type", event.Type, "attempts", event.Attempts+1)
+			err = o.store.MarkOutboxEventDead(ctx, database.MarkOutboxEventDeadParams{
+				ID:        event.ID,
+				LastError: lastError,
+			})
+		} else {
+			err = o.store.MarkOutboxEventFailed(ctx, database.MarkOutboxEventFailedParams{
+				ID:            event.ID,
+				LastError:     lastError,
+				NextAttemptAt: time.Now().UTC().Add(retryBackoff(int(event.Attempts)+1, outboxBaseBackoff, outboxMaxBackoff)),
+			})
+		}
+		if err != nil {
+			slog.Error("Couldn't record failed outbox event", "event_id", event.ID, "error", err)
+		}
+	}
+	return len(events), nil
+}
+
+// deliver hands the event to every subscriber that hasn't already handled
+// it, recording the ones that succeed so a retry only goes to those that
+// failed
+func (o *outbox) deliver(ctx context.Context, event database.OutboxEvent) error {
+	handled, err := o.store.GetOutboxEventSubscribers(ctx, event.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't get subscribers that handled the event: %w", err)
+	}
+
+	var errs []error
+	for _, sub := range o.subscribers {
+		if slices.Contains(handled, sub.name) {
+			continue
+		}
+		if err := sub.handle(ctx, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sub.name, err))
+			continue
+		}
+		err := o.store.CreateOutboxEventSubscriber(ctx, database.CreateOutboxEventSubscriberParams{
+			EventID:    event.ID,
+			Subscriber: sub.name,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("couldn't record that %s handled the event: %w", sub.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runRetention deletes dispatched and dead events older than
+// outboxRetention every interval until ctx is cancelled
+func (o *outbox) runRetention(ctx context.Context, interval time.Duration, report func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		pruned, err := o.store.DeleteOutboxEventsBefore(ctx, time.Now().Add(-outboxRetention))
+		if err != nil {
+			slog.Error("Couldn't prune outbox events", "error", err)
+		} else if pruned > 0 {
+			slog.Info("Pruned outbox events", "events", pruned)
+		}
+		report(err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// retryBackoff doubles the wait after each attempt up to maxBackoff, with
+// jitter so a dependency coming back up isn't hit by every retry at once
+func retryBackoff(attempts int, base, maxBackoff time.Duration) time.Duration {
+	backoff := base << (attempts - 1)
+	if backoff <= 0 || backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	jitter := time.Duration(rand.Int64N(int64(backoff) / 5))
+	return backoff + jitter
+}
+
+// recordEvent writes an event to the outbox. Pass the transaction's store
+// so the event commits or rolls back with the change.
+func recordEvent(ctx context.Context, q database.Store, eventType string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = q.CreateOutboxEvent(ctx, database.CreateOutboxEventParams{
+		Type:    eventType,
+		Payload: payload,
+	})
+	return err
+}
+
+// withTx runs fn in a transaction, committing if it returns nil
+func (cfg *apiConfig) withTx(ctx context.Context, fn func(q database.Store) error) error {
+	err := cfg.store.InTx(ctx, fn)
+	if err != nil {
+		return err
+	}
+
+	cfg.outbox.notify()
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+)
+
+// makeOutboxEventDue records a failed attempt at event that's due for a
+// retry straight away
+func (api *testAPI) makeOutboxEventDue(event database.OutboxEvent) {
+	api.t.Helper()
+	err := api.store.MarkOutboxEventFailed(api.t.Context(), database.MarkOutboxEventFailedParams{
+		ID:            event.ID,
+		NextAttemptAt: time.Now().Add(-time.Minute),
+	})
+	if err != nil {
+		api.t.Fatal(err)
+	}
+}
+
+func (api *testAPI) outboxEvent(event database.OutboxEvent) database.OutboxEvent {
+	api.t.Helper()
+	events := api.store.OutboxEvents()
+	i := slices.IndexFunc(events, func(e database.OutboxEvent) bool { return e.ID == event.ID })
+	if i < 0 {
+		api.t.Fatalf("outbox event %s is gone", event.ID)
+	}
+	return events[i]
+}
+
+func TestOutboxRetriesOnlyFailedSubscribers(t *testing.T) {
+	api := newTestAPI(t)
+	calls := map[string]int{}
+	api.cfg.outbox.subscribe("steady", func(ctx context.Context, event database.OutboxEvent) error {
+		calls["steady"]++
+		return nil
+	})
+	api.cfg.outbox.subscribe("flaky", func(ctx context.Context, event database.OutboxEvent) error {
+		calls["flaky"]++
+		if calls["flaky"] == 1 {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	event, err := api.store.CreateOutboxEvent(t.Context(), database.CreateOutboxEventParams{Type: eventChirpCreated})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := api.cfg.outbox.dispatch(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if got := api.outboxEvent(event); got.DispatchedAt.Valid || got.Attempts != 1 {
+		t.Fatalf("event after a failure = %+v, want 1 failed attempt", got)
+	}
+	api.makeOutboxEventDue(event)
+	if _, err := api.cfg.outbox.dispatch(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !api.outboxEvent(event).DispatchedAt.Valid {
+		t.Error("event wasn't dispatched once every subscriber succeeded")
+	}
+	if calls["steady"] != 1 || calls["flaky"] != 2 {
+		t.Errorf("calls = %v, want steady once and flaky twice", calls)
+	}
+}
+
+func TestOutboxDeadLetter(t *testing.T) {
+	tests := []struct {
+		name         string
+		prevAttempts int
+		wantDead     bool
+	}{
+		{"First failure", 0, false},
+		{"Last retry", outboxMaxAttempts - 2, false},
+		{"Out of attempts", outboxMaxAttempts - 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI(t)
+			api.cfg.outbox.subscribe("broken", func(ctx context.Context, event database.OutboxEvent) error {
+				return errors.New("boom")
+			})
+			event, err := api.store.CreateOutboxEvent(t.Context(), database.CreateOutboxEventParams{Type: eventChirpCreated})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for range tt.prevAttempts {
+				api.makeOutboxEventDue(event)
+			}
+
+			if _, err := api.cfg.outbox.dispatch(t.Context()); err != nil {
+				t.Fatal(err)
+			}
+			got := api.outboxEvent(event)
+			if got.DeadAt.Valid != tt.wantDead || int(got.Attempts) != tt.prevAttempts+1 {
+				t.Errorf("event after %d attempts is dead %t, want %t", got.Attempts, got.DeadAt.Valid, tt.wantDead)
+			}
+			if !tt.wantDead && !got.NextAttemptAt.After(time.Now()) {
+				t.Errorf("retry is due at %v, want it backed off", got.NextAttemptAt)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// Error codes. Clients match on these, so they mustn't change once
+// released; the detail messages sent with them may.
+const (
+	codeInvalidRequest   = "invalid_request"
+	codeInvalidJSON      = "invalid_json"
+	codeValidationFailed = "validation_failed"
+	codeUnauthorized     = "unauthorized"
+	codeInvalidToken     = "invalid_token"
+	codeForbidden        = "forbidden"
+	codeNotFound         = "not_found"
+	codeConflict         = "conflict"
+	codeEmailTaken       = "email_taken"
+	codeBodyTooLarge     = "body_too_large"
+	codeUnsupportedMedia = "unsupported_media_type"
+	codeRateLimited      = "rate_limited"
+	codeInternal         = "internal_error"
+	codeUnavailable      = "service_unavailable"
+)
+
+// Field error codes
+const (
+	fieldRequired    = "required"
+	fieldTooLong     = "too_long"
+	fieldInvalid     = "invalid"
+	fieldInvalidType = "invalid_type"
+	fieldUnknown     = "unknown"
+)
+
+// apiError is an error response. It's sent as an RFC 9457 problem detail,
+// with Code as an extension member for clients to match on.
+type apiError struct {
+	Status int
+	// Code defaults to the generic code for Status
+	Code   string
+	Detail string
+	Fields []fieldError
+}
+
+// fieldError is a problem with one field of a request
+type fieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *fieldError) Error() string {
+	return e.Message
+}
+
+type problem struct {
+	Type   string       `json:"type"`
+	Title  string       `json:"title"`
+	Status int          `json:"status"`
+	Detail string       `json:"detail,omitempty"`
+	Code   string       `json:"code"`
+	Errors []fieldError `json:"errors,omitempty"`
+}
+
+// statusCode is the code for errors that don't have a more specific one
+func statusCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return codeInvalidRequest
+	case http.StatusUnauthorized:
+		return codeUnauthorized
+	case http.StatusForbidden:
+		return codeForbidden
+	case http.StatusNotFound:
+		return codeNotFound
+	case http.StatusConflict:
+		return codeConflict
+	case http.StatusRequestEntityTooLarge:
+		return codeBodyTooLarge
+	case http.StatusUnsupportedMediaType:
+		return codeUnsupportedMedia
+	case http.StatusTooManyRequests:
+		return codeRateLimited
+	case http.StatusServiceUnavailable:
+		return codeUnavailable
+	}
+	if status >= 500 {
+		return codeInternal
+	}
+	return codeInvalidRequest
+}
+
+// respondWithError sends a problem with the generic code for code. msg is
+// shown to clients, so it mustn't come from err, which is only logged.
+func respondWithError(w http.ResponseWriter, code int, msg string, err error) {
+	respondWithAPIError(w, apiError{Status: code, Detail: msg}, err)
+}
+
+// respondWithAPIError hands err to the request log, or logs it directly
+// when there isn't one, and sends e as application/problem+json
+func respondWithAPIError(w http.ResponseWriter, e apiError, err error) {
+	if e.Code == "" {
+		e.Code = statusCode(e.Status)
+	}
+	if rec := findStatusRecorder(w); rec != nil {
+		rec.errMsg = e.Detail
+		rec.err = err
+	} else if err != nil || e.Status > 499 {
+		slog.Error("Responding with error", "status", e.Status, "code", e.Code, "error_message", e.Detail, "error", err)
+	}
+
+	dat, err := json.Marshal(problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(e.Status),
+		Status: e.Status,
+		Detail: e.Detail,
+		Code:   e.Code,
+		Errors: e.Fields,
+	})
+	if err != nil {
+		slog.Error("Couldn't marshal JSON", "error", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(e.Status)
+	w.Write(dat)
+}
+
+// respondWithValidationError sends a 400 listing the fields err, a
+// *fieldError, complains about. Anything else is an internal error.
+func respondWithValidationError(w http.ResponseWriter, err error) {
+	var fieldErr *fieldError
+	if !errors.As(err, &fieldErr) {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't validate request", err)
+		return
+	}
+	respondWithAPIError(w, apiError{
+		Status: http.StatusBadRequest,
+		Code:   codeValidationFailed,
+		Detail: fieldErr.Message,
+		Fields: []fieldError{*fieldErr},
+	}, nil)
+}
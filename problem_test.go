@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestProblemResponses(t *testing.T) {
+	api := newTestAPI(t)
+	login := api.signUp("gus@lospollos.com", "hermanos")
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       string
+		wantStatus int
+		wantCode   string
+		wantFields []string
+	}{
+		{"Malformed JSON", "POST", "/api/users", "", `{"email":`, http.StatusBadRequest, codeInvalidJSON, nil},
+		{"Empty body", "POST", "/api/login", "", "", http.StatusBadRequest, codeInvalidJSON, nil},
+		{"Wrong type", "POST", "/api/login", "", `{"email": 5}`, http.StatusBadRequest, codeValidationFailed, []string{"email"}},
+		{"Oversized body", "POST", "/api/users", "", `{"email": "` + strings.Repeat("a", smallBodyBytes) + `"}`, http.StatusRequestEntityTooLarge, codeBodyTooLarge, nil},
+		{"Duplicate email", "POST", "/api/users", "", `{"email": "gus@lospollos.com", "password": "pollos"}`, http.StatusConflict, codeEmailTaken, []string{"email"}},
+		{"Password too long", "POST", "/api/users", "", `{"email": "lydia@madrigal.com", "password": "` + strings.Repeat("a", 73) + `"}`, http.StatusBadRequest, codeValidationFailed, []string{"password"}},
+		{"Chirp too long", "POST", "/api/chirps", login.Token, `{"body": "` + strings.Repeat("a", 141) + `"}`, http.StatusBadRequest, codeValidationFailed, []string{"body"}},
+		{"Webhook URL not absolute", "POST", "/api/webhooks", login.Token, `{"url": "/hooks", "event_types": ["chirp.created"]}`, http.StatusBadRequest, codeValidationFailed, []string{"url"}},
+		{"Webhook URL not http", "POST", "/api/webhooks", login.Token, `{"url": "ftp://example.com/hooks", "event_types": ["chirp.created"]}`, http.StatusBadRequest, codeValidationFailed, []string{"url"}},
+		{"Webhook without event types", "POST", "/api/webhooks", login.Token, `{"url": "https://example.com/hooks"}`, http.StatusBadRequest, codeValidationFailed, []string{"event_types"}},
+		{"Webhook unknown event type", "POST", "/api/webhooks", login.Token, `{"url": "https://example.com/hooks", "event_types": ["chirp.eaten"]}`, http.StatusBadRequest, codeValidationFailed, []string{"event_types"}},
+		{"Unknown notification type", "PUT", "/api/notifications/preferences", login.Token, `{"follow": true, "carrier_pigeon": false}`, http.StatusBadRequest, codeValidationFailed, []string{"carrier_pigeon"}},
+		{"Missing token", "POST", "/api/chirps", "", `{"body": "hi"}`, http.StatusUnauthorized, codeUnauthorized, nil},
+		{"Bad token", "POST", "/api/chirps", "nonsense", `{"body": "hi"}`, http.StatusUnauthorized, codeInvalidToken, nil},
+		{"Not found", "GET", "/api/chirps/6a5b9d8e-1f6c-4b1e-9d9c-2f5d0f1e7a10", "", "", http.StatusNotFound, codeNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			api.handler.ServeHTTP(rec, req)
+
+			expectStatus(t, rec, tt.wantStatus)
+			if got := rec.Header().Get("Content-Type"); got != "application/problem+json" {
+				t.Errorf("Content-Type = %q, want application/problem+json", got)
+			}
+			got := decode[problem](t, rec)
+			if got.Status != tt.wantStatus || got.Code != tt.wantCode || got.Title != http.StatusText(tt.wantStatus) {
+				t.Errorf("problem = %+v, want status %d and code %s", got, tt.wantStatus, tt.wantCode)
+			}
+			var fields []string
+			for _, field := range got.Errors {
+				fields = append(fields, field.Field)
+			}
+			if !slices.Equal(fields, tt.wantFields) {
+				t.Errorf("fields = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestProblemHidesInternalErrors(t *testing.T) {
+	rec := httptest.NewRecorder()
+	respondWithError(rec, http.StatusInternalServerError, "Couldn't create user", errors.New("pq: connection refused"))
+
+	got := decode[problem](t, rec)
+	if got.Code != codeInternal || got.Detail != "Couldn't create user" {
+		t.Errorf("problem = %+v, want code %s", got, codeInternal)
+	}
+	if strings.Contains(rec.Body.String(), "connection refused") {
+		t.Errorf("response leaks the internal error: %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/ratelimit"
+)
+
+const (
+	rateLimitStoreMemory   = "memory"
+	rateLimitStorePostgres = "postgres"
+)
+
+// Route groups, which each have their own limit
+const (
+	// Signing up, logging in and refreshing, limited per IP
+	rateLimitAuth = "auth"
+	// Posting chirps
+	rateLimitChirps = "chirps"
+	// Other API writes
+	rateLimitWrite = "write"
+	// API reads
+	rateLimitRead = "read"
+)
+
+var rateLimitGroups = []string{rateLimitAuth, rateLimitChirps, rateLimitWrite, rateLimitRead}
+
+// rateLimitGroup is the group whose limit applies to a route pattern, or ""
+// if the route isn't limited
+func rateLimitGroup(pattern string) string {
+	switch pattern {
+	case "POST /api/users", "POST /api/login", "POST /api/refresh":
+		return rateLimitAuth
+	case "POST /api/chirps":
+		return rateLimitChirps
+	// Probes, and Polka, which retries anything but a 2xx
+	case "GET /api/healthz", "GET /api/readyz", "POST /api/polka/webhooks":
+		return ""
+	}
+	method, path, _ := strings.Cut(pattern, " ")
+	if !strings.HasPrefix(path, "/api/") {
+		return ""
+	}
+	if method == http.MethodGet {
+		return rateLimitRead
+	}
+	return rateLimitWrite
+}
+
+// rateLimiter limits requests by route group, per user if they're
+// authenticated and per client IP if not
+type rateLimiter struct {
+	store          ratelimit.Store
+	limits         map[string]ratelimit.Limit
+	trustedProxies []netip.Prefix
+}
+
+func newRateLimiter(store ratelimit.Store, cfg Config) (*rateLimiter, error) {
+	proxies, err := parseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	return &rateLimiter{
+		store:          store,
+		limits:         cfg.RateLimits,
+		trustedProxies: proxies,
+	}, nil
+}
+
+// parseTrustedProxies accepts CIDRs and, as a single address prefix, plain
+// addresses
+func parseTrustedProxies(proxies []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, proxy := range proxies {
+		if addr, err := netip.ParseAddr(proxy); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q isn't an address or CIDR", proxy)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func (rl *rateLimiter) trusted(addr netip.Addr) bool {
+	for _, prefix := range rl.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP is the address a request came from. X-Forwarded-For is read
+// from the right, and only believed while the hop that added each entry is
+// a trusted proxy, since clients can put anything in it.
+func (rl *rateLimiter) clientIP(r *http.Request) netip.Addr {
+	remote, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return netip.Addr{}
+	}
+	addr := remote.Addr().Unmap()
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0 && rl.trusted(addr); i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop.Unmap()
+	}
+	return addr
+}
+
+// rateLimitKey is who a request counts against. IPv6 clients are limited by /64, as
+// they can usually pick any address in one.
+func (cfg *apiConfig) rateLimitKey(r *http.Request) string {
+	if principal, ok := principalFrom(r.Context()); ok {
+		return "user:" + principal.UserID.String()
+	}
+	addr := cfg.rateLimiter.clientIP(r)
+	if addr.Is6() {
+		prefix, _ := addr.Prefix(64)
+		return "ip:" + prefix.String()
+	}
+	return "ip:" + addr.String()
+}
+
+// middlewareRateLimit applies the limit for the route the mux will pick.
+// It must wrap the mux directly. If the store can't be reached requests are
+// let through, so an outage there doesn't take the API down too.
+func (cfg *apiConfig) middlewareRateLimit(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.rateLimiter == nil {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		_, pattern := mux.Handler(r)
+		group := rateLimitGroup(pattern)
+		limit := cfg.rateLimiter.limits[group]
+		if group == "" || limit.Requests == 0 {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		result, err := cfg.rateLimiter.store.Take(r.Context(), group+":"+cfg.rateLimitKey(r), limit)
+		if err != nil {
+			requestLogger(r.Context()).Warn("Couldn't check rate limit", "group", group, "error", err)
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		header := w.Header()
+		header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%s", limit.Requests, seconds(limit.Per)))
+		header.Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+		header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		header.Set("RateLimit-Reset", seconds(result.Reset))
+		if !result.Allowed {
+			cfg.metrics.rateLimited.WithLabelValues(group).Inc()
+			// The mux never sees the request, so record the route for
+			// middlewareMetrics
+			r.Pattern = pattern
+			header.Set("Retry-After", seconds(result.RetryAfter))
+			respondWithError(w, http.StatusTooManyRequests, "Too many requests", nil)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// seconds rounds d up to whole seconds, as rate limit headers want
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// runSweep forgets full buckets until ctx is cancelled
+func (rl *rateLimiter) runSweep(ctx context.Context, interval time.Duration, report func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := rl.store.Sweep(ctx)
+		if err != nil {
+			slog.Error("Couldn't sweep rate limit buckets", "error", err)
+		}
+		report(err)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/ratelimit"
+)
+
+func newTestRateLimiter(t *testing.T, limits map[string]ratelimit.Limit, trustedProxies ...string) *rateLimiter {
+	t.Helper()
+	cfg := defaultConfig()
+	cfg.RateLimits = limits
+	cfg.TrustedProxies = trustedProxies
+	rl, err := newRateLimiter(ratelimit.NewMemoryStore(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rl
+}
+
+func TestRateLimit(t *testing.T) {
+	api := newTestAPI(t)
+	walt := api.signUp("walt@a1a.com", "heisenberg")
+	jesse := api.signUp("jesse@kcc.com", "yo")
+	api.cfg.rateLimiter = newTestRateLimiter(t, map[string]ratelimit.Limit{
+		rateLimitAuth:   {Requests: 2, Per: time.Minute},
+		rateLimitChirps: {Requests: 1, Per: time.Minute},
+	})
+
+	t.Run("Headers", func(t *testing.T) {
+		rec := api.do("POST", "/api/chirps", walt.Token, map[string]string{"body": "Say my name"})
+		expectStatus(t, rec, http.StatusCreated)
+		want := map[string]string{
+			"RateLimit-Policy":    "1;w=60",
+			"RateLimit-Limit":     "1",
+			"RateLimit-Remaining": "0",
+			"RateLimit-Reset":     "60",
+		}
+		for header, value := range want {
+			if got := rec.Header().Get(header); got != value {
+				t.Errorf("%s = %q, want %q", header, got, value)
+			}
+		}
+	})
+
+	t.Run("Over the limit", func(t *testing.T) {
+		rec := api.do("POST", "/api/chirps", walt.Token, map[string]string{"body": "Say my name again"})
+		expectStatus(t, rec, http.StatusTooManyRequests)
+		if got := rec.Header().Get("Retry-After"); got != "60" {
+			t.Errorf("Retry-After = %q, want 60", got)
+		}
+		chirps := decode[[]Chirp](t, api.do("GET", "/api/chirps", "", nil))
+		if len(chirps) != 1 {
+			t.Errorf("got %d chirps, want 1", len(chirps))
+		}
+	})
+
+	t.Run("Per user", func(t *testing.T) {
+		rec := api.do("POST", "/api/chirps", jesse.Token, map[string]string{"body": "Yeah science"})
+		expectStatus(t, rec, http.StatusCreated)
+	})
+
+	t.Run("Per IP when unauthenticated", func(t *testing.T) {
+		login := func(remoteAddr string) int {
+			req := httptest.NewRequest("POST", "/api/login", strings.NewReader(`{"email": "walt@a1a.com", "password": "heisenberg"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.RemoteAddr = remoteAddr
+			rec := httptest.NewRecorder()
+			api.handler.ServeHTTP(rec, req)
+			return rec.Code
+		}
+		for range 2 {
+			if code := login("192.0.2.1:1234"); code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", code)
+			}
+		}
+		if code := login("192.0.2.1:5678"); code != http.StatusTooManyRequests {
+			t.Errorf("status = %d, want 429", code)
+		}
+		if code := login("192.0.2.2:1234"); code != http.StatusOK {
+			t.Errorf("another IP's status = %d, want 200", code)
+		}
+	})
+
+	t.Run("Unlimited groups", func(t *testing.T) {
+		for range 5 {
+			rec := api.do("GET", "/api/chirps", "", nil)
+			expectStatus(t, rec, http.StatusOK)
+			if rec.Header().Get("RateLimit-Limit") != "" {
+				t.Fatal("unlimited route has rate limit headers")
+			}
+		}
+	})
+
+	t.Run("Counted by route", func(t *testing.T) {
+		body := api.do("GET", "/metrics", "", nil).Body.String()
+		for _, line := range []string{
+			`chirpy_rate_limited_total{group="chirps"} 1`,
+			`chirpy_http_requests_total{method="POST",route="POST /api/chirps",status="4xx"} 1`,
+		} {
+			if !strings.Contains(body, line+"\n") {
+				t.Errorf("metrics don't contain %q", line)
+			}
+		}
+	})
+}
+
+func TestRateLimitGroup(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{"POST /api/login", rateLimitAuth},
+		{"POST /api/users", rateLimitAuth},
+		{"POST /api/chirps", rateLimitChirps},
+		{"PUT /api/users", rateLimitWrite},
+		{"DELETE /api/chirps/{chirpID}", rateLimitWrite},
+		{"GET /api/chirps/{chirpID}", rateLimitRead},
+		{"GET /api/healthz", ""},
+		{"POST /api/polka/webhooks", ""},
+		{"GET /metrics", ""},
+		{"/app/", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			if got := rateLimitGroup(tt.pattern); got != tt.want {
+				t.Errorf("rateLimitGroup(%q) = %q, want %q", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	rl := newTestRateLimiter(t, nil, "10.0.0.0/8", "2001:db8::1")
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		want         string
+	}{
+		{
+			name:       "Direct",
+			remoteAddr: "203.0.113.7:1234",
+			want:       "203.0.113.7",
+		},
+		{
+			name:         "Untrusted peer's header is ignored",
+			remoteAddr:   "203.0.113.7:1234",
+			forwardedFor: []string{"198.51.100.1"},
+			want:         "203.0.113.7",
+		},
+		{
+			name:         "Trusted proxy",
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"198.51.100.1"},
+			want:         "198.51.100.1",
+		},
+		{
+			name:         "Spoofed entries before the proxy's are ignored",
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"1.2.3.4, 198.51.100.1"},
+			want:         "198.51.100.1",
+		},
+		{
+			name:         "Chain of trusted proxies",
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"198.51.100.1, 10.0.0.2", "10.0.0.3"},
+			want:         "198.51.100.1",
+		},
+		{
+			name:         "Garbage stops the walk",
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"198.51.100.1, unknown"},
+			want:         "10.0.0.1",
+		},
+		{
+			name:         "Trusted IPv6 proxy",
+			remoteAddr:   "[2001:db8::1]:1234",
+			forwardedFor: []string{"2001:db8:1::5"},
+			want:         "2001:db8:1::5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/chirps", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.forwardedFor {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			if got := rl.clientIP(req).String(); got != tt.want {
+				t.Errorf("clientIP() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,9 +1,206 @@
 package main
 
-import "net/http"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
 
-func handlerReadiness(w http.ResponseWriter, r *http.Request) {
+	"github.com/pressly/goose/v3"
+)
+
+// readyCheckTimeout bounds each readiness check, so a hung database fails
+// the probe rather than stalling it
+const readyCheckTimeout = 2 * time.Second
+
+// readyCheck reports whether one dependency is usable. detail is shown in
+// the report either way, so it mustn't include anything sensitive; errors
+// are only logged.
+type readyCheck struct {
+	name  string
+	check func(ctx context.Context) (detail string, err error)
+}
+
+type readyCheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Detail    string  `json:"detail,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// handlerLiveness only says the process is up and serving. It deliberately
+// checks nothing else, so an outage elsewhere doesn't get the server
+// restarted.
+func handlerLiveness(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(http.StatusText(http.StatusOK)))
 }
+
+// handlerReadyz runs every readiness check concurrently and responds 503 if
+// any of them fail
+func (cfg *apiConfig) handlerReadyz(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Status string                      `json:"status"`
+		Checks map[string]readyCheckResult `json:"checks"`
+	}
+
+	results := make([]readyCheckResult, len(cfg.readyChecks))
+	var wg sync.WaitGroup
+	for i, c := range cfg.readyChecks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runReadyCheck(r.Context(), c)
+		}()
+	}
+	wg.Wait()
+
+	resp := response{Status: "ok", Checks: make(map[string]readyCheckResult, len(results))}
+	code := http.StatusOK
+	for i, c := range cfg.readyChecks {
+		resp.Checks[c.name] = results[i]
+		if results[i].Status != "ok" {
+			resp.Status = "unavailable"
+			code = http.StatusServiceUnavailable
+		}
+	}
+	respondWithJSON(w, code, resp)
+}
+
+func runReadyCheck(ctx context.Context, c readyCheck) readyCheckResult {
+	ctx, cancel := context.WithTimeout(ctx, readyCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	detail, err := c.check(ctx)
+	result := readyCheckResult{
+		Status:    "ok",
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		Detail:    detail,
+	}
+	if err != nil {
+		// The probe is unauthenticated, so errors, which can carry database
+		// addresses and the like, only go to the log
+		slog.WarnContext(ctx, "Readiness check failed", "check", c.name, "error", err)
+		result.Status = "error"
+		result.Error = "check failed"
+	}
+	return result
+}
+
+func databaseCheck(db *sql.DB) readyCheck {
+	return readyCheck{
+		name: "database",
+		check: func(ctx context.Context) (string, error) {
+			return "", db.PingContext(ctx)
+		},
+	}
+}
+
+// migrationsCheck fails if another instance has migrated the database past
+// this binary, or it's been rolled back underneath it
+func migrationsCheck(migrations *goose.Provider) readyCheck {
+	return readyCheck{
+		name: "migrations",
+		check: func(ctx context.Context) (string, error) {
+			current, target, err := migrations.GetVersions(ctx)
+			if err != nil {
+				return "", err
+			}
+			detail := fmt.Sprintf("version %d", current)
+			if current != target {
+				return detail, fmt.Errorf("schema is at version %d, expected %d", current, target)
+			}
+			return detail, nil
+		},
+	}
+}
+
+// workerHealth tracks each background worker's last pass. Workers report
+// after every pass; one that stops, fails or goes quiet fails readiness.
+type workerHealth struct {
+	mu      sync.Mutex
+	workers map[string]*workerStatus
+}
+
+type workerStatus struct {
+	interval time.Duration
+	started  time.Time
+	lastOK   time.Time
+	err      error
+	stopped  bool
+}
+
+func newWorkerHealth() *workerHealth {
+	return &workerHealth{workers: make(map[string]*workerStatus)}
+}
+
+// register starts tracking a worker that's expected to report at least
+// once per interval
+func (h *workerHealth) register(name string, interval time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.workers[name] = &workerStatus{interval: interval, started: time.Now()}
+}
+
+// report records the outcome of one of the worker's passes
+func (h *workerHealth) report(name string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	w := h.workers[name]
+	w.err = err
+	if err == nil {
+		w.lastOK = time.Now()
+	}
+}
+
+// stopped records that the worker's goroutine has returned
+func (h *workerHealth) stop(name string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	w := h.workers[name]
+	w.stopped = true
+	if err != nil {
+		w.err = err
+	}
+}
+
+func (h *workerHealth) check(name string) readyCheck {
+	return readyCheck{
+		name: "worker:" + name,
+		check: func(context.Context) (string, error) {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			return h.workers[name].health(time.Now())
+		},
+	}
+}
+
+func (w *workerStatus) health(now time.Time) (string, error) {
+	// Leave room for a slow pass, e.g. a webhook timing out
+	staleAfter := max(3*w.interval, time.Minute)
+
+	detail := "no successful pass yet"
+	since := w.started
+	if !w.lastOK.IsZero() {
+		since = w.lastOK
+		detail = fmt.Sprintf("last succeeded %s ago", now.Sub(w.lastOK).Round(time.Millisecond))
+	}
+	switch {
+	case w.stopped:
+		if w.err != nil {
+			return detail, fmt.Errorf("stopped: %w", w.err)
+		}
+		return detail, errors.New("stopped")
+	case w.err != nil:
+		return detail, w.err
+	case now.Sub(since) > staleAfter:
+		return detail, fmt.Errorf("no successful pass in %s", staleAfter)
+	}
+	return detail, nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadyz(t *testing.T) {
+	ok := readyCheck{name: "database", check: func(context.Context) (string, error) {
+		return "", nil
+	}}
+	failing := readyCheck{name: "migrations", check: func(context.Context) (string, error) {
+		return "version 3", errors.New("schema is at version 3, expected 4")
+	}}
+	hung := readyCheck{name: "database", check: func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}}
+
+	tests := []struct {
+		name       string
+		checks     []readyCheck
+		wantStatus int
+		wantChecks map[string]string
+	}{
+		{
+			name:       "No checks",
+			wantStatus: http.StatusOK,
+			wantChecks: map[string]string{},
+		},
+		{
+			name:       "All passing",
+			checks:     []readyCheck{ok},
+			wantStatus: http.StatusOK,
+			wantChecks: map[string]string{"database": "ok"},
+		},
+		{
+			name:       "One failing",
+			checks:     []readyCheck{ok, failing},
+			wantStatus: http.StatusServiceUnavailable,
+			wantChecks: map[string]string{"database": "ok", "migrations": "error"},
+		},
+		{
+			name:       "Timed out",
+			checks:     []readyCheck{hung},
+			wantStatus: http.StatusServiceUnavailable,
+			wantChecks: map[string]string{"database": "error"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI(t)
+			api.cfg.readyChecks = tt.checks
+
+			rec := api.do("GET", "/api/readyz", "", nil)
+			expectStatus(t, rec, tt.wantStatus)
+
+			var got struct {
+				Status string                      `json:"status"`
+				Checks map[string]readyCheckResult `json:"checks"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("Couldn't decode response: %v", err)
+			}
+			if len(got.Checks) != len(tt.wantChecks) {
+				t.Errorf("got %d checks, want %d", len(got.Checks), len(tt.wantChecks))
+			}
+			for name, status := range tt.wantChecks {
+				if got.Checks[name].Status != status {
+					t.Errorf("%s status = %q, want %q", name, got.Checks[name].Status, status)
+				}
+			}
+		})
+	}
+}
+
+func TestReadyzHidesErrors(t *testing.T) {
+	api := newTestAPI(t)
+	api.cfg.readyChecks = []readyCheck{{name: "database", check: func(context.Context) (string, error) {
+		return "", errors.New("dial tcp 10.0.4.2:5432: connect: connection refused")
+	}}}
+
+	rec := api.do("GET", "/api/readyz", "", nil)
+	expectStatus(t, rec, http.StatusServiceUnavailable)
+	if strings.Contains(rec.Body.String(), "10.0.4.2") {
+		t.Errorf("readiness report leaks the error: %s", rec.Body)
+	}
+}
+
+func TestHealthzChecksNothing(t *testing.T) {
+	api := newTestAPI(t)
+	api.cfg.readyChecks = []readyCheck{{name: "database", check: func(context.Context) (string, error) {
+		return "", errors.New("connection refused")
+	}}}
+
+	rec := api.do("GET", "/api/healthz", "", nil)
+	expectStatus(t, rec, http.StatusOK)
+}
+
+func TestWorkerHealth(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		status  workerStatus
+		wantErr bool
+	}{
+		{
+			name:   "Just started",
+			status: workerStatus{interval: time.Second, started: now.Add(-time.Second)},
+		},
+		{
+			name:   "Recent pass",
+			status: workerStatus{interval: time.Second, started: now.Add(-time.Hour), lastOK: now.Add(-2 * time.Second)},
+		},
+		{
+			name:    "Never passed",
+			status:  workerStatus{interval: time.Second, started: now.Add(-time.Hour)},
+			wantErr: true,
+		},
+		{
+			name:    "Stale",
+			status:  workerStatus{interval: 10 * time.Minute, started: now.Add(-time.Hour), lastOK: now.Add(-31 * time.Minute)},
+			wantErr: true,
+		},
+		{
+			name:    "Last pass failed",
+			status:  workerStatus{interval: time.Second, started: now, lastOK: now, err: errors.New("connection refused")},
+			wantErr: true,
+		},
+		{
+			name:    "Stopped",
+			status:  workerStatus{interval: time.Second, started: now, lastOK: now, stopped: true},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.status.health(now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("health() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
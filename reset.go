@@ -10,7 +10,8 @@ func (cfg *apiConfig) handlerReset(w http.ResponseWriter, r *http.Request) {
 	}
 
 	cfg.fileserverHits.Store(0)
-	cfg.db.Reset(r.Context())
+	cfg.store.Reset(r.Context())
+	cfg.chirpCache.invalidateAll(r.Context())
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Hits reset to 0 and database reset to initial state."))
 }
@@ -0,0 +1,31 @@
+-- name: CreateBlock :exec
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES (
+        $1,
+        $2,
+        NOW()
+       )
+ON CONFLICT (blocker_id, blocked_id) DO NOTHING;
+
+-- name: DeleteBlock :execrows
+DELETE FROM blocks
+WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: GetBlocksByBlocker :many
+SELECT * FROM blocks
+WHERE blocker_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetBlockRelatedUserIDs :many
+SELECT blocked_id FROM blocks
+WHERE blocker_id = $1
+UNION
+SELECT blocker_id FROM blocks
+WHERE blocked_id = $1;
+
+-- name: IsBlockedBetween :one
+SELECT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = $1 AND blocked_id = $2)
+       OR (blocker_id = $2 AND blocked_id = $1)
+);
@@ -0,0 +1,23 @@
+-- name: GetLatestChirpEvent :one
+SELECT * FROM chirp_events
+WHERE tx_id < txid_snapshot_xmin(txid_current_snapshot())
+ORDER BY tx_id DESC, id DESC
+LIMIT 1;
+
+-- name: GetChirpEvent :one
+SELECT * FROM chirp_events
+WHERE id = $1;
+
+-- name: GetChirpEventsAfter :many
+-- Only events from transactions older than every one still running are
+-- read, so an event that commits late can't be skipped: its transaction
+-- holds the horizon back until it finishes.
+SELECT * FROM chirp_events
+WHERE (tx_id > $1 OR (tx_id = $1 AND id > $2))
+  AND tx_id < txid_snapshot_xmin(txid_current_snapshot())
+ORDER BY tx_id ASC, id ASC
+LIMIT $3;
+
+-- name: DeleteChirpEventsBefore :execrows
+DELETE FROM chirp_events
+WHERE created_at < $1;
@@ -0,0 +1,17 @@
+-- name: CreateFollow :execrows
+INSERT INTO follows (follower_id, followed_id, created_at)
+VALUES (
+        $1,
+        $2,
+        NOW()
+       )
+ON CONFLICT (follower_id, followed_id) DO NOTHING;
+
+-- name: DeleteFollow :execrows
+DELETE FROM follows
+WHERE follower_id = $1 AND followed_id = $2;
+
+-- name: GetFollowsByFollower :many
+SELECT * FROM follows
+WHERE follower_id = $1
+ORDER BY created_at DESC;
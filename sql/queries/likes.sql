@@ -0,0 +1,12 @@
+-- name: CreateChirpLike :execrows
+INSERT INTO chirp_likes (chirp_id, user_id, created_at)
+VALUES (
+        $1,
+        $2,
+        NOW()
+       )
+ON CONFLICT (chirp_id, user_id) DO NOTHING;
+
+-- name: DeleteChirpLike :execrows
+DELETE FROM chirp_likes
+WHERE chirp_id = $1 AND user_id = $2;
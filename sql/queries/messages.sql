@@ -0,0 +1,89 @@
+-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at)
+VALUES (
+        gen_random_uuid(),
+        NOW(),
+        NOW()
+       )
+    RETURNING *;
+
+-- name: AddConversationMember :exec
+INSERT INTO conversation_members (conversation_id, user_id, joined_at, last_read_at)
+VALUES (
+        $1,
+        $2,
+        NOW(),
+        NULL
+       );
+
+-- name: GetConversationMembers :many
+SELECT * FROM conversation_members
+WHERE conversation_id = $1
+ORDER BY joined_at ASC, user_id ASC;
+
+-- name: GetConversationsForUser :many
+SELECT c.id, c.created_at, c.updated_at,
+       (SELECT COUNT(*) FROM messages msg
+        WHERE msg.conversation_id = c.id
+          AND msg.sender_id <> m.user_id
+          AND (m.last_read_at IS NULL OR msg.created_at > m.last_read_at))::BIGINT AS unread_count
+FROM conversations c
+JOIN conversation_members m ON m.conversation_id = c.id
+WHERE m.user_id = $1
+ORDER BY c.updated_at DESC;
+
+-- name: GetConversationForUser :one
+SELECT c.id, c.created_at, c.updated_at,
+       (SELECT COUNT(*) FROM messages msg
+        WHERE msg.conversation_id = c.id
+          AND msg.sender_id <> m.user_id
+          AND (m.last_read_at IS NULL OR msg.created_at > m.last_read_at))::BIGINT AS unread_count
+FROM conversations c
+JOIN conversation_members m ON m.conversation_id = c.id
+WHERE m.user_id = $1 AND c.id = $2;
+
+-- name: TouchConversation :exec
+UPDATE conversations SET updated_at = NOW()
+WHERE id = $1;
+
+-- name: MarkConversationRead :exec
+UPDATE conversation_members SET last_read_at = NOW()
+WHERE conversation_id = $1 AND user_id = $2;
+
+-- name: HasBlockWithConversationMembers :one
+SELECT EXISTS (
+    SELECT 1 FROM conversation_members m
+    JOIN blocks b
+      ON (b.blocker_id = m.user_id AND b.blocked_id = sqlc.arg(user_id))
+      OR (b.blocker_id = sqlc.arg(user_id) AND b.blocked_id = m.user_id)
+    WHERE m.conversation_id = sqlc.arg(conversation_id) AND m.user_id <> sqlc.arg(user_id)
+);
+
+-- name: CreateMessage :one
+INSERT INTO messages (id, created_at, updated_at, conversation_id, sender_id, body)
+VALUES (
+        gen_random_uuid(),
+        NOW(),
+        NOW(),
+        $1,
+        $2,
+        $3
+       )
+    RETURNING *;
+
+-- name: GetMessage :one
+SELECT * FROM messages
+WHERE id = $1 AND conversation_id = $2;
+
+-- name: GetMessages :many
+SELECT * FROM messages
+WHERE conversation_id = $1
+ORDER BY created_at DESC, id DESC
+LIMIT $2;
+
+-- name: GetMessagesBefore :many
+SELECT * FROM messages
+WHERE conversation_id = sqlc.arg(conversation_id)
+  AND (created_at, id) < (sqlc.arg(before_created_at)::TIMESTAMP, sqlc.arg(before_id)::UUID)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(max_results);
@@ -0,0 +1,40 @@
+-- name: CreateMute :exec
+INSERT INTO mutes (muter_id, muted_id, created_at)
+VALUES (
+        $1,
+        $2,
+        NOW()
+       )
+ON CONFLICT (muter_id, muted_id) DO NOTHING;
+
+-- name: DeleteMute :execrows
+DELETE FROM mutes
+WHERE muter_id = $1 AND muted_id = $2;
+
+-- name: GetMutesByMuter :many
+SELECT * FROM mutes
+WHERE muter_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetMutedUserIDs :many
+SELECT muted_id FROM mutes
+WHERE muter_id = $1;
+
+-- name: CreateMutedKeyword :one
+INSERT INTO muted_keywords (id, created_at, user_id, keyword)
+VALUES (
+        gen_random_uuid(),
+        NOW(),
+        $1,
+        $2
+       )
+    RETURNING *;
+
+-- name: DeleteMutedKeyword :execrows
+DELETE FROM muted_keywords
+WHERE id = $1 AND user_id = $2;
+
+-- name: GetMutedKeywords :many
+SELECT * FROM muted_keywords
+WHERE user_id = $1
+ORDER BY created_at ASC;
@@ -0,0 +1,57 @@
+-- name: CreateNotification :one
+INSERT INTO notifications (id, created_at, user_id, actor_id, type, chirp_id, read_at)
+VALUES (
+        gen_random_uuid(),
+        NOW(),
+        $1,
+        $2,
+        $3,
+        $4,
+        NULL
+       )
+    RETURNING *;
+
+-- name: GetNotifications :many
+SELECT * FROM notifications
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: GetUnreadNotifications :many
+SELECT * FROM notifications
+WHERE user_id = $1 AND read_at IS NULL
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications
+WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications SET read_at = COALESCE(read_at, NOW())
+WHERE id = $1 AND user_id = $2;
+
+-- name: MarkAllNotificationsRead :exec
+UPDATE notifications SET read_at = NOW()
+WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: GetNotificationPreferences :many
+SELECT * FROM notification_preferences
+WHERE user_id = $1
+ORDER BY type ASC;
+
+-- name: UpsertNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, type, enabled)
+VALUES (
+        $1,
+        $2,
+        $3
+       )
+ON CONFLICT (user_id, type) DO UPDATE SET enabled = EXCLUDED.enabled;
+
+-- name: IsNotificationEnabled :one
+SELECT COALESCE(
+    (SELECT enabled FROM notification_preferences
+     WHERE user_id = $1 AND type = $2),
+    true
+)::BOOLEAN AS enabled;
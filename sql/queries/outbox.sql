@@ -0,0 +1,58 @@
+-- name: CreateOutboxEvent :one
+INSERT INTO outbox_events (id, created_at, type, payload, attempts, next_attempt_at)
+VALUES (
+        gen_random_uuid(),
+        NOW(),
+        $1,
+        $2,
+        0,
+        NOW()
+       )
+    RETURNING *;
+
+-- name: ClaimOutboxEvents :many
+UPDATE outbox_events
+SET next_attempt_at = NOW() + INTERVAL '1 minute'
+WHERE id IN (
+    SELECT id FROM outbox_events
+    WHERE dispatched_at IS NULL AND dead_at IS NULL AND next_attempt_at <= NOW()
+    ORDER BY created_at ASC
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkOutboxEventDispatched :exec
+UPDATE outbox_events
+SET dispatched_at = NOW(),
+    attempts = attempts + 1,
+    last_error = NULL
+WHERE id = $1;
+
+-- name: MarkOutboxEventFailed :exec
+UPDATE outbox_events
+SET attempts = attempts + 1,
+    last_error = $2,
+    next_attempt_at = $3
+WHERE id = $1;
+
+-- name: MarkOutboxEventDead :exec
+UPDATE outbox_events
+SET attempts = attempts + 1,
+    last_error = $2,
+    dead_at = NOW()
+WHERE id = $1;
+
+-- name: GetOutboxEventSubscribers :many
+SELECT subscriber FROM outbox_event_subscribers
+WHERE event_id = $1;
+
+-- name: CreateOutboxEventSubscriber :exec
+INSERT INTO outbox_event_subscribers (event_id, subscriber, handled_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteOutboxEventsBefore :execrows
+DELETE FROM outbox_events
+WHERE created_at < $1
+  AND (dispatched_at IS NOT NULL OR dead_at IS NOT NULL);
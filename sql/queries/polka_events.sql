@@ -0,0 +1,8 @@
+-- name: RecordPolkaEvent :execrows
+INSERT INTO polka_events (id, event, received_at)
+VALUES (
+        $1,
+        $2,
+        NOW()
+       )
+ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,19 @@
+-- name: LockRateLimitBucket :one
+-- Creates the bucket full if it's new, and locks it until the end of the
+-- transaction either way
+INSERT INTO rate_limit_buckets (key, tokens, updated_at, full_at)
+VALUES ($1, $2, NOW(), NOW())
+ON CONFLICT (key) DO UPDATE
+SET key = EXCLUDED.key
+    RETURNING tokens, updated_at, NOW()::TIMESTAMP AS now;
+
+-- name: UpdateRateLimitBucket :exec
+UPDATE rate_limit_buckets
+SET tokens = $2,
+    updated_at = $3,
+    full_at = $4
+WHERE key = $1;
+
+-- name: DeleteFullRateLimitBuckets :execrows
+DELETE FROM rate_limit_buckets
+WHERE full_at <= NOW();
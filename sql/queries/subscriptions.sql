@@ -0,0 +1,38 @@
+-- name: UpsertSubscription :one
+INSERT INTO subscriptions (user_id, created_at, updated_at, status, current_period_end, grace_period_end)
+VALUES (
+        $1,
+        NOW(),
+        NOW(),
+        $2,
+        $3,
+        $4
+       )
+ON CONFLICT (user_id) DO UPDATE
+SET status = EXCLUDED.status,
+    current_period_end = EXCLUDED.current_period_end,
+    grace_period_end = EXCLUDED.grace_period_end,
+    updated_at = NOW()
+    RETURNING *;
+
+-- name: MarkSubscriptionPastDue :execrows
+UPDATE subscriptions
+SET status = 'past_due',
+    grace_period_end = $2,
+    updated_at = NOW()
+WHERE user_id = $1 AND status <> 'expired';
+
+-- name: CancelSubscription :execrows
+UPDATE subscriptions
+SET status = 'cancelled',
+    grace_period_end = NULL,
+    updated_at = NOW()
+WHERE user_id = $1 AND status <> 'expired';
+
+-- name: ExpireLapsedSubscriptions :many
+UPDATE subscriptions
+SET status = 'expired',
+    updated_at = NOW()
+WHERE (status IN ('active', 'cancelled') AND current_period_end < NOW())
+   OR (status = 'past_due' AND COALESCE(grace_period_end, current_period_end) < NOW())
+RETURNING user_id;
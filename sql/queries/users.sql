@@ -22,3 +22,16 @@ WHERE id = $1
 -- name: UpdateUserChirpyRed :exec
 UPDATE users SET is_chirpy_red = $2
 WHERE id = $1;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1;
+
+-- name: ListUsers :many
+SELECT * FROM users
+ORDER BY created_at ASC;
+
+-- name: SuspendUser :one
+UPDATE users SET suspended_at = NOW(), updated_at = NOW()
+WHERE id = $1
+RETURNING *;
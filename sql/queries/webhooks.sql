@@ -0,0 +1,85 @@
+-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions (id, created_at, updated_at, user_id, url, event_types, secret, active)
+VALUES (
+        gen_random_uuid(),
+        NOW(),
+        NOW(),
+        $1,
+        $2,
+        $3,
+        $4,
+        true
+       )
+    RETURNING *;
+
+-- name: GetWebhookSubscription :one
+SELECT * FROM webhook_subscriptions
+WHERE id = $1 AND user_id = $2;
+
+-- name: GetWebhookSubscriptionsByUser :many
+SELECT * FROM webhook_subscriptions
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: DeleteWebhookSubscription :execrows
+DELETE FROM webhook_subscriptions
+WHERE id = $1 AND user_id = $2;
+
+-- name: EnqueueWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (id, created_at, updated_at, subscription_id, event_type, payload, status, attempts, next_attempt_at)
+SELECT gen_random_uuid(), NOW(), NOW(), s.id, sqlc.arg(event_type)::TEXT, sqlc.arg(payload), 'pending', 0, NOW()
+FROM webhook_subscriptions s
+WHERE s.active
+  AND s.user_id = sqlc.arg(user_id)
+  AND sqlc.arg(event_type)::TEXT = ANY (s.event_types);
+
+-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries d
+SET next_attempt_at = NOW() + INTERVAL '1 minute',
+    updated_at = NOW()
+FROM webhook_subscriptions s
+WHERE d.subscription_id = s.id
+  AND d.id IN (
+    SELECT wd.id FROM webhook_deliveries wd
+    WHERE wd.status = 'pending' AND wd.next_attempt_at <= NOW()
+    ORDER BY wd.next_attempt_at ASC
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+  )
+RETURNING d.id, d.event_type, d.payload, d.attempts, s.url, s.secret;
+
+-- name: MarkWebhookDelivered :exec
+UPDATE webhook_deliveries
+SET status = 'delivered',
+    attempts = attempts + 1,
+    last_attempt_at = NOW(),
+    last_status_code = $2,
+    last_error = NULL,
+    delivered_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: MarkWebhookDeliveryFailed :exec
+UPDATE webhook_deliveries
+SET status = $2,
+    attempts = attempts + 1,
+    last_attempt_at = NOW(),
+    last_status_code = $3,
+    last_error = $4,
+    next_attempt_at = $5,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE subscription_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: RedeliverWebhook :execrows
+UPDATE webhook_deliveries
+SET status = 'pending',
+    attempts = 0,
+    next_attempt_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1 AND subscription_id = $2;
@@ -0,0 +1,27 @@
+-- +goose Up
+CREATE TABLE blocks (
+    blocker_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    blocked_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (blocker_id, blocked_id)
+);
+
+CREATE TABLE mutes (
+    muter_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    muted_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (muter_id, muted_id)
+);
+
+CREATE TABLE muted_keywords (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    keyword TEXT NOT NULL,
+    UNIQUE (user_id, keyword)
+);
+
+-- +goose Down
+DROP TABLE muted_keywords;
+DROP TABLE mutes;
+DROP TABLE blocks;
@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE notifications (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    actor_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type TEXT NOT NULL CHECK (type IN ('reply', 'like', 'mention', 'follow')),
+    chirp_id UUID NULL REFERENCES chirps (id) ON DELETE CASCADE,
+    read_at TIMESTAMP NULL
+);
+
+CREATE INDEX notifications_user_created_idx ON notifications (user_id, created_at DESC);
+
+CREATE TABLE notification_preferences (
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    PRIMARY KEY (user_id, type)
+);
+
+-- +goose Down
+DROP TABLE notification_preferences;
+DROP TABLE notifications;
@@ -0,0 +1,38 @@
+-- +goose Up
+CREATE TABLE chirp_events (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    type TEXT NOT NULL,
+    chirp_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    body TEXT NULL
+);
+
+-- +goose StatementBegin
+CREATE FUNCTION record_chirp_event() RETURNS TRIGGER AS $$
+DECLARE
+    event_id BIGINT;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO chirp_events (created_at, type, chirp_id, user_id, body)
+        VALUES (NOW(), 'chirp.created', NEW.id, NEW.user_id, NEW.body)
+        RETURNING id INTO event_id;
+    ELSE
+        INSERT INTO chirp_events (created_at, type, chirp_id, user_id, body)
+        VALUES (NOW(), 'chirp.deleted', OLD.id, OLD.user_id, NULL)
+        RETURNING id INTO event_id;
+    END IF;
+    PERFORM pg_notify('chirp_events', event_id::TEXT);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirps_record_event
+AFTER INSERT OR DELETE ON chirps
+FOR EACH ROW EXECUTE FUNCTION record_chirp_event();
+
+-- +goose Down
+DROP TRIGGER chirps_record_event ON chirps;
+DROP FUNCTION record_chirp_event();
+DROP TABLE chirp_events;
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE outbox_events (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_error TEXT NULL,
+    dispatched_at TIMESTAMP NULL
+);
+
+CREATE INDEX outbox_events_pending_idx ON outbox_events (next_attempt_at) WHERE dispatched_at IS NULL;
+
+-- +goose Down
+DROP TABLE outbox_events;
@@ -0,0 +1,22 @@
+-- +goose Up
+ALTER TABLE chirps
+    ADD COLUMN reply_to_id UUID NULL REFERENCES chirps (id) ON DELETE SET NULL;
+
+CREATE TABLE chirp_likes (
+    chirp_id UUID NOT NULL REFERENCES chirps (id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (chirp_id, user_id)
+);
+
+CREATE TABLE follows (
+    follower_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    followed_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (follower_id, followed_id)
+);
+
+-- +goose Down
+DROP TABLE follows;
+DROP TABLE chirp_likes;
+ALTER TABLE chirps DROP COLUMN reply_to_id;
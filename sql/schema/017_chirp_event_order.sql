@@ -0,0 +1,14 @@
+-- +goose Up
+-- BIGSERIAL IDs aren't handed out in commit order, so readers order events
+-- by the transaction that wrote them and only read transactions that have
+-- finished. See GetChirpEventsAfter.
+ALTER TABLE chirp_events
+    ADD COLUMN tx_id BIGINT NOT NULL DEFAULT txid_current();
+
+CREATE INDEX chirp_events_tx_id_id_idx ON chirp_events (tx_id, id);
+CREATE INDEX chirp_events_created_at_idx ON chirp_events (created_at);
+
+-- +goose Down
+DROP INDEX chirp_events_created_at_idx;
+DROP INDEX chirp_events_tx_id_id_idx;
+ALTER TABLE chirp_events DROP COLUMN tx_id;
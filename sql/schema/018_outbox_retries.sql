@@ -0,0 +1,19 @@
+-- +goose Up
+-- Events that keep failing are dead-lettered instead of retried forever
+ALTER TABLE outbox_events ADD COLUMN dead_at TIMESTAMP NULL;
+
+-- Subscribers that have handled an event, so a retry only goes to the ones
+-- that failed
+CREATE TABLE outbox_event_subscribers (
+    event_id UUID NOT NULL REFERENCES outbox_events (id) ON DELETE CASCADE,
+    subscriber TEXT NOT NULL,
+    handled_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (event_id, subscriber)
+);
+
+CREATE INDEX outbox_events_created_at_idx ON outbox_events (created_at);
+
+-- +goose Down
+DROP INDEX outbox_events_created_at_idx;
+DROP TABLE outbox_event_subscribers;
+ALTER TABLE outbox_events DROP COLUMN dead_at;
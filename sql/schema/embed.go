@@ -0,0 +1,7 @@
+// Package schema embeds the Postgres goose migrations
+package schema
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
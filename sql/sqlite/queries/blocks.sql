@@ -0,0 +1,27 @@
+-- name: CreateBlock :exec
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES (?, ?, ?)
+ON CONFLICT (blocker_id, blocked_id) DO NOTHING;
+
+-- name: DeleteBlock :execrows
+DELETE FROM blocks
+WHERE blocker_id = ? AND blocked_id = ?;
+
+-- name: GetBlocksByBlocker :many
+SELECT * FROM blocks
+WHERE blocker_id = ?
+ORDER BY created_at DESC;
+
+-- name: GetBlockRelatedUserIDs :many
+SELECT blocked_id FROM blocks
+WHERE blocker_id = sqlc.arg(user_id)
+UNION
+SELECT blocker_id FROM blocks
+WHERE blocked_id = sqlc.arg(user_id);
+
+-- name: IsBlockedBetween :one
+SELECT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = sqlc.arg(blocker_id) AND blocked_id = sqlc.arg(blocked_id))
+       OR (blocker_id = sqlc.arg(blocked_id) AND blocked_id = sqlc.arg(blocker_id))
+);
@@ -0,0 +1,18 @@
+-- name: GetLatestChirpEvent :one
+SELECT * FROM chirp_events
+ORDER BY id DESC
+LIMIT 1;
+
+-- name: GetChirpEvent :one
+SELECT * FROM chirp_events
+WHERE id = ?;
+
+-- name: GetChirpEventsAfter :many
+SELECT * FROM chirp_events
+WHERE id > ?
+ORDER BY id ASC
+LIMIT ?;
+
+-- name: DeleteChirpEventsBefore :execrows
+DELETE FROM chirp_events
+WHERE created_at < ?;
@@ -0,0 +1,26 @@
+-- name: CreatChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, reply_to_id)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetChirps :many
+SELECT * FROM chirps
+ORDER BY created_at ASC;
+
+-- name: GetChirpsDesc :many
+SELECT * FROM chirps
+ORDER BY created_at DESC;
+
+-- name: GetChirp :one
+SELECT * FROM chirps
+WHERE id = ?;
+
+-- name: DeleteChirp :one
+DELETE FROM chirps
+WHERE id = ? AND user_id = ?
+RETURNING *;
+
+-- name: GetChirpsByUserID :many
+SELECT * FROM chirps
+WHERE user_id = ?
+ORDER BY created_at ASC;
@@ -0,0 +1,13 @@
+-- name: CreateFollow :execrows
+INSERT INTO follows (follower_id, followed_id, created_at)
+VALUES (?, ?, ?)
+ON CONFLICT (follower_id, followed_id) DO NOTHING;
+
+-- name: DeleteFollow :execrows
+DELETE FROM follows
+WHERE follower_id = ? AND followed_id = ?;
+
+-- name: GetFollowsByFollower :many
+SELECT * FROM follows
+WHERE follower_id = ?
+ORDER BY created_at DESC;
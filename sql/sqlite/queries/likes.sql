@@ -0,0 +1,8 @@
+-- name: CreateChirpLike :execrows
+INSERT INTO chirp_likes (chirp_id, user_id, created_at)
+VALUES (?, ?, ?)
+ON CONFLICT (chirp_id, user_id) DO NOTHING;
+
+-- name: DeleteChirpLike :execrows
+DELETE FROM chirp_likes
+WHERE chirp_id = ? AND user_id = ?;
@@ -0,0 +1,74 @@
+-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at)
+VALUES (?, ?, ?)
+RETURNING *;
+
+-- name: AddConversationMember :exec
+INSERT INTO conversation_members (conversation_id, user_id, joined_at, last_read_at)
+VALUES (?, ?, ?, NULL);
+
+-- name: GetConversationMembers :many
+SELECT * FROM conversation_members
+WHERE conversation_id = ?
+ORDER BY joined_at ASC, user_id ASC;
+
+-- name: GetConversationsForUser :many
+SELECT c.id, c.created_at, c.updated_at,
+       (SELECT COUNT(*) FROM messages msg
+        WHERE msg.conversation_id = c.id
+          AND msg.sender_id <> m.user_id
+          AND (m.last_read_at IS NULL OR msg.created_at > m.last_read_at)) AS unread_count
+FROM conversations c
+JOIN conversation_members m ON m.conversation_id = c.id
+WHERE m.user_id = ?
+ORDER BY c.updated_at DESC;
+
+-- name: GetConversationForUser :one
+SELECT c.id, c.created_at, c.updated_at,
+       (SELECT COUNT(*) FROM messages msg
+        WHERE msg.conversation_id = c.id
+          AND msg.sender_id <> m.user_id
+          AND (m.last_read_at IS NULL OR msg.created_at > m.last_read_at)) AS unread_count
+FROM conversations c
+JOIN conversation_members m ON m.conversation_id = c.id
+WHERE m.user_id = ? AND c.id = ?;
+
+-- name: TouchConversation :exec
+UPDATE conversations SET updated_at = ?
+WHERE id = ?;
+
+-- name: MarkConversationRead :exec
+UPDATE conversation_members SET last_read_at = ?
+WHERE conversation_id = ? AND user_id = ?;
+
+-- name: HasBlockWithConversationMembers :one
+SELECT EXISTS (
+    SELECT 1 FROM conversation_members m
+    JOIN blocks b
+      ON (b.blocker_id = m.user_id AND b.blocked_id = sqlc.arg(user_id))
+      OR (b.blocker_id = sqlc.arg(user_id) AND b.blocked_id = m.user_id)
+    WHERE m.conversation_id = sqlc.arg(conversation_id) AND m.user_id <> sqlc.arg(user_id)
+);
+
+-- name: CreateMessage :one
+INSERT INTO messages (id, created_at, updated_at, conversation_id, sender_id, body)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetMessage :one
+SELECT * FROM messages
+WHERE id = ? AND conversation_id = ?;
+
+-- name: GetMessages :many
+SELECT * FROM messages
+WHERE conversation_id = ?
+ORDER BY created_at DESC, id DESC
+LIMIT ?;
+
+-- name: GetMessagesBefore :many
+SELECT * FROM messages
+WHERE conversation_id = sqlc.arg(conversation_id)
+  AND (created_at < sqlc.arg(before_created_at)
+       OR (created_at = sqlc.arg(before_created_at) AND id < sqlc.arg(before_id)))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(max_results);
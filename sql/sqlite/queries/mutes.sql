@@ -0,0 +1,31 @@
+-- name: CreateMute :exec
+INSERT INTO mutes (muter_id, muted_id, created_at)
+VALUES (?, ?, ?)
+ON CONFLICT (muter_id, muted_id) DO NOTHING;
+
+-- name: DeleteMute :execrows
+DELETE FROM mutes
+WHERE muter_id = ? AND muted_id = ?;
+
+-- name: GetMutesByMuter :many
+SELECT * FROM mutes
+WHERE muter_id = ?
+ORDER BY created_at DESC;
+
+-- name: GetMutedUserIDs :many
+SELECT muted_id FROM mutes
+WHERE muter_id = ?;
+
+-- name: CreateMutedKeyword :one
+INSERT INTO muted_keywords (id, created_at, user_id, keyword)
+VALUES (?, ?, ?, ?)
+RETURNING *;
+
+-- name: DeleteMutedKeyword :execrows
+DELETE FROM muted_keywords
+WHERE id = ? AND user_id = ?;
+
+-- name: GetMutedKeywords :many
+SELECT * FROM muted_keywords
+WHERE user_id = ?
+ORDER BY created_at ASC;
@@ -0,0 +1,45 @@
+-- name: CreateNotification :one
+INSERT INTO notifications (id, created_at, user_id, actor_id, type, chirp_id, read_at)
+VALUES (?, ?, ?, ?, ?, ?, NULL)
+RETURNING *;
+
+-- name: GetNotifications :many
+SELECT * FROM notifications
+WHERE user_id = ?
+ORDER BY created_at DESC
+LIMIT ?;
+
+-- name: GetUnreadNotifications :many
+SELECT * FROM notifications
+WHERE user_id = ? AND read_at IS NULL
+ORDER BY created_at DESC
+LIMIT ?;
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications
+WHERE user_id = ? AND read_at IS NULL;
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications SET read_at = COALESCE(read_at, sqlc.arg(read_at))
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id);
+
+-- name: MarkAllNotificationsRead :exec
+UPDATE notifications SET read_at = ?
+WHERE user_id = ? AND read_at IS NULL;
+
+-- name: GetNotificationPreferences :many
+SELECT * FROM notification_preferences
+WHERE user_id = ?
+ORDER BY type ASC;
+
+-- name: UpsertNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, type, enabled)
+VALUES (?, ?, ?)
+ON CONFLICT (user_id, type) DO UPDATE SET enabled = excluded.enabled;
+
+-- name: IsNotificationEnabled :one
+SELECT CAST(COALESCE(
+    (SELECT enabled FROM notification_preferences
+     WHERE user_id = ? AND type = ?),
+    true
+) AS BOOLEAN) AS enabled;
@@ -0,0 +1,50 @@
+-- name: CreateOutboxEvent :one
+INSERT INTO outbox_events (id, created_at, type, payload, attempts, next_attempt_at)
+VALUES (?, ?, ?, ?, 0, ?)
+RETURNING *;
+
+-- name: ClaimOutboxEvents :many
+UPDATE outbox_events
+SET next_attempt_at = sqlc.arg(lease_until)
+WHERE id IN (
+    SELECT id FROM outbox_events
+    WHERE dispatched_at IS NULL AND dead_at IS NULL AND next_attempt_at <= sqlc.arg(now)
+    ORDER BY created_at ASC
+    LIMIT sqlc.arg(max_results)
+)
+RETURNING *;
+
+-- name: MarkOutboxEventDispatched :exec
+UPDATE outbox_events
+SET dispatched_at = ?,
+    attempts = attempts + 1,
+    last_error = NULL
+WHERE id = ?;
+
+-- name: MarkOutboxEventFailed :exec
+UPDATE outbox_events
+SET attempts = attempts + 1,
+    last_error = ?,
+    next_attempt_at = ?
+WHERE id = ?;
+
+-- name: MarkOutboxEventDead :exec
+UPDATE outbox_events
+SET attempts = attempts + 1,
+    last_error = ?,
+    dead_at = ?
+WHERE id = ?;
+
+-- name: GetOutboxEventSubscribers :many
+SELECT subscriber FROM outbox_event_subscribers
+WHERE event_id = ?;
+
+-- name: CreateOutboxEventSubscriber :exec
+INSERT INTO outbox_event_subscribers (event_id, subscriber, handled_at)
+VALUES (?, ?, ?)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteOutboxEventsBefore :execrows
+DELETE FROM outbox_events
+WHERE created_at < ?
+  AND (dispatched_at IS NOT NULL OR dead_at IS NOT NULL);
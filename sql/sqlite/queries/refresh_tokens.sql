@@ -0,0 +1,21 @@
+-- name: CreateRefreshTokens :one
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetRefreshTokenByToken :one
+SELECT * FROM refresh_tokens
+WHERE token = ? AND revoked_at IS NULL AND expires_at > sqlc.arg(now)
+LIMIT 1;
+
+-- name: UpdateRefreshToken :exec
+UPDATE refresh_tokens
+SET revoked_at = ?,
+    updated_at = ?
+WHERE token = ?;
+
+-- name: RevokeUserRefreshTokens :execrows
+UPDATE refresh_tokens
+SET revoked_at = ?,
+    updated_at = ?
+WHERE user_id = ? AND revoked_at IS NULL;
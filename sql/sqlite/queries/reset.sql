@@ -0,0 +1,2 @@
+-- name: Reset :exec
+DELETE FROM users;
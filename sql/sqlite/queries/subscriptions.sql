@@ -0,0 +1,31 @@
+-- name: UpsertSubscription :one
+INSERT INTO subscriptions (user_id, created_at, updated_at, status, current_period_end, grace_period_end)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT (user_id) DO UPDATE
+SET status = excluded.status,
+    current_period_end = excluded.current_period_end,
+    grace_period_end = excluded.grace_period_end,
+    updated_at = excluded.updated_at
+RETURNING *;
+
+-- name: MarkSubscriptionPastDue :execrows
+UPDATE subscriptions
+SET status = 'past_due',
+    grace_period_end = ?,
+    updated_at = ?
+WHERE user_id = ? AND status <> 'expired';
+
+-- name: CancelSubscription :execrows
+UPDATE subscriptions
+SET status = 'cancelled',
+    grace_period_end = NULL,
+    updated_at = ?
+WHERE user_id = ? AND status <> 'expired';
+
+-- name: ExpireLapsedSubscriptions :many
+UPDATE subscriptions
+SET status = 'expired',
+    updated_at = sqlc.arg(now)
+WHERE (status IN ('active', 'cancelled') AND current_period_end < sqlc.arg(now))
+   OR (status = 'past_due' AND COALESCE(grace_period_end, current_period_end) < sqlc.arg(now))
+RETURNING user_id;
@@ -0,0 +1,30 @@
+-- name: CreateUser :one
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, is_chirpy_red)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetUserByEmail :one
+SELECT * FROM users
+WHERE email = ?;
+
+-- name: UpdateUser :one
+UPDATE users SET email = ?, hashed_password = ?, updated_at = ?
+WHERE id = ?
+RETURNING *;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = ?;
+
+-- name: ListUsers :many
+SELECT * FROM users
+ORDER BY created_at ASC;
+
+-- name: SuspendUser :one
+UPDATE users SET suspended_at = ?, updated_at = ?
+WHERE id = ?
+RETURNING *;
+
+-- name: UpdateUserChirpyRed :exec
+UPDATE users SET is_chirpy_red = ?
+WHERE id = ?;
@@ -0,0 +1,77 @@
+-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions (id, created_at, updated_at, user_id, url, event_types, secret, active)
+VALUES (?, ?, ?, ?, ?, ?, ?, true)
+RETURNING *;
+
+-- name: GetWebhookSubscription :one
+SELECT * FROM webhook_subscriptions
+WHERE id = ? AND user_id = ?;
+
+-- name: GetWebhookSubscriptionsByUser :many
+SELECT * FROM webhook_subscriptions
+WHERE user_id = ?
+ORDER BY created_at ASC;
+
+-- name: DeleteWebhookSubscription :execrows
+DELETE FROM webhook_subscriptions
+WHERE id = ? AND user_id = ?;
+
+-- name: GetWebhookSubscriptionIDsForEvent :many
+SELECT s.id FROM webhook_subscriptions s
+WHERE s.active
+  AND s.user_id = sqlc.arg(user_id)
+  AND EXISTS (SELECT 1 FROM json_each(s.event_types) WHERE json_each.value = sqlc.arg(event_type));
+
+-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries (id, created_at, updated_at, subscription_id, event_type, payload, status, attempts, next_attempt_at)
+VALUES (?1, ?2, ?2, ?3, ?4, ?5, 'pending', 0, ?2);
+
+-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET next_attempt_at = sqlc.arg(lease_until),
+    updated_at = sqlc.arg(now)
+WHERE id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= sqlc.arg(now)
+    ORDER BY next_attempt_at ASC
+    LIMIT sqlc.arg(max_results)
+)
+RETURNING id, event_type, payload, attempts,
+    (SELECT s.url FROM webhook_subscriptions s WHERE s.id = subscription_id) AS url,
+    (SELECT s.secret FROM webhook_subscriptions s WHERE s.id = subscription_id) AS secret;
+
+-- name: MarkWebhookDelivered :exec
+UPDATE webhook_deliveries
+SET status = 'delivered',
+    attempts = attempts + 1,
+    last_attempt_at = sqlc.arg(now),
+    last_status_code = sqlc.arg(last_status_code),
+    last_error = NULL,
+    delivered_at = sqlc.arg(now),
+    updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: MarkWebhookDeliveryFailed :exec
+UPDATE webhook_deliveries
+SET status = sqlc.arg(status),
+    attempts = attempts + 1,
+    last_attempt_at = sqlc.arg(now),
+    last_status_code = sqlc.arg(last_status_code),
+    last_error = sqlc.arg(last_error),
+    next_attempt_at = sqlc.arg(next_attempt_at),
+    updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: GetWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE subscription_id = ?
+ORDER BY created_at DESC
+LIMIT ?;
+
+-- name: RedeliverWebhook :execrows
+UPDATE webhook_deliveries
+SET status = 'pending',
+    attempts = 0,
+    next_attempt_at = sqlc.arg(now),
+    updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id) AND subscription_id = sqlc.arg(subscription_id);
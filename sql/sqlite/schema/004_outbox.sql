@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE outbox_events (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    type TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_error TEXT NULL,
+    dispatched_at TIMESTAMP NULL
+);
+
+-- +goose Down
+DROP TABLE outbox_events;
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE subscriptions (
+    user_id TEXT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('active', 'past_due', 'cancelled', 'expired')),
+    current_period_end TIMESTAMP NOT NULL,
+    grace_period_end TIMESTAMP NULL
+);
+
+-- +goose Down
+DROP TABLE subscriptions;
@@ -0,0 +1,32 @@
+-- +goose Up
+-- Written by triggers on chirps, as on Postgres. SQLite has a single
+-- writer, so ids are assigned in commit order and streams poll by id.
+CREATE TABLE chirp_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at TIMESTAMP NOT NULL,
+    type TEXT NOT NULL,
+    chirp_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    body TEXT NULL
+);
+
+-- +goose StatementBegin
+CREATE TRIGGER chirps_record_created AFTER INSERT ON chirps
+BEGIN
+    INSERT INTO chirp_events (created_at, type, chirp_id, user_id, body)
+    VALUES (strftime('%Y-%m-%d %H:%M:%f', 'now'), 'chirp.created', NEW.id, NEW.user_id, NEW.body);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER chirps_record_deleted AFTER DELETE ON chirps
+BEGIN
+    INSERT INTO chirp_events (created_at, type, chirp_id, user_id, body)
+    VALUES (strftime('%Y-%m-%d %H:%M:%f', 'now'), 'chirp.deleted', OLD.id, OLD.user_id, NULL);
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TRIGGER chirps_record_deleted;
+DROP TRIGGER chirps_record_created;
+DROP TABLE chirp_events;
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	polkaEventUpgraded      = "user.upgraded"
+	polkaEventRenewed       = "user.renewed"
+	polkaEventDowngraded    = "user.downgraded"
+	polkaEventCancelled     = "user.cancelled"
+	polkaEventPaymentFailed = "user.payment_failed"
+
+	subscriptionStatusActive    = "active"
+	subscriptionStatusPastDue   = "past_due"
+	subscriptionStatusCancelled = "cancelled"
+	subscriptionStatusExpired   = "expired"
+
+	// defaultSubscriptionPeriod is used when Polka doesn't send a period end
+	defaultSubscriptionPeriod = 30 * 24 * time.Hour
+	// paymentGracePeriod is how long Chirpy Red survives a failed payment
+	paymentGracePeriod = 7 * 24 * time.Hour
+)
+
+func isPolkaSubscriptionEvent(event string) bool {
+	switch event {
+	case polkaEventUpgraded, polkaEventRenewed, polkaEventDowngraded, polkaEventCancelled, polkaEventPaymentFailed:
+		return true
+	}
+	return false
+}
+
+// applyPolkaEvent moves a user's subscription through its lifecycle.
+// Cancellations and failed payments keep Chirpy Red until the period (or
+// grace period) runs out; expireSubscriptions takes it away after that.
+func applyPolkaEvent(ctx context.Context, q database.Store, event string, userID uuid.UUID, periodEnd *time.Time) error {
+	now := time.Now().UTC()
+	end := now.Add(defaultSubscriptionPeriod)
+	if periodEnd != nil {
+		end = periodEnd.UTC()
+	}
+
+	switch event {
+	case polkaEventUpgraded, polkaEventRenewed:
+		_, err := q.UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+			UserID:           userID,
+			Status:           subscriptionStatusActive,
+			CurrentPeriodEnd: end,
+		})
+		if err != nil {
+			return err
+		}
+		return q.UpdateUserChirpyRed(ctx, database.UpdateUserChirpyRedParams{
+			ID:          userID,
+			IsChirpyRed: true,
+		})
+
+	case polkaEventDowngraded:
+		_, err := q.UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+			UserID:           userID,
+			Status:           subscriptionStatusExpired,
+			CurrentPeriodEnd: now,
+		})
+		if err != nil {
+			return err
+		}
+		return q.UpdateUserChirpyRed(ctx, database.UpdateUserChirpyRedParams{
+			ID:          userID,
+			IsChirpyRed: false,
+		})
+
+	case polkaEventCancelled:
+		updated, err := q.CancelSubscription(ctx, userID)
+		if err != nil || updated > 0 {
+			return err
+		}
+		legacy, err := hasLegacyChirpyRed(ctx, q, userID)
+		if err != nil || !legacy {
+			return err
+		}
+		_, err = q.UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+			UserID:           userID,
+			Status:           subscriptionStatusCancelled,
+			CurrentPeriodEnd: end,
+		})
+		return err
+
+	case polkaEventPaymentFailed:
+		graceEnd := sql.NullTime{Time: now.Add(paymentGracePeriod), Valid: true}
+		updated, err := q.MarkSubscriptionPastDue(ctx, database.MarkSubscriptionPastDueParams{
+			UserID:         userID,
+			GracePeriodEnd: graceEnd,
+		})
+		if err != nil || updated > 0 {
+			return err
+		}
+		legacy, err := hasLegacyChirpyRed(ctx, q, userID)
+		if err != nil || !legacy {
+			return err
+		}
+		_, err = q.UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+			UserID:           userID,
+			Status:           subscriptionStatusPastDue,
+			CurrentPeriodEnd: now,
+			GracePeriodEnd:   graceEnd,
+		})
+		return err
+	}
+	return nil
+}
+
+// hasLegacyChirpyRed is called when a cancellation or failed payment
+// matched no live subscription. Users upgraded before subscriptions were
+// tracked have no row yet but still have Chirpy Red; anyone else's
+// subscription has already expired, and stays that way.
+func hasLegacyChirpyRed(ctx context.Context, q database.Store, userID uuid.UUID) (bool, error) {
+	user, err := q.GetUserByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return user.IsChirpyRed, nil
+}
+
+// runSubscriptionExpiry expires lapsed subscriptions every interval until
+// ctx is cancelled
+func (cfg *apiConfig) runSubscriptionExpiry(ctx context.Context, interval time.Duration, report func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := cfg.expireSubscriptions(ctx)
+		if err != nil {
+			slog.Error("Couldn't expire subscriptions", "error", err)
+		}
+		report(err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *apiConfig) expireSubscriptions(ctx context.Context) error {
+	return cfg.store.InTx(ctx, func(q database.Store) error {
+		userIDs, err := q.ExpireLapsedSubscriptions(ctx)
+		if err != nil {
+			return err
+		}
+		for _, userID := range userIDs {
+			err = q.UpdateUserChirpyRed(ctx, database.UpdateUserChirpyRedParams{
+				ID:          userID,
+				IsChirpyRed: false,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if len(userIDs) > 0 {
+			slog.Info("Expired Chirpy Red", "users", len(userIDs))
+		}
+		return nil
+	})
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestSubscriptionLifecycle(t *testing.T) {
+	// Period ends relative to now; zero leaves it to the default
+	const (
+		future = 24 * time.Hour
+		past   = -time.Hour
+	)
+	type polkaStep struct {
+		event     string
+		periodEnd time.Duration
+	}
+
+	tests := []struct {
+		name string
+		// legacyRed gives the user Chirpy Red without a subscription row, as
+		// upgrades did before subscriptions were tracked
+		legacyRed bool
+		steps     []polkaStep
+		// lapseGrace ends the payment grace period before the sweep
+		lapseGrace      bool
+		wantStatus      string
+		wantRed         bool
+		wantSweptStatus string
+		wantSweptRed    bool
+	}{
+		{
+			name:            "Upgrade",
+			steps:           []polkaStep{{polkaEventUpgraded, future}},
+			wantStatus:      subscriptionStatusActive,
+			wantRed:         true,
+			wantSweptStatus: subscriptionStatusActive,
+			wantSweptRed:    true,
+		},
+		{
+			name:            "Upgrade with the default period",
+			steps:           []polkaStep{{polkaEventUpgraded, 0}},
+			wantStatus:      subscriptionStatusActive,
+			wantRed:         true,
+			wantSweptStatus: subscriptionStatusActive,
+			wantSweptRed:    true,
+		},
+		{
+			name:            "Period runs out",
+			steps:           []polkaStep{{polkaEventUpgraded, past}},
+			wantStatus:      subscriptionStatusActive,
+			wantRed:         true,
+			wantSweptStatus: subscriptionStatusExpired,
+			wantSweptRed:    false,
+		},
+		{
+			name:            "Renew",
+			steps:           []polkaStep{{polkaEventUpgraded, past}, {polkaEventRenewed, future}},
+			wantStatus:      subscriptionStatusActive,
+			wantRed:         true,
+			wantSweptStatus: subscriptionStatusActive,
+			wantSweptRed:    true,
+		},
+		{
+			name:            "Downgrade",
+			steps:           []polkaStep{{polkaEventUpgraded, future}, {polkaEventDowngraded, 0}},
+			wantStatus:      subscriptionStatusExpired,
+			wantRed:         false,
+			wantSweptStatus: subscriptionStatusExpired,
+			wantSweptRed:    false,
+		},
+		{
+			name:            "Cancel keeps Red until the period ends",
+			steps:           []polkaStep{{polkaEventUpgraded, future}, {polkaEventCancelled, 0}},
+			wantStatus:      subscriptionStatusCancelled,
+			wantRed:         true,
+			wantSweptStatus: subscriptionStatusCancelled,
+			wantSweptRed:    true,
+		},
+		{
+			name:            "Cancelled period runs out",
+			steps:           []polkaStep{{polkaEventUpgraded, past}, {polkaEventCancelled, 0}},
+			wantStatus:      subscriptionStatusCancelled,
+			wantRed:         true,
+			wantSweptStatus: subscriptionStatusExpired,
+			wantSweptRed:    false,
+		},
+		{
+			name:            "Cancel from before subscriptions were tracked",
+			legacyRed:       true,
+			steps:           []polkaStep{{polkaEventCancelled, past}},
+			wantStatus:      subscriptionStatusCancelled,
+			wantRed:         true,
+			wantSweptStatus: subscriptionStatusExpired,
+			wantSweptRed:    false,
+		},
+		{
+			name:            "Payment failed from before subscriptions were tracked",
+			legacyRed:       true,
+			steps:           []polkaStep{{polkaEventPaymentFailed, 0}},
+			wantStatus:      subscriptionStatusPastDue,
+			wantRed:         true,
+			wantSweptStatus: subscriptionStatusPastDue,
+			wantSweptRed:    true,
+		},
+		{
+			name:            "Cancel after a downgrade",
+			steps:           []polkaStep{{polkaEventUpgraded, future}, {polkaEventDowngraded, 0}, {polkaEventCancelled, 0}},
+			wantStatus:      subscriptionStatusExpired,
+			wantRed:         false,
+			wantSweptStatus: subscriptionStatusExpired,
+			wantSweptRed:    false,
+		},
+		{
+			name:            "Payment failed after a downgrade",
+			steps:           []polkaStep{{polkaEventUpgraded, future}, {polkaEventDowngraded, 0}, {polkaEventPaymentFailed, 0}},
+			wantStatus:      subscriptionStatusExpired,
+			wantRed:         false,
+			wantSweptStatus: subscriptionStatusExpired,
+			wantSweptRed:    false,
+		},
+		{
+			name:            "Payment failed keeps Red past the period",
+			steps:           []polkaStep{{polkaEventUpgraded, past}, {polkaEventPaymentFailed, 0}},
+			wantStatus:      subscriptionStatusPastDue,
+			wantRed:         true,
+			wantSweptStatus: subscriptionStatusPastDue,
+			wantSweptRed:    true,
+		},
+		{
+			name:            "Grace period runs out",
+			steps:           []polkaStep{{polkaEventUpgraded, future}, {polkaEventPaymentFailed, 0}},
+			lapseGrace:      true,
+			wantStatus:      subscriptionStatusPastDue,
+			wantRed:         true,
+			wantSweptStatus: subscriptionStatusExpired,
+			wantSweptRed:    false,
+		},
+		{
+			name:            "Renew after a failed payment",
+			steps:           []polkaStep{{polkaEventUpgraded, past}, {polkaEventPaymentFailed, 0}, {polkaEventRenewed, future}},
+			wantStatus:      subscriptionStatusActive,
+			wantRed:         true,
+			wantSweptStatus: subscriptionStatusActive,
+			wantSweptRed:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI(t)
+			user := api.signUp("lydia@madrigal.com", "stevia")
+			if tt.legacyRed {
+				err := api.store.UpdateUserChirpyRed(t.Context(), database.UpdateUserChirpyRedParams{ID: user.ID, IsChirpyRed: true})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, step := range tt.steps {
+				var periodEnd *time.Time
+				if step.periodEnd != 0 {
+					end := time.Now().Add(step.periodEnd)
+					periodEnd = &end
+				}
+				if err := applyPolkaEvent(t.Context(), api.store, step.event, user.ID, periodEnd); err != nil {
+					t.Fatalf("applyPolkaEvent(%s): %v", step.event, err)
+				}
+			}
+			if tt.lapseGrace {
+				_, err := api.store.MarkSubscriptionPastDue(t.Context(), database.MarkSubscriptionPastDueParams{
+					UserID:         user.ID,
+					GracePeriodEnd: sql.NullTime{Time: time.Now().Add(past), Valid: true},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			api.expectSubscription(user.ID, tt.wantStatus, tt.wantRed)
+
+			if err := api.cfg.expireSubscriptions(t.Context()); err != nil {
+				t.Fatalf("expireSubscriptions: %v", err)
+			}
+			api.expectSubscription(user.ID, tt.wantSweptStatus, tt.wantSweptRed)
+		})
+	}
+}
+
+func (api *testAPI) expectSubscription(userID uuid.UUID, wantStatus string, wantRed bool) {
+	api.t.Helper()
+	subscription, ok := api.store.Subscription(userID)
+	if !ok || subscription.Status != wantStatus {
+		api.t.Errorf("subscription = %+v, want status %s", subscription, wantStatus)
+	}
+	user, err := api.store.GetUserByID(api.t.Context(), userID)
+	if err != nil {
+		api.t.Fatal(err)
+	}
+	if user.IsChirpyRed != wantRed {
+		api.t.Errorf("IsChirpyRed = %v, want %v", user.IsChirpyRed, wantRed)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	traceExporterNone   = "none"
+	traceExporterStdout = "stdout"
+	traceExporterOTLP   = "otlp"
+)
+
+// tracer uses the global TracerProvider, so it's a no-op until
+// setupTracing installs one
+func tracer() trace.Tracer {
+	return otel.Tracer("github.com/exglegaming/Chirpy")
+}
+
+// setupTracing installs the exporter cfg asks for and W3C trace context
+// propagation. The returned func flushes any spans still buffered.
+func setupTracing(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.TraceExporter {
+	case traceExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case traceExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case traceExporterOTLP:
+		// With no endpoint the exporter falls back to the standard
+		// OTEL_EXPORTER_OTLP_* variables, then localhost:4318
+		var opts []otlptracehttp.Option
+		if cfg.OTLPEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.OTLPEndpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.TraceExporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create %s trace exporter: %w", cfg.TraceExporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", "chirpy"),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.TraceSampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// middlewareTrace starts a server span per request, continuing the
+// caller's trace if it sent a traceparent header
+func (cfg *apiConfig) middlewareTrace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer().Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		rec, w := recorderFor(w)
+		next.ServeHTTP(w, r.WithContext(ctx))
+
+		if rec.route != "" {
+			span.SetName(rec.route)
+			span.SetAttributes(attribute.String("http.route", rec.route))
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", rec.statusCode()))
+		if rec.statusCode() >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, strconv.Itoa(rec.statusCode()))
+		}
+		if rec.err != nil {
+			span.RecordError(rec.err)
+		}
+	})
+}
+
+// observeQuery times each query for metrics and traces it as a child of
+// the request's span
+func (cfg *apiConfig) observeQuery(ctx context.Context, name string) func(error) {
+	start := time.Now()
+	_, span := tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.operation.name", name)),
+	)
+	return func(err error) {
+		cfg.metrics.observeQuery(name, time.Since(start))
+		if err != nil && !errors.Is(err, context.Canceled) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans installs a TracerProvider that keeps every span in memory
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return recorder
+}
+
+func TestTraceRequest(t *testing.T) {
+	api := newTestAPI(t)
+	spans := recordSpans(t)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest("GET", "/api/chirps/not-a-uuid", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	api.handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	ended := spans.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("got %d spans, want 1", len(ended))
+	}
+	span := ended[0]
+	if got := span.SpanContext().TraceID().String(); got != traceID {
+		t.Errorf("trace ID = %s, want the caller's %s", got, traceID)
+	}
+	if got, want := span.Name(), "GET /api/chirps/{chirpID}"; got != want {
+		t.Errorf("span name = %q, want %q", got, want)
+	}
+	if span.SpanKind() != trace.SpanKindServer {
+		t.Errorf("span kind = %s, want server", span.SpanKind())
+	}
+	for _, attr := range span.Attributes() {
+		if attr.Key == "http.response.status_code" && attr.Value.AsInt64() != http.StatusBadRequest {
+			t.Errorf("status attribute = %d, want 400", attr.Value.AsInt64())
+		}
+	}
+}
+
+func TestTraceQuery(t *testing.T) {
+	api := newTestAPI(t)
+	spans := recordSpans(t)
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+	api.cfg.observeQuery(ctx, "GetChirp")(nil)
+	parent.End()
+
+	ended := spans.Ended()
+	if len(ended) != 2 {
+		t.Fatalf("got %d spans, want 2", len(ended))
+	}
+	query := ended[0]
+	if query.Name() != "GetChirp" {
+		t.Errorf("span name = %q, want GetChirp", query.Name())
+	}
+	if query.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("query span isn't a child of the request span")
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/auth"
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	webhookStatusPending   = "pending"
+	webhookStatusDelivered = "delivered"
+	webhookStatusDead      = "dead"
+
+	// webhookMaxAttempts is how many times a delivery is tried before it's
+	// dead-lettered; it can still be redelivered by hand after that
+	webhookMaxAttempts = 8
+	webhookBaseBackoff = 30 * time.Second
+	webhookMaxBackoff  = 6 * time.Hour
+	webhookBatchSize   = 20
+	webhookTimeout     = 10 * time.Second
+)
+
+// webhookEventTypes are the outbox events subscribers can register for
+var webhookEventTypes = []string{
+	eventChirpCreated,
+	eventChirpDeleted,
+	eventUserCreated,
+	eventUserUpdated,
+	eventUserUpgraded,
+}
+
+// webhookEvent is the body POSTed to subscribers
+type webhookEvent struct {
+	ID        uuid.UUID       `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// enqueueWebhooks is the outbox subscriber that queues a delivery of each
+// event for every active subscription that wants it. Subscriptions only hear
+// about their own user's account and chirps. The outbox event ID is reused
+// as the webhook event ID so receivers can drop duplicates.
+func (cfg *apiConfig) enqueueWebhooks(ctx context.Context, event database.OutboxEvent) error {
+	if !slices.Contains(webhookEventTypes, event.Type) {
+		return nil
+	}
+
+	owner, data, err := webhookEventData(event)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(webhookEvent{
+		ID:        event.ID,
+		Type:      event.Type,
+		CreatedAt: event.CreatedAt,
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = cfg.store.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+		EventType: event.Type,
+		Payload:   payload,
+		UserID:    owner,
+	})
+	return err
+}
+
+// webhookEventData returns the user an event is about and the data sent to
+// their subscriptions. Chirps go out as they're shown publicly; user events
+// carry just the user's ID, so an email never leaves in a payload.
+func webhookEventData(event database.OutboxEvent) (uuid.UUID, json.RawMessage, error) {
+	var ids struct {
+		ID     uuid.UUID `json:"id"`
+		UserID uuid.UUID `json:"user_id"`
+	}
+	if err := json.Unmarshal(event.Payload, &ids); err != nil {
+		return uuid.Nil, nil, fmt.Errorf("couldn't decode %s event: %w", event.Type, err)
+	}
+
+	switch event.Type {
+	case eventChirpCreated, eventChirpDeleted:
+		return ids.UserID, event.Payload, nil
+	case eventUserCreated, eventUserUpdated:
+		ids.UserID = ids.ID
+	}
+	data, err := json.Marshal(map[string]uuid.UUID{"user_id": ids.UserID})
+	return ids.UserID, data, err
+}
+
+// runWebhookDeliveries delivers due webhooks every interval until ctx is
+// cancelled. Deliveries are claimed with SKIP LOCKED, so any number of
+// instances can run this at once.
+func (cfg *apiConfig) runWebhookDeliveries(ctx context.Context, interval time.Duration, report func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			delivered, err := cfg.deliverDueWebhooks(ctx, report)
+			if err != nil {
+				slog.Error("Couldn't deliver webhooks", "error", err)
+			}
+			report(err)
+			// Keep going while there's a backlog
+			if err != nil || delivered < webhookBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverDueWebhooks tries a batch of due deliveries, reporting progress
+// after each one, since a batch of slow subscribers can take longer than
+// readiness waits to hear from the worker
+func (cfg *apiConfig) deliverDueWebhooks(ctx context.Context, report func(error)) (int, error) {
+	deliveries, err := cfg.store.ClaimWebhookDeliveries(ctx, webhookBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, delivery := range deliveries {
+		// A subscriber failing is its problem, not the worker's
+		report(nil)
+		statusCode, err := cfg.sendWebhook(ctx, delivery)
+		code := sql.NullInt32{Int32: int32(statusCode), Valid: statusCode != 0}
+		if err == nil {
+			err = cfg.store.MarkWebhookDelivered(ctx, database.MarkWebhookDeliveredParams{
+				ID:             delivery.ID,
+				LastStatusCode: code,
+			})
+			if err != nil {
+				slog.Error("Couldn't mark webhook delivered", "delivery_id", delivery.ID, "error", err)
+			}
+			continue
+		}
+
+		status := webhookStatusPending
+		if delivery.Attempts+1 >= webhookMaxAttempts {
+			status = webhookStatusDead
+		}
+		err = cfg.store.MarkWebhookDeliveryFailed(ctx, database.MarkWebhookDeliveryFailedParams{
+			ID:             delivery.ID,
+			Status:         status,
+			LastStatusCode: code,
+			LastError:      sql.NullString{String: err.Error(), Valid: true},
+			NextAttemptAt:  time.Now().UTC().Add(webhookBackoff(int(delivery.Attempts) + 1)),
+		})
+		if err != nil {
+			slog.Error("Couldn't record failed webhook", "delivery_id", delivery.ID, "error", err)
+		}
+	}
+	return len(deliveries), nil
+}
+
+// sendWebhook POSTs a delivery and returns the response status, treating
+// anything but a 2xx as a failure
+func (cfg *apiConfig) sendWebhook(ctx context.Context, delivery database.ClaimWebhookDeliveriesRow) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set("X-Chirpy-Event", delivery.EventType)
+	req.Header.Set("X-Chirpy-Delivery", delivery.ID.String())
+	req.Header.Set("X-Chirpy-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Chirpy-Signature", auth.SignWebhook(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := cfg.webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("subscriber responded with %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// webhookBackoff is the wait before retrying a delivery that has been
+// attempted attempts times
+func webhookBackoff(attempts int) time.Duration {
+	return retryBackoff(attempts, webhookBaseBackoff, webhookMaxBackoff)
+}
+
+// webhookBlockedPrefixes are the ranges webhooks can't be delivered to
+// outside dev: our own hosts and networks, and anything else that isn't
+// publicly routable
+var webhookBlockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this" network
+	netip.MustParsePrefix("10.0.0.0/8"),      // private
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("127.0.0.0/8"),     // loopback
+	netip.MustParsePrefix("169.254.0.0/16"),  // link-local, including cloud metadata
+	netip.MustParsePrefix("172.16.0.0/12"),   // private
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("192.168.0.0/16"),  // private
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("224.0.0.0/4"),     // multicast
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved and broadcast
+	netip.MustParsePrefix("::/128"),          // unspecified
+	netip.MustParsePrefix("::1/128"),         // loopback
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, which reaches IPv4
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local NAT64
+	netip.MustParsePrefix("100::/64"),        // discard
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4, which reaches IPv4
+	netip.MustParsePrefix("fc00::/7"),        // unique local
+	netip.MustParsePrefix("fe80::/10"),       // link-local
+	netip.MustParsePrefix("ff00::/8"),        // multicast
+}
+
+// webhookDialControl refuses connections to webhookBlockedPrefixes. It runs
+// after DNS resolution, so a hostname can't be pointed at them either.
+func webhookDialControl(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	// IPv4-mapped IPv6 addresses reach the IPv4 address, and zones don't
+	// change which network an address is on
+	addr = addr.Unmap().WithZone("")
+	for _, prefix := range webhookBlockedPrefixes {
+		if prefix.Contains(addr) {
+			return errors.New("webhook address is not publicly routable")
+		}
+	}
+	return nil
+}
+
+// newWebhookClient returns the client used for deliveries. Outside dev it
+// refuses to connect to addresses that aren't publicly routable so a
+// subscription can't be pointed at our own network.
+func newWebhookClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if !allowPrivate {
+		dialer.Control = webhookDialControl
+	}
+
+	return &http.Client{
+		Timeout: webhookTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
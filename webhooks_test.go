@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/exglegaming/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestWebhookDispatch(t *testing.T) {
+	api := newTestAPI(t)
+	api.cfg.outbox.subscribe("webhooks", api.cfg.enqueueWebhooks)
+	login := api.signUp("walter@graymatter.com", "heisenberg")
+
+	rec := api.do("POST", "/api/webhooks", login.Token, map[string]interface{}{
+		"url":         "https://example.com/hooks",
+		"event_types": []string{eventChirpCreated},
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	subscription := decode[WebhookSubscription](t, rec)
+	if subscription.Secret == "" {
+		t.Error("the secret wasn't returned on creation")
+	}
+
+	api.postChirp(login.Token, "Say my name")
+	if _, err := api.cfg.outbox.dispatch(context.Background()); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+
+	rec = api.do("GET", "/api/webhooks/"+subscription.ID.String()+"/deliveries", login.Token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	deliveries := decode[[]WebhookDelivery](t, rec)
+	if len(deliveries) != 1 || deliveries[0].EventType != eventChirpCreated || deliveries[0].Status != webhookStatusPending {
+		t.Errorf("deliveries = %+v, want one pending %s", deliveries, eventChirpCreated)
+	}
+}
+
+func TestWebhookDispatchOnlyToOwner(t *testing.T) {
+	api := newTestAPI(t)
+	api.cfg.outbox.subscribe("webhooks", api.cfg.enqueueWebhooks)
+	walt := api.signUp("walter@graymatter.com", "heisenberg")
+	jesse := api.signUp("jesse@pinkman.com", "yo")
+	if _, err := api.cfg.outbox.dispatch(context.Background()); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+
+	subscriptions := map[string]WebhookSubscription{}
+	for _, login := range []loginResponse{walt, jesse} {
+		rec := api.do("POST", "/api/webhooks", login.Token, map[string]interface{}{
+			"url":         "https://example.com/hooks",
+			"event_types": webhookEventTypes,
+		})
+		expectStatus(t, rec, http.StatusCreated)
+		subscriptions[login.Email] = decode[WebhookSubscription](t, rec)
+	}
+
+	api.postChirp(walt.Token, "Say my name")
+	rec := api.do("PUT", "/api/users", walt.Token, map[string]string{
+		"email":    "heisenberg@graymatter.com",
+		"password": "heisenberg",
+	})
+	expectStatus(t, rec, http.StatusOK)
+	if _, err := api.cfg.outbox.dispatch(context.Background()); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+
+	deliveries := func(login loginResponse) []WebhookDelivery {
+		rec := api.do("GET", "/api/webhooks/"+subscriptions[login.Email].ID.String()+"/deliveries", login.Token, nil)
+		expectStatus(t, rec, http.StatusOK)
+		return decode[[]WebhookDelivery](t, rec)
+	}
+	if got := deliveries(jesse); len(got) != 0 {
+		t.Errorf("jesse got deliveries about walt: %+v", got)
+	}
+	got := deliveries(walt)
+	if len(got) != 2 {
+		t.Fatalf("walt got %d deliveries, want his chirp and his update", len(got))
+	}
+	for _, delivery := range got {
+		if strings.Contains(string(delivery.Payload), "graymatter.com") {
+			t.Errorf("%s payload includes an email: %s", delivery.EventType, delivery.Payload)
+		}
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, webhookBaseBackoff},
+		{2, 2 * webhookBaseBackoff},
+		{4, 8 * webhookBaseBackoff},
+		{webhookMaxAttempts, webhookBaseBackoff << (webhookMaxAttempts - 1)},
+		{12, webhookMaxBackoff},
+		// Large enough to overflow the shift
+		{80, webhookMaxBackoff},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.attempts), func(t *testing.T) {
+			for range 20 {
+				got := webhookBackoff(tt.attempts)
+				if got < tt.want || got >= tt.want+tt.want/5 {
+					t.Fatalf("webhookBackoff(%d) = %v, want %v plus up to 20%% jitter", tt.attempts, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookDialControl(t *testing.T) {
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{"93.184.215.14:443", true},
+		{"[2606:4700::1111]:443", true},
+		{"127.0.0.1:80", false},
+		{"10.1.2.3:80", false},
+		{"172.16.0.1:80", false},
+		{"192.168.1.1:80", false},
+		{"100.64.0.1:80", false},
+		{"0.0.0.0:80", false},
+		{"0.1.2.3:80", false},
+		{"169.254.169.254:80", false},
+		{"224.0.0.1:80", false},
+		{"255.255.255.255:80", false},
+		{"[::]:80", false},
+		{"[::1]:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+		{"[::ffff:169.254.169.254]:80", false},
+		{"[64:ff9b::a00:1]:80", false},
+		{"[fd00::1]:80", false},
+		{"[fe80::1%eth0]:80", false},
+		{"[ff02::1]:80", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			err := webhookDialControl("tcp", tt.address, nil)
+			if (err == nil) != tt.allowed {
+				t.Errorf("webhookDialControl(%q) = %v, want allowed %t", tt.address, err, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestWebhookSubscriptionsManage(t *testing.T) {
+	api := newTestAPI(t)
+	api.cfg.outbox.subscribe("webhooks", api.cfg.enqueueWebhooks)
+	walt := api.signUp("walter@graymatter.com", "heisenberg")
+	jesse := api.signUp("jesse@pinkman.com", "yo")
+
+	rec := api.do("POST", "/api/webhooks", walt.Token, map[string]interface{}{
+		"url":         "https://example.com/hooks",
+		"event_types": []string{eventChirpCreated},
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	subscription := decode[WebhookSubscription](t, rec)
+	path := "/api/webhooks/" + subscription.ID.String()
+
+	api.postChirp(walt.Token, "Say my name")
+	if _, err := api.cfg.outbox.dispatch(t.Context()); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	rec = api.do("GET", path+"/deliveries", walt.Token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	deliveries := decode[[]WebhookDelivery](t, rec)
+	if len(deliveries) != 1 {
+		t.Fatalf("deliveries = %+v, want one", deliveries)
+	}
+	redeliverPath := path + "/deliveries/" + deliveries[0].ID.String() + "/redeliver"
+
+	rec = api.do("GET", "/api/webhooks", walt.Token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if list := decode[[]WebhookSubscription](t, rec); len(list) != 1 || list[0].ID != subscription.ID || list[0].Secret != "" {
+		t.Errorf("subscriptions = %+v, want the one created, without its secret", list)
+	}
+
+	// Someone else's subscription is as good as missing
+	rec = api.do("GET", "/api/webhooks", jesse.Token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if list := decode[[]WebhookSubscription](t, rec); len(list) != 0 {
+		t.Errorf("another user's subscriptions = %+v, want none", list)
+	}
+	expectStatus(t, api.do("GET", path+"/deliveries", jesse.Token, nil), http.StatusNotFound)
+	expectStatus(t, api.do("POST", redeliverPath, jesse.Token, nil), http.StatusNotFound)
+	expectStatus(t, api.do("DELETE", path, jesse.Token, nil), http.StatusNotFound)
+
+	expectStatus(t, api.do("POST", path+"/deliveries/"+uuid.NewString()+"/redeliver", walt.Token, nil), http.StatusNotFound)
+	expectStatus(t, api.do("POST", redeliverPath, walt.Token, nil), http.StatusAccepted)
+
+	expectStatus(t, api.do("DELETE", path, walt.Token, nil), http.StatusNoContent)
+	expectStatus(t, api.do("DELETE", path, walt.Token, nil), http.StatusNotFound)
+	rec = api.do("GET", "/api/webhooks", walt.Token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if list := decode[[]WebhookSubscription](t, rec); len(list) != 0 {
+		t.Errorf("subscriptions after deleting = %+v, want none", list)
+	}
+}
+
+func TestEnqueueWebhooks(t *testing.T) {
+	api := newTestAPI(t)
+	login := api.signUp("walter@graymatter.com", "heisenberg")
+	rec := api.do("POST", "/api/webhooks", login.Token, map[string]interface{}{
+		"url":         "https://example.com/hooks",
+		"event_types": []string{eventChirpCreated, eventUserUpdated},
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	subscription := decode[WebhookSubscription](t, rec)
+
+	chirpID := uuid.New()
+	tests := []struct {
+		name     string
+		event    database.OutboxEvent
+		wantData string
+	}{
+		{
+			name: "Subscribed chirp event",
+			event: database.OutboxEvent{
+				Type:    eventChirpCreated,
+				Payload: json.RawMessage(`{"id":"` + chirpID.String() + `","body":"Say my name","user_id":"` + login.ID.String() + `"}`),
+			},
+			wantData: `{"id":"` + chirpID.String() + `","body":"Say my name","user_id":"` + login.ID.String() + `"}`,
+		},
+		{
+			name: "Subscribed user event",
+			event: database.OutboxEvent{
+				Type:    eventUserUpdated,
+				Payload: json.RawMessage(`{"id":"` + login.ID.String() + `","email":"walter@graymatter.com"}`),
+			},
+			wantData: `{"user_id":"` + login.ID.String() + `"}`,
+		},
+		{
+			name: "Unsubscribed event",
+			event: database.OutboxEvent{
+				Type:    eventChirpDeleted,
+				Payload: json.RawMessage(`{"id":"` + chirpID.String() + `","user_id":"` + login.ID.String() + `"}`),
+			},
+		},
+		{
+			name: "Another user's event",
+			event: database.OutboxEvent{
+				Type:    eventUserUpdated,
+				Payload: json.RawMessage(`{"id":"` + uuid.NewString() + `","email":"jesse@pinkman.com"}`),
+			},
+		},
+		{
+			name: "Not a webhook event",
+			event: database.OutboxEvent{
+				Type:    eventUserLoggedIn,
+				Payload: json.RawMessage(`{"user_id":"` + login.ID.String() + `"}`),
+			},
+		},
+	}
+	deliveries := func() []database.WebhookDelivery {
+		deliveries, err := api.store.GetWebhookDeliveries(t.Context(), database.GetWebhookDeliveriesParams{
+			SubscriptionID: subscription.ID,
+			Limit:          10,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return deliveries
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(deliveries())
+			tt.event.ID = uuid.New()
+			if err := api.cfg.enqueueWebhooks(t.Context(), tt.event); err != nil {
+				t.Fatal(err)
+			}
+
+			after := deliveries()
+			if tt.wantData == "" {
+				if len(after) != before {
+					t.Errorf("enqueued %+v, want nothing", after[0])
+				}
+				return
+			}
+			if len(after) != before+1 {
+				t.Fatalf("%d deliveries enqueued, want 1", len(after)-before)
+			}
+			var sent webhookEvent
+			if err := json.Unmarshal(after[0].Payload, &sent); err != nil {
+				t.Fatal(err)
+			}
+			if sent.ID != tt.event.ID || sent.Type != tt.event.Type || string(sent.Data) != tt.wantData {
+				t.Errorf("sent %+v with data %s, want %s", sent, sent.Data, tt.wantData)
+			}
+		})
+	}
+}
+
+func TestDeliverDueWebhooks(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		prevAttempts int
+		wantStatus   string
+	}{
+		{"Delivered", http.StatusOK, 0, webhookStatusDelivered},
+		{"First failure", http.StatusInternalServerError, 0, webhookStatusPending},
+		{"Last retry", http.StatusInternalServerError, webhookMaxAttempts - 2, webhookStatusPending},
+		{"Dead-lettered", http.StatusInternalServerError, webhookMaxAttempts - 1, webhookStatusDead},
+		{"Delivered on the last attempt", http.StatusNoContent, webhookMaxAttempts - 1, webhookStatusDelivered},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subscriber := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer subscriber.Close()
+
+			api := newTestAPI(t)
+			api.cfg.webhookClient = newWebhookClient(true)
+			login := api.signUp("walter@graymatter.com", "heisenberg")
+			subscription, err := api.store.CreateWebhookSubscription(t.Context(), database.CreateWebhookSubscriptionParams{
+				UserID:     login.ID,
+				Url:        subscriber.URL,
+				EventTypes: []string{eventChirpCreated},
+				Secret:     "whsec",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = api.store.EnqueueWebhookDeliveries(t.Context(), database.EnqueueWebhookDeliveriesParams{
+				EventType: eventChirpCreated,
+				Payload:   json.RawMessage(`{}`),
+				UserID:    login.ID,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			delivery := func() database.WebhookDelivery {
+				deliveries, err := api.store.GetWebhookDeliveries(t.Context(), database.GetWebhookDeliveriesParams{
+					SubscriptionID: subscription.ID,
+					Limit:          1,
+				})
+				if err != nil || len(deliveries) != 1 {
+					t.Fatalf("GetWebhookDeliveries = %+v, %v", deliveries, err)
+				}
+				return deliveries[0]
+			}
+			// Earlier failures that are due for another try
+			for range tt.prevAttempts {
+				err := api.store.MarkWebhookDeliveryFailed(t.Context(), database.MarkWebhookDeliveryFailedParams{
+					ID:            delivery().ID,
+					Status:        webhookStatusPending,
+					NextAttemptAt: time.Now().Add(-time.Minute),
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			reports := 0
+			if _, err := api.cfg.deliverDueWebhooks(t.Context(), func(error) { reports++ }); err != nil {
+				t.Fatal(err)
+			}
+			if reports != 1 {
+				t.Errorf("reported progress %d times, want once per delivery", reports)
+			}
+			got := delivery()
+			if got.Status != tt.wantStatus || int(got.Attempts) != tt.prevAttempts+1 {
+				t.Errorf("delivery is %s after %d attempts, want %s after %d", got.Status, got.Attempts, tt.wantStatus, tt.prevAttempts+1)
+			}
+			if got.LastStatusCode.Int32 != int32(tt.status) {
+				t.Errorf("last status code = %d, want %d", got.LastStatusCode.Int32, tt.status)
+			}
+		})
+	}
+}